@@ -5,12 +5,16 @@ go 1.16
 require (
 	github.com/cucumber/godog v0.11.0
 	github.com/cucumber/messages-go/v10 v10.0.3
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/labstack/echo/v4 v4.3.0
 	github.com/labstack/gommon v0.3.0
+	github.com/mmcdole/gofeed v1.1.3
 	github.com/wepala/blog-aggregator-module v0.0.0-20210706211025-bb385df0a11c
 	github.com/wepala/go-testhelpers v0.0.0-20200715110105-55c57c235b75
 	github.com/wepala/weos v0.0.7-0.20210607144120-0006285c4ee3
 	github.com/wepala/weos-controller v0.0.0-20210625160511-6d256ddaef1a
+	golang.org/x/net v0.0.0-20210525063256-abc453219eb5
+	golang.org/x/text v0.3.6
 	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.21.10
 )
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SEARCH_MATCH_PENDING  = "pending"
+	SEARCH_MATCH_NOTIFIED = "notified"
+	SEARCH_MATCH_FAILED   = "failed"
+	//the event in the body of the webhooks
+	SEARCH_ALERT_EVENT = "saved_search.matched"
+	//the header with the signature of the webhook body, the HMAC-SHA256 of the body using the secret of the search
+	SEARCH_ALERT_SIGNATURE_HEADER = "X-Signature-256"
+	//the number of notifications that are sent each time the job runs
+	searchAlertBatchSize = 50
+	//the most of the response to a webhook that is read
+	maxWebhookResponseSize = 64 << 10
+)
+
+var ErrEmailDisabled = errors.New("SMTP_HOST must be set for emails to be sent")
+
+var searchAlertHTMLTemplate = htmltemplate.Must(htmltemplate.New("alert").Funcs(digestFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 0 auto;">
+{{with .Post}}<h1 style="font-size: 1.2em; margin-bottom: 0.2em;"><a href="{{.Link}}">{{.Title}}</a></h1>
+<div style="color: #666; font-size: 0.9em;">{{with .Blog}}{{.Title}} &middot; {{end}}{{date .PublishDate}}</div>
+{{with .Excerpt}}<p>{{.}}</p>{{end}}
+{{end}}<p style="color: #666; font-size: 0.8em;">You're getting this email because the post matched your saved search "{{.Name}}". Delete the search to stop these emails.</p>
+</body>
+</html>
+`))
+
+var searchAlertTextTemplate = texttemplate.Must(texttemplate.New("alert").Funcs(digestFuncs).Parse(`{{with .Post}}{{.Title}}
+{{with .Blog}}{{.Title}} - {{end}}{{date .PublishDate}}
+{{.Link}}
+{{with .Excerpt}}
+{{.}}
+{{end}}
+{{end}}You're getting this email because the post matched your saved search "{{.Name}}". Delete the search to stop these emails.
+`))
+
+//SearchAlert is the notification that a new post matched a saved search. It's the body of the webhooks
+type SearchAlert struct {
+	Event     string    `json:"event"`
+	SearchID  string    `json:"searchId"`
+	Name      string    `json:"name"`
+	Post      *Post     `json:"post"`
+	MatchedAt time.Time `json:"matchedAt"`
+}
+
+func (a *SearchAlert) Subject() string {
+	return fmt.Sprintf("New post matching \"%s\": %s", a.Name, a.Post.Title)
+}
+
+//Message render the html and plain text versions of the email
+func (a *SearchAlert) Message(to string) (*EmailMessage, error) {
+	var html bytes.Buffer
+	if err := searchAlertHTMLTemplate.Execute(&html, a); err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	if err := searchAlertTextTemplate.Execute(&text, a); err != nil {
+		return nil, err
+	}
+	return &EmailMessage{
+		To:      to,
+		Subject: a.Subject(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+//Matches check whether a new post matches the saved search. The text is the searchable text of the post (see searchText)
+func (s *SavedSearch) Matches(post *Post, text string) bool {
+	if len(s.Blogs) > 0 && !containsString(s.Blogs, post.BlogID) {
+		return false
+	}
+	if len(s.Authors) > 0 {
+		found := false
+		for _, id := range s.Authors {
+			if post.AuthorID != nil && *post.AuthorID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(s.Categories) > 0 {
+		found := false
+		for _, category := range s.Categories {
+			for _, postCategory := range post.Categories {
+				if category.ID == postCategory.ID {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	terms := s.terms
+	if terms == nil {
+		terms = searchTerms(s.Query)
+	}
+	for _, term := range terms {
+		if !containsTerm(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+//searchCache keeps the saved searches that new posts are matched against so that they aren't loaded for each post. It's
+//cleared when searches are added or removed and when their categories change
+type searchCache struct {
+	mutex    sync.Mutex
+	searches []*SavedSearch
+	loaded   bool
+}
+
+//get the saved searches, loading them when they're not cached. The lock is held while loading so that a search that is
+//added in the meantime isn't left out
+func (c *searchCache) get(db *gorm.DB) ([]*SavedSearch, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.loaded {
+		return c.searches, nil
+	}
+	var searches []*SavedSearch
+	err := db.Preload("Categories").Find(&searches).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, search := range searches {
+		search.terms = searchTerms(search.Query)
+	}
+	c.searches = searches
+	c.loaded = true
+	return searches, nil
+}
+
+func (c *searchCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.searches = nil
+	c.loaded = false
+}
+
+//searchText get the text of a post that the terms of saved searches are looked for in, lower case with single spaces
+func searchText(post *Post) string {
+	text := post.Title + " " + PlainText(post.Description) + " " + PlainText(post.Content)
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//WebhookSender posts the alerts of saved searches to their webhooks. The body is signed with the secret of the search
+type WebhookSender struct {
+	client *http.Client
+}
+
+//Send post the alert to the webhook. The status code of the response is returned
+func (s *WebhookSender) Send(ctx context.Context, webhook string, secret string, alert *SearchAlert) (int, error) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SEARCH_ALERT_SIGNATURE_HEADER, "sha256="+signWebhook(body, secret))
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxWebhookResponseSize))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, &StatusError{URL: webhook, StatusCode: response.StatusCode}
+	}
+	return response.StatusCode, nil
+}
+
+//signWebhook get the hex encoded HMAC-SHA256 of the body
+func signWebhook(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewWebhookSender(client *http.Client) *WebhookSender {
+	return &WebhookSender{
+		client: client,
+	}
+}
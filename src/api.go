@@ -3,7 +3,13 @@ package api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -16,15 +22,33 @@ import (
 
 type API struct {
 	weoscontroller.API
-	Application weos.Application
-	Log         weos.Log
-	DB          *sql.DB
-	Client      *http.Client
+	Application  weos.Application
+	Log          weos.Log
+	DB           *sql.DB
+	Client       *http.Client
+	//requests to servers that aren't being crawled (webmention endpoints and fediverse servers) don't go through the
+	//fetcher, they're only made to public addresses
+	PublicClient *http.Client
+	Trending     *TrendingConfig
+	Health       *HealthConfig
+	Webmentions  *WebmentionConfig
+	Federation   *FederationConfig
+	Digests      *DigestConfig
+	SearchAlerts *SearchAlertConfig
+	Stream       *StreamConfig
+	//emails are only sent (e.g. the digests) when there's a mailer
+	Mailer Mailer
+	//limits how often each blog can be refreshed by an admin
+	RefreshLimiter *RateLimiter
+	//limits how often a view of a post from the same address is counted
+	ViewLimiter *RateLimiter
+	Scheduler   *Scheduler
 	projection  *GORMProjection
+	enricher    *BlogEnricher
 }
 
 func (a *API) AddBlog(e echo.Context) error {
-	blogAddRequest := &blogaggregatormodule.AddBlogRequest{e.FormValue("url")}
+	blogAddRequest := &blogaggregatormodule.AddBlogRequest{Url: e.FormValue("url")}
 	err := a.Application.Dispatcher().Dispatch(e.Request().Context(), blogaggregatormodule.AddBlogCommand(blogAddRequest.Url))
 	if err != nil {
 		return weoscontroller.NewControllerError("Error creating blog", err, 0)
@@ -34,31 +58,153 @@ func (a *API) AddBlog(e echo.Context) error {
 
 //Get list of authors
 func (a *API) GetAuthors(e echo.Context) error {
+	var lastError error
 	page, _ := strconv.Atoi(e.QueryParam("page"))
 	limit, _ := strconv.Atoi(e.QueryParam("limit"))
 
 	filters := make(map[string]interface{})
 	sorts := make(map[string]string)
 
-	authors, _, err := a.projection.GetAuthors(page, limit, "", sorts, filters)
+	if blogId := e.QueryParam("blog_id"); blogId != "" {
+		filters["blog_id"] = blogId
+	}
+
+	if page == 0 {
+		page = 1
+	}
+
+	for _, projection := range a.Application.Projections() {
+		authors, count, err := projection.(Projection).GetAuthors(page, limit, e.QueryParam("q"), sorts, filters)
+		if err == nil {
+			return e.JSON(http.StatusOK, &AuthorList{
+				Page:  page,
+				Limit: limit,
+				Total: count,
+				Items: authors,
+			})
+		} else {
+			lastError = err
+		}
+	}
+	if lastError != nil {
+		return weoscontroller.NewControllerError("Error getting authors", lastError, 0)
+	}
+	return nil
+}
 
+//Get an author with the blogs they write for
+func (a *API) GetAuthor(e echo.Context) error {
+	var lastError error
+	authorID, err := strconv.ParseUint(e.Param("id"), 10, 64)
 	if err != nil {
-		return weoscontroller.NewControllerError("Error getting authors", err, 0)
+		return weoscontroller.NewControllerError("Invalid author id", err, http.StatusBadRequest)
+	}
+	for _, projection := range a.Application.Projections() {
+		author, err := projection.(Projection).GetAuthor(uint(authorID))
+		if err == nil {
+			if author == nil {
+				return weoscontroller.NewControllerError("Author not found", fmt.Errorf("author '%d' not found", authorID), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, author)
+		} else {
+			lastError = err
+		}
 	}
-	return e.JSON(http.StatusOK, authors)
+	return lastError
 }
 
 //Get list of posts.
 func (a *API) GetPosts(e echo.Context) error {
-	//initialize projection params
+	page, limit, sorts, filters, err := postQuery(e)
+	if err != nil {
+		return err
+	}
+	if e.QueryParam("sort") == "trending" {
+		sorts["trending_score"] = "desc"
+	}
+	return a.listPosts(e, page, limit, sorts, filters)
+}
+
+//Get the posts that are trending, ranked by a blend of views and recency
+func (a *API) GetTrendingPosts(e echo.Context) error {
+	page, limit, _, filters, err := postQuery(e)
+	if err != nil {
+		return err
+	}
+	filters["trending"] = true
+	return a.listPosts(e, page, limit, map[string]string{"trending_score": "desc"}, filters)
+}
+
+//Get the posts that are related to a post
+func (a *API) GetRelatedPosts(e echo.Context) error {
 	var lastError error
-	var page int
-	var limit int
+	id := e.Param("id")
+	limit, _ := strconv.Atoi(e.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 5
+	}
+	for _, projection := range a.Application.Projections() {
+		posts, err := projection.(Projection).GetRelatedPosts(id, limit)
+		if err == nil {
+			if posts == nil {
+				return weoscontroller.NewControllerError("Post not found", fmt.Errorf("post '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, &PostList{
+				Page:  1,
+				Limit: limit,
+				Total: int64(len(posts)),
+				Items: posts,
+			})
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Get the pages that mention a post through webmentions and pingbacks
+func (a *API) GetMentions(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	for _, projection := range a.Application.Projections() {
+		mentions, err := projection.(Projection).GetMentions(id)
+		if err == nil {
+			if mentions == nil {
+				return weoscontroller.NewControllerError("Post not found", fmt.Errorf("post '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, mentions)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Get the earlier versions of a post with what changed and when, the latest revision is first
+func (a *API) GetPostRevisions(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	for _, projection := range a.Application.Projections() {
+		revisions, err := projection.(Projection).GetPostRevisions(id)
+		if err == nil {
+			if revisions == nil {
+				return weoscontroller.NewControllerError("Post not found", fmt.Errorf("post '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, revisions)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//postQuery parse the pagination, sort and filter parameters used by the post lists
+func postQuery(e echo.Context) (int, int, map[string]string, map[string]interface{}, error) {
 	filters := make(map[string]interface{})
 	sorts := make(map[string]string)
 	//parse query parameters
-	page, _ = strconv.Atoi(e.QueryParam("page"))
-	limit, _ = strconv.Atoi(e.QueryParam("limit"))
+	page, _ := strconv.Atoi(e.QueryParam("page"))
+	limit, _ := strconv.Atoi(e.QueryParam("limit"))
 	//parse sort parameters
 	if viewsSort := e.QueryParam("views"); viewsSort != "" {
 		sorts["views"] = viewsSort
@@ -70,12 +216,25 @@ func (a *API) GetPosts(e echo.Context) error {
 	//parse query parameters
 	if blogId := e.QueryParam("blog_id"); blogId != "" {
 		filters["blog_id"] = blogId
+	} else if includeDead, _ := strconv.ParseBool(e.QueryParam("include_dead")); !includeDead {
+		filters["exclude_dead"] = true
 	}
 
 	if category := e.QueryParam("category"); category != "" {
 		filters["category"] = category
 	}
 
+	if authorId := e.QueryParam("author_id"); authorId != "" {
+		filters["author_id"] = authorId
+	}
+
+	if medium := e.QueryParam("has_media"); medium != "" {
+		if !ValidMedium(medium) {
+			return 0, 0, nil, nil, weoscontroller.NewControllerError("Invalid media type", fmt.Errorf("has_media must be audio, video or image, got '%s'", medium), http.StatusBadRequest)
+		}
+		filters["has_media"] = medium
+	}
+
 	startDate := e.QueryParam("start_date")
 	endDate := e.QueryParam("end_date")
 
@@ -87,7 +246,11 @@ func (a *API) GetPosts(e echo.Context) error {
 	if page == 0 {
 		page = 1
 	}
+	return page, limit, sorts, filters, nil
+}
 
+func (a *API) listPosts(e echo.Context, page int, limit int, sorts map[string]string, filters map[string]interface{}) error {
+	var lastError error
 	for _, projection := range a.Application.Projections() {
 		posts, count, err := projection.(Projection).GetPosts(page, limit, "", sorts, filters)
 		if err == nil {
@@ -139,45 +302,1350 @@ func (a *API) GetCategories(e echo.Context) error {
 	return lastError
 }
 
-// health check handler
-func (a *API) HealthCheck(e echo.Context) error {
-	return e.JSON(200, "GOOD")
+//Get category details by the category slug
+func (a *API) GetCategory(e echo.Context) error {
+	var lastError error
+	slug := e.Param("slug")
+	for _, projection := range a.Application.Projections() {
+		category, err := projection.(Projection).GetCategory(slug)
+		if err == nil {
+			if category == nil {
+				return weoscontroller.NewControllerError("Category not found", fmt.Errorf("category '%s' not found", slug), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, category)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
 }
 
-func (a *API) Initialize() error {
-	var err error
-	//initialize app
-	if a.Client == nil {
-		a.Client = &http.Client{
-			Timeout: time.Second * 10,
+//Get the synonyms that are mapped to a category
+func (a *API) GetCategorySynonyms(e echo.Context) error {
+	var lastError error
+	categoryID, err := strconv.ParseUint(e.Param("id"), 10, 64)
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid category id", err, http.StatusBadRequest)
+	}
+	for _, projection := range a.Application.Projections() {
+		synonyms, err := projection.(Projection).GetCategorySynonyms(uint(categoryID))
+		if err == nil {
+			return e.JSON(http.StatusOK, synonyms)
+		} else {
+			lastError = err
 		}
 	}
-	a.Application, err = weos.NewApplicationFromConfig(a.Config.ApplicationConfig, a.Log, a.DB, a.Client, nil)
+	return lastError
+}
+
+//Map a synonym to a category so that posts with that tag are added to the category
+func (a *API) AddCategorySynonym(e echo.Context) error {
+	categoryID, err := strconv.ParseUint(e.Param("id"), 10, 64)
 	if err != nil {
-		return err
+		return weoscontroller.NewControllerError("Invalid category id", err, http.StatusBadRequest)
 	}
-	//setup projections
-	a.projection, err = NewProjection(a.Application)
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), AddCategorySynonymCommand(uint(categoryID), e.FormValue("synonym")))
 	if err != nil {
-		return err
+		return weoscontroller.NewControllerError("Error adding synonym", err, 0)
+	}
+	return e.JSON(http.StatusCreated, "Synonym Added")
+}
+
+//Remove a synonym from a category
+func (a *API) RemoveCategorySynonym(e echo.Context) error {
+	categoryID, err := strconv.ParseUint(e.Param("id"), 10, 64)
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid category id", err, http.StatusBadRequest)
+	}
+	synonym, err := url.PathUnescape(e.Param("synonym"))
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid synonym", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), RemoveCategorySynonymCommand(uint(categoryID), synonym))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error removing synonym", err, 0)
 	}
-	//enable module
-	err = blogaggregatormodule.Initialize(a.Application)
+	return e.JSON(http.StatusOK, "Synonym Removed")
+}
+
+//Merge a category into another category
+func (a *API) MergeCategory(e echo.Context) error {
+	categoryID, err := strconv.ParseUint(e.Param("id"), 10, 64)
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid category id", err, http.StatusBadRequest)
+	}
+	intoID, err := strconv.ParseUint(e.FormValue("into"), 10, 64)
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid category to merge into", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), MergeCategoryCommand(uint(categoryID), uint(intoID)))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error merging category", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Category Merged")
+}
+
+//Get a blog with the details from its homepage
+func (a *API) GetBlog(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	for _, projection := range a.Application.Projections() {
+		blog, err := projection.(Projection).GetBlogByID(id)
+		if err == nil {
+			if blog == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, blog)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Get list of blogs. The blogs can be filtered by their health status
+func (a *API) GetBlogs(e echo.Context) error {
+	var lastError error
+	page, _ := strconv.Atoi(e.QueryParam("page"))
+	limit, _ := strconv.Atoi(e.QueryParam("limit"))
+	filters := make(map[string]interface{})
+	if status := e.QueryParam("status"); status != "" {
+		if !ValidBlogStatus(status) {
+			return weoscontroller.NewControllerError("Invalid status", fmt.Errorf("status must be active, stale, failing or dead, got '%s'", status), http.StatusBadRequest)
+		}
+		filters["status"] = status
+	}
+	if page == 0 {
+		page = 1
+	}
+	for _, projection := range a.Application.Projections() {
+		blogs, count, err := projection.(Projection).GetBlogs(page, limit, e.QueryParam("q"), nil, filters)
+		if err == nil {
+			return e.JSON(http.StatusOK, &BlogList{
+				Page:  page,
+				Limit: limit,
+				Total: count,
+				Items: blogs,
+			})
+		} else {
+			lastError = err
+		}
+	}
+	if lastError != nil {
+		return weoscontroller.NewControllerError("Error getting blogs", lastError, 0)
+	}
+	return nil
+}
+
+//Get the health of a blog i.e. whether it's still responding and publishing
+func (a *API) GetBlogHealth(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	config := a.Health
+	if config == nil {
+		config = NewHealthConfig()
+	}
+	for _, projection := range a.Application.Projections() {
+		blog, err := projection.(Projection).GetBlogByID(id)
+		if err == nil {
+			if blog == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, NewBlogHealth(blog, config, time.Now()))
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Update a blog with the details from its homepage now instead of waiting for the enrichment job
+func (a *API) EnrichBlog(e echo.Context) error {
+	id := e.Param("id")
+	blog, err := a.projection.EnrichBlog(e.Request().Context(), a.enricher, id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error enriching blog", err, http.StatusBadGateway)
+	}
+	if blog == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+	}
+	return e.JSON(http.StatusOK, blog)
+}
+
+//Fetch the feed of a blog now instead of waiting for the next poll. The blog is refreshed the same way as when it's
+//polled and a summary of what was found in the feed is returned
+func (a *API) RefreshBlog(e echo.Context) error {
+	id := e.Param("id")
+	getBlog := func() (*Blog, error) {
+		var lastError error
+		for _, projection := range a.Application.Projections() {
+			blog, err := projection.(Projection).GetBlogByID(id)
+			if err == nil {
+				return blog, nil
+			}
+			lastError = err
+		}
+		return nil, lastError
+	}
+	blog, err := getBlog()
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting blog", err, 0)
+	}
+	if blog == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+	}
+	if a.RefreshLimiter != nil {
+		if allowed, wait := a.RefreshLimiter.Allow(id, time.Now()); !allowed {
+			e.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return weoscontroller.NewControllerError("Blog was refreshed recently", fmt.Errorf("blog '%s' can be refreshed again in %s", id, wait.Round(time.Second)), http.StatusTooManyRequests)
+		}
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), RefreshBlogCommand(id))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error refreshing blog", err, 0)
+	}
+	//the projection is updated with the result of the fetch once the command is handled
+	blog, err = getBlog()
+	if err != nil || blog == nil {
+		return weoscontroller.NewControllerError("Error getting blog", err, 0)
+	}
+	if blog.LastError != "" {
+		return weoscontroller.NewControllerError("Error refreshing blog", errors.New(blog.LastError), http.StatusBadGateway)
+	}
+	summary := &RefreshSummary{
+		BlogID:       blog.ID,
+		FeedURL:      blog.FeedURL,
+		FetchSummary: blog.LastFetch,
+		Problems:     []string{},
+	}
+	if blog.LastFetchedAt != nil {
+		summary.FetchedAt = *blog.LastFetchedAt
+	}
+	for _, diagnostic := range blog.FetchDiagnostics {
+		summary.Problems = append(summary.Problems, diagnostic.Problem)
+	}
+	return e.JSON(http.StatusOK, summary)
+}
+
+//Delete a blog with its posts. With purge=true the blog is removed for good and its content is scrubbed from the stored
+//events e.g. for a takedown request
+func (a *API) DeleteBlog(e echo.Context) error {
+	id := e.Param("id")
+	purge, err := deletePurge(e)
 	if err != nil {
 		return err
 	}
-	//run fixtures
-	err = a.Application.Migrate(context.Background())
+	//blogs that were deleted can still be purged
+	if !purge {
+		var blog *Blog
+		for _, projection := range a.Application.Projections() {
+			if blog, err = projection.(Projection).GetBlogByID(id); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return weoscontroller.NewControllerError("Error getting blog", err, 0)
+		}
+		if blog == nil {
+			return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+		}
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), DeleteBlogCommand(id, purge))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error deleting blog", err, 0)
+	}
+	if purge {
+		return e.JSON(http.StatusOK, "Blog Purged")
+	}
+	return e.JSON(http.StatusOK, "Blog Deleted")
+}
+
+//Delete a post. With purge=true the post is removed for good and its content is scrubbed from the stored events
+func (a *API) DeletePost(e echo.Context) error {
+	id := e.Param("id")
+	purge, err := deletePurge(e)
 	if err != nil {
 		return err
 	}
-	//set log level to debug
-	a.EchoInstance().Logger.SetLevel(log.DEBUG)
-	return nil
+	var post *Post
+	for _, projection := range a.Application.Projections() {
+		if post, err = projection.(Projection).GetPostByID(id); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting post", err, 0)
+	}
+	if post == nil {
+		return weoscontroller.NewControllerError("Post not found", fmt.Errorf("post '%s' not found", id), http.StatusNotFound)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), DeletePostCommand(id, purge))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error deleting post", err, 0)
+	}
+	if purge {
+		return e.JSON(http.StatusOK, "Post Purged")
+	}
+	return e.JSON(http.StatusOK, "Post Deleted")
 }
 
-func New(port *string, apiConfig string) {
-	e := echo.New()
-	weoscontroller.Initialize(e, &API{}, apiConfig)
+//deletePurge parse the purge query parameter of the delete endpoints
+func deletePurge(e echo.Context) (bool, error) {
+	value := e.QueryParam("purge")
+	if value == "" {
+		return false, nil
+	}
+	purge, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, weoscontroller.NewControllerError("Invalid value for purge", err, http.StatusBadRequest)
+	}
+	return purge, nil
+}
+
+//Turn on (or off) downloading the full article for posts on a blog that only publishes summaries
+func (a *API) SetBlogFullContent(e echo.Context) error {
+	enabled, err := strconv.ParseBool(e.FormValue("enabled"))
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid value for enabled", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), SetBlogFullContentCommand(e.Param("id"), enabled))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error updating blog", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Blog Updated")
+}
+
+//Turn on (or off) sending webmentions to the pages that the new posts on a blog link to
+func (a *API) SetBlogSendMentions(e echo.Context) error {
+	enabled, err := strconv.ParseBool(e.FormValue("enabled"))
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid value for enabled", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), SetBlogSendMentionsCommand(e.Param("id"), enabled))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error updating blog", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Blog Updated")
+}
+
+//Get the webmentions sent for the posts on a blog
+func (a *API) GetSentMentions(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	status := e.QueryParam("status")
+	switch status {
+	case "", SENT_MENTION_PENDING, SENT_MENTION_SENT, SENT_MENTION_NO_ENDPOINT, SENT_MENTION_FAILED:
+	default:
+		return weoscontroller.NewControllerError("Invalid status", fmt.Errorf("unknown status '%s'", status), http.StatusBadRequest)
+	}
+	for _, projection := range a.Application.Projections() {
+		mentions, err := projection.(Projection).GetSentMentions(id, status)
+		if err == nil {
+			if mentions == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, mentions)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Claim a blog for the logged in user. The claim has the token that has to be placed on the blog to verify the claim
+func (a *API) ClaimBlog(e echo.Context) error {
+	id := e.Param("id")
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	var blog *Blog
+	var err error
+	for _, projection := range a.Application.Projections() {
+		if blog, err = projection.(Projection).GetBlogByID(id); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting blog", err, 0)
+	}
+	if blog == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), ClaimBlogCommand(id, userID))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error claiming blog", err, 0)
+	}
+	return a.blogClaim(e, http.StatusCreated)
+}
+
+//Get the logged in user's claim on a blog
+func (a *API) GetBlogClaim(e echo.Context) error {
+	return a.blogClaim(e, http.StatusOK)
+}
+
+//Verify the logged in user's claim on a blog by looking for the token on the blog
+func (a *API) VerifyBlogClaim(e echo.Context) error {
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	err := a.Application.Dispatcher().Dispatch(e.Request().Context(), VerifyBlogClaimCommand(e.Param("id"), userID))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error verifying claim", err, 0)
+	}
+	return a.blogClaim(e, http.StatusOK)
+}
+
+//blogClaim responds with the logged in user's claim on the blog in the id path param
+func (a *API) blogClaim(e echo.Context, status int) error {
+	id := e.Param("id")
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		claim, err := projection.(Projection).GetBlogClaim(id, a.userID(e))
+		if err == nil {
+			if claim == nil {
+				return weoscontroller.NewControllerError("Claim not found", fmt.Errorf("blog '%s' hasn't been claimed by the user", id), http.StatusNotFound)
+			}
+			return e.JSON(status, claim)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Update the description of a blog
+func (a *API) UpdateBlog(e echo.Context) error {
+	err := a.Application.Dispatcher().Dispatch(e.Request().Context(), UpdateBlogCommand(e.Param("id"), e.FormValue("description")))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error updating blog", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Blog Updated")
+}
+
+//Hide (or show) a post on a blog
+func (a *API) HidePost(e echo.Context) error {
+	hidden, err := strconv.ParseBool(e.FormValue("hidden"))
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid value for hidden", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), HidePostCommand(e.Param("id"), e.Param("postId"), hidden))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error updating post", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Post Updated")
+}
+
+//Replace the categories of a post on a blog
+func (a *API) SetPostCategories(e echo.Context) error {
+	params, err := e.FormParams()
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid categories", err, http.StatusBadRequest)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), SetPostCategoriesCommand(e.Param("id"), e.Param("postId"), params["categories"]))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error updating post", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Post Updated")
+}
+
+//Receive a webmention for a post on a blog. The mention is recorded once the source is found to link to the post
+func (a *API) ReceiveWebmention(e echo.Context) error {
+	id := e.Param("id")
+	blog, err := a.getBlog(id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting blog", err, 0)
+	}
+	if blog == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+	}
+	source, target := e.FormValue("source"), e.FormValue("target")
+	if err = checkMention(source, target); err != nil {
+		return weoscontroller.NewControllerError("Invalid webmention", err, http.StatusBadRequest)
+	}
+	err = a.queueMention(id, source, target, MENTION_TYPE_WEBMENTION)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error receiving webmention", err, 0)
+	}
+	return e.JSON(http.StatusAccepted, "Mention Queued")
+}
+
+//Receive a pingback (an XML-RPC pingback.ping call) for a post on a blog. Errors are returned as XML-RPC faults
+func (a *API) ReceivePingback(e echo.Context) error {
+	id := e.Param("id")
+	source, target, err := ParsePingback(e.Request().Body)
+	if err != nil {
+		return e.Blob(http.StatusOK, "text/xml", []byte(pingbackFault(pingbackFaultGeneric, err.Error())))
+	}
+	blog, err := a.getBlog(id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting blog", err, 0)
+	}
+	if blog == nil {
+		return e.Blob(http.StatusOK, "text/xml", []byte(pingbackFault(pingbackFaultTargetNotFound, fmt.Sprintf("blog '%s' not found", id))))
+	}
+	if err = checkMention(source, target); err != nil {
+		return e.Blob(http.StatusOK, "text/xml", []byte(pingbackFault(pingbackFaultGeneric, err.Error())))
+	}
+	err = a.queueMention(id, source, target, MENTION_TYPE_PINGBACK)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error receiving pingback", err, 0)
+	}
+	return e.Blob(http.StatusOK, "text/xml", []byte(pingbackResponse(fmt.Sprintf("Pingback from '%s' to '%s' queued", source, target))))
+}
+
+//queueMention queue a webmention or pingback so the source is downloaded and checked by the received mentions job
+//instead of while the sender waits
+func (a *API) queueMention(blogID string, source string, target string, mentionType string) error {
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		err := projection.(Projection).QueueMention(&ReceivedMention{
+			BlogID: blogID,
+			Source: source,
+			Target: target,
+			Type:   mentionType,
+		})
+		if err == nil {
+			return nil
+		}
+		lastError = err
+	}
+	return lastError
+}
+
+//Look up the fediverse actor of a blog from its handle e.g. acct:ak33m-com@example.com
+func (a *API) WebFinger(e echo.Context) error {
+	var lastError error
+	resource := e.QueryParam("resource")
+	if resource == "" {
+		return weoscontroller.NewControllerError("Resource required", errors.New("the resource to look up is required"), http.StatusBadRequest)
+	}
+	for _, projection := range a.Application.Projections() {
+		finger, err := projection.(Projection).GetWebFinger(resource)
+		if err == nil {
+			if finger == nil {
+				return weoscontroller.NewControllerError("Resource not found", fmt.Errorf("no blog has the handle '%s'", resource), http.StatusNotFound)
+			}
+			return federationResponse(e, "application/jrd+json", finger)
+		} else {
+			lastError = err
+		}
+	}
+	return federationError(lastError)
+}
+
+//Get the ActivityPub actor of a blog that fediverse users can follow
+func (a *API) GetBlogActor(e echo.Context) error {
+	actor, err := a.getActor(e.Param("id"))
+	if err != nil {
+		return federationError(err)
+	}
+	if actor == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", e.Param("id")), http.StatusNotFound)
+	}
+	return federationResponse(e, ACTIVITY_CONTENT_TYPE, actor)
+}
+
+//Get the latest posts of a blog as ActivityPub activities
+func (a *API) GetBlogOutbox(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	for _, projection := range a.Application.Projections() {
+		outbox, err := projection.(Projection).GetOutbox(id)
+		if err == nil {
+			if outbox == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return federationResponse(e, ACTIVITY_CONTENT_TYPE, outbox)
+		} else {
+			lastError = err
+		}
+	}
+	return federationError(lastError)
+}
+
+//Get the number of fediverse users following a blog
+func (a *API) GetBlogFediverseFollowers(e echo.Context) error {
+	var lastError error
+	id := e.Param("id")
+	for _, projection := range a.Application.Projections() {
+		followers, err := projection.(Projection).GetFediverseFollowers(id)
+		if err == nil {
+			if followers == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return federationResponse(e, ACTIVITY_CONTENT_TYPE, followers)
+		} else {
+			lastError = err
+		}
+	}
+	return federationError(lastError)
+}
+
+//Receive an activity for a blog from the fediverse. The request has to be signed by the actor that sent the activity.
+//Follows (and undoing them) are handled, other activities are accepted and ignored
+func (a *API) BlogInbox(e echo.Context) error {
+	id := e.Param("id")
+	actor, err := a.getActor(id)
+	if err != nil {
+		return federationError(err)
+	}
+	if actor == nil {
+		return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+	}
+	body, err := io.ReadAll(io.LimitReader(e.Request().Body, maxActivitySize))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error reading activity", err, http.StatusBadRequest)
+	}
+	sender, err := NewSignatureVerifier(a.PublicClient).Verify(e.Request().Context(), e.Request(), body)
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid signature", err, http.StatusUnauthorized)
+	}
+	var activity *Activity
+	if err = json.Unmarshal(body, &activity); err != nil || activity == nil {
+		return weoscontroller.NewControllerError("Invalid activity", err, http.StatusBadRequest)
+	}
+	if activity.Actor != sender.ID {
+		return weoscontroller.NewControllerError("Invalid activity", fmt.Errorf("the activity of '%s' was signed by '%s'", activity.Actor, sender.ID), http.StatusUnauthorized)
+	}
+	objectID, objectType := activityObject(activity.Object)
+	switch {
+	case activity.Type == "Follow" && objectID == actor.ID:
+		follower := &BlogFediverseFollowedPayload{
+			ActorID:  sender.ID,
+			Inbox:    sender.Inbox,
+			FollowID: activity.ID,
+		}
+		if sender.Endpoints != nil {
+			follower.SharedInbox = sender.Endpoints.SharedInbox
+		}
+		err = a.Application.Dispatcher().Dispatch(e.Request().Context(), FediverseFollowCommand(id, follower, true))
+	case activity.Type == "Undo" && objectType == "Follow":
+		err = a.Application.Dispatcher().Dispatch(e.Request().Context(), FediverseFollowCommand(id, &BlogFediverseFollowedPayload{ActorID: sender.ID}, false))
+	}
+	if err != nil {
+		var domainError *weos.DomainError
+		if errors.As(err, &domainError) {
+			return weoscontroller.NewControllerError("Invalid activity", err, http.StatusBadRequest)
+		}
+		return weoscontroller.NewControllerError("Error receiving activity", err, 0)
+	}
+	return e.JSON(http.StatusAccepted, "Activity Accepted")
+}
+
+//getActor get the ActivityPub actor of a blog from the projections. Nil is returned if the blog doesn't exist
+func (a *API) getActor(id string) (*Actor, error) {
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		actor, err := projection.(Projection).GetActor(id)
+		if err == nil {
+			return actor, nil
+		}
+		lastError = err
+	}
+	return nil, lastError
+}
+
+//federationResponse write an ActivityPub (or WebFinger) document with its content type
+func federationResponse(e echo.Context, contentType string, document interface{}) error {
+	body, err := json.Marshal(document)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error encoding response", err, 0)
+	}
+	return e.Blob(http.StatusOK, contentType, body)
+}
+
+//federationError blogs can't be found on the fediverse when federation isn't set up
+func federationError(err error) error {
+	if errors.Is(err, ErrFederationDisabled) {
+		return weoscontroller.NewControllerError("Not found", err, http.StatusNotFound)
+	}
+	return err
+}
+
+//Get the email digests the logged in user subscribed to
+func (a *API) GetDigestSubscriptions(e echo.Context) error {
+	var lastError error
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	for _, projection := range a.Application.Projections() {
+		subscriptions, err := projection.(Projection).GetDigestSubscriptions(userID)
+		if err == nil {
+			return e.JSON(http.StatusOK, subscriptions)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Subscribe the logged in user to a daily or weekly email digest of the posts from the blogs they follow and/or
+//categories. The email from the token is used if one isn't set
+func (a *API) SubscribeDigest(e echo.Context) error {
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	params, err := e.FormParams()
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid subscription", err, http.StatusBadRequest)
+	}
+	subscription := &DigestSubscriptionRequest{
+		Email:      params.Get("email"),
+		Frequency:  params.Get("frequency"),
+		Categories: params["categories"],
+	}
+	if subscription.Email == "" {
+		subscription.Email, _ = a.claims(e)["email"].(string)
+	}
+	if value := params.Get("followedBlogs"); value != "" {
+		if subscription.FollowedBlogs, err = strconv.ParseBool(value); err != nil {
+			return weoscontroller.NewControllerError("Invalid value for followedBlogs", err, http.StatusBadRequest)
+		}
+	}
+	id := blogaggregatormodule.GenerateID()
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), SubscribeDigestCommand(id, userID, subscription))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error subscribing to digest", err, 0)
+	}
+	created, err := a.getDigestSubscription(id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting subscription", err, 0)
+	}
+	return e.JSON(http.StatusCreated, created)
+}
+
+//Unsubscribe the logged in user from a digest
+func (a *API) UnsubscribeDigest(e echo.Context) error {
+	id := e.Param("id")
+	subscription, err := a.getDigestSubscription(id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting subscription", err, 0)
+	}
+	if subscription == nil || subscription.UserID != a.userID(e) {
+		return weoscontroller.NewControllerError("Subscription not found", fmt.Errorf("subscription '%s' not found", id), http.StatusNotFound)
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), UnsubscribeDigestCommand(id))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error unsubscribing from digest", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Unsubscribed")
+}
+
+//Unsubscribe from a digest using the link in the digest. POST is supported for one-click unsubscribes from email clients
+func (a *API) UnsubscribeDigestByToken(e echo.Context) error {
+	var subscription *DigestSubscription
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		var err error
+		if subscription, err = projection.(Projection).GetDigestSubscriptionByToken(e.QueryParam("token")); err == nil {
+			lastError = nil
+			break
+		}
+		lastError = err
+	}
+	if lastError != nil {
+		return weoscontroller.NewControllerError("Error getting subscription", lastError, 0)
+	}
+	//the link could be opened again after unsubscribing
+	if subscription == nil {
+		return e.JSON(http.StatusOK, "Unsubscribed")
+	}
+	err := a.Application.Dispatcher().Dispatch(e.Request().Context(), UnsubscribeDigestCommand(subscription.ID))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error unsubscribing from digest", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Unsubscribed")
+}
+
+//getDigestSubscription get a digest subscription from the projections. Nil is returned if it doesn't exist
+func (a *API) getDigestSubscription(id string) (*DigestSubscription, error) {
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		subscription, err := projection.(Projection).GetDigestSubscription(id)
+		if err == nil {
+			return subscription, nil
+		}
+		lastError = err
+	}
+	return nil, lastError
+}
+
+//Stream the new posts as server-sent events as they're added, filtered by blog_id and category like GetPosts. Clients
+//that reconnect with Last-Event-ID get the posts they missed first. A comment is sent as a heartbeat when there are no
+//new posts so that the connection isn't closed by proxies
+func (a *API) StreamPosts(e echo.Context) error {
+	filter := &PostStreamFilter{
+		BlogID:   e.QueryParam("blog_id"),
+		Category: e.QueryParam("category"),
+	}
+	//the posts are streamed from the first projection
+	projections := a.Application.Projections()
+	if len(projections) == 0 {
+		return weoscontroller.NewControllerError("Stream not available", errors.New("there are no projections"), http.StatusServiceUnavailable)
+	}
+	projection := projections[0].(Projection)
+	//the config can be set without NewStreamConfig, a heartbeat that isn't positive would make the ticker panic
+	config := NewStreamConfig()
+	if a.Stream != nil {
+		if a.Stream.Heartbeat > 0 {
+			config.Heartbeat = a.Stream.Heartbeat
+		}
+		if a.Stream.MaxReplay > 0 {
+			config.MaxReplay = a.Stream.MaxReplay
+		}
+	}
+	//the listener is added before the missed posts are loaded so that no posts are lost in between
+	listener := projection.SubscribePosts(filter)
+	defer projection.UnsubscribePosts(listener)
+	lastEventID := e.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = e.QueryParam("lastEventId")
+	}
+	var missed []*Post
+	//the posts after an event that isn't known or that are too many to replay can't be sent
+	complete := true
+	if lastEventID != "" {
+		var err error
+		missed, complete, err = projection.GetPostsAfter(lastEventID, filter, config.MaxReplay)
+		if err != nil {
+			return weoscontroller.NewControllerError("Error getting posts", err, 0)
+		}
+	}
+	response := e.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	//stops nginx from buffering the stream
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	if !complete {
+		if err := writeResetEvent(response); err != nil {
+			return nil
+		}
+	}
+	sent := make(map[string]bool)
+	for _, post := range missed {
+		if err := writePostEvent(response, post); err != nil {
+			return nil
+		}
+		sent[post.ID] = true
+	}
+	response.Flush()
+	heartbeat := time.NewTicker(config.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-e.Request().Context().Done():
+			return nil
+		case post, ok := <-listener.Posts:
+			//the listener fell behind, the client reconnects and gets the posts it missed
+			if !ok {
+				return nil
+			}
+			if sent[post.ID] {
+				continue
+			}
+			if err := writePostEvent(response, post); err != nil {
+				return nil
+			}
+			response.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(response, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			response.Flush()
+		}
+	}
+}
+
+//Get the searches the logged in user saved
+func (a *API) GetSavedSearches(e echo.Context) error {
+	var lastError error
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	for _, projection := range a.Application.Projections() {
+		searches, err := projection.(Projection).GetSavedSearches(userID)
+		if err == nil {
+			return e.JSON(http.StatusOK, searches)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Save a search for the logged in user to be notified about new posts that match it. Users are notified in the app unless
+//a webhook or email is chosen, the email from the token is used if one isn't set
+func (a *API) SaveSearch(e echo.Context) error {
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	params, err := e.FormParams()
+	if err != nil {
+		return weoscontroller.NewControllerError("Invalid search", err, http.StatusBadRequest)
+	}
+	search := &SavedSearchRequest{
+		Name:       params.Get("name"),
+		Query:      params.Get("query"),
+		Categories: params["categories"],
+		Blogs:      params["blogs"],
+		Notify:     params.Get("notify"),
+		WebhookURL: params.Get("webhookUrl"),
+		Email:      params.Get("email"),
+	}
+	for _, value := range params["authors"] {
+		authorID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return weoscontroller.NewControllerError("Invalid author", err, http.StatusBadRequest)
+		}
+		search.Authors = append(search.Authors, uint(authorID))
+	}
+	if search.Notify == "" {
+		search.Notify = SEARCH_NOTIFY_IN_APP
+	}
+	if search.Notify == SEARCH_NOTIFY_EMAIL {
+		if a.Mailer == nil {
+			return weoscontroller.NewControllerError("Email notifications are not available", ErrEmailDisabled, http.StatusBadRequest)
+		}
+		if search.Email == "" {
+			search.Email, _ = a.claims(e)["email"].(string)
+		}
+	}
+	id := blogaggregatormodule.GenerateID()
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), SaveSearchCommand(id, userID, search))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error saving search", err, 0)
+	}
+	created, err := a.getSavedSearch(id)
+	if err != nil {
+		return weoscontroller.NewControllerError("Error getting search", err, 0)
+	}
+	return e.JSON(http.StatusCreated, created)
+}
+
+//Get a search the logged in user saved
+func (a *API) GetSavedSearch(e echo.Context) error {
+	search, err := a.getUserSavedSearch(e)
+	if err != nil {
+		return err
+	}
+	return e.JSON(http.StatusOK, search)
+}
+
+//Delete a search the logged in user saved, they're no longer notified about it
+func (a *API) DeleteSearch(e echo.Context) error {
+	search, err := a.getUserSavedSearch(e)
+	if err != nil {
+		return err
+	}
+	err = a.Application.Dispatcher().Dispatch(e.Request().Context(), DeleteSearchCommand(search.ID))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error deleting search", err, 0)
+	}
+	return e.JSON(http.StatusOK, "Deleted")
+}
+
+//Get the new posts that matched a search the logged in user saved, the newest first
+func (a *API) GetSavedSearchMatches(e echo.Context) error {
+	search, err := a.getUserSavedSearch(e)
+	if err != nil {
+		return err
+	}
+	page, _ := strconv.Atoi(e.QueryParam("page"))
+	limit, _ := strconv.Atoi(e.QueryParam("limit"))
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		matches, count, err := projection.(Projection).GetSavedSearchMatches(search.ID, page, limit)
+		if err == nil {
+			return e.JSON(http.StatusOK, &SavedSearchMatchList{
+				Page:  page,
+				Limit: limit,
+				Total: count,
+				Items: matches,
+			})
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//getUserSavedSearch get the saved search in the path. A not found error is returned if the logged in user didn't save it
+func (a *API) getUserSavedSearch(e echo.Context) (*SavedSearch, error) {
+	id := e.Param("id")
+	search, err := a.getSavedSearch(id)
+	if err != nil {
+		return nil, weoscontroller.NewControllerError("Error getting search", err, 0)
+	}
+	if search == nil || search.UserID != a.userID(e) {
+		return nil, weoscontroller.NewControllerError("Search not found", fmt.Errorf("saved search '%s' not found", id), http.StatusNotFound)
+	}
+	return search, nil
+}
+
+//getSavedSearch get a saved search from the projections. Nil is returned if it doesn't exist
+func (a *API) getSavedSearch(id string) (*SavedSearch, error) {
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		search, err := projection.(Projection).GetSavedSearch(id)
+		if err == nil {
+			return search, nil
+		}
+		lastError = err
+	}
+	return nil, lastError
+}
+
+//getBlog get a blog from the projections. Nil is returned if the blog doesn't exist
+func (a *API) getBlog(id string) (*Blog, error) {
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		blog, err := projection.(Projection).GetBlogByID(id)
+		if err == nil {
+			return blog, nil
+		}
+		lastError = err
+	}
+	return nil, lastError
+}
+
+//Follow a blog
+func (a *API) FollowBlog(e echo.Context) error {
+	return a.followBlog(e, true)
+}
+
+//Stop following a blog
+func (a *API) UnfollowBlog(e echo.Context) error {
+	return a.followBlog(e, false)
+}
+
+func (a *API) followBlog(e echo.Context, follow bool) error {
+	userID := a.userID(e)
+	if userID == "" {
+		return weoscontroller.NewControllerError("User not found", errors.New("the token doesn't identify the user"), http.StatusForbidden)
+	}
+	err := a.Application.Dispatcher().Dispatch(e.Request().Context(), FollowBlogCommand(e.Param("id"), userID, follow))
+	if err != nil {
+		return weoscontroller.NewControllerError("Error following blog", err, 0)
+	}
+	if follow {
+		return e.JSON(http.StatusOK, "Blog Followed")
+	}
+	return e.JSON(http.StatusOK, "Blog Unfollowed")
+}
+
+//Count a view of a post. Views of the same post from the same address are only counted once per interval
+func (a *API) RecordPostView(e echo.Context) error {
+	id := e.Param("id")
+	now := time.Now()
+	if a.ViewLimiter != nil {
+		if allowed, _ := a.ViewLimiter.Allow(e.RealIP()+" "+id, now); !allowed {
+			return e.JSON(http.StatusAccepted, "View Recorded")
+		}
+	}
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		found, err := projection.(Projection).RecordPostView(id, now)
+		if err == nil {
+			if !found {
+				return weoscontroller.NewControllerError("Post not found", fmt.Errorf("post '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusAccepted, "View Recorded")
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+//Get the stats of a blog for a range of days. The range defaults to the last 30 days
+func (a *API) GetBlogStats(e echo.Context) error {
+	id := e.Param("id")
+	to := time.Now().UTC()
+	if value := e.QueryParam("to"); value != "" {
+		date, err := time.Parse(statsDayFormat, value)
+		if err != nil {
+			return weoscontroller.NewControllerError("Invalid to date", err, http.StatusBadRequest)
+		}
+		to = date
+	}
+	from := to.AddDate(0, 0, -(defaultStatsDays - 1))
+	if value := e.QueryParam("from"); value != "" {
+		date, err := time.Parse(statsDayFormat, value)
+		if err != nil {
+			return weoscontroller.NewControllerError("Invalid from date", err, http.StatusBadRequest)
+		}
+		from = date
+	}
+	if from.After(to) {
+		return weoscontroller.NewControllerError("Invalid date range", errors.New("the from date is after the to date"), http.StatusBadRequest)
+	}
+	if to.Sub(from) >= maxStatsDays*24*time.Hour {
+		return weoscontroller.NewControllerError("Invalid date range", fmt.Errorf("the range can't be more than %d days", maxStatsDays), http.StatusBadRequest)
+	}
+	var lastError error
+	for _, projection := range a.Application.Projections() {
+		stats, err := projection.(Projection).GetBlogStats(id, from, to)
+		if err == nil {
+			if stats == nil {
+				return weoscontroller.NewControllerError("Blog not found", fmt.Errorf("blog '%s' not found", id), http.StatusNotFound)
+			}
+			return e.JSON(http.StatusOK, stats)
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
+// health check handler
+func (a *API) HealthCheck(e echo.Context) error {
+	return e.JSON(200, "GOOD")
+}
+
+func (a *API) Initialize() error {
+	var err error
+	//the key isn't in the api specification, without one the tokens couldn't be validated
+	if !a.jwtKeySet() {
+		return ErrJWTKeyNotSet
+	}
+	//initialize app
+	//every request to the sites of the blogs goes through the fetcher so that the aggregator is polite to the hosts
+	if a.Client == nil {
+		a.Client = NewFetcher(NewFetcherConfig(), nil).Client()
+	}
+	if a.PublicClient == nil {
+		a.PublicClient = NewPublicClient(NewFetcherConfig().Timeout)
+	}
+	a.Application, err = weos.NewApplicationFromConfig(a.Config.ApplicationConfig, a.Log, a.DB, a.Client, nil)
+	if err != nil {
+		return err
+	}
+	//setup projections
+	a.projection, err = NewProjection(a.Application)
+	if err != nil {
+		return err
+	}
+	//setup the command handlers for the api. Blogs are added by the api instead of the module so that JSON Feed and RDF
+	//feeds can be discovered and parsed
+	receiver := NewReceiver(a.Application, a.projection)
+	a.Application.Dispatcher().AddSubscriber(blogaggregatormodule.AddBlogCommand(""), receiver.AddBlog)
+	a.Application.Dispatcher().AddSubscriber(AddCategorySynonymCommand(0, ""), receiver.AddCategorySynonym)
+	a.Application.Dispatcher().AddSubscriber(RemoveCategorySynonymCommand(0, ""), receiver.RemoveCategorySynonym)
+	a.Application.Dispatcher().AddSubscriber(MergeCategoryCommand(0, 0), receiver.MergeCategory)
+	a.Application.Dispatcher().AddSubscriber(SetBlogFullContentCommand("", false), receiver.SetBlogFullContent)
+	a.Application.Dispatcher().AddSubscriber(RefreshBlogCommand(""), receiver.RefreshBlog)
+	a.Application.Dispatcher().AddSubscriber(DeleteBlogCommand("", false), receiver.DeleteBlog)
+	a.Application.Dispatcher().AddSubscriber(DeletePostCommand("", false), receiver.DeletePost)
+	a.Application.Dispatcher().AddSubscriber(ClaimBlogCommand("", ""), receiver.ClaimBlog)
+	a.Application.Dispatcher().AddSubscriber(VerifyBlogClaimCommand("", ""), receiver.VerifyBlogClaim)
+	a.Application.Dispatcher().AddSubscriber(UpdateBlogCommand("", ""), receiver.UpdateBlog)
+	a.Application.Dispatcher().AddSubscriber(HidePostCommand("", "", false), receiver.HidePost)
+	a.Application.Dispatcher().AddSubscriber(SetPostCategoriesCommand("", "", nil), receiver.SetPostCategories)
+	a.Application.Dispatcher().AddSubscriber(FollowBlogCommand("", "", false), receiver.FollowBlog)
+	a.Application.Dispatcher().AddSubscriber(ReceiveMentionCommand("", "", "", ""), receiver.ReceiveMention)
+	a.Application.Dispatcher().AddSubscriber(SetBlogSendMentionsCommand("", false), receiver.SetBlogSendMentions)
+	a.Application.Dispatcher().AddSubscriber(FediverseFollowCommand("", nil, false), receiver.FediverseFollow)
+	a.Application.Dispatcher().AddSubscriber(SubscribeDigestCommand("", "", nil), receiver.SubscribeDigest)
+	a.Application.Dispatcher().AddSubscriber(UnsubscribeDigestCommand(""), receiver.UnsubscribeDigest)
+	a.Application.Dispatcher().AddSubscriber(SaveSearchCommand("", "", nil), receiver.SaveSearch)
+	a.Application.Dispatcher().AddSubscriber(DeleteSearchCommand(""), receiver.DeleteSearch)
+	if a.RefreshLimiter == nil {
+		a.RefreshLimiter = NewRateLimiter(envDuration("ADMIN_REFRESH_INTERVAL", time.Minute))
+	}
+	if a.ViewLimiter == nil {
+		a.ViewLimiter = NewRateLimiter(envDuration("VIEW_INTERVAL", 30*time.Minute))
+	}
+	//setup the background jobs
+	if a.Trending == nil {
+		a.Trending = NewTrendingConfig()
+	}
+	a.Scheduler = NewScheduler(a.Application.Logger())
+	a.Scheduler.AddJob(&Job{
+		Name:     "trending",
+		Interval: a.Trending.Interval,
+		Run: func(ctx context.Context) error {
+			return a.projection.UpdateTrendingScores(a.Trending, time.Now())
+		},
+	})
+	pollingMaxAge := envDuration("POLLING_MAX_AGE", 30*time.Minute)
+	a.Scheduler.AddJob(&Job{
+		Name:     "feed polling",
+		Interval: envDuration("POLLING_INTERVAL", time.Minute),
+		Run: func(ctx context.Context) error {
+			//the health config is set up below, blogs that aren't polled are still fetched now and then
+			pausedRetry := a.Health.PausedRetry
+			if pausedRetry <= 0 {
+				pausedRetry = NewHealthConfig().PausedRetry
+			}
+			blogs, err := a.projection.BlogsToRefresh(pollingMaxAge, pausedRetry, pollingBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, blog := range blogs {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				//the error is logged so the other blogs can still be refreshed
+				err = a.Application.Dispatcher().Dispatch(ctx, RefreshBlogCommand(blog.ID))
+				if err != nil {
+					a.Application.Logger().Errorf("error refreshing blog '%s': '%s'", blog.ID, err)
+				}
+			}
+			return nil
+		},
+	})
+	if a.Health == nil {
+		a.Health = NewHealthConfig()
+	}
+	a.Scheduler.AddJob(&Job{
+		Name:     "blog health",
+		Interval: a.Health.Interval,
+		Run: func(ctx context.Context) error {
+			return a.projection.UpdateBlogHealth(a.Health, time.Now())
+		},
+	})
+	a.enricher = NewBlogEnricher(a.Client)
+	enrichmentMaxAge := envDuration("ENRICHMENT_MAX_AGE", 24*time.Hour)
+	a.Scheduler.AddJob(&Job{
+		Name:     "blog enrichment",
+		Interval: envDuration("ENRICHMENT_INTERVAL", time.Minute),
+		Run: func(ctx context.Context) error {
+			return a.projection.EnrichBlogs(ctx, a.enricher, enrichmentMaxAge, enrichmentBatchSize)
+		},
+	})
+	extractor := NewContentExtractor(a.Client)
+	a.Scheduler.AddJob(&Job{
+		Name:     "full content",
+		Interval: envDuration("FULL_CONTENT_INTERVAL", 5*time.Minute),
+		Run: func(ctx context.Context) error {
+			return a.projection.ExtractFullContent(ctx, extractor, fullContentBatchSize)
+		},
+	})
+	if a.Webmentions == nil {
+		a.Webmentions = NewWebmentionConfig()
+	}
+	sender := NewWebmentionSender(a.PublicClient)
+	a.Scheduler.AddJob(&Job{
+		Name:     "webmentions",
+		Interval: a.Webmentions.Interval,
+		Run: func(ctx context.Context) error {
+			return a.projection.SendWebmentions(ctx, sender, a.Webmentions, time.Now())
+		},
+	})
+	mentionMaxAttempts := envInt("MENTION_MAX_ATTEMPTS", 5)
+	mentionRetryDelay := envDuration("MENTION_RETRY_DELAY", 15*time.Minute)
+	a.Scheduler.AddJob(&Job{
+		Name:     "received mentions",
+		Interval: envDuration("MENTION_INTERVAL", time.Minute),
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			mentions, err := a.projection.GetQueuedMentions(now, receivedMentionBatchSize)
+			if err != nil {
+				return err
+			}
+			for _, mention := range mentions {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				checkErr := a.Application.Dispatcher().Dispatch(ctx, ReceiveMentionCommand(mention.BlogID, mention.Source, mention.Target, mention.Type))
+				//mentions that are rejected (e.g. the source doesn't link to the post) are dropped, mentions that couldn't be
+				//checked (e.g. the source was down) are checked again later
+				var domainError *weos.DomainError
+				if checkErr != nil && !errors.As(checkErr, &domainError) && mention.Attempts+1 < mentionMaxAttempts {
+					err = a.projection.RetryQueuedMention(mention.ID, checkErr, now.Add(mentionRetryDelay*time.Duration(1<<mention.Attempts)))
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				if checkErr != nil && domainError == nil {
+					a.Application.Logger().Errorf("error checking mention from '%s': '%s'", mention.Source, checkErr)
+				}
+				err = a.projection.RemoveQueuedMention(mention.ID)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	if a.Federation == nil {
+		a.Federation = NewFederationConfig()
+	}
+	deliverer := NewActivityDeliverer(a.PublicClient)
+	a.Scheduler.AddJob(&Job{
+		Name:     "activitypub deliveries",
+		Interval: a.Federation.Interval,
+		Run: func(ctx context.Context) error {
+			return a.projection.DeliverActivities(ctx, deliverer, a.Federation, time.Now())
+		},
+	})
+	if a.Mailer == nil {
+		if config := NewSMTPConfig(); config != nil {
+			a.Mailer = NewSMTPMailer(config)
+		}
+	}
+	if a.Mailer != nil {
+		if a.Digests == nil {
+			a.Digests = NewDigestConfig()
+		}
+		a.Scheduler.AddJob(&Job{
+			Name:     "digests",
+			Interval: a.Digests.Interval,
+			Run: func(ctx context.Context) error {
+				return a.projection.SendDigests(ctx, a.Mailer, a.Digests, time.Now())
+			},
+		})
+	}
+	if a.SearchAlerts == nil {
+		a.SearchAlerts = NewSearchAlertConfig()
+	}
+	if a.Stream == nil {
+		a.Stream = NewStreamConfig()
+	}
+	//webhooks go to endpoints the users set up so they don't go through the fetcher (and robots.txt)
+	webhooks := NewWebhookSender(NewPublicClient(a.SearchAlerts.Timeout))
+	a.Scheduler.AddJob(&Job{
+		Name:     "saved search alerts",
+		Interval: a.SearchAlerts.Interval,
+		Run: func(ctx context.Context) error {
+			return a.projection.SendSearchAlerts(ctx, webhooks, a.Mailer, a.SearchAlerts, time.Now())
+		},
+	})
+	a.Scheduler.AddJob(&Job{
+		Name:     "rate limiters",
+		Interval: rateLimiterPruneInterval,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			a.RefreshLimiter.Prune(now)
+			a.ViewLimiter.Prune(now)
+			return nil
+		},
+	})
+	//run fixtures
+	err = a.Application.Migrate(context.Background())
+	if err != nil {
+		return err
+	}
+	//set log level to debug
+	a.EchoInstance().Logger.SetLevel(log.DEBUG)
+	return nil
+}
+
+func New(port *string, apiConfig string) {
+	e := echo.New()
+	blogAPI := &API{}
+	weoscontroller.Initialize(e, blogAPI, apiConfig)
+	if blogAPI.Scheduler != nil {
+		blogAPI.Scheduler.Start(context.Background())
+	}
 	e.Logger.Fatal(e.Start(":" + *port))
 }
@@ -1,20 +1,65 @@
 package api_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
 	api "github.com/wepala/blog-aggregator-api/src"
 	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
 	"github.com/wepala/weos"
+	weoscontroller "github.com/wepala/weos-controller"
 )
 
+//newTestAPI setup the api with an application that returns the projection and the dispatcher, either can be nil
+func newTestAPI(projection api.Projection, dispatcher weos.Dispatcher) *api.API {
+	application := &ApplicationMock{
+		ProjectionsFunc: func() []weos.Projection {
+			if projection == nil {
+				return nil
+			}
+			return []weos.Projection{projection}
+		},
+		DispatcherFunc: func() weos.Dispatcher {
+			return dispatcher
+		},
+	}
+	return &api.API{
+		Application: application,
+	}
+}
+
+func TestInitializeWithoutJWTKey(t *testing.T) {
+	tests := []*weoscontroller.APIConfig{
+		nil,
+		{},
+		{JWTConfig: &weoscontroller.JWTConfig{}},
+	}
+	for _, config := range tests {
+		blogAPI := &api.API{}
+		blogAPI.Config = config
+		if err := blogAPI.Initialize(); !errors.Is(err, api.ErrJWTKeyNotSet) {
+			t.Errorf("expected the api not to start without a jwt key, got '%v'", err)
+		}
+	}
+}
+
 func TestBlogAdd(t *testing.T) {
 	e := echo.New()
 	dispatcher := &DispatcherMock{
@@ -344,3 +389,1306 @@ func TestGetCategories(t *testing.T) {
 	}
 
 }
+
+func TestGetCategory(t *testing.T) {
+	e := echo.New()
+
+	mockCategory := &api.CategoryDetail{
+		Category: &api.Category{
+			Title: "React",
+			Slug:  "react",
+		},
+		PostCount: 2,
+	}
+
+	mockProjection := &ProjectionMock{
+		GetCategoryFunc: func(slug string) (*api.CategoryDetail, error) {
+			if slug == mockCategory.Slug {
+				return mockCategory, nil
+			}
+			return nil, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+
+	t.Run("get existing category", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/categories/react", nil)
+		req = req.WithContext(context.TODO())
+		req.Close = true
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.SetParamNames("slug")
+		ctx.SetParamValues("react")
+		err := blogAPI.GetCategory(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error getting category '%s'", err)
+		}
+		if recorder.Code != 200 {
+			t.Errorf("expected response code to be %d, got %d", 200, recorder.Code)
+		}
+		var category *api.CategoryDetail
+		json.NewDecoder(recorder.Body).Decode(&category)
+		if category == nil {
+			t.Fatal("expected category response")
+		}
+		if category.Title != mockCategory.Title {
+			t.Errorf("expected the category title to be '%s', got '%s'", mockCategory.Title, category.Title)
+		}
+		if category.PostCount != mockCategory.PostCount {
+			t.Errorf("expected the post count to be %d, got %d", mockCategory.PostCount, category.PostCount)
+		}
+	})
+
+	t.Run("get category that doesn't exist", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/categories/vue", nil)
+		req = req.WithContext(context.TODO())
+		req.Close = true
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.SetParamNames("slug")
+		ctx.SetParamValues("vue")
+		err := blogAPI.GetCategory(ctx)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != 404 {
+			t.Errorf("expected the status code to be %d, got %d", 404, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestMergeCategory(t *testing.T) {
+	e := echo.New()
+	dispatcher := &DispatcherMock{
+		DispatchFunc: func(ctx context.Context, command *weos.Command) error {
+			return nil
+		},
+	}
+	blogAPI := newTestAPI(nil, dispatcher)
+
+	formData := url.Values{
+		"into": {"2"},
+	}
+	req := httptest.NewRequest("POST", "/admin/categories/1/merge", strings.NewReader(formData.Encode()))
+	req = req.WithContext(context.TODO())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Close = true
+	recorder := httptest.NewRecorder()
+	ctx := e.NewContext(req, recorder)
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("1")
+	err := blogAPI.MergeCategory(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error merging category '%s'", err)
+	}
+
+	if len(dispatcher.DispatchCalls()) != 1 {
+		t.Fatalf("expected a command to be dispatched")
+	}
+	command := dispatcher.DispatchCalls()[0].Command
+	if command.Type != "category.merge" {
+		t.Errorf("expected the command type to be '%s', got '%s'", "category.merge", command.Type)
+	}
+	var mergeRequest *api.MergeCategoryRequest
+	json.Unmarshal(command.Payload, &mergeRequest)
+	if mergeRequest.CategoryID != 1 || mergeRequest.IntoID != 2 {
+		t.Errorf("expected category %d to be merged into %d, got %d into %d", 1, 2, mergeRequest.CategoryID, mergeRequest.IntoID)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	e := echo.New()
+	blogAPI := &api.API{}
+	handler := blogAPI.AdminOnly(func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, "OK")
+	})
+
+	t.Run("admin user", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/categories/1/merge", nil)
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "123", "role": "admin"}})
+		err := handler(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error '%s'", err)
+		}
+		if recorder.Code != http.StatusOK {
+			t.Errorf("expected response code to be %d, got %d", http.StatusOK, recorder.Code)
+		}
+	})
+
+	t.Run("user that is not an admin", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/categories/1/merge", nil)
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "123"}})
+		err := handler(ctx)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusForbidden {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusForbidden, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestBlogOwner(t *testing.T) {
+	e := echo.New()
+	mockProjection := &ProjectionMock{
+		IsBlogOwnerFunc: func(blogID string, userID string) (bool, error) {
+			return blogID == "123" && userID == "owner", nil
+		},
+	}
+	blogAPI := newTestAPI(mockProjection, nil)
+	handler := blogAPI.BlogOwner(func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, "OK")
+	})
+
+	tests := []struct {
+		name   string
+		blogID string
+		claims jwt.MapClaims
+		status int
+	}{
+		{"verified owner", "123", jwt.MapClaims{"sub": "owner"}, http.StatusOK},
+		{"admin user", "123", jwt.MapClaims{"sub": "123", "role": "admin"}, http.StatusOK},
+		{"owner of another blog", "456", jwt.MapClaims{"sub": "owner"}, http.StatusForbidden},
+		{"user that is not an owner", "123", jwt.MapClaims{"sub": "someone"}, http.StatusForbidden},
+		{"user without an id", "123", jwt.MapClaims{}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/blogs/"+test.blogID, nil)
+			recorder := httptest.NewRecorder()
+			ctx := e.NewContext(req, recorder)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(test.blogID)
+			ctx.Set("user", &jwt.Token{Claims: test.claims})
+			err := handler(ctx)
+			if test.status == http.StatusOK {
+				if err != nil {
+					t.Fatalf("unexpected error '%s'", err)
+				}
+				if recorder.Code != http.StatusOK {
+					t.Errorf("expected response code to be %d, got %d", http.StatusOK, recorder.Code)
+				}
+				return
+			}
+			var controllerError *weoscontroller.WeOSControllerError
+			if !errors.As(err, &controllerError) {
+				t.Fatalf("expected a controller error, got '%v'", err)
+			}
+			if controllerError.StatusCode != test.status {
+				t.Errorf("expected the status code to be %d, got %d", test.status, controllerError.StatusCode)
+			}
+		})
+	}
+}
+
+func TestGetAuthorsHandler(t *testing.T) {
+	e := echo.New()
+
+	mockAuthors := []*api.Author{
+		{
+			Name:   "Akeem Philbert",
+			BlogID: "123",
+		},
+	}
+
+	mockProjection := &ProjectionMock{
+		GetAuthorsFunc: func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Author, int64, error) {
+			if query != "akeem" {
+				t.Errorf("expected the query to be '%s', got '%s'", "akeem", query)
+			}
+			if blogId, _ := filterOptions["blog_id"].(string); blogId != "123" {
+				t.Errorf("expected the blog_id filter value to be '%s', got '%s'", "123", blogId)
+			}
+			return mockAuthors, int64(len(mockAuthors)), nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+	req := httptest.NewRequest("GET", "/authors?q=akeem&blog_id=123", nil)
+	req = req.WithContext(context.TODO())
+	req.Close = true
+	recorder := httptest.NewRecorder()
+	err := blogAPI.GetAuthors(e.NewContext(req, recorder))
+	if err != nil {
+		t.Fatalf("unexpected error getting authors '%s'", err)
+	}
+
+	if len(mockProjection.GetAuthorsCalls()) == 0 {
+		t.Error("expected GetAuthors to be called")
+	}
+	var authorList *api.AuthorList
+	json.NewDecoder(recorder.Body).Decode(&authorList)
+	if authorList == nil {
+		t.Fatal("expected author list response")
+	}
+	if authorList.Total != 1 || len(authorList.Items) != 1 {
+		t.Fatalf("expected %d author to be returned, got %d", 1, authorList.Total)
+	}
+	if authorList.Items[0].Name != mockAuthors[0].Name {
+		t.Errorf("expected the author name to be '%s', got '%s'", mockAuthors[0].Name, authorList.Items[0].Name)
+	}
+}
+
+func TestGetTrendingPosts(t *testing.T) {
+	e := echo.New()
+	mockProjection := &ProjectionMock{
+		GetPostsFunc: func(page, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Post, int64, error) {
+			if sortOptions["trending_score"] != "desc" {
+				t.Errorf("expected the posts to be sorted by trending score")
+			}
+			if _, ok := filterOptions["trending"]; !ok {
+				t.Errorf("expected the filter option 'trending' to be set")
+			}
+			if filterOptions["category"] != "go" {
+				t.Errorf("expected the category filter value to be '%s', got '%v'", "go", filterOptions["category"])
+			}
+			return []*api.Post{{Title: "Post 1"}}, 1, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+	req := httptest.NewRequest("GET", "/posts/trending?category=go", nil)
+	recorder := httptest.NewRecorder()
+	blogAPI.GetTrendingPosts(e.NewContext(req, recorder))
+
+	if len(mockProjection.GetPostsCalls()) == 0 {
+		t.Error("expected GetPosts to be called")
+	}
+	if recorder.Code != 200 {
+		t.Errorf("expected response code to be %d, got %d", 200, recorder.Code)
+	}
+}
+
+func TestGetRelatedPosts(t *testing.T) {
+	e := echo.New()
+	mockProjection := &ProjectionMock{
+		GetRelatedPostsFunc: func(id string, limit int) ([]*api.Post, error) {
+			if id != "123" {
+				return nil, nil
+			}
+			if limit != 3 {
+				t.Errorf("expected limit to be %d, got %d", 3, limit)
+			}
+			return []*api.Post{{Title: "Post 1"}}, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+
+	req := httptest.NewRequest("GET", "/posts/123/related?limit=3", nil)
+	recorder := httptest.NewRecorder()
+	ctxt := e.NewContext(req, recorder)
+	ctxt.SetParamNames("id")
+	ctxt.SetParamValues("123")
+	err := blogAPI.GetRelatedPosts(ctxt)
+	if err != nil {
+		t.Fatalf("unexpected error getting related posts '%s'", err)
+	}
+	var postList *api.PostList
+	json.NewDecoder(recorder.Body).Decode(&postList)
+	if postList == nil || len(postList.Items) != 1 {
+		t.Fatalf("expected %d related post", 1)
+	}
+
+	t.Run("post not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/posts/456/related", nil)
+		ctxt := e.NewContext(req, httptest.NewRecorder())
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("456")
+		err := blogAPI.GetRelatedPosts(ctxt)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusNotFound, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestGetBlog(t *testing.T) {
+	e := echo.New()
+	mockProjection := &ProjectionMock{
+		GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+			if id != "123" {
+				return nil, nil
+			}
+			return &api.Blog{ID: "123", Title: "Some Blog", SiteName: "Some Site"}, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+
+	req := httptest.NewRequest("GET", "/blogs/123", nil)
+	recorder := httptest.NewRecorder()
+	ctxt := e.NewContext(req, recorder)
+	ctxt.SetParamNames("id")
+	ctxt.SetParamValues("123")
+	err := blogAPI.GetBlog(ctxt)
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	var blog *api.Blog
+	json.NewDecoder(recorder.Body).Decode(&blog)
+	if blog == nil || blog.SiteName != "Some Site" {
+		t.Fatalf("expected the blog to be returned")
+	}
+
+	t.Run("blog not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/blogs/456", nil)
+		ctxt := e.NewContext(req, httptest.NewRecorder())
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("456")
+		err := blogAPI.GetBlog(ctxt)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusNotFound, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestGetFeed(t *testing.T) {
+	e := echo.New()
+	mockProjection := &ProjectionMock{
+		GetPostsFunc: func(page, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Post, int64, error) {
+			if filterOptions["has_media"] != "audio" {
+				t.Errorf("expected the has_media filter value to be '%s', got '%v'", "audio", filterOptions["has_media"])
+			}
+			return []*api.Post{
+				{
+					ID:    "1",
+					Title: "Episode 1",
+					Link:  "https://example.org/episodes/1",
+					Enclosures: []*api.Enclosure{
+						{
+							URL:    "https://example.org/episode-1.mp3",
+							Type:   "audio/mpeg",
+							Medium: "audio",
+							Length: 1000,
+						},
+					},
+				},
+			}, 1, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+
+	req := httptest.NewRequest("GET", "/feed?has_media=audio", nil)
+	recorder := httptest.NewRecorder()
+	err := blogAPI.GetFeed(e.NewContext(req, recorder))
+	if err != nil {
+		t.Fatalf("unexpected error getting feed '%s'", err)
+	}
+	body := recorder.Body.String()
+	if !strings.Contains(body, `<enclosure url="https://example.org/episode-1.mp3" length="1000" type="audio/mpeg"></enclosure>`) {
+		t.Errorf("expected the enclosure to be in the feed, got '%s'", body)
+	}
+	if !strings.Contains(body, `<media:content url="https://example.org/episode-1.mp3"`) {
+		t.Errorf("expected the media content to be in the feed, got '%s'", body)
+	}
+
+	t.Run("invalid media type", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/feed?has_media=pdf", nil)
+		err := blogAPI.GetFeed(e.NewContext(req, httptest.NewRecorder()))
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) || controllerError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a bad request error, got '%v'", err)
+		}
+	})
+}
+
+func TestGetBlogHealth(t *testing.T) {
+	e := echo.New()
+	lastPost := time.Now().Add(-100 * 24 * time.Hour)
+	mockProjection := &ProjectionMock{
+		GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+			if id != "123" {
+				return nil, nil
+			}
+			return &api.Blog{ID: "123", Title: "Some Blog", ConsecutiveFailures: 1, LastError: "unexpected status 500", LastPostAt: &lastPost}, nil
+		},
+	}
+
+	blogAPI := newTestAPI(mockProjection, nil)
+	blogAPI.Health = &api.HealthConfig{
+		FailingAfter:   3,
+		StaleAfter:     90 * 24 * time.Hour,
+		DeadAfter:      30 * 24 * time.Hour,
+		AbandonedAfter: 365 * 24 * time.Hour,
+		PauseStatuses:  []string{api.BLOG_STATUS_STALE},
+	}
+
+	req := httptest.NewRequest("GET", "/blogs/123/health", nil)
+	recorder := httptest.NewRecorder()
+	ctxt := e.NewContext(req, recorder)
+	ctxt.SetParamNames("id")
+	ctxt.SetParamValues("123")
+	err := blogAPI.GetBlogHealth(ctxt)
+	if err != nil {
+		t.Fatalf("unexpected error getting blog health '%s'", err)
+	}
+	var health *api.BlogHealth
+	json.NewDecoder(recorder.Body).Decode(&health)
+	if health == nil {
+		t.Fatalf("expected the health of the blog to be returned")
+	}
+	if health.Status != api.BLOG_STATUS_STALE {
+		t.Errorf("expected the status to be '%s', got '%s'", api.BLOG_STATUS_STALE, health.Status)
+	}
+	if health.DaysSinceLastPost != 100 {
+		t.Errorf("expected %d days since the last post, got %d", 100, health.DaysSinceLastPost)
+	}
+	if !health.PollingPaused {
+		t.Errorf("expected polling to be paused for stale blogs")
+	}
+	if health.ConsecutiveFailures != 1 || health.LastError != "unexpected status 500" {
+		t.Errorf("expected the last error to be returned, got '%s'", health.LastError)
+	}
+
+	t.Run("blog not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/blogs/456/health", nil)
+		ctxt := e.NewContext(req, httptest.NewRecorder())
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("456")
+		err := blogAPI.GetBlogHealth(ctxt)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusNotFound, controllerError.StatusCode)
+		}
+	})
+
+	t.Run("list blogs with an invalid status", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/blogs?status=sleeping", nil)
+		err := blogAPI.GetBlogs(e.NewContext(req, httptest.NewRecorder()))
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusBadRequest, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestGetBlogStats(t *testing.T) {
+	e := echo.New()
+	var from, to time.Time
+	mockProjection := &ProjectionMock{
+		GetBlogStatsFunc: func(blogID string, fromDate time.Time, toDate time.Time) (*api.BlogStats, error) {
+			if blogID != "123" {
+				return nil, nil
+			}
+			from, to = fromDate, toDate
+			return &api.BlogStats{BlogID: blogID}, nil
+		},
+	}
+	blogAPI := newTestAPI(mockProjection, nil)
+
+	tests := []struct {
+		name   string
+		blogID string
+		query  string
+		status int
+		from   string
+		to     string
+	}{
+		{"date range", "123", "from=2021-03-01&to=2021-03-31", http.StatusOK, "2021-03-01", "2021-03-31"},
+		{"last 30 days to a date", "123", "to=2021-03-31", http.StatusOK, "2021-03-02", "2021-03-31"},
+		{"invalid date", "123", "from=March", http.StatusBadRequest, "", ""},
+		{"from after to", "123", "from=2021-04-01&to=2021-03-31", http.StatusBadRequest, "", ""},
+		{"range that is too long", "123", "from=2020-01-01&to=2021-03-31", http.StatusBadRequest, "", ""},
+		{"blog not found", "456", "", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/blogs/"+test.blogID+"/stats?"+test.query, nil)
+			recorder := httptest.NewRecorder()
+			ctxt := e.NewContext(req, recorder)
+			ctxt.SetParamNames("id")
+			ctxt.SetParamValues(test.blogID)
+			err := blogAPI.GetBlogStats(ctxt)
+			if test.status != http.StatusOK {
+				var controllerError *weoscontroller.WeOSControllerError
+				if !errors.As(err, &controllerError) {
+					t.Fatalf("expected a controller error, got '%v'", err)
+				}
+				if controllerError.StatusCode != test.status {
+					t.Errorf("expected the status code to be %d, got %d", test.status, controllerError.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error getting blog stats '%s'", err)
+			}
+			if recorder.Code != http.StatusOK {
+				t.Errorf("expected response code to be %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if from.Format("2006-01-02") != test.from || to.Format("2006-01-02") != test.to {
+				t.Errorf("expected the stats from '%s' to '%s', got '%s' to '%s'", test.from, test.to, from.Format("2006-01-02"), to.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestRefreshBlog(t *testing.T) {
+	e := echo.New()
+	fetchedAt := time.Now()
+	var lastFetch api.FetchSummary
+	mockProjection := &ProjectionMock{
+		GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+			if id != "123" {
+				return nil, nil
+			}
+			return &api.Blog{
+				ID:               "123",
+				FeedURL:          "https://ak33m.com/feed.xml",
+				LastFetchedAt:    &fetchedAt,
+				LastFetch:        lastFetch,
+				FetchDiagnostics: []*api.FetchDiagnostic{{BlogID: "123", Problem: "item 2 could not be parsed"}},
+			}, nil
+		},
+	}
+	dispatcher := &DispatcherMock{
+		DispatchFunc: func(ctx context.Context, command *weos.Command) error {
+			if command.Type != "blog.refresh" {
+				t.Errorf("expected the blog to be refreshed, got command '%s'", command.Type)
+			}
+			//the projection is updated when the command is handled
+			lastFetch = api.FetchSummary{NewItems: 2, UnchangedItems: 8}
+			return nil
+		},
+	}
+	blogAPI := newTestAPI(mockProjection, dispatcher)
+	blogAPI.RefreshLimiter = api.NewRateLimiter(time.Minute)
+
+	req := httptest.NewRequest("POST", "/admin/blogs/123/refresh", nil)
+	recorder := httptest.NewRecorder()
+	ctxt := e.NewContext(req, recorder)
+	ctxt.SetParamNames("id")
+	ctxt.SetParamValues("123")
+	err := blogAPI.RefreshBlog(ctxt)
+	if err != nil {
+		t.Fatalf("unexpected error refreshing blog '%s'", err)
+	}
+	var summary *api.RefreshSummary
+	json.NewDecoder(recorder.Body).Decode(&summary)
+	if summary == nil {
+		t.Fatalf("expected a summary of the refresh to be returned")
+	}
+	if summary.NewItems != 2 || summary.UnchangedItems != 8 {
+		t.Errorf("expected %d new and %d unchanged items, got %d new and %d unchanged", 2, 8, summary.NewItems, summary.UnchangedItems)
+	}
+	if len(summary.Problems) != 1 || summary.Problems[0] != "item 2 could not be parsed" {
+		t.Errorf("expected the parse errors to be returned, got %v", summary.Problems)
+	}
+
+	t.Run("refreshed too soon", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/blogs/123/refresh", nil)
+		recorder := httptest.NewRecorder()
+		ctxt := e.NewContext(req, recorder)
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("123")
+		err := blogAPI.RefreshBlog(ctxt)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusTooManyRequests, controllerError.StatusCode)
+		}
+		if recorder.Header().Get("Retry-After") != "60" {
+			t.Errorf("expected Retry-After to be '%s', got '%s'", "60", recorder.Header().Get("Retry-After"))
+		}
+		if len(dispatcher.DispatchCalls()) != 1 {
+			t.Errorf("expected the blog to be refreshed once, got %d", len(dispatcher.DispatchCalls()))
+		}
+	})
+
+	t.Run("blog not found", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/blogs/456/refresh", nil)
+		ctxt := e.NewContext(req, httptest.NewRecorder())
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("456")
+		err := blogAPI.RefreshBlog(ctxt)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) {
+			t.Fatalf("expected a controller error, got '%v'", err)
+		}
+		if controllerError.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusNotFound, controllerError.StatusCode)
+		}
+	})
+}
+
+func TestReceivePingback(t *testing.T) {
+	e := echo.New()
+	var queued []*api.ReceivedMention
+	projection := &ProjectionMock{
+		GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+			if id == "123" {
+				return &api.Blog{ID: id}, nil
+			}
+			return nil, nil
+		},
+		QueueMentionFunc: func(mention *api.ReceivedMention) error {
+			queued = append(queued, mention)
+			return nil
+		},
+	}
+	blogAPI := newTestAPI(projection, &DispatcherMock{})
+	ping := `<?xml version="1.0"?><methodCall><methodName>pingback.ping</methodName><params>` +
+		`<param><value><string>https://example.com/reply</string></value></param>` +
+		`<param><value>https://ak33m.com/posts/viro-react/</value></param></params></methodCall>`
+
+	tests := []struct {
+		name     string
+		blogID   string
+		body     string
+		response string
+		queued   int
+	}{
+		{"pingback queued", "123", ping, "<string>Pingback from", 1},
+		{"blog not found", "456", ping, "<int>32</int>", 0},
+		{"invalid call", "123", "<methodCall>", "<int>0</int>", 0},
+		{"source isn't a web page", "123", strings.Replace(ping, "https://example.com/reply", "file:///etc/passwd", 1), "<int>0</int>", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queued = nil
+			req := httptest.NewRequest("POST", "/blogs/"+test.blogID+"/pingback", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "text/xml")
+			recorder := httptest.NewRecorder()
+			ctxt := e.NewContext(req, recorder)
+			ctxt.SetParamNames("id")
+			ctxt.SetParamValues(test.blogID)
+			if err := blogAPI.ReceivePingback(ctxt); err != nil {
+				t.Fatalf("unexpected error receiving pingback '%s'", err)
+			}
+			if recorder.Code != http.StatusOK {
+				t.Errorf("expected the status code to be %d, got %d", http.StatusOK, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), test.response) {
+				t.Errorf("expected the response to contain '%s', got '%s'", test.response, recorder.Body.String())
+			}
+			if len(queued) != test.queued {
+				t.Fatalf("expected %d mentions to be queued, got %d", test.queued, len(queued))
+			}
+			if test.queued == 1 {
+				if queued[0].BlogID != "123" || queued[0].Source != "https://example.com/reply" || queued[0].Target != "https://ak33m.com/posts/viro-react/" || queued[0].Type != api.MENTION_TYPE_PINGBACK {
+					t.Errorf("expected a pingback from '%s' to '%s', got %+v", "https://example.com/reply", "https://ak33m.com/posts/viro-react/", queued[0])
+				}
+			}
+		})
+	}
+
+	t.Run("webmention", func(t *testing.T) {
+		queued = nil
+		form := url.Values{"source": {"https://example.com/reply"}, "target": {"https://ak33m.com/posts/viro-react/"}}
+		req := httptest.NewRequest("POST", "/blogs/123/webmention", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		ctxt := e.NewContext(req, recorder)
+		ctxt.SetParamNames("id")
+		ctxt.SetParamValues("123")
+		if err := blogAPI.ReceiveWebmention(ctxt); err != nil {
+			t.Fatalf("unexpected error receiving webmention '%s'", err)
+		}
+		if recorder.Code != http.StatusAccepted {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusAccepted, recorder.Code)
+		}
+		if len(queued) != 1 || queued[0].Type != api.MENTION_TYPE_WEBMENTION {
+			t.Errorf("expected the webmention to be queued, got %d mentions", len(queued))
+		}
+	})
+}
+
+func TestBlogInbox(t *testing.T) {
+	e := echo.New()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating key '%s'", err)
+	}
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	//the fake fediverse server has the actors that send activities to the blog
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	alice := server.URL + "/users/alice"
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", api.ACTIVITY_CONTENT_TYPE)
+		json.NewEncoder(w).Encode(&api.Actor{
+			ID:        alice,
+			Type:      "Person",
+			Inbox:     alice + "/inbox",
+			Endpoints: &api.ActorEndpoints{SharedInbox: server.URL + "/inbox"},
+			PublicKey: &api.PublicKey{
+				ID:           alice + "#main-key",
+				Owner:        alice,
+				PublicKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+			},
+		})
+	})
+
+	//another server that serves an actor document claiming to be alice with its own key
+	mallory, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating key '%s'", err)
+	}
+	malloryPublic, _ := x509.MarshalPKIXPublicKey(&mallory.PublicKey)
+	spoofer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", api.ACTIVITY_CONTENT_TYPE)
+		json.NewEncoder(w).Encode(&api.Actor{
+			ID:    alice,
+			Type:  "Person",
+			Inbox: alice + "/inbox",
+			PublicKey: &api.PublicKey{
+				ID:           "http://" + r.Host + "/users/mallory#main-key",
+				Owner:        alice,
+				PublicKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: malloryPublic})),
+			},
+		})
+	}))
+	defer spoofer.Close()
+
+	actorID := "https://aggregator.example.com/blogs/123/actor"
+	dispatcher := &DispatcherMock{
+		DispatchFunc: func(ctx context.Context, command *weos.Command) error {
+			return nil
+		},
+	}
+	projection := &ProjectionMock{
+		GetActorFunc: func(blogID string) (*api.Actor, error) {
+			if blogID == "123" {
+				return &api.Actor{ID: actorID, Type: "Service"}, nil
+			}
+			return nil, nil
+		},
+	}
+	blogAPI := newTestAPI(projection, dispatcher)
+	blogAPI.PublicClient = server.Client()
+	follow := fmt.Sprintf(`{"id":"%[1]s/follows/1","type":"Follow","actor":"%[1]s","object":"%[2]s"}`, alice, actorID)
+	undo := fmt.Sprintf(`{"id":"%[1]s/undo/1","type":"Undo","actor":"%[1]s","object":%[2]s}`, alice, follow)
+	tests := []struct {
+		name       string
+		blogID     string
+		body       string
+		signed     string
+		statusCode int
+		dispatched int
+		follow     bool
+		spoofed    bool
+	}{
+		{"follow", "123", follow, follow, http.StatusAccepted, 1, true, false},
+		{"undo follow", "123", undo, undo, http.StatusAccepted, 1, false, false},
+		{"other activities are ignored", "123", fmt.Sprintf(`{"id":"%[1]s/likes/1","type":"Like","actor":"%[1]s","object":"https://aggregator.example.com/posts/1"}`, alice), "", http.StatusAccepted, 0, false, false},
+		{"body changed after signing", "123", strings.Replace(follow, "/follows/1", "/follows/2", 1), follow, http.StatusUnauthorized, 0, false, false},
+		{"activity of another actor", "123", strings.Replace(follow, `"actor":"`+alice, `"actor":"`+server.URL+"/users/bob", 1), "", http.StatusUnauthorized, 0, false, false},
+		{"unsigned", "123", follow, "-", http.StatusUnauthorized, 0, false, false},
+		{"key on another host than the actor", "123", follow, "", http.StatusUnauthorized, 0, false, true},
+		{"blog not found", "456", follow, follow, http.StatusNotFound, 0, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dispatched := len(dispatcher.DispatchCalls())
+			req := httptest.NewRequest("POST", "/blogs/"+test.blogID+"/inbox", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", api.ACTIVITY_CONTENT_TYPE)
+			switch {
+			case test.spoofed:
+				api.SignRequest(req, []byte(test.body), spoofer.URL+"/users/mallory#main-key", mallory)
+			case test.signed == "-":
+			case test.signed == "":
+				api.SignRequest(req, []byte(test.body), alice+"#main-key", key)
+			default:
+				api.SignRequest(req, []byte(test.signed), alice+"#main-key", key)
+			}
+			recorder := httptest.NewRecorder()
+			ctxt := e.NewContext(req, recorder)
+			ctxt.SetParamNames("id")
+			ctxt.SetParamValues(test.blogID)
+			err := blogAPI.BlogInbox(ctxt)
+			statusCode := recorder.Code
+			if err != nil {
+				var controllerError *weoscontroller.WeOSControllerError
+				if !errors.As(err, &controllerError) {
+					t.Fatalf("expected a controller error, got '%v'", err)
+				}
+				statusCode = controllerError.StatusCode
+			}
+			if statusCode != test.statusCode {
+				t.Errorf("expected the status code to be %d, got %d (%v)", test.statusCode, statusCode, err)
+			}
+			if len(dispatcher.DispatchCalls())-dispatched != test.dispatched {
+				t.Fatalf("expected %d commands to be dispatched, got %d", test.dispatched, len(dispatcher.DispatchCalls())-dispatched)
+			}
+			if test.dispatched == 1 {
+				var request *api.FediverseFollowRequest
+				json.Unmarshal(dispatcher.DispatchCalls()[dispatched].Command.Payload, &request)
+				if request.BlogID != "123" || request.Follow != test.follow || request.Follower.ActorID != alice {
+					t.Errorf("expected the follow of '%s' to be %t, got %+v", alice, test.follow, request)
+				}
+				if test.follow && (request.Follower.Inbox != alice+"/inbox" || request.Follower.SharedInbox != server.URL+"/inbox" || request.Follower.FollowID != alice+"/follows/1") {
+					t.Errorf("expected the inboxes of the follower to be saved, got %+v", request.Follower)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestSubscriptions(t *testing.T) {
+	e := echo.New()
+	dispatcher := &DispatcherMock{
+		DispatchFunc: func(ctx context.Context, command *weos.Command) error {
+			return nil
+		},
+	}
+	projection := &ProjectionMock{
+		GetDigestSubscriptionFunc: func(id string) (*api.DigestSubscription, error) {
+			if id == "sub1" {
+				return &api.DigestSubscription{ID: id, UserID: "user1"}, nil
+			}
+			return &api.DigestSubscription{ID: id, UserID: "user2"}, nil
+		},
+		GetDigestSubscriptionByTokenFunc: func(token string) (*api.DigestSubscription, error) {
+			if token == "token1" {
+				return &api.DigestSubscription{ID: "sub1", UserID: "user1"}, nil
+			}
+			return nil, nil
+		},
+	}
+	blogAPI := newTestAPI(projection, dispatcher)
+
+	t.Run("subscribe with the email from the token", func(t *testing.T) {
+		dispatched := len(dispatcher.DispatchCalls())
+		form := url.Values{"frequency": {"weekly"}, "followedBlogs": {"true"}, "categories": {"go", "rust"}}
+		req := httptest.NewRequest("POST", "/me/digests", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1", "email": "reader@example.com"}})
+		if err := blogAPI.SubscribeDigest(ctx); err != nil {
+			t.Fatalf("unexpected error subscribing '%s'", err)
+		}
+		if recorder.Code != http.StatusCreated {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusCreated, recorder.Code)
+		}
+		if len(dispatcher.DispatchCalls()) != dispatched+1 {
+			t.Fatalf("expected a command to be dispatched")
+		}
+		var request *api.SubscribeDigestRequest
+		json.Unmarshal(dispatcher.DispatchCalls()[dispatched].Command.Payload, &request)
+		if request.SubscriptionID == "" || request.UserID != "user1" || request.Email != "reader@example.com" || request.Frequency != "weekly" ||
+			!request.FollowedBlogs || len(request.Categories) != 2 {
+			t.Errorf("unexpected subscription %+v", request)
+		}
+	})
+
+	t.Run("subscribe with an invalid value for followed blogs", func(t *testing.T) {
+		dispatched := len(dispatcher.DispatchCalls())
+		req := httptest.NewRequest("POST", "/me/digests", strings.NewReader("frequency=daily&followedBlogs=maybe"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := e.NewContext(req, httptest.NewRecorder())
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1"}})
+		err := blogAPI.SubscribeDigest(ctx)
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) || controllerError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a bad request error, got '%v'", err)
+		}
+		if len(dispatcher.DispatchCalls()) != dispatched {
+			t.Errorf("expected no commands to be dispatched")
+		}
+	})
+
+	tests := []struct {
+		name       string
+		id         string
+		token      string
+		statusCode int
+		dispatched int
+	}{
+		{"unsubscribe", "sub1", "", http.StatusOK, 1},
+		{"unsubscribe from another user's digest", "sub2", "", http.StatusNotFound, 0},
+		{"unsubscribe link", "", "token1", http.StatusOK, 1},
+		{"unsubscribe link that was already used", "", "token2", http.StatusOK, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dispatched := len(dispatcher.DispatchCalls())
+			var err error
+			recorder := httptest.NewRecorder()
+			if test.token != "" {
+				ctx := e.NewContext(httptest.NewRequest("GET", "/digests/unsubscribe?token="+test.token, nil), recorder)
+				err = blogAPI.UnsubscribeDigestByToken(ctx)
+			} else {
+				ctx := e.NewContext(httptest.NewRequest("DELETE", "/me/digests/"+test.id, nil), recorder)
+				ctx.SetParamNames("id")
+				ctx.SetParamValues(test.id)
+				ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1"}})
+				err = blogAPI.UnsubscribeDigest(ctx)
+			}
+			statusCode := recorder.Code
+			if err != nil {
+				var controllerError *weoscontroller.WeOSControllerError
+				if !errors.As(err, &controllerError) {
+					t.Fatalf("expected a controller error, got '%v'", err)
+				}
+				statusCode = controllerError.StatusCode
+			}
+			if statusCode != test.statusCode {
+				t.Errorf("expected the status code to be %d, got %d", test.statusCode, statusCode)
+			}
+			if len(dispatcher.DispatchCalls())-dispatched != test.dispatched {
+				t.Fatalf("expected %d commands to be dispatched, got %d", test.dispatched, len(dispatcher.DispatchCalls())-dispatched)
+			}
+			if test.dispatched == 1 {
+				var request *api.UnsubscribeDigestRequest
+				json.Unmarshal(dispatcher.DispatchCalls()[dispatched].Command.Payload, &request)
+				if request.SubscriptionID != "sub1" {
+					t.Errorf("expected subscription '%s' to be unsubscribed, got '%s'", "sub1", request.SubscriptionID)
+				}
+			}
+		})
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	e := echo.New()
+	dispatcher := &DispatcherMock{
+		DispatchFunc: func(ctx context.Context, command *weos.Command) error {
+			return nil
+		},
+	}
+	projection := &ProjectionMock{
+		GetSavedSearchFunc: func(id string) (*api.SavedSearch, error) {
+			if id == "search1" {
+				return &api.SavedSearch{ID: id, UserID: "user1"}, nil
+			}
+			return &api.SavedSearch{ID: id, UserID: "user2"}, nil
+		},
+		GetSavedSearchMatchesFunc: func(searchID string, page int, limit int) ([]*api.SavedSearchMatch, int64, error) {
+			return []*api.SavedSearchMatch{{Post: &api.Post{Title: "Magento 2.4"}, Status: api.SEARCH_MATCH_NOTIFIED}}, 1, nil
+		},
+	}
+	blogAPI := newTestAPI(projection, dispatcher)
+	save := func(form url.Values) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest("POST", "/me/searches", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(req, recorder)
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1", "email": "analyst@example.com"}})
+		return recorder, blogAPI.SaveSearch(ctx)
+	}
+
+	t.Run("save search", func(t *testing.T) {
+		dispatched := len(dispatcher.DispatchCalls())
+		recorder, err := save(url.Values{"query": {"magento"}, "categories": {"php"}, "blogs": {"123"}, "authors": {"1", "2"}})
+		if err != nil {
+			t.Fatalf("unexpected error saving search '%s'", err)
+		}
+		if recorder.Code != http.StatusCreated {
+			t.Errorf("expected the status code to be %d, got %d", http.StatusCreated, recorder.Code)
+		}
+		if len(dispatcher.DispatchCalls()) != dispatched+1 {
+			t.Fatalf("expected a command to be dispatched")
+		}
+		var request *api.SaveSearchRequest
+		json.Unmarshal(dispatcher.DispatchCalls()[dispatched].Command.Payload, &request)
+		if request.SearchID == "" || request.UserID != "user1" || request.Query != "magento" || request.Notify != api.SEARCH_NOTIFY_IN_APP ||
+			len(request.Categories) != 1 || len(request.Blogs) != 1 || len(request.Authors) != 2 || request.Authors[1] != 2 || request.Email != "" {
+			t.Errorf("unexpected search %+v", request)
+		}
+	})
+
+	t.Run("email notifications without a mailer", func(t *testing.T) {
+		dispatched := len(dispatcher.DispatchCalls())
+		_, err := save(url.Values{"query": {"magento"}, "notify": {"email"}})
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) || controllerError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a bad request error, got '%v'", err)
+		}
+		if len(dispatcher.DispatchCalls()) != dispatched {
+			t.Errorf("expected no commands to be dispatched")
+		}
+	})
+
+	t.Run("email notifications with the email from the token", func(t *testing.T) {
+		blogAPI.Mailer = api.NewSMTPMailer(&api.SMTPConfig{Host: "127.0.0.1"})
+		defer func() { blogAPI.Mailer = nil }()
+		dispatched := len(dispatcher.DispatchCalls())
+		_, err := save(url.Values{"query": {"magento"}, "notify": {"email"}})
+		if err != nil {
+			t.Fatalf("unexpected error saving search '%s'", err)
+		}
+		var request *api.SaveSearchRequest
+		json.Unmarshal(dispatcher.DispatchCalls()[dispatched].Command.Payload, &request)
+		if request.Notify != api.SEARCH_NOTIFY_EMAIL || request.Email != "analyst@example.com" {
+			t.Errorf("expected the email from the token to be notified, got %+v", request)
+		}
+	})
+
+	t.Run("invalid author", func(t *testing.T) {
+		_, err := save(url.Values{"authors": {"jane"}})
+		var controllerError *weoscontroller.WeOSControllerError
+		if !errors.As(err, &controllerError) || controllerError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a bad request error, got '%v'", err)
+		}
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		ctx := e.NewContext(httptest.NewRequest("GET", "/me/searches/search1/matches?page=2&limit=5", nil), recorder)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues("search1")
+		ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1"}})
+		err := blogAPI.GetSavedSearchMatches(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error getting matches '%s'", err)
+		}
+		calls := projection.GetSavedSearchMatchesCalls()
+		if len(calls) != 1 || calls[0].SearchID != "search1" || calls[0].Page != 2 || calls[0].Limit != 5 {
+			t.Fatalf("expected the matches of the search to be requested, got %+v", calls)
+		}
+		var list *api.SavedSearchMatchList
+		json.Unmarshal(recorder.Body.Bytes(), &list)
+		if list.Total != 1 || len(list.Items) != 1 || list.Items[0].Post.Title != "Magento 2.4" {
+			t.Errorf("expected the match to be returned, got '%s'", recorder.Body.String())
+		}
+	})
+
+	tests := []struct {
+		name       string
+		id         string
+		statusCode int
+		dispatched int
+	}{
+		{"delete search", "search1", http.StatusOK, 1},
+		{"delete another user's search", "search2", http.StatusNotFound, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dispatched := len(dispatcher.DispatchCalls())
+			recorder := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest("DELETE", "/me/searches/"+test.id, nil), recorder)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(test.id)
+			ctx.Set("user", &jwt.Token{Claims: jwt.MapClaims{"sub": "user1"}})
+			err := blogAPI.DeleteSearch(ctx)
+			statusCode := recorder.Code
+			if err != nil {
+				var controllerError *weoscontroller.WeOSControllerError
+				if !errors.As(err, &controllerError) {
+					t.Fatalf("expected a controller error, got '%v'", err)
+				}
+				statusCode = controllerError.StatusCode
+			}
+			if statusCode != test.statusCode {
+				t.Errorf("expected the status code to be %d, got %d", test.statusCode, statusCode)
+			}
+			if len(dispatcher.DispatchCalls())-dispatched != test.dispatched {
+				t.Errorf("expected %d commands to be dispatched, got %d", test.dispatched, len(dispatcher.DispatchCalls())-dispatched)
+			}
+		})
+	}
+}
+
+func TestStreamPosts(t *testing.T) {
+	broker := api.NewPostBroker()
+	projection := &ProjectionMock{
+		SubscribePostsFunc: func(filter *api.PostStreamFilter) *api.PostListener {
+			return broker.Subscribe(filter)
+		},
+		UnsubscribePostsFunc: func(listener *api.PostListener) {
+			broker.Unsubscribe(listener)
+		},
+		GetPostsAfterFunc: func(eventID string, filter *api.PostStreamFilter, limit int) ([]*api.Post, bool, error) {
+			if eventID == "unknown" {
+				return nil, false, nil
+			}
+			return []*api.Post{{ID: "1", Title: "Missed Post", BlogID: "123", EventID: "event1"}}, true, nil
+		},
+	}
+	blogAPI := newTestAPI(projection, nil)
+	blogAPI.Stream = &api.StreamConfig{Heartbeat: 50 * time.Millisecond, MaxReplay: 100}
+	e := echo.New()
+	e.GET("/stream/posts", blogAPI.StreamPosts)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/stream/posts?blog_id=123", nil)
+	req.Header.Set("Last-Event-ID", "event0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error connecting to the stream '%s'", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("expected the content type to be '%s', got '%s'", "text/event-stream", contentType)
+	}
+	if calls := projection.GetPostsAfterCalls(); len(calls) != 1 || calls[0].EventID != "event0" || calls[0].Filter.BlogID != "123" || calls[0].Limit != 100 {
+		t.Fatalf("expected the posts after the last event to be requested, got %+v", calls)
+	}
+	reader := bufio.NewReader(resp.Body)
+	//readEvent reads the lines up to the blank line at the end of the event
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("unexpected error reading the stream '%s'", err)
+			}
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	event := readEvent()
+	if len(event) != 3 || event[0] != "id: event1" || event[1] != "event: post" || !strings.Contains(event[2], `"title":"Missed Post"`) {
+		t.Fatalf("expected the missed post to be sent first, got %v", event)
+	}
+	//the missed post isn't sent again and posts from other blogs aren't sent
+	broker.Publish(&api.Post{ID: "1", Title: "Missed Post", BlogID: "123", EventID: "event1"})
+	broker.Publish(&api.Post{ID: "2", Title: "Other Blog", BlogID: "456", EventID: "event2"})
+	broker.Publish(&api.Post{ID: "3", Title: "New Post", BlogID: "123", EventID: "event3"})
+	for {
+		event = readEvent()
+		if len(event) == 1 && event[0] == ": heartbeat" {
+			continue
+		}
+		break
+	}
+	if len(event) != 3 || event[0] != "id: event3" || !strings.Contains(event[2], `"title":"New Post"`) {
+		t.Fatalf("expected the new post to be sent, got %v", event)
+	}
+	if event = readEvent(); len(event) != 1 || event[0] != ": heartbeat" {
+		t.Errorf("expected a heartbeat, got %v", event)
+	}
+
+	cancel()
+	for i := 0; i < 100 && broker.Listeners() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if broker.Listeners() != 0 {
+		t.Errorf("expected the listener to be removed when the client disconnects")
+	}
+
+	//a heartbeat that isn't positive falls back to the default instead of making the ticker panic
+	blogAPI.Stream = &api.StreamConfig{}
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", server.URL+"/stream/posts", nil)
+	//the client is told to load the posts again when the posts it missed can't be sent
+	req.Header.Set("Last-Event-ID", "unknown")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error connecting to the stream '%s'", err)
+	}
+	defer resp.Body.Close()
+	reader = bufio.NewReader(resp.Body)
+	if event = readEvent(); len(event) != 2 || event[0] != "event: reset" {
+		t.Errorf("expected a reset event, got %v", event)
+	}
+	//the stream stays open until the client gives up
+	if _, err = reader.ReadString('\n'); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the stream to stay open, got '%v'", err)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("intervals that aren't positive fall back to the default", func(t *testing.T) {
+		t.Setenv("TRENDING_INTERVAL", "0s")
+		t.Setenv("TRENDING_WINDOW", "-1h")
+		config := api.NewTrendingConfig()
+		if config.Interval != 15*time.Minute {
+			t.Errorf("expected the interval to be %s, got %s", 15*time.Minute, config.Interval)
+		}
+		if config.Window != 30*24*time.Hour {
+			t.Errorf("expected the window to be %s, got %s", 30*24*time.Hour, config.Window)
+		}
+	})
+
+	t.Run("a job that panics keeps running", func(t *testing.T) {
+		var mutex sync.Mutex
+		var errs []string
+		logger := &LogMock{
+			ErrorfFunc: func(format string, args ...interface{}) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				errs = append(errs, fmt.Sprintf(format, args...))
+			},
+		}
+		runs := make(chan int, 10)
+		calls := 0
+		scheduler := api.NewScheduler(logger)
+		scheduler.AddJob(&api.Job{
+			Name:     "panics",
+			Interval: 10 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				calls++
+				runs <- calls
+				panic("something went wrong")
+			},
+		})
+		scheduler.AddJob(&api.Job{
+			Name: "no interval",
+			Run: func(ctx context.Context) error {
+				t.Error("expected the job without an interval not to run")
+				return nil
+			},
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		scheduler.Start(ctx)
+		for i := 1; i <= 2; i++ {
+			select {
+			case <-runs:
+			case <-time.After(time.Second):
+				t.Fatalf("expected the job to run again after it panicked")
+			}
+		}
+		cancel()
+		mutex.Lock()
+		defer mutex.Unlock()
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err, "panic: something went wrong") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected the panic to be logged, got %v", errs)
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := api.NewRateLimiter(time.Minute)
+	now := time.Date(2021, 3, 28, 17, 5, 53, 0, time.UTC)
+	if allowed, _ := limiter.Allow("127.0.0.1", now); !allowed {
+		t.Fatalf("expected the first use of the key to be allowed")
+	}
+	limiter.Prune(now.Add(30 * time.Second))
+	if allowed, wait := limiter.Allow("127.0.0.1", now.Add(30*time.Second)); allowed || wait != 30*time.Second {
+		t.Errorf("expected the key to still be limited for %s after pruning, got %v %s", 30*time.Second, allowed, wait)
+	}
+	limiter.Prune(now.Add(time.Minute))
+	if allowed, _ := limiter.Allow("127.0.0.1", now.Add(time.Minute)); !allowed {
+		t.Errorf("expected the key to be allowed once the interval has passed")
+	}
+}
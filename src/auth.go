@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	weoscontroller "github.com/wepala/weos-controller"
+)
+
+//ErrJWTKeyNotSet is returned at startup when there's no key to validate the tokens with
+var ErrJWTKeyNotSet = errors.New("JWT_KEY must be set for the tokens to be validated")
+
+//jwtKeySet checks that the config has a key, a certificate or a key set to validate the tokens with
+func (a *API) jwtKeySet() bool {
+	if a.Config == nil || a.Config.JWTConfig == nil {
+		return false
+	}
+	config := a.Config.JWTConfig
+	return config.Key != "" || len(config.SigningKeys) > 0 || len(config.Certificate) > 0 || config.CertificatePath != "" || config.JWKSUrl != ""
+}
+
+//AdminOnly only lets through users that have the admin role. The Authenticate middleware must run before this one
+func (a *API) AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if role, _ := a.claims(e)["role"].(string); role != "admin" {
+			return weoscontroller.NewControllerError("Admin access required", errors.New("the user is not an admin"), http.StatusForbidden)
+		}
+		return next(e)
+	}
+}
+
+//BlogOwner only lets through admins and users that have verified their claim to own the blog in the id path param. The
+//Authenticate middleware must run before this one
+func (a *API) BlogOwner(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if role, _ := a.claims(e)["role"].(string); role == "admin" {
+			return next(e)
+		}
+		userID := a.userID(e)
+		if userID != "" {
+			for _, projection := range a.Application.Projections() {
+				if projection, ok := projection.(Projection); ok {
+					owner, err := projection.IsBlogOwner(e.Param("id"), userID)
+					if err != nil {
+						return weoscontroller.NewControllerError("Error checking blog owner", err, http.StatusBadRequest)
+					}
+					if owner {
+						return next(e)
+					}
+				}
+			}
+		}
+		return weoscontroller.NewControllerError("Blog owner access required", errors.New("the user doesn't own the blog"), http.StatusForbidden)
+	}
+}
+
+//userID get the id of the user from the token that was validated by the Authenticate middleware
+func (a *API) userID(e echo.Context) string {
+	userID, _ := a.claims(e)["sub"].(string)
+	return userID
+}
+
+//claims get the claims from the token that was validated by the Authenticate middleware
+func (a *API) claims(e echo.Context) jwt.MapClaims {
+	contextKey := "user"
+	if a.Config != nil && a.Config.JWTConfig != nil && a.Config.JWTConfig.ContextKey != "" {
+		contextKey = a.Config.JWTConfig.ContextKey
+	}
+	if token, ok := e.Get(contextKey).(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			return claims
+		}
+	}
+	return jwt.MapClaims{}
+}
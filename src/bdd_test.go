@@ -62,7 +62,28 @@ var selectedPosts *api.PostList
 var currentDate time.Time
 
 func aPingbackUrlShouldBeGenerated() error {
-	return godog.ErrPending
+	if createdBlog == nil {
+		return fmt.Errorf("blog was not created by a previous step")
+	}
+	req := httptest.NewRequest("GET", "/blogs/"+createdBlog.ID, nil)
+	req = req.WithContext(context.TODO())
+	req.Close = true
+	rw := httptest.NewRecorder()
+	e.ServeHTTP(rw, req)
+	response = rw.Result()
+	defer response.Body.Close()
+	var blog *api.Blog
+	json.NewDecoder(response.Body).Decode(&blog)
+	if blog == nil {
+		return fmt.Errorf("expected the blog to be returned")
+	}
+	if blog.PingbackURL != "/blogs/"+createdBlog.ID+"/pingback" {
+		return fmt.Errorf("expected the pingback url to be '%s', got '%s'", "/blogs/"+createdBlog.ID+"/pingback", blog.PingbackURL)
+	}
+	if blog.WebmentionURL != "/blogs/"+createdBlog.ID+"/webmention" {
+		return fmt.Errorf("expected the webmention url to be '%s', got '%s'", "/blogs/"+createdBlog.ID+"/webmention", blog.WebmentionURL)
+	}
+	return nil
 }
 
 func aUserNamed(arg1 string) error {
@@ -604,6 +625,10 @@ func reset(*godog.Scenario) {
 
 func InitializeScenario(ctx *godog.ScenarioContext) {
 	err = os.Remove("test.db") //TODO hack to reset the database between runs
+	//the api specification gets the key of the tokens from the environment
+	if os.Getenv("JWT_KEY") == "" {
+		os.Setenv("JWT_KEY", "blog-aggregator-test-key")
+	}
 	e = echo.New()
 	blogAPI = &api.API{}
 	blogDataFetched := 0
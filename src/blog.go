@@ -0,0 +1,489 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
+	"github.com/wepala/weos"
+)
+
+//BlogAggregate is used to record admin changes to a blog as events. The blog is rebuilt from its existing events so that
+//new changes continue from the events recorded by the blog aggregator module
+type BlogAggregate struct {
+	blogaggregatormodule.Blog
+	//the content hashes of the items from the feed that have already been added as posts. Items with an empty hash were
+	//deleted
+	items   map[string]string
+	deleted bool
+	//the claims to own the blog by user id
+	claims map[string]*blogClaim
+	//the users following the blog
+	followers map[string]bool
+	//the fediverse actors following the blog
+	fediverseFollowers map[string]bool
+	//the sources that mention each post
+	mentions map[string]bool
+}
+
+type blogClaim struct {
+	token    string
+	verified bool
+}
+
+//NewBlogAggregate rebuild the blog from its events
+func NewBlogAggregate(id string, events []*weos.Event) (*BlogAggregate, error) {
+	blog := &BlogAggregate{
+		items:              make(map[string]string),
+		claims:             make(map[string]*blogClaim),
+		followers:          make(map[string]bool),
+		mentions:           make(map[string]bool),
+		fediverseFollowers: make(map[string]bool),
+	}
+	blog.ID = id
+	err := blog.ApplyChanges(events)
+	if err != nil {
+		return nil, err
+	}
+	return blog, nil
+}
+
+//ApplyChanges rebuilds the blog and keeps track of the items that have been added so they're not added again, and of
+//their content so that changes to them can be found
+func (b *BlogAggregate) ApplyChanges(changes []*weos.Event) error {
+	err := b.Blog.ApplyChanges(changes)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		switch change.Type {
+		case blogaggregatormodule.POST_CREATED, POST_UPDATED:
+			var post *blogaggregatormodule.PostCreatedPayload
+			err = json.Unmarshal(change.Payload, &post)
+			if err != nil {
+				return err
+			}
+			b.items[itemKey(&post.Item)] = contentHash(&post.Item)
+		case POST_DELETED:
+			var post *PostDeletedPayload
+			err = json.Unmarshal(change.Payload, &post)
+			if err != nil {
+				return err
+			}
+			b.items[post.ItemKey] = ""
+		case BLOG_DELETED:
+			b.deleted = true
+		case BLOG_CLAIMED:
+			var claim *BlogClaimedPayload
+			err = json.Unmarshal(change.Payload, &claim)
+			if err != nil {
+				return err
+			}
+			b.claims[claim.UserID] = &blogClaim{token: claim.Token}
+		case BLOG_CLAIM_VERIFIED:
+			var claim *BlogClaimVerifiedPayload
+			err = json.Unmarshal(change.Payload, &claim)
+			if err != nil {
+				return err
+			}
+			if b.claims[claim.UserID] != nil {
+				b.claims[claim.UserID].verified = true
+			}
+		case BLOG_FOLLOWED, BLOG_UNFOLLOWED:
+			var follower *BlogFollowedPayload
+			err = json.Unmarshal(change.Payload, &follower)
+			if err != nil {
+				return err
+			}
+			b.followers[follower.UserID] = change.Type == BLOG_FOLLOWED
+		case BLOG_FEDIVERSE_FOLLOWED, BLOG_FEDIVERSE_UNFOLLOWED:
+			var follower *BlogFediverseFollowedPayload
+			err = json.Unmarshal(change.Payload, &follower)
+			if err != nil {
+				return err
+			}
+			b.fediverseFollowers[follower.ActorID] = change.Type == BLOG_FEDIVERSE_FOLLOWED
+		case POST_MENTIONED:
+			var mention *PostMentionedPayload
+			err = json.Unmarshal(change.Payload, &mention)
+			if err != nil {
+				return err
+			}
+			b.mentions[mentionKey(mention.PostID, mention.ItemKey, mention.Source)] = true
+		case POST_MENTION_REMOVED:
+			var mention *PostMentionRemovedPayload
+			err = json.Unmarshal(change.Payload, &mention)
+			if err != nil {
+				return err
+			}
+			delete(b.mentions, mentionKey(mention.PostID, mention.ItemKey, mention.Source))
+			delete(b.mentions, mentionKey(mention.PostID, "", mention.Source))
+		}
+	}
+	return nil
+}
+
+//SetFullContent turns on (or off) downloading the full article for posts that only have a summary in the feed
+func (b *BlogAggregate) SetFullContent(enabled bool) error {
+	event, err := weos.NewBasicEvent(BLOG_FULL_CONTENT_SET, b.ID, "Blog", &BlogFullContentPayload{
+		Enabled: enabled,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//SetSendMentions turns on (or off) sending webmentions to the pages the blog's new posts link to
+func (b *BlogAggregate) SetSendMentions(enabled bool) error {
+	event, err := weos.NewBasicEvent(BLOG_SEND_MENTIONS_SET, b.ID, "Blog", &BlogSendMentionsPayload{
+		Enabled: enabled,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//Move changes the feed url (and homepage) of the blog when the feed has moved
+func (b *BlogAggregate) Move(feedURL string, link string) error {
+	payload := &blogaggregatormodule.BlogCreatedPayload{
+		Blog: blogaggregatormodule.Blog{
+			URL:     b.URL,
+			FeedURL: feedURL,
+		},
+	}
+	if link != "" {
+		payload.URL = link
+	}
+	event, err := weos.NewBasicEvent(blogaggregatormodule.BLOG_UPDATED, b.ID, "Blog", payload)
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//AddItems adds the items in the feed that haven't been added before as posts and updates the posts for items that
+//have changed since they were added. The number of items that were added, updated and unchanged is returned
+func (b *BlogAggregate) AddItems(feed *gofeed.Feed) (FetchSummary, error) {
+	var summary FetchSummary
+	for _, item := range feed.Items {
+		if hash, ok := b.items[itemKey(item)]; ok {
+			//posts that were deleted aren't added again
+			if hash == "" || hash == contentHash(item) {
+				summary.UnchangedItems++
+				continue
+			}
+			event, err := weos.NewBasicEvent(POST_UPDATED, b.ID, "Blog", &PostUpdatedPayload{
+				BlogID:     b.ID,
+				Item:       *item,
+				DetectedAt: time.Now(),
+			})
+			if err != nil {
+				return summary, err
+			}
+			b.NewChange(event)
+			err = b.ApplyChanges([]*weos.Event{event})
+			if err != nil {
+				return summary, err
+			}
+			summary.UpdatedItems++
+			continue
+		}
+		item.Published = item.PublishedParsed.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+		event, err := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, b.ID, "Blog", &blogaggregatormodule.PostCreatedPayload{
+			BlogID: b.ID,
+			Item:   *item,
+		})
+		if err != nil {
+			return summary, err
+		}
+		b.NewChange(event)
+		err = b.ApplyChanges([]*weos.Event{event})
+		if err != nil {
+			return summary, err
+		}
+		summary.NewItems++
+	}
+	return summary, nil
+}
+
+//Delete removes the blog and its posts. Purging removes them for good e.g. for a takedown request
+func (b *BlogAggregate) Delete(purge bool) error {
+	if b.deleted && !purge {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' has already been deleted", b.ID), "Blog", b.ID, nil)
+	}
+	event, err := weos.NewBasicEvent(BLOG_DELETED, b.ID, "Blog", &BlogDeletedPayload{
+		Purge: purge,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//DeletePost removes a post. The item the post was added from is remembered so that the post isn't added again
+func (b *BlogAggregate) DeletePost(postID string, key string, purge bool) error {
+	event, err := weos.NewBasicEvent(POST_DELETED, b.ID, "Blog", &PostDeletedPayload{
+		PostID:  postID,
+		ItemKey: key,
+		Purge:   purge,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//Claim records a user asking to own the blog. The token that has to be placed on the blog to verify the claim is
+//returned. Asking again gives the same token until the claim is verified
+func (b *BlogAggregate) Claim(userID string) (string, error) {
+	if userID == "" {
+		return "", weos.NewDomainError("a user is required to claim a blog", "Blog", b.ID, nil)
+	}
+	if claim := b.claims[userID]; claim != nil {
+		if claim.verified {
+			return "", weos.NewDomainError(fmt.Sprintf("blog '%s' is already owned by the user", b.ID), "Blog", b.ID, nil)
+		}
+		return claim.token, nil
+	}
+	token, err := newClaimToken()
+	if err != nil {
+		return "", err
+	}
+	event, err := weos.NewBasicEvent(BLOG_CLAIMED, b.ID, "Blog", &BlogClaimedPayload{
+		UserID: userID,
+		Token:  token,
+	})
+	if err != nil {
+		return "", err
+	}
+	b.NewChange(event)
+	return token, b.ApplyChanges([]*weos.Event{event})
+}
+
+//ClaimToken get the token for the user's claim. An empty token is returned if the user hasn't claimed the blog
+func (b *BlogAggregate) ClaimToken(userID string) string {
+	if claim := b.claims[userID]; claim != nil {
+		return claim.token
+	}
+	return ""
+}
+
+//VerifyClaim records that the token of the user's claim was found on the blog and the user now owns the blog
+func (b *BlogAggregate) VerifyClaim(userID string, method string) error {
+	claim := b.claims[userID]
+	if claim == nil {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' hasn't been claimed by the user", b.ID), "Blog", b.ID, nil)
+	}
+	if claim.verified {
+		return nil
+	}
+	event, err := weos.NewBasicEvent(BLOG_CLAIM_VERIFIED, b.ID, "Blog", &BlogClaimVerifiedPayload{
+		UserID:     userID,
+		Method:     method,
+		VerifiedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//Follow records a user following (or unfollowing) the blog. Nothing is recorded if the user already follows (or doesn't
+//follow) the blog
+func (b *BlogAggregate) Follow(userID string, follow bool) error {
+	if userID == "" {
+		return weos.NewDomainError("a user is required to follow a blog", "Blog", b.ID, nil)
+	}
+	if b.followers[userID] == follow {
+		return nil
+	}
+	eventType := BLOG_FOLLOWED
+	if !follow {
+		eventType = BLOG_UNFOLLOWED
+	}
+	event, err := weos.NewBasicEvent(eventType, b.ID, "Blog", &BlogFollowedPayload{
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//FediverseFollow records an ActivityPub actor following (or unfollowing) the blog. A follow from an actor that already
+//follows the blog is recorded again so that the follow is accepted again e.g. when the follower's server lost track of it
+func (b *BlogAggregate) FediverseFollow(follower *BlogFediverseFollowedPayload, follow bool) error {
+	if follower == nil || follower.ActorID == "" {
+		return weos.NewDomainError("an actor is required to follow a blog", "Blog", b.ID, nil)
+	}
+	eventType := BLOG_FEDIVERSE_FOLLOWED
+	if !follow {
+		if !b.fediverseFollowers[follower.ActorID] {
+			return nil
+		}
+		eventType = BLOG_FEDIVERSE_UNFOLLOWED
+		follower = &BlogFediverseFollowedPayload{ActorID: follower.ActorID}
+	} else if follower.Inbox == "" {
+		return weos.NewDomainError("the actor doesn't have an inbox", "Blog", b.ID, nil)
+	}
+	event, err := weos.NewBasicEvent(eventType, b.ID, "Blog", follower)
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//Mention records a page that links to a post on the blog. A mention from the same source replaces the earlier one
+func (b *BlogAggregate) Mention(mention *PostMentionedPayload) error {
+	event, err := weos.NewBasicEvent(POST_MENTIONED, b.ID, "Blog", mention)
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//HasMention checks if the source mentions the post. Mentions recorded before the item key was stored are found by the id
+//of the post
+func (b *BlogAggregate) HasMention(postID string, key string, source string) bool {
+	return b.mentions[mentionKey(postID, key, source)] || b.mentions[mentionKey(postID, "", source)]
+}
+
+//RemoveMention removes the mention of a post e.g. when the source no longer links to the post
+func (b *BlogAggregate) RemoveMention(postID string, key string, source string) error {
+	if !b.HasMention(postID, key, source) {
+		return weos.NewDomainError(fmt.Sprintf("post '%s' isn't mentioned by '%s'", postID, source), "Blog", b.ID, nil)
+	}
+	event, err := weos.NewBasicEvent(POST_MENTION_REMOVED, b.ID, "Blog", &PostMentionRemovedPayload{
+		PostID:  postID,
+		ItemKey: key,
+		Source:  source,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return b.ApplyChanges([]*weos.Event{event})
+}
+
+//mentionKey the key of the mention of a post by a source. The post is identified by the key of its item when there is one
+func mentionKey(postID string, key string, source string) string {
+	if key == "" {
+		key = postID
+	}
+	return key + " " + normalizeMentionURL(source)
+}
+
+//SetDescription changes the description of the blog
+func (b *BlogAggregate) SetDescription(description string) error {
+	event, err := weos.NewBasicEvent(BLOG_DESCRIPTION_SET, b.ID, "Blog", &BlogDescriptionPayload{
+		Description: description,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//HidePost hides (or shows) a post on the blog
+func (b *BlogAggregate) HidePost(postID string, key string, hidden bool) error {
+	event, err := weos.NewBasicEvent(POST_HIDDEN, b.ID, "Blog", &PostHiddenPayload{
+		PostID:  postID,
+		ItemKey: key,
+		Hidden:  hidden,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//SetPostCategories replaces the categories of a post on the blog
+func (b *BlogAggregate) SetPostCategories(postID string, key string, categories []string) error {
+	event, err := weos.NewBasicEvent(POST_CATEGORIES_SET, b.ID, "Blog", &PostCategoriesSetPayload{
+		PostID:     postID,
+		ItemKey:    key,
+		Categories: categories,
+	})
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//Fetched records a fetch of the blog's feed
+func (b *BlogAggregate) Fetched(payload *BlogFetchedPayload) error {
+	event, err := weos.NewBasicEvent(BLOG_FETCHED, b.ID, "Blog", payload)
+	if err != nil {
+		return err
+	}
+	b.NewChange(event)
+	return nil
+}
+
+//itemKey identifies an item in a feed using its guid, or its link when it doesn't have one
+func itemKey(item *gofeed.Item) string {
+	switch {
+	case item.GUID != "":
+		return item.GUID
+	case item.Link != "":
+		return item.Link
+	}
+	return item.Title
+}
+
+//contentHash is used to find items that changed since they were added. Only the parts of the item that are shown in the
+//post are hashed so that changes to e.g. the published date don't create a revision
+func contentHash(item *gofeed.Item) string {
+	hash := sha256.New()
+	for _, part := range []string{item.Title, item.Link, item.Description, item.Content} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+//blogCache keeps the blogs between refreshes so that polling a blog doesn't rebuild it from its whole history. A blog
+//is taken out while it's being refreshed so that it isn't changed by two refreshes at once
+type blogCache struct {
+	mutex sync.Mutex
+	blogs map[string]*BlogAggregate
+}
+
+func (c *blogCache) take(id string) *BlogAggregate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	blog := c.blogs[id]
+	delete(c.blogs, id)
+	return blog
+}
+
+func (c *blogCache) put(blog *BlogAggregate) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.blogs[blog.ID] = blog
+}
+
+func newBlogCache() *blogCache {
+	return &blogCache{
+		blogs: make(map[string]*BlogAggregate),
+	}
+}
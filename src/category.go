@@ -0,0 +1,55 @@
+package api
+
+import (
+	"github.com/wepala/weos"
+)
+
+//CategoryAggregate is used to record admin changes to a category (e.g. synonyms, merges) as events. The category slug is
+//used as the entity id since it's stable across projection rebuilds
+type CategoryAggregate struct {
+	weos.AggregateRoot
+}
+
+//NewCategoryAggregate setup the aggregate so that new changes continue from the existing events for the category
+func NewCategoryAggregate(slug string, events []*weos.Event) *CategoryAggregate {
+	category := &CategoryAggregate{}
+	category.ID = slug
+	for _, event := range events {
+		category.SequenceNo = event.Meta.SequenceNo
+	}
+	return category
+}
+
+func (c *CategoryAggregate) AddSynonym(synonym string) error {
+	event, err := weos.NewBasicEvent(CATEGORY_SYNONYM_ADDED, c.ID, "Category", &CategorySynonymPayload{
+		Synonym: synonym,
+	})
+	if err != nil {
+		return err
+	}
+	c.NewChange(event)
+	return nil
+}
+
+func (c *CategoryAggregate) RemoveSynonym(synonym string) error {
+	event, err := weos.NewBasicEvent(CATEGORY_SYNONYM_REMOVED, c.ID, "Category", &CategorySynonymPayload{
+		Synonym: synonym,
+	})
+	if err != nil {
+		return err
+	}
+	c.NewChange(event)
+	return nil
+}
+
+//Merge moves everything on this category to another category
+func (c *CategoryAggregate) Merge(into *Category) error {
+	event, err := weos.NewBasicEvent(CATEGORY_MERGED, c.ID, "Category", &CategoryMergedPayload{
+		Into: into.Slug,
+	})
+	if err != nil {
+		return err
+	}
+	c.NewChange(event)
+	return nil
+}
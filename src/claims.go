@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	//where the token of a claim was found
+	CLAIM_METHOD_META = "meta"
+	CLAIM_METHOD_FILE = "file"
+	CLAIM_METHOD_FEED = "feed"
+
+	CLAIM_STATUS_PENDING  = "pending"
+	CLAIM_STATUS_VERIFIED = "verified"
+
+	//the name of the meta tag on the homepage e.g. <meta name="blog-aggregator-verification" content="token">
+	claimMetaName = "blog-aggregator-verification"
+	//the well-known file that has the token
+	claimFilePath = "/.well-known/blog-aggregator-verification.txt"
+	//the largest page that is downloaded when looking for the token
+	maxClaimPageSize = 2 << 20
+)
+
+//ClaimVerifier checks that the person claiming a blog can change it. The token of the claim has to be in a meta tag on
+//the homepage, in the well-known file on the blog's host or anywhere in the feed
+type ClaimVerifier struct {
+	client *http.Client
+}
+
+//Verify look for the token on the blog. The method is where the token was found
+func (v *ClaimVerifier) Verify(ctx context.Context, homepage string, feedURL string, token string) (string, error) {
+	var problems []string
+	if homepage != "" {
+		page, err := v.get(ctx, homepage)
+		if err == nil {
+			if claimMetaToken(page) == token {
+				return CLAIM_METHOD_META, nil
+			}
+			problems = append(problems, "the meta tag isn't on the homepage")
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+	//the file is looked for on the host of the homepage and the host of the feed when the feed is hosted elsewhere
+	checked := make(map[string]bool)
+	for _, link := range []string{homepage, feedURL} {
+		base, err := url.Parse(link)
+		if err != nil || base.Host == "" {
+			continue
+		}
+		fileURL := (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: claimFilePath}).String()
+		if checked[fileURL] {
+			continue
+		}
+		checked[fileURL] = true
+		file, err := v.get(ctx, fileURL)
+		if err == nil {
+			for _, line := range strings.Split(string(file), "\n") {
+				if strings.TrimSpace(line) == token {
+					return CLAIM_METHOD_FILE, nil
+				}
+			}
+			problems = append(problems, fmt.Sprintf("the token isn't in '%s'", fileURL))
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+	if feedURL != "" {
+		feed, err := v.get(ctx, feedURL)
+		if err == nil {
+			if bytes.Contains(feed, []byte(token)) {
+				return CLAIM_METHOD_FEED, nil
+			}
+			problems = append(problems, "the token isn't in the feed")
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+	return "", fmt.Errorf("the token was not found: %s", strings.Join(problems, ", "))
+}
+
+func (v *ClaimVerifier) get(ctx context.Context, link string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := v.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download '%s'", link)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status '%d' downloading '%s'", response.StatusCode, link)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxClaimPageSize))
+}
+
+//claimMetaToken get the token from the verification meta tag in the page
+func claimMetaToken(page []byte) string {
+	document, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return ""
+	}
+	var token string
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.DataAtom == atom.Meta && strings.EqualFold(attribute(node, "name"), claimMetaName) {
+			token = strings.TrimSpace(attribute(node, "content"))
+			return
+		}
+		for child := node.FirstChild; child != nil && token == ""; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	return token
+}
+
+//newClaimToken generates the token that has to be placed on the blog
+func newClaimToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return "blog-aggregator-" + hex.EncodeToString(token), nil
+}
+
+func NewClaimVerifier(client *http.Client) *ClaimVerifier {
+	return &ClaimVerifier{
+		client: client,
+	}
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/wepala/weos"
+)
+
+func AddCategorySynonymCommand(categoryID uint, synonym string) *weos.Command {
+	payload := &CategorySynonymRequest{
+		CategoryID: categoryID,
+		Synonym:    synonym,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "category.add_synonym",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func RemoveCategorySynonymCommand(categoryID uint, synonym string) *weos.Command {
+	payload := &CategorySynonymRequest{
+		CategoryID: categoryID,
+		Synonym:    synonym,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "category.remove_synonym",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func MergeCategoryCommand(categoryID uint, intoID uint) *weos.Command {
+	payload := &MergeCategoryRequest{
+		CategoryID: categoryID,
+		IntoID:     intoID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "category.merge",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func SetBlogFullContentCommand(blogID string, enabled bool) *weos.Command {
+	payload := &BlogFullContentRequest{
+		BlogID:  blogID,
+		Enabled: enabled,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.set_full_content",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func SetBlogSendMentionsCommand(blogID string, enabled bool) *weos.Command {
+	payload := &BlogSendMentionsRequest{
+		BlogID:  blogID,
+		Enabled: enabled,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.set_send_mentions",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func RefreshBlogCommand(blogID string) *weos.Command {
+	payload := &RefreshBlogRequest{
+		BlogID: blogID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.refresh",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func DeleteBlogCommand(blogID string, purge bool) *weos.Command {
+	payload := &DeleteBlogRequest{
+		BlogID: blogID,
+		Purge:  purge,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.delete",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func DeletePostCommand(postID string, purge bool) *weos.Command {
+	payload := &DeletePostRequest{
+		PostID: postID,
+		Purge:  purge,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "post.delete",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func ClaimBlogCommand(blogID string, userID string) *weos.Command {
+	payload := &ClaimBlogRequest{
+		BlogID: blogID,
+		UserID: userID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.claim",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func VerifyBlogClaimCommand(blogID string, userID string) *weos.Command {
+	payload := &ClaimBlogRequest{
+		BlogID: blogID,
+		UserID: userID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.verify_claim",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func UpdateBlogCommand(blogID string, description string) *weos.Command {
+	payload := &UpdateBlogRequest{
+		BlogID:      blogID,
+		Description: description,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.update",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func HidePostCommand(blogID string, postID string, hidden bool) *weos.Command {
+	payload := &HidePostRequest{
+		BlogID: blogID,
+		PostID: postID,
+		Hidden: hidden,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "post.hide",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func SetPostCategoriesCommand(blogID string, postID string, categories []string) *weos.Command {
+	payload := &SetPostCategoriesRequest{
+		BlogID:     blogID,
+		PostID:     postID,
+		Categories: categories,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "post.set_categories",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func FollowBlogCommand(blogID string, userID string, follow bool) *weos.Command {
+	payload := &FollowBlogRequest{
+		BlogID: blogID,
+		UserID: userID,
+		Follow: follow,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.follow",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func FediverseFollowCommand(blogID string, follower *BlogFediverseFollowedPayload, follow bool) *weos.Command {
+	payload := &FediverseFollowRequest{
+		BlogID:   blogID,
+		Follower: follower,
+		Follow:   follow,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.fediverse_follow",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func ReceiveMentionCommand(blogID string, source string, target string, mentionType string) *weos.Command {
+	payload := &ReceiveMentionRequest{
+		BlogID: blogID,
+		Source: source,
+		Target: target,
+		Type:   mentionType,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "blog.receive_mention",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func SubscribeDigestCommand(subscriptionID string, userID string, subscription *DigestSubscriptionRequest) *weos.Command {
+	payload := &SubscribeDigestRequest{
+		SubscriptionID: subscriptionID,
+		UserID:         userID,
+	}
+	if subscription != nil {
+		payload.DigestSubscriptionRequest = *subscription
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "digest.subscribe",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func UnsubscribeDigestCommand(subscriptionID string) *weos.Command {
+	payload := &UnsubscribeDigestRequest{
+		SubscriptionID: subscriptionID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "digest.unsubscribe",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func SaveSearchCommand(searchID string, userID string, search *SavedSearchRequest) *weos.Command {
+	payload := &SaveSearchRequest{
+		SearchID: searchID,
+		UserID:   userID,
+	}
+	if search != nil {
+		payload.SavedSearchRequest = *search
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "saved_search.create",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
+
+func DeleteSearchCommand(searchID string) *weos.Command {
+	payload := &DeleteSearchRequest{
+		SearchID: searchID,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	return &weos.Command{
+		Type:    "saved_search.delete",
+		Payload: payloadJson,
+		Metadata: weos.CommandMetadata{
+			Version: 1,
+		},
+	}
+}
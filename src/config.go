@@ -0,0 +1,228 @@
+package api
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//TrendingConfig controls how posts are ranked when they are sorted by trending. The score is the view count decayed by
+//the age of the post i.e. (views + 1) / (age in hours + Offset) ^ Gravity
+type TrendingConfig struct {
+	Gravity  float64       //how quickly posts fall as they get older
+	Offset   float64       //hours added to the age of a post so that brand new posts don't dominate
+	Window   time.Duration //posts older than this are no longer trending
+	Interval time.Duration //how often the scores are recalculated
+}
+
+//NewTrendingConfig get the trending config from the environment, falling back to the defaults
+func NewTrendingConfig() *TrendingConfig {
+	return &TrendingConfig{
+		Gravity:  envFloat("TRENDING_GRAVITY", 1.8),
+		Offset:   envFloat("TRENDING_OFFSET", 2),
+		Window:   envDuration("TRENDING_WINDOW", 30*24*time.Hour),
+		Interval: envDuration("TRENDING_INTERVAL", 15*time.Minute),
+	}
+}
+
+//FetcherConfig controls how politely the aggregator fetches from the sites of the blogs
+type FetcherConfig struct {
+	UserAgent    string
+	Timeout      time.Duration
+	HostInterval time.Duration //the least time between requests to the same host
+	HostLimit    int           //the most requests that can be made to the same host at a time
+	RobotsTTL    time.Duration //how long robots.txt is cached
+	MaxBackoff   time.Duration //the longest a host is skipped after it responds with 503 or 429
+}
+
+//NewFetcherConfig get the fetcher config from the environment, falling back to the defaults
+func NewFetcherConfig() *FetcherConfig {
+	return &FetcherConfig{
+		UserAgent:    envString("FETCHER_USER_AGENT", "BlogAggregator/1.0 (+https://github.com/wepala/blog-aggregator-api)"),
+		Timeout:      envDuration("FETCHER_TIMEOUT", 10*time.Second),
+		HostInterval: envDuration("FETCHER_HOST_INTERVAL", time.Second),
+		HostLimit:    envInt("FETCHER_HOST_LIMIT", 2),
+		RobotsTTL:    envDuration("FETCHER_ROBOTS_TTL", 24*time.Hour),
+		MaxBackoff:   envDuration("FETCHER_MAX_BACKOFF", 6*time.Hour),
+	}
+}
+
+//HealthConfig is the policy used to decide if a blog is active, stale, failing or dead. Polling is paused for blogs with
+//a status in PauseStatuses
+type HealthConfig struct {
+	FailingAfter   int           //the number of fetches in a row that fail before a blog is failing
+	StaleAfter     time.Duration //how long without a new post before a blog is stale
+	DeadAfter      time.Duration //how long a blog can fail before it's dead
+	AbandonedAfter time.Duration //how long without a new post before a blog is dead
+	PauseStatuses  []string
+	PausedRetry    time.Duration //how long before a blog that isn't polled is fetched again to check if it came back
+	Interval       time.Duration //how often the statuses are updated
+}
+
+//NewHealthConfig get the health policy from the environment, falling back to the defaults
+func NewHealthConfig() *HealthConfig {
+	return &HealthConfig{
+		FailingAfter:   envInt("HEALTH_FAILING_AFTER", 3),
+		StaleAfter:     envDuration("HEALTH_STALE_AFTER", 90*24*time.Hour),
+		DeadAfter:      envDuration("HEALTH_DEAD_AFTER", 30*24*time.Hour),
+		AbandonedAfter: envDuration("HEALTH_ABANDONED_AFTER", 365*24*time.Hour),
+		PauseStatuses:  envList("HEALTH_PAUSE_STATUSES", []string{BLOG_STATUS_DEAD}),
+		PausedRetry:    envDuration("HEALTH_PAUSED_RETRY", 7*24*time.Hour),
+		Interval:       envDuration("HEALTH_INTERVAL", 15*time.Minute),
+	}
+}
+
+//WebmentionConfig controls how the webmentions for the links in posts are sent
+type WebmentionConfig struct {
+	MaxAttempts int           //the most times a webmention is tried before it fails
+	RetryDelay  time.Duration //how long before a webmention that failed is tried again, it doubles each attempt
+	Interval    time.Duration //how often the webmentions that are due are sent
+}
+
+//NewWebmentionConfig get the webmention config from the environment, falling back to the defaults
+func NewWebmentionConfig() *WebmentionConfig {
+	return &WebmentionConfig{
+		MaxAttempts: envInt("WEBMENTION_MAX_ATTEMPTS", 5),
+		RetryDelay:  envDuration("WEBMENTION_RETRY_DELAY", 15*time.Minute),
+		Interval:    envDuration("WEBMENTION_INTERVAL", time.Minute),
+	}
+}
+
+//FederationConfig controls how the activities of the blogs are delivered to their fediverse followers
+type FederationConfig struct {
+	MaxAttempts int           //the most times an activity is delivered before it fails
+	RetryDelay  time.Duration //how long before an activity that failed is delivered again, it doubles each attempt
+	Interval    time.Duration //how often the activities that are due are delivered
+}
+
+//NewFederationConfig get the federation config from the environment, falling back to the defaults
+func NewFederationConfig() *FederationConfig {
+	return &FederationConfig{
+		MaxAttempts: envInt("ACTIVITYPUB_MAX_ATTEMPTS", 8),
+		RetryDelay:  envDuration("ACTIVITYPUB_RETRY_DELAY", 5*time.Minute),
+		Interval:    envDuration("ACTIVITYPUB_DELIVERY_INTERVAL", time.Minute),
+	}
+}
+
+//SMTPConfig is the SMTP server emails (e.g. the digests) are sent through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string //emails are sent without authenticating when the username is empty
+	Password string
+	From     string        //the address the emails are sent from
+	Timeout  time.Duration //how long sending an email can take
+}
+
+//NewSMTPConfig get the SMTP config from the environment. Nil is returned when SMTP_HOST isn't set
+func NewSMTPConfig() *SMTPConfig {
+	host := envString("SMTP_HOST", "")
+	if host == "" {
+		return nil
+	}
+	return &SMTPConfig{
+		Host:     host,
+		Port:     envInt("SMTP_PORT", 587),
+		Username: envString("SMTP_USERNAME", ""),
+		Password: envString("SMTP_PASSWORD", ""),
+		From:     envString("SMTP_FROM", "digest@"+host),
+		Timeout:  envDuration("SMTP_TIMEOUT", 30*time.Second),
+	}
+}
+
+//DigestConfig controls how the email digests are sent
+type DigestConfig struct {
+	MaxPosts   int           //the most posts in a digest
+	RetryDelay time.Duration //how long before a digest that couldn't be sent is tried again
+	Interval   time.Duration //how often the digests that are due are sent
+}
+
+//NewDigestConfig get the digest config from the environment, falling back to the defaults
+func NewDigestConfig() *DigestConfig {
+	return &DigestConfig{
+		MaxPosts:   envInt("DIGEST_MAX_POSTS", 30),
+		RetryDelay: envDuration("DIGEST_RETRY_DELAY", time.Hour),
+		Interval:   envDuration("DIGEST_INTERVAL", 15*time.Minute),
+	}
+}
+
+//SearchAlertConfig controls how the webhooks and emails for new posts that match saved searches are sent
+type SearchAlertConfig struct {
+	MaxAttempts int           //the most times a notification is sent before it fails
+	RetryDelay  time.Duration //how long before a notification that failed is sent again, it doubles each attempt
+	Interval    time.Duration //how often the notifications that are due are sent
+	Timeout     time.Duration //how long a webhook can take
+}
+
+//NewSearchAlertConfig get the saved search alert config from the environment, falling back to the defaults
+func NewSearchAlertConfig() *SearchAlertConfig {
+	return &SearchAlertConfig{
+		MaxAttempts: envInt("SEARCH_ALERT_MAX_ATTEMPTS", 5),
+		RetryDelay:  envDuration("SEARCH_ALERT_RETRY_DELAY", 5*time.Minute),
+		Interval:    envDuration("SEARCH_ALERT_INTERVAL", time.Minute),
+		Timeout:     envDuration("SEARCH_ALERT_TIMEOUT", 10*time.Second),
+	}
+}
+
+//StreamConfig controls the stream of new posts
+type StreamConfig struct {
+	Heartbeat time.Duration //how often a comment is sent to keep idle connections open
+	MaxReplay int           //the most missed posts that are sent when a client resumes
+}
+
+//NewStreamConfig get the stream config from the environment, falling back to the defaults
+func NewStreamConfig() *StreamConfig {
+	config := &StreamConfig{
+		Heartbeat: envDuration("STREAM_HEARTBEAT", 15*time.Second),
+		MaxReplay: envInt("STREAM_MAX_REPLAY", 500),
+	}
+	if config.MaxReplay <= 0 {
+		config.MaxReplay = 500
+	}
+	return config
+}
+
+func envFloat(name string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+//envDuration get a duration. Durations that aren't positive are ignored since they'd make tickers panic
+func envDuration(name string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func envInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func envString(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+//envList get a comma separated list. An empty list can be set with "none"
+func envList(name string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" && item != "none" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
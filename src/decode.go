@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	xmlDeclaration = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([^"']+)["']`)
+	xmlEntity      = regexp.MustCompile(`&(#[0-9]+;|#[xX][0-9a-fA-F]+;|[A-Za-z][A-Za-z0-9]*;)?`)
+	feedItem       = regexp.MustCompile(`(?s)<(item|entry)[\s>].*?</(item|entry)>`)
+	cdataSection   = regexp.MustCompile(`(?s)<!\[CDATA\[.*?\]\]>`)
+)
+
+//xmlEntities are the entities that don't have to be declared in XML
+var xmlEntities = map[string]bool{
+	"amp":  true,
+	"lt":   true,
+	"gt":   true,
+	"quot": true,
+	"apos": true,
+}
+
+//decodeFeed converts a feed to UTF-8 and repairs the common problems that stop XML feeds from being parsed. The problems
+//that were found are returned so they can be recorded on the blog
+func decodeFeed(body []byte, contentType string) ([]byte, []string) {
+	body, problems := toUTF8(body, contentType)
+	if isJSON(body) {
+		return body, problems
+	}
+	return repairXML(body, problems)
+}
+
+//toUTF8 detects the character set of the feed from the BOM, the Content-Type header, the XML declaration and the bytes
+//in the feed, in that order, and transcodes the feed to UTF-8
+func toUTF8(body []byte, contentType string) ([]byte, []string) {
+	var problems []string
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		body = body[3:]
+		enc = unicode.UTF8
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}), bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+	if enc == nil {
+		var headerLabel, declaredLabel string
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			headerLabel = strings.ToLower(params["charset"])
+		}
+		if match := xmlDeclaration.FindSubmatch(body[:Min(len(body), 1024)]); match != nil {
+			declaredLabel = strings.ToLower(string(match[1]))
+		}
+		label := headerLabel
+		if label == "" {
+			label = declaredLabel
+		}
+		if headerLabel != "" && declaredLabel != "" && headerLabel != declaredLabel {
+			problems = append(problems, fmt.Sprintf("the charset in the Content-Type header '%s' doesn't match the XML declaration '%s'", headerLabel, declaredLabel))
+			//servers often add a default charset to the header so a declared UTF-8 feed that is valid is trusted
+			if utf8.Valid(body) && (headerLabel == "utf-8" || declaredLabel == "utf-8") {
+				label = "utf-8"
+			}
+		}
+		if label == "" {
+			label = "utf-8"
+		}
+		var err error
+		if enc, err = htmlindex.Get(label); err != nil {
+			problems = append(problems, fmt.Sprintf("unknown charset '%s'", label))
+			enc = unicode.UTF8
+		}
+		//the most common mistake is a Windows-1252 feed that is served as UTF-8
+		if enc == unicode.UTF8 && !utf8.Valid(body) {
+			problems = append(problems, "the feed is not valid UTF-8, it was decoded as Windows-1252")
+			enc = charmap.Windows1252
+		}
+	}
+	if enc != unicode.UTF8 {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("error decoding the feed '%s'", err))
+		} else {
+			body = decoded
+		}
+	}
+	//the parser would decode the feed again if the declaration still had the original charset
+	if match := xmlDeclaration.FindSubmatchIndex(body); match != nil {
+		body = append(append(append([]byte{}, body[:match[2]]...), "UTF-8"...), body[match[3]:]...)
+	}
+	return body, problems
+}
+
+//repairXML removes the characters that aren't allowed in XML and fixes entities that aren't declared
+func repairXML(body []byte, problems []string) ([]byte, []string) {
+	if !utf8.Valid(body) {
+		body = bytes.ToValidUTF8(body, []byte("�"))
+		problems = append(problems, "invalid UTF-8 characters were replaced")
+	}
+	controlCharacters := 0
+	body = bytes.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			controlCharacters++
+			return -1
+		}
+		return r
+	}, body)
+	if controlCharacters > 0 {
+		problems = append(problems, fmt.Sprintf("%d control characters that aren't allowed in XML were removed", controlCharacters))
+	}
+	//CDATA sections are copied as is since entities aren't used in them
+	var repaired bytes.Buffer
+	ampersands := 0
+	undeclared := make(map[string]bool)
+	var undeclaredNames []string
+	last := 0
+	fix := func(text []byte) {
+		repaired.Write(xmlEntity.ReplaceAllFunc(text, func(entity []byte) []byte {
+			if len(entity) == 1 {
+				ampersands++
+				return []byte("&amp;")
+			}
+			name := string(entity[1 : len(entity)-1])
+			if name[0] == '#' || xmlEntities[name] {
+				return entity
+			}
+			if !undeclared[name] {
+				undeclared[name] = true
+				undeclaredNames = append(undeclaredNames, "&"+name+";")
+			}
+			//HTML entities (e.g. &nbsp;) are the most common undeclared entities
+			if decoded := html.UnescapeString(string(entity)); decoded != string(entity) {
+				var references strings.Builder
+				for _, r := range decoded {
+					fmt.Fprintf(&references, "&#%d;", r)
+				}
+				return []byte(references.String())
+			}
+			return append([]byte("&amp;"), entity[1:]...)
+		}))
+	}
+	for _, section := range cdataSection.FindAllIndex(body, -1) {
+		fix(body[last:section[0]])
+		repaired.Write(body[section[0]:section[1]])
+		last = section[1]
+	}
+	fix(body[last:])
+	if ampersands > 0 {
+		problems = append(problems, fmt.Sprintf("%d ampersands that weren't escaped were escaped", ampersands))
+	}
+	if len(undeclaredNames) > 0 {
+		problems = append(problems, fmt.Sprintf("undeclared entities were replaced %s", strings.Join(undeclaredNames, ", ")))
+	}
+	return repaired.Bytes(), problems
+}
+
+//salvageFeed parses the items of a feed one at a time so that a broken item doesn't stop the rest of the feed from
+//being added
+func salvageFeed(body []byte) (*gofeed.Feed, []string, error) {
+	items := feedItem.FindAllIndex(body, -1)
+	if len(items) == 0 {
+		return nil, nil, fmt.Errorf("no items found")
+	}
+	prefix := body[:items[0][0]]
+	suffix := body[items[len(items)-1][1]:]
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(append(append([]byte{}, prefix...), suffix...)))
+	if err != nil {
+		return nil, nil, err
+	}
+	var problems []string
+	for i, item := range items {
+		document := append(append(append([]byte{}, prefix...), body[item[0]:item[1]]...), suffix...)
+		itemFeed, err := gofeed.NewParser().Parse(bytes.NewReader(document))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("item %d could not be parsed '%s'", i+1, err))
+			continue
+		}
+		//the parser skips over broken elements so an item without a link or id is one where the details were lost
+		if len(itemFeed.Items) != 1 || (itemFeed.Items[0].Link == "" && itemFeed.Items[0].GUID == "") {
+			problems = append(problems, fmt.Sprintf("item %d could not be parsed", i+1))
+			continue
+		}
+		feed.Items = append(feed.Items, itemFeed.Items...)
+	}
+	if len(feed.Items) == 0 {
+		return nil, problems, fmt.Errorf("none of the items could be parsed")
+	}
+	return feed, problems, nil
+}
+
+func isJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var ErrNonPublicAddress = errors.New("the address isn't public")
+
+//nonPublicNetworks are the addresses that can't be reached from the internet e.g. loopback, private networks and
+//link-local addresses (which include the metadata endpoints of cloud providers)
+var nonPublicNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+//publicIP checks that the ip can be reached from the internet
+func publicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+//publicAddressControl refuses connections to addresses that aren't public. It's checked after the host is resolved, for
+//each address that is tried, so a host (or a redirect) that resolves to the aggregator's own network is refused too
+func publicAddressControl(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w '%s'", ErrNonPublicAddress, host)
+	}
+	return nil
+}
+
+//NewPublicTransport get a transport that only connects to public addresses. It's used for requests to urls that come from
+//outside the aggregator (e.g. blogs, the sources of mentions, webmention endpoints, fediverse servers and webhooks) so
+//they can't be used to reach services on the aggregator's network. Proxies aren't used since they'd be on a private
+//address
+func NewPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicAddressControl,
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+//NewPublicClient get an http client that only connects to public addresses (see NewPublicTransport)
+func NewPublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: NewPublicTransport(),
+		Timeout:   timeout,
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	//the number of subscriptions that are sent a digest each time the job runs
+	digestBatchSize = 50
+)
+
+var ErrDigestsDisabled = errors.New("PUBLIC_URL must be set for digests to have unsubscribe links")
+
+var digestFuncs = map[string]interface{}{
+	"date": func(date time.Time) string {
+		if date.IsZero() {
+			return ""
+		}
+		return date.Format("Jan 2, 2006")
+	},
+}
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Funcs(digestFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: sans-serif; max-width: 640px; margin: 0 auto;">
+<h1 style="font-size: 1.4em;">{{.Subject}}</h1>
+{{range .Posts}}<div style="margin-bottom: 1.5em;">
+<h2 style="font-size: 1.1em; margin-bottom: 0.2em;"><a href="{{.Link}}">{{.Title}}</a></h2>
+<div style="color: #666; font-size: 0.9em;">{{with .Blog}}{{.Title}} &middot; {{end}}{{date .PublishDate}}</div>
+{{with .Excerpt}}<p>{{.}}</p>{{end}}
+</div>
+{{end}}{{with .More}}<p>And {{.}} more posts.</p>
+{{end}}<p style="color: #666; font-size: 0.8em;">You're getting this email because you subscribed to a {{.Subscription.Frequency}} digest. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+</body>
+</html>
+`))
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest").Funcs(digestFuncs).Parse(`{{.Subject}}
+
+{{range .Posts}}{{.Title}}
+{{with .Blog}}{{.Title}} - {{end}}{{date .PublishDate}}
+{{.Link}}
+{{with .Excerpt}}
+{{.}}
+{{end}}
+{{end}}{{with .More}}And {{.}} more posts.
+
+{{end}}You're getting this email because you subscribed to a {{.Subscription.Frequency}} digest. Unsubscribe: {{.UnsubscribeURL}}
+`))
+
+//Digest is the email with the posts added since the last digest was sent to the subscription
+type Digest struct {
+	Subscription *DigestSubscription
+	Posts        []*Post
+	//the number of posts that were added, there could be more than the posts in the digest
+	Total          int64
+	UnsubscribeURL string
+}
+
+func (d *Digest) Subject() string {
+	period := "day"
+	if d.Subscription.Frequency == DIGEST_WEEKLY {
+		period = "week"
+	}
+	if d.Total == 1 {
+		return fmt.Sprintf("1 new post this %s", period)
+	}
+	return fmt.Sprintf("%d new posts this %s", d.Total, period)
+}
+
+//More is the number of posts that were left out of the digest
+func (d *Digest) More() int64 {
+	return d.Total - int64(len(d.Posts))
+}
+
+//Message render the html and plain text versions of the digest. The unsubscribe link is also set in the
+//List-Unsubscribe header so that email clients can show it
+func (d *Digest) Message() (*EmailMessage, error) {
+	var html bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&html, d); err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	if err := digestTextTemplate.Execute(&text, d); err != nil {
+		return nil, err
+	}
+	return &EmailMessage{
+		To:      d.Subscription.Email,
+		Subject: d.Subject(),
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + d.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+//nextDigest get when the next digest of the subscription is due
+func nextDigest(frequency string, now time.Time) time.Time {
+	if frequency == DIGEST_WEEKLY {
+		return now.AddDate(0, 0, 7)
+	}
+	return now.AddDate(0, 0, 1)
+}
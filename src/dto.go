@@ -1,5 +1,11 @@
 package api
 
+import (
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
 type PostList struct {
 	Limit int     `json:"limit"`
 	Total int64   `json:"total"`
@@ -13,3 +19,398 @@ type CategoryList struct {
 	Page  int         `json:"page"`
 	Items []*Category `json:"items"`
 }
+
+type AuthorList struct {
+	Limit int       `json:"limit"`
+	Total int64     `json:"total"`
+	Page  int       `json:"page"`
+	Items []*Author `json:"items"`
+}
+
+type AuthorDetail struct {
+	*Author
+	PostCount int64         `json:"postCount"`
+	Blogs     []*AuthorBlog `json:"blogs"`
+}
+
+type AuthorBlog struct {
+	BlogID    string `json:"blogId"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	PostCount int64  `json:"postCount"`
+}
+
+type CategoryDetail struct {
+	*Category
+	PostCount         int64              `json:"postCount"`
+	TopBlogs          []*BlogPostCount   `json:"topBlogs"`
+	RelatedCategories []*RelatedCategory `json:"relatedCategories"`
+}
+
+type BlogPostCount struct {
+	BlogID    string `json:"blogId"`
+	Title     string `json:"title"`
+	PostCount int64  `json:"postCount"`
+}
+
+type RelatedCategory struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Slug        string `json:"slug"`
+	SharedPosts int64  `json:"sharedPosts"`
+}
+
+type CategorySynonymRequest struct {
+	CategoryID uint   `json:"categoryId"`
+	Synonym    string `json:"synonym"`
+}
+
+type MergeCategoryRequest struct {
+	CategoryID uint `json:"categoryId"`
+	IntoID     uint `json:"intoId"`
+}
+
+type CategorySynonymPayload struct {
+	Synonym string `json:"synonym"`
+}
+
+type CategoryMergedPayload struct {
+	Into string `json:"into"`
+}
+
+type BlogFullContentRequest struct {
+	BlogID  string `json:"blogId"`
+	Enabled bool   `json:"enabled"`
+}
+
+type BlogFullContentPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+type BlogSendMentionsRequest struct {
+	BlogID  string `json:"blogId"`
+	Enabled bool   `json:"enabled"`
+}
+
+type BlogSendMentionsPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+//BlogMetadata is the information about a blog found on its homepage
+type BlogMetadata struct {
+	FaviconURL        string
+	AppleTouchIconURL string
+	ImageURL          string
+	SiteName          string
+	Description       string
+	Language          string
+	Profiles          []*BlogProfile
+}
+
+//BlogFetchedPayload records a fetch of a blog's feed and the problems that had to be fixed to parse it. The error is
+//set when the feed couldn't be fetched
+type BlogFetchedPayload struct {
+	FeedURL   string    `json:"feedUrl"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Problems  []string  `json:"problems"`
+	Error     string    `json:"error,omitempty"`
+	FetchSummary
+}
+
+//FetchSummary counts what happened to the items in a feed when it was fetched
+type FetchSummary struct {
+	NewItems       int `json:"newItems"`
+	UpdatedItems   int `json:"updatedItems"`
+	UnchangedItems int `json:"unchangedItems"`
+}
+
+//RefreshSummary is the result of refreshing a blog on request
+type RefreshSummary struct {
+	BlogID    string    `json:"blogId"`
+	FeedURL   string    `json:"feedUrl"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	FetchSummary
+	Problems []string `json:"problems"`
+}
+
+//PostUpdatedPayload is the item from the feed for a post that changed after it was added
+type PostUpdatedPayload struct {
+	gofeed.Item
+	BlogID     string    `json:"blogId"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+type RefreshBlogRequest struct {
+	BlogID string `json:"blogId"`
+}
+
+type BlogList struct {
+	Limit int     `json:"limit"`
+	Total int64   `json:"total"`
+	Page  int     `json:"page"`
+	Items []*Blog `json:"items"`
+}
+
+type BlogHealth struct {
+	BlogID              string     `json:"blogId"`
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastFetchedAt       *time.Time `json:"lastFetchedAt,omitempty"`
+	LastSucceededAt     *time.Time `json:"lastSucceededAt,omitempty"`
+	LastPostAt          *time.Time `json:"lastPostAt,omitempty"`
+	DaysSinceLastPost   int        `json:"daysSinceLastPost"`
+	PollingPaused       bool       `json:"pollingPaused"`
+}
+
+//DeleteBlogRequest removes a blog. Purging removes the blog for good and scrubs its content from the stored events, it's
+//used for takedown requests
+type DeleteBlogRequest struct {
+	BlogID string `json:"blogId"`
+	Purge  bool   `json:"purge"`
+}
+
+type DeletePostRequest struct {
+	PostID string `json:"postId"`
+	Purge  bool   `json:"purge"`
+}
+
+type BlogDeletedPayload struct {
+	Purge bool `json:"purge"`
+}
+
+//PostDeletedPayload removes a post. The item key is the guid (or link) of the item in the feed so that the item isn't
+//added again the next time the feed is fetched
+type PostDeletedPayload struct {
+	PostID  string `json:"postId"`
+	ItemKey string `json:"itemKey"`
+	Purge   bool   `json:"purge"`
+}
+
+//ScrubbedPayload replaces the payload of the events of a blog or post that was purged. The blog and the guid of the item
+//are only kept for posts so that the item isn't added again
+type ScrubbedPayload struct {
+	Scrubbed bool   `json:"scrubbed"`
+	BlogID   string `json:"blogId,omitempty"`
+	GUID     string `json:"guid,omitempty"`
+}
+
+//ClaimBlogRequest is a user asking to own a blog, it's also used when the user asks for their claim to be verified
+type ClaimBlogRequest struct {
+	BlogID string `json:"blogId"`
+	UserID string `json:"userId"`
+}
+
+type UpdateBlogRequest struct {
+	BlogID      string `json:"blogId"`
+	Description string `json:"description"`
+}
+
+type HidePostRequest struct {
+	BlogID string `json:"blogId"`
+	PostID string `json:"postId"`
+	Hidden bool   `json:"hidden"`
+}
+
+type SetPostCategoriesRequest struct {
+	BlogID     string   `json:"blogId"`
+	PostID     string   `json:"postId"`
+	Categories []string `json:"categories"`
+}
+
+//BlogClaimedPayload is a user asking to own a blog. The token has to be placed on the blog to verify the claim
+type BlogClaimedPayload struct {
+	UserID string `json:"userId"`
+	Token  string `json:"token"`
+}
+
+//BlogClaimVerifiedPayload records that the token of a claim was found on the blog. The method is where the token was
+//found i.e. meta, file or feed
+type BlogClaimVerifiedPayload struct {
+	UserID     string    `json:"userId"`
+	Method     string    `json:"method"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+//BlogDescriptionPayload is the description an owner gave the blog
+type BlogDescriptionPayload struct {
+	Description string `json:"description"`
+}
+
+//PostHiddenPayload hides (or shows) a post. The post is found by the item key (see PostDeletedPayload), events from before
+//the key was stored only have the id of the post
+type PostHiddenPayload struct {
+	PostID  string `json:"postId"`
+	ItemKey string `json:"itemKey"`
+	Hidden  bool   `json:"hidden"`
+}
+
+//PostCategoriesSetPayload replaces the categories of a post. The post is found the same way as PostHiddenPayload
+type PostCategoriesSetPayload struct {
+	PostID     string   `json:"postId"`
+	ItemKey    string   `json:"itemKey"`
+	Categories []string `json:"categories"`
+}
+
+//FollowBlogRequest is a user following (or unfollowing) a blog
+type FollowBlogRequest struct {
+	BlogID string `json:"blogId"`
+	UserID string `json:"userId"`
+	Follow bool   `json:"follow"`
+}
+
+type BlogFollowedPayload struct {
+	UserID string `json:"userId"`
+}
+
+//FediverseFollowRequest is an ActivityPub actor following (or unfollowing) a blog
+type FediverseFollowRequest struct {
+	BlogID   string                        `json:"blogId"`
+	Follower *BlogFediverseFollowedPayload `json:"follower"`
+	Follow   bool                          `json:"follow"`
+}
+
+//BlogFediverseFollowedPayload is the actor that followed the blog. The follow id is the id of the Follow activity, it's
+//used to accept the follow
+type BlogFediverseFollowedPayload struct {
+	ActorID     string `json:"actorId"`
+	Inbox       string `json:"inbox,omitempty"`
+	SharedInbox string `json:"sharedInbox,omitempty"`
+	FollowID    string `json:"followId,omitempty"`
+}
+
+//BlogStats are the numbers for a blog's dashboard. Views, posts, categories and fetches are for the days from From to To
+//and the followers are the current followers
+type BlogStats struct {
+	BlogID        string           `json:"blogId"`
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	TotalViews    int64            `json:"totalViews"`
+	DailyViews    []*DailyViews    `json:"dailyViews"`
+	PostsPerMonth []*MonthlyPosts  `json:"postsPerMonth"`
+	TopPosts      []*PostViews     `json:"topPosts"`
+	TopCategories []*CategoryPosts `json:"topCategories"`
+	Followers     int64            `json:"followers"`
+	Fetches       FetchStats       `json:"fetches"`
+}
+
+type DailyViews struct {
+	Day   string `json:"day"`
+	Views int64  `json:"views"`
+}
+
+type MonthlyPosts struct {
+	Month string `json:"month"`
+	Posts int64  `json:"posts"`
+}
+
+type PostViews struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+	Views int64  `json:"views"`
+}
+
+type CategoryPosts struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Posts int64  `json:"posts"`
+}
+
+//FetchStats is how reliably the feed could be fetched. The reliability is the share of fetches that succeeded and is left
+//out when the feed wasn't fetched
+type FetchStats struct {
+	Total       int64    `json:"total"`
+	Failed      int64    `json:"failed"`
+	Reliability *float64 `json:"reliability,omitempty"`
+}
+
+//ReceiveMentionRequest is a webmention or pingback from the source to a post on the blog
+type ReceiveMentionRequest struct {
+	BlogID string `json:"blogId"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+//PostMentionedPayload is a page (the source) that links to a post. The target is the link to the post that was used. The
+//post is found by the item key (see PostDeletedPayload)
+type PostMentionedPayload struct {
+	PostID  string `json:"postId"`
+	ItemKey string `json:"itemKey"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Type    string `json:"type"`
+	Title   string `json:"title,omitempty"`
+}
+
+type PostMentionRemovedPayload struct {
+	PostID  string `json:"postId"`
+	ItemKey string `json:"itemKey"`
+	Source  string `json:"source"`
+}
+
+//DigestSubscriptionRequest is what a user subscribes to: the posts of the blogs they follow and/or posts in categories
+type DigestSubscriptionRequest struct {
+	Email         string   `json:"email"`
+	Frequency     string   `json:"frequency"`
+	FollowedBlogs bool     `json:"followedBlogs"`
+	Categories    []string `json:"categories"`
+}
+
+//SubscribeDigestRequest is a user subscribing to an email digest
+type SubscribeDigestRequest struct {
+	SubscriptionID string `json:"subscriptionId"`
+	UserID         string `json:"userId"`
+	DigestSubscriptionRequest
+}
+
+//DigestSubscribedPayload is a new digest subscription. The token is used in the unsubscribe links of the digests
+type DigestSubscribedPayload struct {
+	UserID string `json:"userId"`
+	Token  string `json:"token"`
+	DigestSubscriptionRequest
+}
+
+type UnsubscribeDigestRequest struct {
+	SubscriptionID string `json:"subscriptionId"`
+}
+
+//SavedSearchRequest is a query a user is notified about when new posts match it. Posts have to match each of the filters
+//that are set: all the terms of the query and one of the categories, blogs and authors
+type SavedSearchRequest struct {
+	Name       string   `json:"name"`
+	Query      string   `json:"query"`
+	Categories []string `json:"categories"`
+	Blogs      []string `json:"blogs"`
+	Authors    []uint   `json:"authors"`
+	Notify     string   `json:"notify"`
+	WebhookURL string   `json:"webhookUrl,omitempty"`
+	Email      string   `json:"email,omitempty"`
+}
+
+//SaveSearchRequest is a user saving a search
+type SaveSearchRequest struct {
+	SearchID string `json:"searchId"`
+	UserID   string `json:"userId"`
+	SavedSearchRequest
+}
+
+//SavedSearchCreatedPayload is a new saved search. The secret is used to sign the webhooks of the search
+type SavedSearchCreatedPayload struct {
+	UserID string `json:"userId"`
+	Secret string `json:"secret,omitempty"`
+	SavedSearchRequest
+}
+
+type DeleteSearchRequest struct {
+	SearchID string `json:"searchId"`
+}
+
+type SavedSearchMatchList struct {
+	Limit int                 `json:"limit"`
+	Total int64               `json:"total"`
+	Page  int                 `json:"page"`
+	Items []*SavedSearchMatch `json:"items"`
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+const (
+	MEDIUM_AUDIO = "audio"
+	MEDIUM_VIDEO = "video"
+	MEDIUM_IMAGE = "image"
+)
+
+//ValidMedium checks that the medium can be used to filter posts
+func ValidMedium(medium string) bool {
+	return medium == MEDIUM_AUDIO || medium == MEDIUM_VIDEO || medium == MEDIUM_IMAGE
+}
+
+//postEnclosures get the media attached to a feed item from its enclosures, Media RSS elements and iTunes tags
+func postEnclosures(item *gofeed.Item, base *url.URL) []*Enclosure {
+	var enclosures []*Enclosure
+	seen := make(map[string]*Enclosure)
+	add := func(enclosure *Enclosure) {
+		enclosure.URL = safeURL(enclosure.URL, base)
+		if enclosure.URL == "" {
+			return
+		}
+		//the same file is often in the enclosure and in the Media RSS elements so the details are combined
+		if existing, ok := seen[enclosure.URL]; ok {
+			if existing.Type == "" {
+				existing.Type = enclosure.Type
+			}
+			if existing.Length == 0 {
+				existing.Length = enclosure.Length
+			}
+			if existing.Duration == 0 {
+				existing.Duration = enclosure.Duration
+			}
+			if existing.ThumbnailURL == "" {
+				existing.ThumbnailURL = enclosure.ThumbnailURL
+			}
+			if existing.Medium == "" {
+				existing.Medium = enclosure.Medium
+			}
+			return
+		}
+		if enclosure.Medium == "" {
+			enclosure.Medium = mediumFromType(enclosure.Type)
+		}
+		seen[enclosure.URL] = enclosure
+		enclosures = append(enclosures, enclosure)
+	}
+
+	for _, enclosure := range item.Enclosures {
+		length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		add(&Enclosure{
+			URL:    enclosure.URL,
+			Type:   enclosure.Type,
+			Length: length,
+		})
+	}
+	if media, ok := item.Extensions["media"]; ok {
+		thumbnail := mediaThumbnail(media)
+		contents := media["content"]
+		for _, group := range media["group"] {
+			contents = append(contents, group.Children["content"]...)
+			if thumbnail == "" {
+				thumbnail = mediaThumbnail(group.Children)
+			}
+		}
+		for _, content := range contents {
+			length, _ := strconv.ParseInt(content.Attrs["fileSize"], 10, 64)
+			duration, _ := strconv.Atoi(content.Attrs["duration"])
+			enclosureThumbnail := mediaThumbnail(content.Children)
+			if enclosureThumbnail == "" {
+				enclosureThumbnail = thumbnail
+			}
+			add(&Enclosure{
+				URL:          content.Attrs["url"],
+				Type:         content.Attrs["type"],
+				Medium:       mediumFromMediaRSS(content.Attrs["medium"]),
+				Length:       length,
+				Duration:     duration,
+				ThumbnailURL: safeURL(enclosureThumbnail, base),
+			})
+		}
+	}
+	//podcasts put the duration and artwork in the iTunes tags
+	if item.ITunesExt != nil {
+		for _, enclosure := range enclosures {
+			if enclosure.Duration == 0 && (enclosure.Medium == MEDIUM_AUDIO || enclosure.Medium == MEDIUM_VIDEO) {
+				enclosure.Duration = parseDuration(item.ITunesExt.Duration)
+			}
+			if enclosure.ThumbnailURL == "" && enclosure.Medium != MEDIUM_IMAGE {
+				enclosure.ThumbnailURL = safeURL(item.ITunesExt.Image, base)
+			}
+		}
+	}
+	return enclosures
+}
+
+func mediaThumbnail(elements map[string][]ext.Extension) string {
+	for _, thumbnail := range elements["thumbnail"] {
+		if thumbnail.Attrs["url"] != "" {
+			return thumbnail.Attrs["url"]
+		}
+	}
+	return ""
+}
+
+func mediumFromMediaRSS(medium string) string {
+	medium = strings.ToLower(strings.TrimSpace(medium))
+	if ValidMedium(medium) {
+		return medium
+	}
+	return ""
+}
+
+func mediumFromType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "audio/"):
+		return MEDIUM_AUDIO
+	case strings.HasPrefix(mediaType, "video/"):
+		return MEDIUM_VIDEO
+	case strings.HasPrefix(mediaType, "image/"):
+		return MEDIUM_IMAGE
+	}
+	return ""
+}
+
+//parseDuration converts a duration in the iTunes format (seconds, MM:SS or HH:MM:SS) to seconds
+func parseDuration(duration string) int {
+	seconds := 0
+	for _, part := range strings.Split(strings.TrimSpace(duration), ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	//the largest homepage that is downloaded when enriching a blog
+	maxHomepageSize = 2 << 20
+	//the most social profiles that are stored for a blog
+	maxBlogProfiles = 10
+	//the number of blogs that are enriched each time the job runs
+	enrichmentBatchSize = 20
+)
+
+//socialNetworks maps the hosts of social networks to the network name
+var socialNetworks = map[string]string{
+	"twitter.com":   "twitter",
+	"github.com":    "github",
+	"gitlab.com":    "gitlab",
+	"linkedin.com":  "linkedin",
+	"facebook.com":  "facebook",
+	"instagram.com": "instagram",
+	"youtube.com":   "youtube",
+	"dev.to":        "dev.to",
+	"medium.com":    "medium",
+}
+
+//BlogEnricher downloads the homepage of a blog and gets the details about the blog from it e.g. icons and OpenGraph tags
+type BlogEnricher struct {
+	client *http.Client
+}
+
+//Enrich download the homepage and get the blog details from it
+func (b *BlogEnricher) Enrich(ctx context.Context, homepage string) (*BlogMetadata, error) {
+	base, err := url.Parse(homepage)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("invalid blog url '%s'", homepage)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, homepage, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	response, err := b.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status '%d' downloading '%s'", response.StatusCode, homepage)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("unexpected content type '%s' downloading '%s'", contentType, homepage)
+	}
+	if response.Request != nil && response.Request.URL != nil {
+		base = response.Request.URL
+	}
+	return ParseBlogMetadata(io.LimitReader(response.Body, maxHomepageSize), base)
+}
+
+//ParseBlogMetadata get the blog details from the html of the homepage
+func ParseBlogMetadata(page io.Reader, base *url.URL) (*BlogMetadata, error) {
+	document, err := html.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &BlogMetadata{}
+	var description string
+	profiles := make(map[string]bool)
+	addProfile := func(link string, me bool) {
+		profileURL := safeURL(link, base)
+		if profileURL == "" || profiles[profileURL] || len(metadata.Profiles) >= maxBlogProfiles {
+			return
+		}
+		parsed, err := url.Parse(profileURL)
+		if err != nil {
+			return
+		}
+		network, ok := socialNetworks[strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")]
+		//links with rel="me" are profiles of the author even if they're not on a network we know about
+		if !ok && !me {
+			return
+		}
+		if !ok {
+			network = parsed.Hostname()
+		}
+		//links to the homepage of the network are not profiles
+		if strings.Trim(parsed.Path, "/") == "" {
+			return
+		}
+		profiles[profileURL] = true
+		metadata.Profiles = append(metadata.Profiles, &BlogProfile{
+			Network: network,
+			URL:     profileURL,
+		})
+	}
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.DataAtom {
+			case atom.Html:
+				metadata.Language = attribute(node, "lang")
+			case atom.Link:
+				rels := strings.Fields(strings.ToLower(attribute(node, "rel")))
+				href := attribute(node, "href")
+				for _, rel := range rels {
+					switch rel {
+					case "icon":
+						if metadata.FaviconURL == "" {
+							metadata.FaviconURL = safeURL(href, base)
+						}
+					case "apple-touch-icon", "apple-touch-icon-precomposed":
+						if metadata.AppleTouchIconURL == "" {
+							metadata.AppleTouchIconURL = safeURL(href, base)
+						}
+					case "me":
+						addProfile(href, true)
+					}
+				}
+			case atom.Meta:
+				content := strings.TrimSpace(attribute(node, "content"))
+				name := strings.ToLower(attribute(node, "property"))
+				if name == "" {
+					name = strings.ToLower(attribute(node, "name"))
+				}
+				switch name {
+				case "og:image":
+					if metadata.ImageURL == "" {
+						metadata.ImageURL = safeURL(content, base)
+					}
+				case "og:site_name":
+					metadata.SiteName = content
+				case "og:description":
+					metadata.Description = content
+				case "og:locale":
+					if metadata.Language == "" {
+						metadata.Language = strings.ReplaceAll(content, "_", "-")
+					}
+				case "description":
+					description = content
+				case "twitter:site", "twitter:creator":
+					if handle := strings.TrimPrefix(content, "@"); handle != "" {
+						addProfile("https://twitter.com/"+handle, false)
+					}
+				}
+			case atom.A:
+				rels := strings.Fields(strings.ToLower(attribute(node, "rel")))
+				me := false
+				for _, rel := range rels {
+					me = me || rel == "me"
+				}
+				addProfile(attribute(node, "href"), me)
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	if metadata.Description == "" {
+		metadata.Description = description
+	}
+	//browsers look for the favicon at the root of the site if it's not in the page
+	if metadata.FaviconURL == "" {
+		metadata.FaviconURL = safeURL("/favicon.ico", base)
+	}
+	return metadata, nil
+}
+
+func NewBlogEnricher(client *http.Client) *BlogEnricher {
+	return &BlogEnricher{
+		client: client,
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+const CATEGORY_SYNONYM_ADDED = "category.synonym_added"
+const CATEGORY_SYNONYM_REMOVED = "category.synonym_removed"
+const CATEGORY_MERGED = "category.merged"
+const BLOG_FULL_CONTENT_SET = "blog.full_content_set"
+const BLOG_FETCHED = "blog.fetched"
+const POST_UPDATED = "post.updated"
+const POST_DELETED = "post.deleted"
+const BLOG_DELETED = "blog.deleted"
+const BLOG_CLAIMED = "blog.claimed"
+const BLOG_CLAIM_VERIFIED = "blog.claim_verified"
+const POST_HIDDEN = "post.hidden"
+const POST_CATEGORIES_SET = "post.categories_set"
+const BLOG_DESCRIPTION_SET = "blog.description_set"
+const BLOG_FOLLOWED = "blog.followed"
+const BLOG_UNFOLLOWED = "blog.unfollowed"
+const POST_MENTIONED = "post.mentioned"
+const POST_MENTION_REMOVED = "post.mention_removed"
+const BLOG_SEND_MENTIONS_SET = "blog.send_mentions_set"
+const BLOG_FEDIVERSE_FOLLOWED = "blog.fediverse_followed"
+const BLOG_FEDIVERSE_UNFOLLOWED = "blog.fediverse_unfollowed"
+const DIGEST_SUBSCRIBED = "digest.subscribed"
+const DIGEST_UNSUBSCRIBED = "digest.unsubscribed"
+const SAVED_SEARCH_CREATED = "saved_search.created"
+const SAVED_SEARCH_DELETED = "saved_search.deleted"
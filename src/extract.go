@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	CONTENT_SOURCE_FEED    = "feed"
+	CONTENT_SOURCE_ARTICLE = "article"
+	//the largest page that is downloaded when extracting an article
+	maxArticleSize = 5 << 20
+	//the shortest text that is treated as an article
+	minArticleLength = 250
+	//the number of posts that have their article extracted each time the job runs
+	fullContentBatchSize = 20
+)
+
+var (
+	positiveCandidate = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeCandidate = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|sponsor|advert|share|social|related|nav|menu|header|widget|promo|banner|combx|masthead|popup`)
+)
+
+//unlikelyElements are never part of the article
+var unlikelyElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Input:    true,
+}
+
+//ContentExtractor downloads the page of a post and extracts the article from it. It uses a readability style algorithm
+//where the paragraphs in the page are scored and the element that contains the best paragraphs is the article
+type ContentExtractor struct {
+	client *http.Client
+}
+
+//Extract download the page and return the sanitized html of the article
+func (c *ContentExtractor) Extract(ctx context.Context, link string) (string, error) {
+	base, err := url.Parse(link)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return "", fmt.Errorf("invalid post link '%s'", link)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status '%d' downloading '%s'", response.StatusCode, link)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return "", fmt.Errorf("unexpected content type '%s' downloading '%s'", contentType, link)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxArticleSize))
+	if err != nil {
+		return "", err
+	}
+	//resolve relative urls against the final url in case there was a redirect
+	if response.Request != nil && response.Request.URL != nil {
+		base = response.Request.URL
+	}
+	return ExtractArticle(body, base)
+}
+
+//ExtractArticle find the article in a html page and return it sanitized
+func ExtractArticle(page []byte, base *url.URL) (string, error) {
+	document, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", err
+	}
+	removeUnlikely(document)
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	var score func(node *html.Node)
+	score = func(node *html.Node) {
+		if node.Type == html.ElementNode && (node.DataAtom == atom.P || node.DataAtom == atom.Pre || node.DataAtom == atom.Td) {
+			text := PlainText(renderNode(node))
+			if len(text) >= 25 && node.Parent != nil {
+				paragraphScore := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+				parent := node.Parent
+				if _, ok := scores[parent]; !ok {
+					scores[parent] = weight(parent)
+					candidates = append(candidates, parent)
+				}
+				scores[parent] += paragraphScore
+				if grandparent := parent.Parent; grandparent != nil && grandparent.Type == html.ElementNode {
+					if _, ok := scores[grandparent]; !ok {
+						scores[grandparent] = weight(grandparent)
+						candidates = append(candidates, grandparent)
+					}
+					scores[grandparent] += paragraphScore / 2
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			score(child)
+		}
+	}
+	score(document)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		//candidates that are mostly links are navigation rather than the article
+		candidateScore := scores[candidate] * (1 - linkDensity(candidate))
+		if best == nil || candidateScore > bestScore {
+			best = candidate
+			bestScore = candidateScore
+		}
+	}
+	if best == nil {
+		return "", errors.New("no article found")
+	}
+	article := SanitizeHTML(renderNode(best), base)
+	if len(PlainText(article)) < minArticleLength {
+		return "", errors.New("article is too short")
+	}
+	return article, nil
+}
+
+//removeUnlikely removes the elements that are not part of an article e.g. scripts, navigation and comments
+func removeUnlikely(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.CommentNode || (child.Type == html.ElementNode && (unlikelyElements[child.DataAtom] || unlikelyCandidate(child))) {
+			node.RemoveChild(child)
+		} else {
+			removeUnlikely(child)
+		}
+		child = next
+	}
+}
+
+func unlikelyCandidate(node *html.Node) bool {
+	if node.DataAtom == atom.Body || node.DataAtom == atom.Html || node.DataAtom == atom.Article {
+		return false
+	}
+	names := attribute(node, "class") + " " + attribute(node, "id")
+	return negativeCandidate.MatchString(names) && !positiveCandidate.MatchString(names)
+}
+
+//weight is the starting score of a candidate based on its element and class names
+func weight(node *html.Node) float64 {
+	var result float64
+	switch node.DataAtom {
+	case atom.Article:
+		result += 10
+	case atom.Div, atom.Main, atom.Section:
+		result += 5
+	case atom.Blockquote, atom.Pre, atom.Td:
+		result += 3
+	case atom.Li, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt:
+		result -= 3
+	}
+	names := attribute(node, "class") + " " + attribute(node, "id")
+	if positiveCandidate.MatchString(names) {
+		result += 25
+	}
+	if negativeCandidate.MatchString(names) {
+		result -= 25
+	}
+	return result
+}
+
+//linkDensity is the amount of the text in a node that is in links
+func linkDensity(node *html.Node) float64 {
+	text := len(PlainText(renderNode(node)))
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	var count func(node *html.Node)
+	count = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.DataAtom == atom.A {
+			links += len(PlainText(renderNode(node)))
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			count(child)
+		}
+	}
+	count(node)
+	return float64(links) / float64(text)
+}
+
+func attribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func renderNode(node *html.Node) string {
+	var buffer bytes.Buffer
+	html.Render(&buffer, node)
+	return buffer.String()
+}
+
+func NewContentExtractor(client *http.Client) *ContentExtractor {
+	return &ContentExtractor{
+		client: client,
+	}
+}
@@ -0,0 +1,422 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ACTIVITY_CONTENT_TYPE       = "application/activity+json"
+	ACTIVITY_DELIVERY_PENDING   = "pending"
+	ACTIVITY_DELIVERY_DELIVERED = "delivered"
+	ACTIVITY_DELIVERY_FAILED    = "failed"
+	activityStreamsContext      = "https://www.w3.org/ns/activitystreams"
+	securityContext             = "https://w3id.org/security/v1"
+	//the audience of activities that anyone can see
+	activityPublic = "https://www.w3.org/ns/activitystreams#Public"
+	//the largest activity (or actor) that is read
+	maxActivitySize = 1 << 20
+	//how far the Date of a signed request can be from now
+	maxSignatureAge = 12 * time.Hour
+	//the size of the keys the blogs sign their activities with
+	actorKeySize = 2048
+	//the number of activities that are delivered each time the job runs
+	activityDeliveryBatchSize = 20
+	//the number of posts in the outbox of a blog
+	outboxLimit = 20
+)
+
+var (
+	ErrFederationDisabled = errors.New("PUBLIC_URL must be set for blogs to be followed from the fediverse")
+	ErrSignatureMissing   = errors.New("the request isn't signed")
+	ErrSignatureInvalid   = errors.New("the signature of the request is invalid")
+)
+
+//Actor is a blog (or someone following it) on the fediverse
+type Actor struct {
+	Context           interface{}     `json:"@context,omitempty"`
+	ID                string          `json:"id"`
+	Type              string          `json:"type"`
+	PreferredUsername string          `json:"preferredUsername,omitempty"`
+	Name              string          `json:"name,omitempty"`
+	Summary           string          `json:"summary,omitempty"`
+	URL               string          `json:"url,omitempty"`
+	Icon              *ActivityImage  `json:"icon,omitempty"`
+	Inbox             string          `json:"inbox"`
+	Outbox            string          `json:"outbox,omitempty"`
+	Followers         string          `json:"followers,omitempty"`
+	Endpoints         *ActorEndpoints `json:"endpoints,omitempty"`
+	PublicKey         *PublicKey      `json:"publicKey,omitempty"`
+}
+
+type ActorEndpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+//PublicKey is the key that the activities of an actor are signed with
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type ActivityImage struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+//Activity is something an actor did e.g. Follow, Undo, Accept or Create. The object is the id of the object or the
+//object itself
+type Activity struct {
+	Context   interface{} `json:"@context,omitempty"`
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     string      `json:"actor"`
+	Object    interface{} `json:"object"`
+	Published string      `json:"published,omitempty"`
+	To        interface{} `json:"to,omitempty"`
+	Cc        interface{} `json:"cc,omitempty"`
+}
+
+//Article is a post on a blog
+type Article struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Name         string   `json:"name"`
+	Summary      string   `json:"summary,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	To           []string `json:"to"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+//Tombstone is a post that was deleted
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type OrderedCollection struct {
+	Context      interface{}   `json:"@context,omitempty"`
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	TotalItems   int64         `json:"totalItems"`
+	OrderedItems []interface{} `json:"orderedItems,omitempty"`
+}
+
+//WebFinger is the response to a WebFinger lookup of a blog's handle e.g. acct:ak33m-com@example.com
+type WebFinger struct {
+	Subject string           `json:"subject"`
+	Aliases []string         `json:"aliases,omitempty"`
+	Links   []*WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+//activityObject get the id and type of the object of an activity. The type is empty when only the id was sent
+func activityObject(object interface{}) (string, string) {
+	switch object := object.(type) {
+	case string:
+		return object, ""
+	case map[string]interface{}:
+		id, _ := object["id"].(string)
+		objectType, _ := object["type"].(string)
+		return id, objectType
+	}
+	return "", ""
+}
+
+//SignRequest signs the request with the key of the actor using HTTP signatures (rsa-sha256). The Date and Digest
+//headers are set if they're missing
+func SignRequest(request *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	if request.Header.Get("Date") == "" {
+		request.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	request.Header.Set("Digest", bodyDigest(body))
+	headers := []string{"(request-target)", "host", "date", "digest"}
+	hash := sha256.Sum256([]byte(signingString(request, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`, keyID,
+		strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+func bodyDigest(body []byte) string {
+	hash := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+//signingString get the string that is signed from the headers of the request
+func signingString(request *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, header := range headers {
+		var value string
+		switch header {
+		case "(request-target)":
+			value = strings.ToLower(request.Method) + " " + request.URL.RequestURI()
+		case "host":
+			value = request.Host
+			if value == "" {
+				value = request.URL.Host
+			}
+		default:
+			value = strings.Join(request.Header.Values(header), ", ")
+		}
+		lines[i] = header + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+//parseSignature get the parameters of a Signature header e.g. keyId="https://example.com/actor#main-key",signature="..."
+func parseSignature(header string) map[string]string {
+	params := make(map[string]string)
+	for header != "" {
+		separator := strings.Index(header, "=")
+		if separator == -1 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(header[:separator]))
+		header = strings.TrimSpace(header[separator+1:])
+		var value string
+		if strings.HasPrefix(header, `"`) {
+			end := strings.Index(header[1:], `"`)
+			if end == -1 {
+				break
+			}
+			value = header[1 : end+1]
+			header = header[end+2:]
+		} else if end := strings.Index(header, ","); end != -1 {
+			value = header[:end]
+			header = header[end:]
+		} else {
+			value = header
+			header = ""
+		}
+		params[name] = value
+		header = strings.TrimPrefix(strings.TrimSpace(header), ",")
+	}
+	return params
+}
+
+//SignatureVerifier checks the HTTP signatures of the activities sent to the inboxes of the blogs
+type SignatureVerifier struct {
+	client *http.Client
+}
+
+//Verify check that the request was signed by the actor that owns the key in the signature. The signature has to cover
+//the request target, host, date and digest so that it can't be replayed for another inbox or with another body. The
+//actor that signed the request is returned
+func (v *SignatureVerifier) Verify(ctx context.Context, request *http.Request, body []byte) (*Actor, error) {
+	header := request.Header.Get("Signature")
+	if header == "" {
+		return nil, ErrSignatureMissing
+	}
+	params := parseSignature(header)
+	keyID := params["keyid"]
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if keyID == "" || err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: the key id or signature is missing", ErrSignatureInvalid)
+	}
+	if algorithm := params["algorithm"]; algorithm != "" && algorithm != "rsa-sha256" && algorithm != "hs2019" {
+		return nil, fmt.Errorf("%w: unsupported algorithm '%s'", ErrSignatureInvalid, algorithm)
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	signed := make(map[string]bool)
+	for _, name := range headers {
+		signed[name] = true
+	}
+	for _, name := range []string{"(request-target)", "host", "date", "digest"} {
+		if !signed[name] {
+			return nil, fmt.Errorf("%w: the signature doesn't cover '%s'", ErrSignatureInvalid, name)
+		}
+	}
+	date, err := http.ParseTime(request.Header.Get("Date"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid date '%s'", ErrSignatureInvalid, request.Header.Get("Date"))
+	}
+	if age := time.Since(date); age > maxSignatureAge || age < -maxSignatureAge {
+		return nil, fmt.Errorf("%w: the request was signed at %s", ErrSignatureInvalid, date.Format(time.RFC3339))
+	}
+	if request.Header.Get("Digest") != bodyDigest(body) {
+		return nil, fmt.Errorf("%w: the digest doesn't match the body", ErrSignatureInvalid)
+	}
+	actor, err := v.actor(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSignatureInvalid, err)
+	}
+	hash := sha256.Sum256([]byte(signingString(request, headers)))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSignatureInvalid, err)
+	}
+	return actor, nil
+}
+
+//actor download the actor that owns the key. The actor has to be on the same host as the key and, when the key isn't
+//served with the actor document, the actor is downloaded again from its id to check that it has the key. Otherwise
+//anyone could serve a document that claims to be an actor on another server
+func (v *SignatureVerifier) actor(ctx context.Context, keyID string) (*Actor, error) {
+	link, err := url.Parse(keyID)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+		return nil, fmt.Errorf("%w: invalid key id '%s'", ErrSignatureInvalid, keyID)
+	}
+	link.Fragment = ""
+	actor, err := v.fetchActor(ctx, link.String())
+	if err != nil {
+		return nil, err
+	}
+	if actor.PublicKey == nil || actor.PublicKey.ID != keyID || actor.ID == "" {
+		return nil, fmt.Errorf("%w: the key '%s' doesn't belong to the actor", ErrSignatureInvalid, keyID)
+	}
+	actorLink, err := url.Parse(actor.ID)
+	if err != nil || !strings.EqualFold(actorLink.Scheme, link.Scheme) || !strings.EqualFold(actorLink.Host, link.Host) {
+		return nil, fmt.Errorf("%w: the actor '%s' isn't on the same host as the key '%s'", ErrSignatureInvalid, actor.ID, keyID)
+	}
+	if actor.ID == link.String() {
+		return actor, nil
+	}
+	owner, err := v.fetchActor(ctx, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.ID != actor.ID || owner.PublicKey == nil || owner.PublicKey.ID != keyID || owner.PublicKey.PublicKeyPem != actor.PublicKey.PublicKeyPem {
+		return nil, fmt.Errorf("%w: the key '%s' doesn't belong to the actor '%s'", ErrSignatureInvalid, keyID, actor.ID)
+	}
+	return owner, nil
+}
+
+//fetchActor download an actor document
+func (v *SignatureVerifier) fetchActor(ctx context.Context, link string) (*Actor, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", ACTIVITY_CONTENT_TYPE)
+	response, err := v.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the actor could not be downloaded '%s'", ErrSignatureInvalid, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrSignatureInvalid, &StatusError{URL: link, StatusCode: response.StatusCode})
+	}
+	var actor *Actor
+	if err = json.NewDecoder(io.LimitReader(response.Body, maxActivitySize)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("%w: invalid actor '%s'", ErrSignatureInvalid, err)
+	}
+	if actor == nil {
+		return nil, fmt.Errorf("%w: invalid actor", ErrSignatureInvalid)
+	}
+	return actor, nil
+}
+
+//ActivityDeliverer posts the activities of the blogs to the inboxes of their followers
+type ActivityDeliverer struct {
+	client *http.Client
+}
+
+//Deliver post the signed activity to the inbox. The status code of the response is returned
+func (d *ActivityDeliverer) Deliver(ctx context.Context, inbox string, activity []byte, keyID string, key *rsa.PrivateKey) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(activity))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", ACTIVITY_CONTENT_TYPE)
+	if err = SignRequest(request, activity, keyID, key); err != nil {
+		return 0, err
+	}
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxActivitySize))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, &StatusError{URL: inbox, StatusCode: response.StatusCode}
+	}
+	return response.StatusCode, nil
+}
+
+//generateActorKey create the key pair a blog signs its activities with. The keys are PEM encoded
+func generateActorKey() (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, actorKeySize)
+	if err != nil {
+		return "", "", err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	privatePem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+	return string(privatePem), string(publicPem), nil
+}
+
+func parsePrivateKey(value string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(value string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, errors.New("invalid public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key %T", key)
+	}
+	return rsaKey, nil
+}
+
+func NewSignatureVerifier(client *http.Client) *SignatureVerifier {
+	return &SignatureVerifier{
+		client: client,
+	}
+}
+
+func NewActivityDeliverer(client *http.Client) *ActivityDeliverer {
+	return &ActivityDeliverer{
+		client: client,
+	}
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
+	"github.com/wepala/weos"
+	"golang.org/x/net/html"
+)
+
+const (
+	//the largest feed that is downloaded
+	maxFeedSize = 10 << 20
+	//the number of blogs that are refreshed each time the polling job runs
+	pollingBatchSize = 20
+)
+
+//feedTypes are the MIME types of the feeds that can be discovered on a blog's homepage, in order of preference
+var feedTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+	"application/rdf+xml",
+	"application/xml",
+	"text/xml",
+}
+
+//FeedService finds and parses the feed of a blog. RSS (0.9x, 1.0/RDF and 2.0), Atom and JSON Feed (1.0 and 1.1) are
+//supported
+type FeedService struct {
+	client *http.Client
+	//the blogs are looked up so that a feed isn't moved to the feed of another blog
+	projection Projection
+}
+
+//FetchResult is the feed found at a url and the problems that had to be fixed to parse it. The feed url is where the
+//feed was found, which is the new url when the feed was permanently redirected
+type FetchResult struct {
+	Feed     *gofeed.Feed
+	FeedURL  string
+	Problems []string
+	//the url the feed says it has moved to with <itunes:new-feed-url> or its self link
+	MovedTo string
+}
+
+//AddBlog find the feed of the blog and create the blog with the posts in the feed
+func (f *FeedService) AddBlog(ctx context.Context, request *blogaggregatormodule.AddBlogRequest) (*blogaggregatormodule.Blog, error) {
+	blog, err := new(blogaggregatormodule.Blog).Init(request)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.Fetch(ctx, blog.URL)
+	if err != nil {
+		return nil, weos.NewDomainError(err.Error(), "Blog", blog.ID, err)
+	}
+	//the feed url is recorded when the feed links to the blog's homepage
+	blog.URL = result.FeedURL
+	err = blog.AddFeed(result.Feed)
+	if err != nil {
+		return nil, err
+	}
+	event, err := weos.NewBasicEvent(BLOG_FETCHED, blog.ID, "Blog", &BlogFetchedPayload{
+		FeedURL:   result.FeedURL,
+		FetchedAt: time.Now(),
+		Problems:  result.Problems,
+	})
+	if err != nil {
+		return nil, err
+	}
+	blog.NewChange(event)
+	return blog, nil
+}
+
+//Refresh fetch the feed of a blog that has already been added and add the new items as posts. When the feed has moved
+//the blog is updated with the new url. The fetch is only recorded on the blog when something changed or the feed
+//couldn't be fetched so that polling a feed that hasn't changed doesn't add events. The result of the fetch is returned
+//either way
+func (f *FeedService) Refresh(ctx context.Context, blog *BlogAggregate) (*BlogFetchedPayload, error) {
+	feedURL := blog.FeedURL
+	if feedURL == "" {
+		feedURL = blog.URL
+	}
+	result, err := f.Fetch(ctx, feedURL)
+	if err != nil {
+		fetched := &BlogFetchedPayload{
+			FeedURL:   feedURL,
+			FetchedAt: time.Now(),
+			Error:     err.Error(),
+		}
+		return fetched, blog.Fetched(fetched)
+	}
+	//the new url a feed gives is only used if the same feed is there. Anyone can write a self link so a feed that points
+	//to the feed of another blog isn't followed
+	if result.MovedTo != "" {
+		if f.otherBlog(blog.ID, result.MovedTo) {
+			result.Problems = append(result.Problems, fmt.Sprintf("the feed says it moved to '%s' but that's the feed of another blog", result.MovedTo))
+		} else if moved, err := f.Fetch(ctx, result.MovedTo); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("the feed says it moved to '%s' but it couldn't be fetched", result.MovedTo))
+		} else if !sameFeed(result.Feed, moved.Feed) {
+			result.Problems = append(result.Problems, fmt.Sprintf("the feed says it moved to '%s' but there's another feed there", result.MovedTo))
+		} else {
+			result = moved
+		}
+	}
+	if result.FeedURL != feedURL {
+		if f.otherBlog(blog.ID, result.FeedURL) {
+			result.Problems = append(result.Problems, fmt.Sprintf("the feed was redirected to '%s' but that's the feed of another blog", result.FeedURL))
+			result.FeedURL = feedURL
+		} else {
+			err = blog.Move(result.FeedURL, result.Feed.Link)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	summary, err := blog.AddItems(result.Feed)
+	if err != nil {
+		return nil, err
+	}
+	fetched := &BlogFetchedPayload{
+		FeedURL:      result.FeedURL,
+		FetchedAt:    time.Now(),
+		Problems:     result.Problems,
+		FetchSummary: summary,
+	}
+	if len(blog.GetNewChanges()) == 0 {
+		return fetched, nil
+	}
+	return fetched, blog.Fetched(fetched)
+}
+
+//Fetch get the feed at the url. If the url is a web page the feed linked from the page is used
+func (f *FeedService) Fetch(ctx context.Context, link string) (*FetchResult, error) {
+	response, moved, err := f.get(ctx, link)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch feed '%s': %w", link, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status '%d' fetching feed '%s'", response.StatusCode, link)
+	}
+	if isHTML(response.Header.Get("Content-Type")) {
+		feedURL := DiscoverFeed(response.Request.URL, response.Body)
+		if feedURL == "" {
+			return nil, fmt.Errorf("no feed found on '%s'", link)
+		}
+		response.Body.Close()
+		response, moved, err = f.get(ctx, feedURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch feed '%s': %w", feedURL, err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status '%d' fetching feed '%s'", response.StatusCode, feedURL)
+		}
+		link = feedURL
+	}
+	//only permanent redirects change the url of the feed
+	feedURL := link
+	if moved {
+		feedURL = response.Request.URL.String()
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch feed '%s': %w", feedURL, err)
+	}
+	result := &FetchResult{
+		FeedURL: feedURL,
+	}
+	body, result.Problems = decodeFeed(body, response.Header.Get("Content-Type"))
+	result.Feed, err = gofeed.NewParser().Parse(bytes.NewReader(body))
+	//a broken item can stop the parser or make it skip the rest of the feed so the items that can be parsed are kept
+	if err != nil || (!isJSON(body) && len(result.Feed.Items) < len(feedItem.FindAllIndex(body, -1))) {
+		feed, problems, salvageErr := salvageFeed(body)
+		switch {
+		case salvageErr == nil:
+			result.Feed = feed
+			result.Problems = append(result.Problems, problems...)
+		case err != nil:
+			return nil, fmt.Errorf("unable to parse feed '%s'", feedURL)
+		}
+	}
+	normalizeFeed(result.Feed, time.Now())
+	result.MovedTo = movedFeedURL(result.Feed, feedURL)
+	return result, nil
+}
+
+//get the url. The redirects that are followed are checked to see if they're all permanent i.e. the feed has moved
+func (f *FeedService) get(ctx context.Context, link string) (*http.Response, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	request.Header.Set("Accept", strings.Join(feedTypes, ",")+",text/html;q=0.8")
+	client := *f.client
+	redirected, permanent := false, true
+	client.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		redirected = true
+		if request.Response != nil && request.Response.StatusCode != http.StatusMovedPermanently && request.Response.StatusCode != http.StatusPermanentRedirect {
+			permanent = false
+		}
+		if f.client.CheckRedirect != nil {
+			return f.client.CheckRedirect(request, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, false, err
+	}
+	//the request isn't set on responses from some clients (e.g. test clients)
+	if response.Request == nil {
+		response.Request = request
+	}
+	return response, redirected && permanent, nil
+}
+
+//DiscoverFeed find the feed linked from a web page using the <link rel="alternate"> elements. Links to other versions
+//of the page (e.g. translations) are skipped
+func DiscoverFeed(base *url.URL, page io.Reader) string {
+	links := make(map[string]string)
+	tokenizer := html.NewTokenizer(page)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		if token.Data == "body" {
+			break
+		}
+		if token.Data != "link" {
+			continue
+		}
+		var rel, linkType, href string
+		for _, attr := range token.Attr {
+			switch attr.Key {
+			case "rel":
+				rel = strings.ToLower(attr.Val)
+			case "type":
+				linkType, _, _ = mime.ParseMediaType(attr.Val)
+			case "href":
+				href = attr.Val
+			}
+		}
+		isAlternate := false
+		for _, value := range strings.Fields(rel) {
+			isAlternate = isAlternate || value == "alternate"
+		}
+		if !isAlternate || href == "" {
+			continue
+		}
+		if _, ok := links[linkType]; !ok {
+			links[linkType] = safeURL(href, base)
+		}
+	}
+	for _, feedType := range feedTypes {
+		if link := links[feedType]; link != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+//normalizeFeed fills in the details that some formats leave out so that every item can be turned into a post. e.g.
+//RSS 1.0 items don't need a date and JSON Feed items can only have a modified date
+func normalizeFeed(feed *gofeed.Feed, now time.Time) {
+	for _, item := range feed.Items {
+		if item.PublishedParsed == nil {
+			switch {
+			case item.UpdatedParsed != nil:
+				item.PublishedParsed = item.UpdatedParsed
+			case feed.UpdatedParsed != nil:
+				item.PublishedParsed = feed.UpdatedParsed
+			case feed.PublishedParsed != nil:
+				item.PublishedParsed = feed.PublishedParsed
+			default:
+				item.PublishedParsed = &now
+			}
+		}
+		if item.Author == nil && len(item.Authors) == 0 {
+			item.Author = feed.Author
+			item.Authors = feed.Authors
+		}
+		if item.Link == "" && len(item.Links) > 0 {
+			item.Link = item.Links[0]
+		}
+	}
+}
+
+//movedFeedURL get the url the feed says it has moved to. Podcasts use <itunes:new-feed-url> and other feeds change
+//their self link. Self links that only differ by the scheme or a trailing slash are ignored since they're often wrong
+func movedFeedURL(feed *gofeed.Feed, feedURL string) string {
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		return ""
+	}
+	var links []string
+	if feed.ITunesExt != nil {
+		links = append(links, feed.ITunesExt.NewFeedURL)
+	}
+	links = append(links, feed.FeedLink)
+	for _, link := range links {
+		link = safeURL(strings.TrimSpace(link), base)
+		if link == "" || strings.HasPrefix(link, "mailto:") {
+			continue
+		}
+		if moved, err := url.Parse(link); err == nil && !sameURL(moved, base) {
+			return link
+		}
+	}
+	return ""
+}
+
+//otherBlog checks whether the url is the feed or homepage of another blog than the blog with the id
+func (f *FeedService) otherBlog(blogID string, link string) bool {
+	if f.projection == nil {
+		return false
+	}
+	existing, err := f.projection.GetBlogByURL(link)
+	return err == nil && existing != nil && existing.ID != blogID
+}
+
+//sameFeed checks whether a feed is the same as another one, they either link to the same homepage or have an item in
+//common
+func sameFeed(feed *gofeed.Feed, other *gofeed.Feed) bool {
+	link, err := url.Parse(feed.Link)
+	otherLink, otherErr := url.Parse(other.Link)
+	if err == nil && otherErr == nil && link.Host != "" && sameURL(link, otherLink) {
+		return true
+	}
+	keys := make(map[string]bool)
+	for _, item := range feed.Items {
+		keys[itemKey(item)] = true
+	}
+	for _, item := range other.Items {
+		if keys[itemKey(item)] {
+			return true
+		}
+	}
+	return false
+}
+
+func sameURL(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Host, b.Host) && strings.TrimSuffix(a.EscapedPath(), "/") == strings.TrimSuffix(b.EscapedPath(), "/") && a.RawQuery == b.RawQuery
+}
+
+func NewFeedService(client *http.Client, projection Projection) *FeedService {
+	return &FeedService{
+		client:     client,
+		projection: projection,
+	}
+}
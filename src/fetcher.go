@@ -0,0 +1,421 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//the largest robots.txt that is read, the rest of the file is ignored
+	maxRobotsSize = 500 << 10
+	//how long robots.txt is cached when it couldn't be downloaded
+	robotsRetryInterval = 10 * time.Minute
+	//the most redirects that are followed to get robots.txt
+	maxRobotsRedirects = 5
+	//the first backoff when a host responds with 503 (or 429) without a Retry-After header. It doubles each time
+	minBackoff = time.Minute
+)
+
+var (
+	ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+	ErrRobotsUnavailable  = errors.New("robots.txt couldn't be downloaded")
+	ErrHostBackingOff     = errors.New("host asked us to back off")
+)
+
+//robotsError is robots.txt that couldn't be downloaded. The site isn't crawled until it can be, the cause is kept so it
+//can be checked e.g. whether the host is backing off
+type robotsError struct {
+	cause error
+}
+
+func (e *robotsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRobotsUnavailable, e.cause)
+}
+
+func (e *robotsError) Is(target error) bool {
+	return target == ErrRobotsUnavailable
+}
+
+func (e *robotsError) Unwrap() error {
+	return e.cause
+}
+
+//StatusError is a response from a site that wasn't successful
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status '%d' from '%s'", e.StatusCode, e.URL)
+}
+
+//temporaryError checks whether a request to a site could work if it's tried again e.g. the site (or its robots.txt) was
+//down or asked us to back off. Pages that don't exist, that robots.txt doesn't allow or that aren't on a public address
+//are not retried
+func temporaryError(err error) bool {
+	if errors.Is(err, ErrDisallowedByRobots) || errors.Is(err, ErrNonPublicAddress) {
+		return false
+	}
+	var statusError *StatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode == http.StatusRequestTimeout || statusError.StatusCode == http.StatusTooManyRequests ||
+			statusError.StatusCode >= 500
+	}
+	return true
+}
+
+//Fetcher is the http transport used for every request the aggregator makes to the sites of the blogs. It identifies the
+//aggregator with the User-Agent, follows the rules in robots.txt and limits how often and how many requests are made to
+//each host. Hosts that respond with 429 or 503 are skipped until the Retry-After time (or an exponential backoff)
+type Fetcher struct {
+	config    *FetcherConfig
+	transport http.RoundTripper
+	mutex     sync.Mutex
+	hosts     map[string]*fetcherHost
+}
+
+type fetcherHost struct {
+	slots         chan struct{}
+	mutex         sync.Mutex
+	next          time.Time //the earliest time the next request can be made
+	blockedUntil  time.Time
+	failures      int
+	robots        *robotsRules
+	robotsErr     error //why robots.txt couldn't be downloaded
+	robotsExpires time.Time
+}
+
+func (f *Fetcher) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header.Get("User-Agent") == "" {
+		request = request.Clone(request.Context())
+		request.Header.Set("User-Agent", f.config.UserAgent)
+	}
+	host := f.host(request.URL)
+	if request.URL.Path != "/robots.txt" {
+		robots, err := f.robots(request, host)
+		if err != nil {
+			return nil, err
+		}
+		if !robots.allowed(request.URL) {
+			return nil, fmt.Errorf("%w '%s'", ErrDisallowedByRobots, request.URL)
+		}
+	}
+	return f.do(request, host)
+}
+
+//Client get an http client that uses the fetcher
+func (f *Fetcher) Client() *http.Client {
+	return &http.Client{
+		Transport: f,
+		Timeout:   f.config.Timeout,
+	}
+}
+
+func (f *Fetcher) host(link *url.URL) *fetcherHost {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	key := strings.ToLower(link.Scheme + "://" + link.Host)
+	host, ok := f.hosts[key]
+	if !ok {
+		host = &fetcherHost{
+			slots: make(chan struct{}, Max(f.config.HostLimit, 1)),
+		}
+		f.hosts[key] = host
+	}
+	return host
+}
+
+//do make the request once the host has a free slot and enough time has passed since the last request
+func (f *Fetcher) do(request *http.Request, host *fetcherHost) (*http.Response, error) {
+	ctx := request.Context()
+	host.mutex.Lock()
+	if until := host.blockedUntil; time.Now().Before(until) {
+		host.mutex.Unlock()
+		return nil, fmt.Errorf("%w until %s", ErrHostBackingOff, until.Format(time.RFC3339))
+	}
+	host.mutex.Unlock()
+
+	select {
+	case host.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-host.slots }()
+	//the time of the next request is only taken once the wait is over so that a request that gives up waiting doesn't
+	//hold up the requests after it
+	for {
+		host.mutex.Lock()
+		wait := time.Until(host.next)
+		if wait <= 0 {
+			interval := f.config.HostInterval
+			if host.robots != nil && host.robots.crawlDelay > interval {
+				interval = host.robots.crawlDelay
+			}
+			host.next = time.Now().Add(interval)
+			host.mutex.Unlock()
+			break
+		}
+		host.mutex.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	response, err := f.transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable {
+		host.failures++
+		backoff, ok := retryAfter(response.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			backoff = time.Duration(float64(minBackoff) * math.Pow(2, float64(host.failures-1)))
+		}
+		//a Retry-After date in the past (e.g. the clocks are off) means the host can be tried again now
+		if backoff < 0 {
+			backoff = 0
+		}
+		if backoff > f.config.MaxBackoff {
+			backoff = f.config.MaxBackoff
+		}
+		host.blockedUntil = time.Now().Add(backoff)
+	} else {
+		host.failures = 0
+	}
+	return response, nil
+}
+
+//robots get the rules for the host, downloading robots.txt when it's not cached. An error is returned while robots.txt
+//can't be downloaded
+func (f *Fetcher) robots(request *http.Request, host *fetcherHost) (*robotsRules, error) {
+	host.mutex.Lock()
+	if host.robots != nil && time.Now().Before(host.robotsExpires) {
+		defer host.mutex.Unlock()
+		return host.robots, host.robotsErr
+	}
+	host.mutex.Unlock()
+
+	rules, ttl, err := f.fetchRobots(request, host)
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	host.robots = rules
+	host.robotsErr = err
+	host.robotsExpires = time.Now().Add(ttl)
+	return rules, err
+}
+
+func (f *Fetcher) fetchRobots(request *http.Request, host *fetcherHost) (*robotsRules, time.Duration, error) {
+	robotsURL := &url.URL{Scheme: request.URL.Scheme, Host: request.URL.Host, Path: "/robots.txt"}
+	robotsRequest, err := http.NewRequestWithContext(request.Context(), http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return disallowAll, robotsRetryInterval, &robotsError{cause: err}
+	}
+	robotsRequest.Header.Set("User-Agent", f.config.UserAgent)
+	//robots.txt is often redirected e.g. from http to https
+	for redirects := 0; ; redirects++ {
+		response, err := f.do(robotsRequest, host)
+		if err != nil {
+			//robots.txt that can't be reached means the site can't be crawled for now
+			return disallowAll, robotsRetryInterval, &robotsError{cause: err}
+		}
+		location, _ := response.Location()
+		switch {
+		case response.StatusCode >= 500:
+			response.Body.Close()
+			return disallowAll, robotsRetryInterval, &robotsError{cause: &StatusError{URL: robotsRequest.URL.String(), StatusCode: response.StatusCode}}
+		case response.StatusCode >= 400:
+			response.Body.Close()
+			return allowAll, f.config.RobotsTTL, nil
+		case response.StatusCode >= 300:
+			response.Body.Close()
+			if location == nil || redirects >= maxRobotsRedirects {
+				return allowAll, robotsRetryInterval, nil
+			}
+			robotsRequest = robotsRequest.Clone(robotsRequest.Context())
+			robotsRequest.URL = location
+			robotsRequest.Host = location.Host
+			host = f.host(location)
+			continue
+		}
+		defer response.Body.Close()
+		return parseRobots(io.LimitReader(response.Body, maxRobotsSize), f.config.UserAgent), f.config.RobotsTTL, nil
+	}
+}
+
+//retryAfter parse the Retry-After header which is either a number of seconds or a date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		//seconds that don't fit in a duration are treated as the longest backoff
+		if seconds > math.MaxInt64/int64(time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+//NewFetcher create a fetcher that makes requests with the transport. Without a transport only public addresses can be
+//reached (see NewPublicTransport)
+func NewFetcher(config *FetcherConfig, transport http.RoundTripper) *Fetcher {
+	if transport == nil {
+		transport = NewPublicTransport()
+	}
+	return &Fetcher{
+		config:    config,
+		transport: transport,
+		hosts:     make(map[string]*fetcherHost),
+	}
+}
+
+//robotsRules are the rules in robots.txt that apply to the aggregator
+type robotsRules struct {
+	rules      []*robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	path    string
+	pattern *regexp.Regexp
+}
+
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{rules: []*robotsRule{{allow: false, path: "/", pattern: regexp.MustCompile("^/")}}}
+)
+
+//allowed checks the path against the rules. The rule with the longest path wins and allow wins when rules are the same
+//length
+func (r *robotsRules) allowed(link *url.URL) bool {
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if link.RawQuery != "" {
+		path += "?" + link.RawQuery
+	}
+	allowed := true
+	length := -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if len(rule.path) > length || (len(rule.path) == length && rule.allow) {
+			allowed = rule.allow
+			length = len(rule.path)
+		}
+	}
+	return allowed
+}
+
+//parseRobots get the rules in robots.txt for the user agent. The rules in the group for the user agent are used and if
+//there isn't one the rules for * are used
+func parseRobots(robots io.Reader, userAgent string) *robotsRules {
+	//the product token is the user agent up to the first / or space e.g. BlogAggregator
+	token := strings.ToLower(strings.FieldsFunc(userAgent+" ", func(r rune) bool { return r == '/' || r == ' ' })[0])
+	var matched, wildcard *robotsRules
+	var current []*robotsRules
+	inRules := false
+	scanner := bufio.NewScanner(robots)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+		switch key {
+		case "user-agent":
+			//a user agent after rules starts a new group
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case agent == token:
+				if matched == nil {
+					matched = &robotsRules{}
+				}
+				current = append(current, matched)
+			default:
+				current = append(current, &robotsRules{})
+			}
+		case "allow", "disallow":
+			inRules = true
+			//an empty disallow allows everything
+			if value == "" {
+				continue
+			}
+			rule := &robotsRule{allow: key == "allow", path: value, pattern: robotsPattern(value)}
+			for _, group := range current {
+				group.rules = append(group.rules, rule)
+			}
+		case "crawl-delay":
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				for _, group := range current {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+	switch {
+	case matched != nil:
+		return matched
+	case wildcard != nil:
+		return wildcard
+	}
+	return allowAll
+}
+
+//robotsPattern converts a robots.txt path to a regular expression. * matches any characters and $ matches the end of
+//the path
+func robotsPattern(path string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i, part := range strings.Split(path, "*") {
+		if i > 0 {
+			pattern.WriteString(".*")
+		}
+		if strings.HasSuffix(part, "$") && i == strings.Count(path, "*") {
+			pattern.WriteString(regexp.QuoteMeta(strings.TrimSuffix(part, "$")) + "$")
+			continue
+		}
+		pattern.WriteString(regexp.QuoteMeta(part))
+	}
+	return regexp.MustCompile(pattern.String())
+}
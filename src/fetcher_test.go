@@ -0,0 +1,237 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/wepala/blog-aggregator-api/src"
+)
+
+func TestFetcher(t *testing.T) {
+	var mutex sync.Mutex
+	var userAgents []string
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		userAgents = append(userAgents, r.UserAgent())
+		requests = append(requests, time.Now())
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, `# robots.txt for the tests
+User-agent: *
+Disallow: /
+
+User-agent: OtherBot
+User-agent: BlogAggregator
+Disallow: /drafts/*.xml$
+Disallow: /private
+Allow: /private/feed.xml
+`)
+		case "/busy":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	fetcher := api.NewFetcher(&api.FetcherConfig{
+		UserAgent:    "BlogAggregator/1.0 (+https://github.com/wepala/blog-aggregator-api)",
+		Timeout:      5 * time.Second,
+		HostInterval: 50 * time.Millisecond,
+		HostLimit:    1,
+		RobotsTTL:    time.Hour,
+		MaxBackoff:   time.Hour,
+	}, server.Client().Transport)
+	client := fetcher.Client()
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		//the group for the aggregator is used instead of *
+		{"/feed.xml", true},
+		{"/drafts/feed.xml", false},
+		{"/drafts/feed.xml.bak", true},
+		{"/private/posts", false},
+		{"/private/feed.xml", true},
+	}
+	for _, test := range tests {
+		response, err := client.Get(server.URL + test.path)
+		if test.allowed {
+			if err != nil {
+				t.Fatalf("expected '%s' to be fetched, got error '%s'", test.path, err)
+			}
+			response.Body.Close()
+			continue
+		}
+		if !errors.Is(err, api.ErrDisallowedByRobots) {
+			t.Errorf("expected '%s' to be disallowed by robots.txt, got '%v'", test.path, err)
+		}
+	}
+
+	mutex.Lock()
+	//robots.txt is only downloaded once and then cached
+	if len(requests) != 4 {
+		t.Errorf("expected %d requests, got %d", 4, len(requests))
+	}
+	for i, userAgent := range userAgents {
+		if userAgent != "BlogAggregator/1.0 (+https://github.com/wepala/blog-aggregator-api)" {
+			t.Errorf("expected the user agent to be set, got '%s'", userAgent)
+		}
+		if i > 0 && requests[i].Sub(requests[i-1]) < 40*time.Millisecond {
+			t.Errorf("expected requests to the same host to be at least %s apart, got %s", 50*time.Millisecond, requests[i].Sub(requests[i-1]))
+		}
+	}
+	mutex.Unlock()
+
+	response, err := client.Get(server.URL + "/busy")
+	if err != nil {
+		t.Fatalf("unexpected error '%s'", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, response.StatusCode)
+	}
+	//the host is skipped until the Retry-After time
+	_, err = client.Get(server.URL + "/feed.xml")
+	if !errors.Is(err, api.ErrHostBackingOff) {
+		t.Errorf("expected the host to be skipped after a 429, got '%v'", err)
+	}
+
+	t.Run("robots.txt that can't be downloaded", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		}))
+		defer server.Close()
+		client := api.NewFetcher(&api.FetcherConfig{
+			UserAgent:  "BlogAggregator/1.0",
+			Timeout:    5 * time.Second,
+			HostLimit:  1,
+			RobotsTTL:  time.Hour,
+			MaxBackoff: time.Hour,
+		}, server.Client().Transport).Client()
+		//the page isn't requested until robots.txt can be checked but it isn't disallowed either
+		for i := 0; i < 2; i++ {
+			_, err := client.Get(server.URL + "/feed.xml")
+			if !errors.Is(err, api.ErrRobotsUnavailable) || errors.Is(err, api.ErrDisallowedByRobots) {
+				t.Errorf("expected robots.txt to be unavailable, got '%v'", err)
+			}
+			var statusError *api.StatusError
+			if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusBadGateway {
+				t.Errorf("expected the status of robots.txt to be kept, got '%v'", err)
+			}
+		}
+	})
+
+	t.Run("a Retry-After date in the past doesn't block the host", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/robots.txt":
+				w.WriteHeader(http.StatusNotFound)
+			case "/busy":
+				calls++
+				if calls == 1 {
+					w.Header().Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}
+		}))
+		defer server.Close()
+		client := api.NewFetcher(&api.FetcherConfig{
+			UserAgent:  "BlogAggregator/1.0",
+			Timeout:    5 * time.Second,
+			HostLimit:  1,
+			RobotsTTL:  time.Hour,
+			MaxBackoff: time.Hour,
+		}, server.Client().Transport).Client()
+		for i, status := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+			response, err := client.Get(server.URL + "/busy")
+			if err != nil {
+				t.Fatalf("expected request %d to be made, got '%s'", i+1, err)
+			}
+			response.Body.Close()
+			if response.StatusCode != status {
+				t.Errorf("expected status %d, got %d", status, response.StatusCode)
+			}
+		}
+	})
+
+	t.Run("a request that gives up waiting doesn't hold up the next one", func(t *testing.T) {
+		var mutex sync.Mutex
+		var requests []time.Time
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			mutex.Lock()
+			requests = append(requests, time.Now())
+			mutex.Unlock()
+			fmt.Fprint(w, "ok")
+		}))
+		defer server.Close()
+		client := api.NewFetcher(&api.FetcherConfig{
+			UserAgent:    "BlogAggregator/1.0",
+			Timeout:      5 * time.Second,
+			HostInterval: 300 * time.Millisecond,
+			HostLimit:    1,
+			RobotsTTL:    time.Hour,
+			MaxBackoff:   time.Hour,
+		}, server.Client().Transport).Client()
+		response, err := client.Get(server.URL + "/feed.xml")
+		if err != nil {
+			t.Fatalf("unexpected error '%s'", err)
+		}
+		response.Body.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/feed.xml", nil)
+		if _, err = client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the request to give up waiting, got '%v'", err)
+		}
+		response, err = client.Get(server.URL + "/feed.xml")
+		if err != nil {
+			t.Fatalf("unexpected error '%s'", err)
+		}
+		response.Body.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(requests) != 2 {
+			t.Fatalf("expected %d requests, got %d", 2, len(requests))
+		}
+		if gap := requests[1].Sub(requests[0]); gap > 500*time.Millisecond {
+			t.Errorf("expected the next request to be made %s after the first, got %s", 300*time.Millisecond, gap)
+		}
+	})
+}
+
+func TestPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	client := api.NewPublicClient(5 * time.Second)
+	for _, link := range []string{server.URL, fmt.Sprintf("http://localhost:%d/", server.Listener.Addr().(*net.TCPAddr).Port), "http://169.254.169.254/latest/meta-data/", "http://[::1]:80/"} {
+		_, err := client.Get(link)
+		if !errors.Is(err, api.ErrNonPublicAddress) {
+			t.Errorf("expected '%s' to be refused, got '%v'", link, err)
+		}
+	}
+}
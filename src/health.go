@@ -0,0 +1,69 @@
+package api
+
+import "time"
+
+const (
+	BLOG_STATUS_ACTIVE  = "active"
+	BLOG_STATUS_STALE   = "stale"
+	BLOG_STATUS_FAILING = "failing"
+	BLOG_STATUS_DEAD    = "dead"
+)
+
+//ValidBlogStatus checks that the status can be used to filter blogs
+func ValidBlogStatus(status string) bool {
+	return status == BLOG_STATUS_ACTIVE || status == BLOG_STATUS_STALE || status == BLOG_STATUS_FAILING || status == BLOG_STATUS_DEAD
+}
+
+//HealthStatus works out the status of a blog from how many fetches in a row have failed and how long it's been since
+//the last new post
+func HealthStatus(blog *Blog, config *HealthConfig, now time.Time) string {
+	if blog.ConsecutiveFailures >= config.FailingAfter {
+		lastSucceeded := blog.CreatedAt
+		if blog.LastSucceededAt != nil {
+			lastSucceeded = *blog.LastSucceededAt
+		}
+		if now.Sub(lastSucceeded) >= config.DeadAfter {
+			return BLOG_STATUS_DEAD
+		}
+		return BLOG_STATUS_FAILING
+	}
+	lastPost := blog.CreatedAt
+	if blog.LastPostAt != nil {
+		lastPost = *blog.LastPostAt
+	}
+	switch {
+	case now.Sub(lastPost) >= config.AbandonedAfter:
+		return BLOG_STATUS_DEAD
+	case now.Sub(lastPost) >= config.StaleAfter:
+		return BLOG_STATUS_STALE
+	}
+	return BLOG_STATUS_ACTIVE
+}
+
+//Pauses checks if polling should be paused for blogs with the status
+func (c *HealthConfig) Pauses(status string) bool {
+	for _, pauseStatus := range c.PauseStatuses {
+		if pauseStatus == status {
+			return true
+		}
+	}
+	return false
+}
+
+//NewBlogHealth get the health of a blog as of now
+func NewBlogHealth(blog *Blog, config *HealthConfig, now time.Time) *BlogHealth {
+	health := &BlogHealth{
+		BlogID:              blog.ID,
+		Status:              HealthStatus(blog, config, now),
+		ConsecutiveFailures: blog.ConsecutiveFailures,
+		LastError:           blog.LastError,
+		LastFetchedAt:       blog.LastFetchedAt,
+		LastSucceededAt:     blog.LastSucceededAt,
+		LastPostAt:          blog.LastPostAt,
+	}
+	health.PollingPaused = config.Pauses(health.Status)
+	if blog.LastPostAt != nil {
+		health.DaysSinceLastPost = int(now.Sub(*blog.LastPostAt).Hours() / 24)
+	}
+	return health
+}
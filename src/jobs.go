@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wepala/weos"
+)
+
+//Job is a task that runs in the background on an interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+//Scheduler runs the background jobs of the aggregator
+type Scheduler struct {
+	logger weos.Log
+	jobs   []*Job
+}
+
+func (s *Scheduler) AddJob(job *Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *Scheduler) Jobs() []*Job {
+	return s.jobs
+}
+
+//Start runs each job right away and then on its interval until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.run(ctx, job)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job) {
+	if job.Interval <= 0 {
+		s.logger.Errorf("job '%s' not started, the interval '%s' must be positive", job.Name, job.Interval)
+		return
+	}
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.runOnce(ctx, job); err != nil {
+			s.logger.Errorf("error running job '%s': '%s'", job.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//runOnce run the job, a panic is returned as an error so that the job (and the server) keeps running
+func (s *Scheduler) runOnce(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func NewScheduler(logger weos.Log) *Scheduler {
+	return &Scheduler{
+		logger: logger,
+	}
+}
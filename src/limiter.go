@@ -0,0 +1,48 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+//how often the keys that can be used again are removed from the rate limiters
+const rateLimiterPruneInterval = 5 * time.Minute
+
+//RateLimiter allows something to happen once per interval for each key e.g. once a minute per blog
+type RateLimiter struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	last     map[string]time.Time
+}
+
+//Allow checks if the key can be used now. When it can't, the time left until it can is returned
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if last, ok := l.last[key]; ok {
+		if wait := last.Add(l.interval).Sub(now); wait > 0 {
+			return false, wait
+		}
+	}
+	l.last[key] = now
+	return true, 0
+}
+
+//Prune removes the keys that can be used again so the map doesn't keep growing. It's run on a timer (see
+//API.Initialize) rather than on each call to Allow so that Allow doesn't go through every key
+func (l *RateLimiter) Prune(now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for key, last := range l.last {
+		if now.Sub(last) >= l.interval {
+			delete(l.last, key)
+		}
+	}
+}
+
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
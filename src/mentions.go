@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	MENTION_TYPE_WEBMENTION = "webmention"
+	MENTION_TYPE_PINGBACK   = "pingback"
+	//the largest source page that is downloaded when verifying a mention
+	maxMentionSourceSize = 2 << 20
+	//the number of received mentions that are checked each time the job runs
+	receivedMentionBatchSize = 20
+	//the fault codes from the pingback spec
+	pingbackFaultGeneric        = 0
+	pingbackFaultTargetNotFound = 32
+)
+
+var (
+	ErrMentionSourceNotFound = errors.New("the source could not be downloaded")
+	ErrMentionSourceGone     = errors.New("the source was removed")
+	ErrMentionNoLink         = errors.New("the source doesn't link to the target")
+	ErrMentionTargetNotFound = errors.New("the target is not a post on the blog")
+	ErrMentionExists         = errors.New("the mention has already been registered")
+)
+
+//mentionSourceError is a source that couldn't be downloaded. The cause is kept to tell whether it's worth trying again
+type mentionSourceError struct {
+	cause error
+}
+
+func (e *mentionSourceError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrMentionSourceNotFound, e.cause)
+}
+
+func (e *mentionSourceError) Is(target error) bool {
+	return target == ErrMentionSourceNotFound
+}
+
+func (e *mentionSourceError) Unwrap() error {
+	return e.cause
+}
+
+//MentionVerifier checks that the source of a mention (a webmention or pingback) links to the target
+type MentionVerifier struct {
+	client *http.Client
+}
+
+//Verify download the source and check that it links to the target. The title of the source is returned
+func (v *MentionVerifier) Verify(ctx context.Context, source string, target string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := v.client.Do(request)
+	if err != nil {
+		return "", &mentionSourceError{cause: err}
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusGone {
+		return "", ErrMentionSourceGone
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", &mentionSourceError{cause: &StatusError{URL: source, StatusCode: response.StatusCode}}
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxMentionSourceSize))
+	if err != nil {
+		return "", &mentionSourceError{cause: err}
+	}
+	//sources that aren't html only have to have the target somewhere in them
+	if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+		if bytes.Contains(body, []byte(target)) {
+			return "", nil
+		}
+		return "", ErrMentionNoLink
+	}
+	title, linked := mentionLinks(body, response.Request.URL, target)
+	if !linked {
+		return "", ErrMentionNoLink
+	}
+	return title, nil
+}
+
+//mentionLinks get the title of the page and check whether any of the links in the page are to the target
+func mentionLinks(page []byte, base *url.URL, target string) (string, bool) {
+	document, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", false
+	}
+	target = normalizeMentionURL(target)
+	var title string
+	var linked bool
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			var link string
+			switch node.DataAtom {
+			case atom.Title:
+				if title == "" && node.FirstChild != nil {
+					title = strings.TrimSpace(node.FirstChild.Data)
+				}
+			case atom.A, atom.Area, atom.Link:
+				link = attribute(node, "href")
+			case atom.Img, atom.Video, atom.Audio, atom.Source:
+				link = attribute(node, "src")
+			}
+			if link != "" && !linked {
+				if resolved, err := base.Parse(strings.TrimSpace(link)); err == nil && normalizeMentionURL(resolved.String()) == target {
+					linked = true
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	return title, linked
+}
+
+//normalizeMentionURL makes urls that point to the same page the same e.g. the fragment and trailing slash are removed
+func normalizeMentionURL(link string) string {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+	parsed.Fragment = ""
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	parsed.RawPath = ""
+	return parsed.String()
+}
+
+//mentionURL checks that the source or target of a mention is a web page
+func mentionURL(link string) bool {
+	parsed, err := url.Parse(link)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+//checkMention checks the source and target of a mention before it's queued and again before the source is downloaded
+func checkMention(source string, target string) error {
+	if !mentionURL(source) || !mentionURL(target) {
+		return errors.New("the source and target must be http urls")
+	}
+	if normalizeMentionURL(source) == normalizeMentionURL(target) {
+		return errors.New("the source and target must be different")
+	}
+	return nil
+}
+
+type xmlrpcCall struct {
+	XMLName    xml.Name      `xml:"methodCall"`
+	MethodName string        `xml:"methodName"`
+	Params     []xmlrpcValue `xml:"params>param>value"`
+}
+
+//xmlrpcValue is a string value, the type can be left out in XML-RPC
+type xmlrpcValue struct {
+	String string `xml:"string"`
+	Text   string `xml:",chardata"`
+}
+
+func (v xmlrpcValue) value() string {
+	if v.String != "" {
+		return strings.TrimSpace(v.String)
+	}
+	return strings.TrimSpace(v.Text)
+}
+
+//ParsePingback get the source and target from a pingback.ping XML-RPC call
+func ParsePingback(body io.Reader) (string, string, error) {
+	var call *xmlrpcCall
+	if err := xml.NewDecoder(io.LimitReader(body, maxMentionSourceSize)).Decode(&call); err != nil {
+		return "", "", fmt.Errorf("invalid XML-RPC call '%s'", err)
+	}
+	if call.MethodName != "pingback.ping" {
+		return "", "", fmt.Errorf("unsupported method '%s'", call.MethodName)
+	}
+	if len(call.Params) != 2 {
+		return "", "", fmt.Errorf("expected %d params, got %d", 2, len(call.Params))
+	}
+	return call.Params[0].value(), call.Params[1].value(), nil
+}
+
+//pingbackResponse is the XML-RPC response to a pingback that was registered
+func pingbackResponse(message string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?><methodResponse><params><param><value><string>%s</string></value></param></params></methodResponse>`, xmlText(message))
+}
+
+//pingbackFault is the XML-RPC response to a pingback that could not be registered
+func pingbackFault(code int, message string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?><methodResponse><fault><value><struct><member><name>faultCode</name><value><int>%d</int></value></member><member><name>faultString</name><value><string>%s</string></value></member></struct></value></fault></methodResponse>`, code, xmlText(message))
+}
+
+func xmlText(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
+
+func NewMentionVerifier(client *http.Client) *MentionVerifier {
+	return &MentionVerifier{
+		client: client,
+	}
+}
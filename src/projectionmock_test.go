@@ -8,6 +8,7 @@ import (
 	"github.com/wepala/blog-aggregator-api/src"
 	"github.com/wepala/weos"
 	"sync"
+	"time"
 )
 
 // Ensure, that ProjectionMock does implement api.Projection.
@@ -20,24 +21,132 @@ var _ api.Projection = &ProjectionMock{}
 //
 // 		// make and configure a mocked api.Projection
 // 		mockedProjection := &ProjectionMock{
+// 			FediverseFollowedFunc: func(blogID string, follower *api.BlogFediverseFollowedPayload) error {
+// 				panic("mock out the FediverseFollowed method")
+// 			},
+// 			GetActorFunc: func(blogID string) (*api.Actor, error) {
+// 				panic("mock out the GetActor method")
+// 			},
+// 			GetAuthorFunc: func(id uint) (*api.AuthorDetail, error) {
+// 				panic("mock out the GetAuthor method")
+// 			},
+// 			GetAuthorsFunc: func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Author, int64, error) {
+// 				panic("mock out the GetAuthors method")
+// 			},
 // 			GetBlogByIDFunc: func(id string) (*api.Blog, error) {
 // 				panic("mock out the GetBlogByID method")
 // 			},
 // 			GetBlogByURLFunc: func(url string) (*api.Blog, error) {
 // 				panic("mock out the GetBlogByURL method")
 // 			},
+// 			GetBlogClaimFunc: func(blogID string, userID string) (*api.BlogClaim, error) {
+// 				panic("mock out the GetBlogClaim method")
+// 			},
+// 			GetBlogStatsFunc: func(blogID string, from time.Time, to time.Time) (*api.BlogStats, error) {
+// 				panic("mock out the GetBlogStats method")
+// 			},
+// 			GetBlogsFunc: func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Blog, int64, error) {
+// 				panic("mock out the GetBlogs method")
+// 			},
 // 			GetCategoriesFunc: func(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Category, int64, error) {
 // 				panic("mock out the GetCategories method")
 // 			},
+// 			GetCategoryFunc: func(slug string) (*api.CategoryDetail, error) {
+// 				panic("mock out the GetCategory method")
+// 			},
+// 			GetCategoryByIDFunc: func(id uint) (*api.Category, error) {
+// 				panic("mock out the GetCategoryByID method")
+// 			},
+// 			GetCategorySynonymsFunc: func(categoryID uint) ([]*api.CategorySynonym, error) {
+// 				panic("mock out the GetCategorySynonyms method")
+// 			},
+// 			GetDigestSubscriptionFunc: func(id string) (*api.DigestSubscription, error) {
+// 				panic("mock out the GetDigestSubscription method")
+// 			},
+// 			GetDigestSubscriptionByTokenFunc: func(token string) (*api.DigestSubscription, error) {
+// 				panic("mock out the GetDigestSubscriptionByToken method")
+// 			},
+// 			GetDigestSubscriptionsFunc: func(userID string) ([]*api.DigestSubscription, error) {
+// 				panic("mock out the GetDigestSubscriptions method")
+// 			},
 // 			GetEventHandlerFunc: func() weos.EventHandler {
 // 				panic("mock out the GetEventHandler method")
 // 			},
+// 			GetFediverseFollowersFunc: func(blogID string) (*api.OrderedCollection, error) {
+// 				panic("mock out the GetFediverseFollowers method")
+// 			},
+// 			GetMentionsFunc: func(postID string) ([]*api.Mention, error) {
+// 				panic("mock out the GetMentions method")
+// 			},
+// 			GetOutboxFunc: func(blogID string) (*api.OrderedCollection, error) {
+// 				panic("mock out the GetOutbox method")
+// 			},
+// 			GetPostByIDFunc: func(id string) (*api.Post, error) {
+// 				panic("mock out the GetPostByID method")
+// 			},
+// 			GetPostByLinkFunc: func(blogID string, link string) (*api.Post, error) {
+// 				panic("mock out the GetPostByLink method")
+// 			},
+// 			GetPostRevisionsFunc: func(id string) ([]*api.PostRevision, error) {
+// 				panic("mock out the GetPostRevisions method")
+// 			},
 // 			GetPostsFunc: func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Post, int64, error) {
 // 				panic("mock out the GetPosts method")
 // 			},
+// 			GetPostsAfterFunc: func(eventID string, filter *api.PostStreamFilter, limit int) ([]*api.Post, bool, error) {
+// 				panic("mock out the GetPostsAfter method")
+// 			},
+// 			GetQueuedMentionsFunc: func(now time.Time, limit int) ([]*api.ReceivedMention, error) {
+// 				panic("mock out the GetQueuedMentions method")
+// 			},
+// 			GetRelatedPostsFunc: func(id string, limit int) ([]*api.Post, error) {
+// 				panic("mock out the GetRelatedPosts method")
+// 			},
+// 			GetSavedSearchFunc: func(id string) (*api.SavedSearch, error) {
+// 				panic("mock out the GetSavedSearch method")
+// 			},
+// 			GetSavedSearchMatchesFunc: func(searchID string, page int, limit int) ([]*api.SavedSearchMatch, int64, error) {
+// 				panic("mock out the GetSavedSearchMatches method")
+// 			},
+// 			GetSavedSearchesFunc: func(userID string) ([]*api.SavedSearch, error) {
+// 				panic("mock out the GetSavedSearches method")
+// 			},
+// 			GetSentMentionsFunc: func(blogID string, status string) ([]*api.SentMention, error) {
+// 				panic("mock out the GetSentMentions method")
+// 			},
+// 			GetWebFingerFunc: func(resource string) (*api.WebFinger, error) {
+// 				panic("mock out the GetWebFinger method")
+// 			},
+// 			IsBlogOwnerFunc: func(blogID string, userID string) (bool, error) {
+// 				panic("mock out the IsBlogOwner method")
+// 			},
 // 			MigrateFunc: func(ctx context.Context) error {
 // 				panic("mock out the Migrate method")
 // 			},
+// 			PostsAddedFunc: func(eventIDs []string) error {
+// 				panic("mock out the PostsAdded method")
+// 			},
+// 			QueueMentionFunc: func(mention *api.ReceivedMention) error {
+// 				panic("mock out the QueueMention method")
+// 			},
+// 			RecordFetchFunc: func(blogID string, fetched *api.BlogFetchedPayload) error {
+// 				panic("mock out the RecordFetch method")
+// 			},
+// 			RecordPostViewFunc: func(id string, at time.Time) (bool, error) {
+// 				panic("mock out the RecordPostView method")
+// 			},
+// 			RemoveQueuedMentionFunc: func(id uint) error {
+// 				panic("mock out the RemoveQueuedMention method")
+// 			},
+// 			RetryQueuedMentionFunc: func(id uint, checkErr error, next time.Time) error {
+// 				panic("mock out the RetryQueuedMention method")
+// 			},
+// 			SubscribePostsFunc: func(filter *api.PostStreamFilter) *api.PostListener {
+// 				panic("mock out the SubscribePosts method")
+// 			},
+// 			UnsubscribePostsFunc: func(listener *api.PostListener)  {
+// 				panic("mock out the UnsubscribePosts method")
+// 			},
 // 		}
 //
 // 		// use mockedProjection in code that requires api.Projection
@@ -45,26 +154,164 @@ var _ api.Projection = &ProjectionMock{}
 //
 // 	}
 type ProjectionMock struct {
+	// FediverseFollowedFunc mocks the FediverseFollowed method.
+	FediverseFollowedFunc func(blogID string, follower *api.BlogFediverseFollowedPayload) error
+
+	// GetActorFunc mocks the GetActor method.
+	GetActorFunc func(blogID string) (*api.Actor, error)
+
+	// GetAuthorFunc mocks the GetAuthor method.
+	GetAuthorFunc func(id uint) (*api.AuthorDetail, error)
+
+	// GetAuthorsFunc mocks the GetAuthors method.
+	GetAuthorsFunc func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Author, int64, error)
+
 	// GetBlogByIDFunc mocks the GetBlogByID method.
 	GetBlogByIDFunc func(id string) (*api.Blog, error)
 
 	// GetBlogByURLFunc mocks the GetBlogByURL method.
 	GetBlogByURLFunc func(url string) (*api.Blog, error)
 
+	// GetBlogClaimFunc mocks the GetBlogClaim method.
+	GetBlogClaimFunc func(blogID string, userID string) (*api.BlogClaim, error)
+
+	// GetBlogStatsFunc mocks the GetBlogStats method.
+	GetBlogStatsFunc func(blogID string, from time.Time, to time.Time) (*api.BlogStats, error)
+
+	// GetBlogsFunc mocks the GetBlogs method.
+	GetBlogsFunc func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Blog, int64, error)
+
 	// GetCategoriesFunc mocks the GetCategories method.
 	GetCategoriesFunc func(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Category, int64, error)
 
+	// GetCategoryFunc mocks the GetCategory method.
+	GetCategoryFunc func(slug string) (*api.CategoryDetail, error)
+
+	// GetCategoryByIDFunc mocks the GetCategoryByID method.
+	GetCategoryByIDFunc func(id uint) (*api.Category, error)
+
+	// GetCategorySynonymsFunc mocks the GetCategorySynonyms method.
+	GetCategorySynonymsFunc func(categoryID uint) ([]*api.CategorySynonym, error)
+
+	// GetDigestSubscriptionFunc mocks the GetDigestSubscription method.
+	GetDigestSubscriptionFunc func(id string) (*api.DigestSubscription, error)
+
+	// GetDigestSubscriptionByTokenFunc mocks the GetDigestSubscriptionByToken method.
+	GetDigestSubscriptionByTokenFunc func(token string) (*api.DigestSubscription, error)
+
+	// GetDigestSubscriptionsFunc mocks the GetDigestSubscriptions method.
+	GetDigestSubscriptionsFunc func(userID string) ([]*api.DigestSubscription, error)
+
 	// GetEventHandlerFunc mocks the GetEventHandler method.
 	GetEventHandlerFunc func() weos.EventHandler
 
+	// GetFediverseFollowersFunc mocks the GetFediverseFollowers method.
+	GetFediverseFollowersFunc func(blogID string) (*api.OrderedCollection, error)
+
+	// GetMentionsFunc mocks the GetMentions method.
+	GetMentionsFunc func(postID string) ([]*api.Mention, error)
+
+	// GetOutboxFunc mocks the GetOutbox method.
+	GetOutboxFunc func(blogID string) (*api.OrderedCollection, error)
+
+	// GetPostByIDFunc mocks the GetPostByID method.
+	GetPostByIDFunc func(id string) (*api.Post, error)
+
+	// GetPostByLinkFunc mocks the GetPostByLink method.
+	GetPostByLinkFunc func(blogID string, link string) (*api.Post, error)
+
+	// GetPostRevisionsFunc mocks the GetPostRevisions method.
+	GetPostRevisionsFunc func(id string) ([]*api.PostRevision, error)
+
 	// GetPostsFunc mocks the GetPosts method.
 	GetPostsFunc func(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Post, int64, error)
 
+	// GetPostsAfterFunc mocks the GetPostsAfter method.
+	GetPostsAfterFunc func(eventID string, filter *api.PostStreamFilter, limit int) ([]*api.Post, bool, error)
+
+	// GetQueuedMentionsFunc mocks the GetQueuedMentions method.
+	GetQueuedMentionsFunc func(now time.Time, limit int) ([]*api.ReceivedMention, error)
+
+	// GetRelatedPostsFunc mocks the GetRelatedPosts method.
+	GetRelatedPostsFunc func(id string, limit int) ([]*api.Post, error)
+
+	// GetSavedSearchFunc mocks the GetSavedSearch method.
+	GetSavedSearchFunc func(id string) (*api.SavedSearch, error)
+
+	// GetSavedSearchMatchesFunc mocks the GetSavedSearchMatches method.
+	GetSavedSearchMatchesFunc func(searchID string, page int, limit int) ([]*api.SavedSearchMatch, int64, error)
+
+	// GetSavedSearchesFunc mocks the GetSavedSearches method.
+	GetSavedSearchesFunc func(userID string) ([]*api.SavedSearch, error)
+
+	// GetSentMentionsFunc mocks the GetSentMentions method.
+	GetSentMentionsFunc func(blogID string, status string) ([]*api.SentMention, error)
+
+	// GetWebFingerFunc mocks the GetWebFinger method.
+	GetWebFingerFunc func(resource string) (*api.WebFinger, error)
+
+	// IsBlogOwnerFunc mocks the IsBlogOwner method.
+	IsBlogOwnerFunc func(blogID string, userID string) (bool, error)
+
 	// MigrateFunc mocks the Migrate method.
 	MigrateFunc func(ctx context.Context) error
 
+	// PostsAddedFunc mocks the PostsAdded method.
+	PostsAddedFunc func(eventIDs []string) error
+
+	// QueueMentionFunc mocks the QueueMention method.
+	QueueMentionFunc func(mention *api.ReceivedMention) error
+
+	// RecordFetchFunc mocks the RecordFetch method.
+	RecordFetchFunc func(blogID string, fetched *api.BlogFetchedPayload) error
+
+	// RecordPostViewFunc mocks the RecordPostView method.
+	RecordPostViewFunc func(id string, at time.Time) (bool, error)
+
+	// RemoveQueuedMentionFunc mocks the RemoveQueuedMention method.
+	RemoveQueuedMentionFunc func(id uint) error
+
+	// RetryQueuedMentionFunc mocks the RetryQueuedMention method.
+	RetryQueuedMentionFunc func(id uint, checkErr error, next time.Time) error
+
+	// SubscribePostsFunc mocks the SubscribePosts method.
+	SubscribePostsFunc func(filter *api.PostStreamFilter) *api.PostListener
+
+	// UnsubscribePostsFunc mocks the UnsubscribePosts method.
+	UnsubscribePostsFunc func(listener *api.PostListener)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// FediverseFollowed holds details about calls to the FediverseFollowed method.
+		FediverseFollowed []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// Follower is the follower argument value.
+			Follower *api.BlogFediverseFollowedPayload
+		}
+		// GetActor holds details about calls to the GetActor method.
+		GetActor []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+		}
+		// GetAuthor holds details about calls to the GetAuthor method.
+		GetAuthor []struct {
+			// ID is the id argument value.
+			ID uint
+		}
+		// GetAuthors holds details about calls to the GetAuthors method.
+		GetAuthors []struct {
+			// Page is the page argument value.
+			Page int
+			// Limit is the limit argument value.
+			Limit int
+			// Query is the query argument value.
+			Query string
+			// SortOptions is the sortOptions argument value.
+			SortOptions map[string]string
+			// FilterOptions is the filterOptions argument value.
+			FilterOptions map[string]interface{}
+		}
 		// GetBlogByID holds details about calls to the GetBlogByID method.
 		GetBlogByID []struct {
 			// ID is the id argument value.
@@ -75,6 +322,35 @@ type ProjectionMock struct {
 			// URL is the url argument value.
 			URL string
 		}
+		// GetBlogClaim holds details about calls to the GetBlogClaim method.
+		GetBlogClaim []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetBlogStats holds details about calls to the GetBlogStats method.
+		GetBlogStats []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+		}
+		// GetBlogs holds details about calls to the GetBlogs method.
+		GetBlogs []struct {
+			// Page is the page argument value.
+			Page int
+			// Limit is the limit argument value.
+			Limit int
+			// Query is the query argument value.
+			Query string
+			// SortOptions is the sortOptions argument value.
+			SortOptions map[string]string
+			// FilterOptions is the filterOptions argument value.
+			FilterOptions map[string]interface{}
+		}
 		// GetCategories holds details about calls to the GetCategories method.
 		GetCategories []struct {
 			// Page is the page argument value.
@@ -86,9 +362,71 @@ type ProjectionMock struct {
 			// FilterOptions is the filterOptions argument value.
 			FilterOptions map[string]interface{}
 		}
+		// GetCategory holds details about calls to the GetCategory method.
+		GetCategory []struct {
+			// Slug is the slug argument value.
+			Slug string
+		}
+		// GetCategoryByID holds details about calls to the GetCategoryByID method.
+		GetCategoryByID []struct {
+			// ID is the id argument value.
+			ID uint
+		}
+		// GetCategorySynonyms holds details about calls to the GetCategorySynonyms method.
+		GetCategorySynonyms []struct {
+			// CategoryID is the categoryID argument value.
+			CategoryID uint
+		}
+		// GetDigestSubscription holds details about calls to the GetDigestSubscription method.
+		GetDigestSubscription []struct {
+			// ID is the id argument value.
+			ID string
+		}
+		// GetDigestSubscriptionByToken holds details about calls to the GetDigestSubscriptionByToken method.
+		GetDigestSubscriptionByToken []struct {
+			// Token is the token argument value.
+			Token string
+		}
+		// GetDigestSubscriptions holds details about calls to the GetDigestSubscriptions method.
+		GetDigestSubscriptions []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
 		// GetEventHandler holds details about calls to the GetEventHandler method.
 		GetEventHandler []struct {
 		}
+		// GetFediverseFollowers holds details about calls to the GetFediverseFollowers method.
+		GetFediverseFollowers []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+		}
+		// GetMentions holds details about calls to the GetMentions method.
+		GetMentions []struct {
+			// PostID is the postID argument value.
+			PostID string
+		}
+		// GetOutbox holds details about calls to the GetOutbox method.
+		GetOutbox []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+		}
+		// GetPostByID holds details about calls to the GetPostByID method.
+		GetPostByID []struct {
+			// ID is the id argument value.
+			ID string
+		}
+		// GetPostByLink holds details about calls to the GetPostByLink method.
+		GetPostByLink []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// Link is the link argument value.
+			Link string
+		}
+		// GetPostRevisions holds details about calls to the GetPostRevisions method.
+		GetPostRevisions []struct {
+			// ID is the id argument value.
+			ID string
+		}
 		// GetPosts holds details about calls to the GetPosts method.
 		GetPosts []struct {
 			// Page is the page argument value.
@@ -102,18 +440,307 @@ type ProjectionMock struct {
 			// FilterOptions is the filterOptions argument value.
 			FilterOptions map[string]interface{}
 		}
+		// GetPostsAfter holds details about calls to the GetPostsAfter method.
+		GetPostsAfter []struct {
+			// EventID is the eventID argument value.
+			EventID string
+			// Filter is the filter argument value.
+			Filter *api.PostStreamFilter
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetQueuedMentions holds details about calls to the GetQueuedMentions method.
+		GetQueuedMentions []struct {
+			// Now is the now argument value.
+			Now time.Time
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetRelatedPosts holds details about calls to the GetRelatedPosts method.
+		GetRelatedPosts []struct {
+			// ID is the id argument value.
+			ID string
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetSavedSearch holds details about calls to the GetSavedSearch method.
+		GetSavedSearch []struct {
+			// ID is the id argument value.
+			ID string
+		}
+		// GetSavedSearchMatches holds details about calls to the GetSavedSearchMatches method.
+		GetSavedSearchMatches []struct {
+			// SearchID is the searchID argument value.
+			SearchID string
+			// Page is the page argument value.
+			Page int
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetSavedSearches holds details about calls to the GetSavedSearches method.
+		GetSavedSearches []struct {
+			// UserID is the userID argument value.
+			UserID string
+		}
+		// GetSentMentions holds details about calls to the GetSentMentions method.
+		GetSentMentions []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// Status is the status argument value.
+			Status string
+		}
+		// GetWebFinger holds details about calls to the GetWebFinger method.
+		GetWebFinger []struct {
+			// Resource is the resource argument value.
+			Resource string
+		}
+		// IsBlogOwner holds details about calls to the IsBlogOwner method.
+		IsBlogOwner []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// UserID is the userID argument value.
+			UserID string
+		}
 		// Migrate holds details about calls to the Migrate method.
 		Migrate []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// PostsAdded holds details about calls to the PostsAdded method.
+		PostsAdded []struct {
+			// EventIDs is the eventIDs argument value.
+			EventIDs []string
+		}
+		// QueueMention holds details about calls to the QueueMention method.
+		QueueMention []struct {
+			// Mention is the mention argument value.
+			Mention *api.ReceivedMention
+		}
+		// RecordFetch holds details about calls to the RecordFetch method.
+		RecordFetch []struct {
+			// BlogID is the blogID argument value.
+			BlogID string
+			// Fetched is the fetched argument value.
+			Fetched *api.BlogFetchedPayload
+		}
+		// RecordPostView holds details about calls to the RecordPostView method.
+		RecordPostView []struct {
+			// ID is the id argument value.
+			ID string
+			// At is the at argument value.
+			At time.Time
+		}
+		// RemoveQueuedMention holds details about calls to the RemoveQueuedMention method.
+		RemoveQueuedMention []struct {
+			// ID is the id argument value.
+			ID uint
+		}
+		// RetryQueuedMention holds details about calls to the RetryQueuedMention method.
+		RetryQueuedMention []struct {
+			// ID is the id argument value.
+			ID uint
+			// CheckErr is the checkErr argument value.
+			CheckErr error
+			// Next is the next argument value.
+			Next time.Time
+		}
+		// SubscribePosts holds details about calls to the SubscribePosts method.
+		SubscribePosts []struct {
+			// Filter is the filter argument value.
+			Filter *api.PostStreamFilter
+		}
+		// UnsubscribePosts holds details about calls to the UnsubscribePosts method.
+		UnsubscribePosts []struct {
+			// Listener is the listener argument value.
+			Listener *api.PostListener
+		}
+	}
+	lockFediverseFollowed            sync.RWMutex
+	lockGetActor                     sync.RWMutex
+	lockGetAuthor                    sync.RWMutex
+	lockGetAuthors                   sync.RWMutex
+	lockGetBlogByID                  sync.RWMutex
+	lockGetBlogByURL                 sync.RWMutex
+	lockGetBlogClaim                 sync.RWMutex
+	lockGetBlogStats                 sync.RWMutex
+	lockGetBlogs                     sync.RWMutex
+	lockGetCategories                sync.RWMutex
+	lockGetCategory                  sync.RWMutex
+	lockGetCategoryByID              sync.RWMutex
+	lockGetCategorySynonyms          sync.RWMutex
+	lockGetDigestSubscription        sync.RWMutex
+	lockGetDigestSubscriptionByToken sync.RWMutex
+	lockGetDigestSubscriptions       sync.RWMutex
+	lockGetEventHandler              sync.RWMutex
+	lockGetFediverseFollowers        sync.RWMutex
+	lockGetMentions                  sync.RWMutex
+	lockGetOutbox                    sync.RWMutex
+	lockGetPostByID                  sync.RWMutex
+	lockGetPostByLink                sync.RWMutex
+	lockGetPostRevisions             sync.RWMutex
+	lockGetPosts                     sync.RWMutex
+	lockGetPostsAfter                sync.RWMutex
+	lockGetQueuedMentions            sync.RWMutex
+	lockGetRelatedPosts              sync.RWMutex
+	lockGetSavedSearch               sync.RWMutex
+	lockGetSavedSearchMatches        sync.RWMutex
+	lockGetSavedSearches             sync.RWMutex
+	lockGetSentMentions              sync.RWMutex
+	lockGetWebFinger                 sync.RWMutex
+	lockIsBlogOwner                  sync.RWMutex
+	lockMigrate                      sync.RWMutex
+	lockPostsAdded                   sync.RWMutex
+	lockQueueMention                 sync.RWMutex
+	lockRecordFetch                  sync.RWMutex
+	lockRecordPostView               sync.RWMutex
+	lockRemoveQueuedMention          sync.RWMutex
+	lockRetryQueuedMention           sync.RWMutex
+	lockSubscribePosts               sync.RWMutex
+	lockUnsubscribePosts             sync.RWMutex
+}
+
+// FediverseFollowed calls FediverseFollowedFunc.
+func (mock *ProjectionMock) FediverseFollowed(blogID string, follower *api.BlogFediverseFollowedPayload) error {
+	if mock.FediverseFollowedFunc == nil {
+		panic("ProjectionMock.FediverseFollowedFunc: method is nil but Projection.FediverseFollowed was just called")
+	}
+	callInfo := struct {
+		BlogID   string
+		Follower *api.BlogFediverseFollowedPayload
+	}{
+		BlogID:   blogID,
+		Follower: follower,
+	}
+	mock.lockFediverseFollowed.Lock()
+	mock.calls.FediverseFollowed = append(mock.calls.FediverseFollowed, callInfo)
+	mock.lockFediverseFollowed.Unlock()
+	return mock.FediverseFollowedFunc(blogID, follower)
+}
+
+// FediverseFollowedCalls gets all the calls that were made to FediverseFollowed.
+// Check the length with:
+//     len(mockedProjection.FediverseFollowedCalls())
+func (mock *ProjectionMock) FediverseFollowedCalls() []struct {
+	BlogID   string
+	Follower *api.BlogFediverseFollowedPayload
+} {
+	var calls []struct {
+		BlogID   string
+		Follower *api.BlogFediverseFollowedPayload
+	}
+	mock.lockFediverseFollowed.RLock()
+	calls = mock.calls.FediverseFollowed
+	mock.lockFediverseFollowed.RUnlock()
+	return calls
+}
+
+// GetActor calls GetActorFunc.
+func (mock *ProjectionMock) GetActor(blogID string) (*api.Actor, error) {
+	if mock.GetActorFunc == nil {
+		panic("ProjectionMock.GetActorFunc: method is nil but Projection.GetActor was just called")
+	}
+	callInfo := struct {
+		BlogID string
+	}{
+		BlogID: blogID,
+	}
+	mock.lockGetActor.Lock()
+	mock.calls.GetActor = append(mock.calls.GetActor, callInfo)
+	mock.lockGetActor.Unlock()
+	return mock.GetActorFunc(blogID)
+}
+
+// GetActorCalls gets all the calls that were made to GetActor.
+// Check the length with:
+//     len(mockedProjection.GetActorCalls())
+func (mock *ProjectionMock) GetActorCalls() []struct {
+	BlogID string
+} {
+	var calls []struct {
+		BlogID string
+	}
+	mock.lockGetActor.RLock()
+	calls = mock.calls.GetActor
+	mock.lockGetActor.RUnlock()
+	return calls
+}
+
+// GetAuthor calls GetAuthorFunc.
+func (mock *ProjectionMock) GetAuthor(id uint) (*api.AuthorDetail, error) {
+	if mock.GetAuthorFunc == nil {
+		panic("ProjectionMock.GetAuthorFunc: method is nil but Projection.GetAuthor was just called")
+	}
+	callInfo := struct {
+		ID uint
+	}{
+		ID: id,
+	}
+	mock.lockGetAuthor.Lock()
+	mock.calls.GetAuthor = append(mock.calls.GetAuthor, callInfo)
+	mock.lockGetAuthor.Unlock()
+	return mock.GetAuthorFunc(id)
+}
+
+// GetAuthorCalls gets all the calls that were made to GetAuthor.
+// Check the length with:
+//     len(mockedProjection.GetAuthorCalls())
+func (mock *ProjectionMock) GetAuthorCalls() []struct {
+	ID uint
+} {
+	var calls []struct {
+		ID uint
+	}
+	mock.lockGetAuthor.RLock()
+	calls = mock.calls.GetAuthor
+	mock.lockGetAuthor.RUnlock()
+	return calls
+}
+
+// GetAuthors calls GetAuthorsFunc.
+func (mock *ProjectionMock) GetAuthors(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Author, int64, error) {
+	if mock.GetAuthorsFunc == nil {
+		panic("ProjectionMock.GetAuthorsFunc: method is nil but Projection.GetAuthors was just called")
+	}
+	callInfo := struct {
+		Page          int
+		Limit         int
+		Query         string
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
+	}{
+		Page:          page,
+		Limit:         limit,
+		Query:         query,
+		SortOptions:   sortOptions,
+		FilterOptions: filterOptions,
+	}
+	mock.lockGetAuthors.Lock()
+	mock.calls.GetAuthors = append(mock.calls.GetAuthors, callInfo)
+	mock.lockGetAuthors.Unlock()
+	return mock.GetAuthorsFunc(page, limit, query, sortOptions, filterOptions)
+}
+
+// GetAuthorsCalls gets all the calls that were made to GetAuthors.
+// Check the length with:
+//     len(mockedProjection.GetAuthorsCalls())
+func (mock *ProjectionMock) GetAuthorsCalls() []struct {
+	Page          int
+	Limit         int
+	Query         string
+	SortOptions   map[string]string
+	FilterOptions map[string]interface{}
+} {
+	var calls []struct {
+		Page          int
+		Limit         int
+		Query         string
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
 	}
-	lockGetBlogByID     sync.RWMutex
-	lockGetBlogByURL    sync.RWMutex
-	lockGetCategories   sync.RWMutex
-	lockGetEventHandler sync.RWMutex
-	lockGetPosts        sync.RWMutex
-	lockMigrate         sync.RWMutex
+	mock.lockGetAuthors.RLock()
+	calls = mock.calls.GetAuthors
+	mock.lockGetAuthors.RUnlock()
+	return calls
 }
 
 // GetBlogByID calls GetBlogByIDFunc.
@@ -178,49 +805,356 @@ func (mock *ProjectionMock) GetBlogByURLCalls() []struct {
 	return calls
 }
 
-// GetCategories calls GetCategoriesFunc.
-func (mock *ProjectionMock) GetCategories(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Category, int64, error) {
-	if mock.GetCategoriesFunc == nil {
-		panic("ProjectionMock.GetCategoriesFunc: method is nil but Projection.GetCategories was just called")
+// GetBlogClaim calls GetBlogClaimFunc.
+func (mock *ProjectionMock) GetBlogClaim(blogID string, userID string) (*api.BlogClaim, error) {
+	if mock.GetBlogClaimFunc == nil {
+		panic("ProjectionMock.GetBlogClaimFunc: method is nil but Projection.GetBlogClaim was just called")
 	}
 	callInfo := struct {
-		Page          int
-		Limit         int
-		SortOptions   map[string]string
-		FilterOptions map[string]interface{}
+		BlogID string
+		UserID string
 	}{
-		Page:          page,
-		Limit:         limit,
-		SortOptions:   sortOptions,
-		FilterOptions: filterOptions,
+		BlogID: blogID,
+		UserID: userID,
 	}
-	mock.lockGetCategories.Lock()
-	mock.calls.GetCategories = append(mock.calls.GetCategories, callInfo)
-	mock.lockGetCategories.Unlock()
-	return mock.GetCategoriesFunc(page, limit, sortOptions, filterOptions)
+	mock.lockGetBlogClaim.Lock()
+	mock.calls.GetBlogClaim = append(mock.calls.GetBlogClaim, callInfo)
+	mock.lockGetBlogClaim.Unlock()
+	return mock.GetBlogClaimFunc(blogID, userID)
 }
 
-// GetCategoriesCalls gets all the calls that were made to GetCategories.
+// GetBlogClaimCalls gets all the calls that were made to GetBlogClaim.
 // Check the length with:
-//     len(mockedProjection.GetCategoriesCalls())
-func (mock *ProjectionMock) GetCategoriesCalls() []struct {
-	Page          int
-	Limit         int
-	SortOptions   map[string]string
-	FilterOptions map[string]interface{}
+//     len(mockedProjection.GetBlogClaimCalls())
+func (mock *ProjectionMock) GetBlogClaimCalls() []struct {
+	BlogID string
+	UserID string
 } {
 	var calls []struct {
-		Page          int
-		Limit         int
-		SortOptions   map[string]string
-		FilterOptions map[string]interface{}
+		BlogID string
+		UserID string
 	}
-	mock.lockGetCategories.RLock()
-	calls = mock.calls.GetCategories
+	mock.lockGetBlogClaim.RLock()
+	calls = mock.calls.GetBlogClaim
+	mock.lockGetBlogClaim.RUnlock()
+	return calls
+}
+
+// GetBlogStats calls GetBlogStatsFunc.
+func (mock *ProjectionMock) GetBlogStats(blogID string, from time.Time, to time.Time) (*api.BlogStats, error) {
+	if mock.GetBlogStatsFunc == nil {
+		panic("ProjectionMock.GetBlogStatsFunc: method is nil but Projection.GetBlogStats was just called")
+	}
+	callInfo := struct {
+		BlogID string
+		From   time.Time
+		To     time.Time
+	}{
+		BlogID: blogID,
+		From:   from,
+		To:     to,
+	}
+	mock.lockGetBlogStats.Lock()
+	mock.calls.GetBlogStats = append(mock.calls.GetBlogStats, callInfo)
+	mock.lockGetBlogStats.Unlock()
+	return mock.GetBlogStatsFunc(blogID, from, to)
+}
+
+// GetBlogStatsCalls gets all the calls that were made to GetBlogStats.
+// Check the length with:
+//     len(mockedProjection.GetBlogStatsCalls())
+func (mock *ProjectionMock) GetBlogStatsCalls() []struct {
+	BlogID string
+	From   time.Time
+	To     time.Time
+} {
+	var calls []struct {
+		BlogID string
+		From   time.Time
+		To     time.Time
+	}
+	mock.lockGetBlogStats.RLock()
+	calls = mock.calls.GetBlogStats
+	mock.lockGetBlogStats.RUnlock()
+	return calls
+}
+
+// GetBlogs calls GetBlogsFunc.
+func (mock *ProjectionMock) GetBlogs(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Blog, int64, error) {
+	if mock.GetBlogsFunc == nil {
+		panic("ProjectionMock.GetBlogsFunc: method is nil but Projection.GetBlogs was just called")
+	}
+	callInfo := struct {
+		Page          int
+		Limit         int
+		Query         string
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
+	}{
+		Page:          page,
+		Limit:         limit,
+		Query:         query,
+		SortOptions:   sortOptions,
+		FilterOptions: filterOptions,
+	}
+	mock.lockGetBlogs.Lock()
+	mock.calls.GetBlogs = append(mock.calls.GetBlogs, callInfo)
+	mock.lockGetBlogs.Unlock()
+	return mock.GetBlogsFunc(page, limit, query, sortOptions, filterOptions)
+}
+
+// GetBlogsCalls gets all the calls that were made to GetBlogs.
+// Check the length with:
+//     len(mockedProjection.GetBlogsCalls())
+func (mock *ProjectionMock) GetBlogsCalls() []struct {
+	Page          int
+	Limit         int
+	Query         string
+	SortOptions   map[string]string
+	FilterOptions map[string]interface{}
+} {
+	var calls []struct {
+		Page          int
+		Limit         int
+		Query         string
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
+	}
+	mock.lockGetBlogs.RLock()
+	calls = mock.calls.GetBlogs
+	mock.lockGetBlogs.RUnlock()
+	return calls
+}
+
+// GetCategories calls GetCategoriesFunc.
+func (mock *ProjectionMock) GetCategories(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Category, int64, error) {
+	if mock.GetCategoriesFunc == nil {
+		panic("ProjectionMock.GetCategoriesFunc: method is nil but Projection.GetCategories was just called")
+	}
+	callInfo := struct {
+		Page          int
+		Limit         int
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
+	}{
+		Page:          page,
+		Limit:         limit,
+		SortOptions:   sortOptions,
+		FilterOptions: filterOptions,
+	}
+	mock.lockGetCategories.Lock()
+	mock.calls.GetCategories = append(mock.calls.GetCategories, callInfo)
+	mock.lockGetCategories.Unlock()
+	return mock.GetCategoriesFunc(page, limit, sortOptions, filterOptions)
+}
+
+// GetCategoriesCalls gets all the calls that were made to GetCategories.
+// Check the length with:
+//     len(mockedProjection.GetCategoriesCalls())
+func (mock *ProjectionMock) GetCategoriesCalls() []struct {
+	Page          int
+	Limit         int
+	SortOptions   map[string]string
+	FilterOptions map[string]interface{}
+} {
+	var calls []struct {
+		Page          int
+		Limit         int
+		SortOptions   map[string]string
+		FilterOptions map[string]interface{}
+	}
+	mock.lockGetCategories.RLock()
+	calls = mock.calls.GetCategories
 	mock.lockGetCategories.RUnlock()
 	return calls
 }
 
+// GetCategory calls GetCategoryFunc.
+func (mock *ProjectionMock) GetCategory(slug string) (*api.CategoryDetail, error) {
+	if mock.GetCategoryFunc == nil {
+		panic("ProjectionMock.GetCategoryFunc: method is nil but Projection.GetCategory was just called")
+	}
+	callInfo := struct {
+		Slug string
+	}{
+		Slug: slug,
+	}
+	mock.lockGetCategory.Lock()
+	mock.calls.GetCategory = append(mock.calls.GetCategory, callInfo)
+	mock.lockGetCategory.Unlock()
+	return mock.GetCategoryFunc(slug)
+}
+
+// GetCategoryCalls gets all the calls that were made to GetCategory.
+// Check the length with:
+//     len(mockedProjection.GetCategoryCalls())
+func (mock *ProjectionMock) GetCategoryCalls() []struct {
+	Slug string
+} {
+	var calls []struct {
+		Slug string
+	}
+	mock.lockGetCategory.RLock()
+	calls = mock.calls.GetCategory
+	mock.lockGetCategory.RUnlock()
+	return calls
+}
+
+// GetCategoryByID calls GetCategoryByIDFunc.
+func (mock *ProjectionMock) GetCategoryByID(id uint) (*api.Category, error) {
+	if mock.GetCategoryByIDFunc == nil {
+		panic("ProjectionMock.GetCategoryByIDFunc: method is nil but Projection.GetCategoryByID was just called")
+	}
+	callInfo := struct {
+		ID uint
+	}{
+		ID: id,
+	}
+	mock.lockGetCategoryByID.Lock()
+	mock.calls.GetCategoryByID = append(mock.calls.GetCategoryByID, callInfo)
+	mock.lockGetCategoryByID.Unlock()
+	return mock.GetCategoryByIDFunc(id)
+}
+
+// GetCategoryByIDCalls gets all the calls that were made to GetCategoryByID.
+// Check the length with:
+//     len(mockedProjection.GetCategoryByIDCalls())
+func (mock *ProjectionMock) GetCategoryByIDCalls() []struct {
+	ID uint
+} {
+	var calls []struct {
+		ID uint
+	}
+	mock.lockGetCategoryByID.RLock()
+	calls = mock.calls.GetCategoryByID
+	mock.lockGetCategoryByID.RUnlock()
+	return calls
+}
+
+// GetCategorySynonyms calls GetCategorySynonymsFunc.
+func (mock *ProjectionMock) GetCategorySynonyms(categoryID uint) ([]*api.CategorySynonym, error) {
+	if mock.GetCategorySynonymsFunc == nil {
+		panic("ProjectionMock.GetCategorySynonymsFunc: method is nil but Projection.GetCategorySynonyms was just called")
+	}
+	callInfo := struct {
+		CategoryID uint
+	}{
+		CategoryID: categoryID,
+	}
+	mock.lockGetCategorySynonyms.Lock()
+	mock.calls.GetCategorySynonyms = append(mock.calls.GetCategorySynonyms, callInfo)
+	mock.lockGetCategorySynonyms.Unlock()
+	return mock.GetCategorySynonymsFunc(categoryID)
+}
+
+// GetCategorySynonymsCalls gets all the calls that were made to GetCategorySynonyms.
+// Check the length with:
+//     len(mockedProjection.GetCategorySynonymsCalls())
+func (mock *ProjectionMock) GetCategorySynonymsCalls() []struct {
+	CategoryID uint
+} {
+	var calls []struct {
+		CategoryID uint
+	}
+	mock.lockGetCategorySynonyms.RLock()
+	calls = mock.calls.GetCategorySynonyms
+	mock.lockGetCategorySynonyms.RUnlock()
+	return calls
+}
+
+// GetDigestSubscription calls GetDigestSubscriptionFunc.
+func (mock *ProjectionMock) GetDigestSubscription(id string) (*api.DigestSubscription, error) {
+	if mock.GetDigestSubscriptionFunc == nil {
+		panic("ProjectionMock.GetDigestSubscriptionFunc: method is nil but Projection.GetDigestSubscription was just called")
+	}
+	callInfo := struct {
+		ID string
+	}{
+		ID: id,
+	}
+	mock.lockGetDigestSubscription.Lock()
+	mock.calls.GetDigestSubscription = append(mock.calls.GetDigestSubscription, callInfo)
+	mock.lockGetDigestSubscription.Unlock()
+	return mock.GetDigestSubscriptionFunc(id)
+}
+
+// GetDigestSubscriptionCalls gets all the calls that were made to GetDigestSubscription.
+// Check the length with:
+//     len(mockedProjection.GetDigestSubscriptionCalls())
+func (mock *ProjectionMock) GetDigestSubscriptionCalls() []struct {
+	ID string
+} {
+	var calls []struct {
+		ID string
+	}
+	mock.lockGetDigestSubscription.RLock()
+	calls = mock.calls.GetDigestSubscription
+	mock.lockGetDigestSubscription.RUnlock()
+	return calls
+}
+
+// GetDigestSubscriptionByToken calls GetDigestSubscriptionByTokenFunc.
+func (mock *ProjectionMock) GetDigestSubscriptionByToken(token string) (*api.DigestSubscription, error) {
+	if mock.GetDigestSubscriptionByTokenFunc == nil {
+		panic("ProjectionMock.GetDigestSubscriptionByTokenFunc: method is nil but Projection.GetDigestSubscriptionByToken was just called")
+	}
+	callInfo := struct {
+		Token string
+	}{
+		Token: token,
+	}
+	mock.lockGetDigestSubscriptionByToken.Lock()
+	mock.calls.GetDigestSubscriptionByToken = append(mock.calls.GetDigestSubscriptionByToken, callInfo)
+	mock.lockGetDigestSubscriptionByToken.Unlock()
+	return mock.GetDigestSubscriptionByTokenFunc(token)
+}
+
+// GetDigestSubscriptionByTokenCalls gets all the calls that were made to GetDigestSubscriptionByToken.
+// Check the length with:
+//     len(mockedProjection.GetDigestSubscriptionByTokenCalls())
+func (mock *ProjectionMock) GetDigestSubscriptionByTokenCalls() []struct {
+	Token string
+} {
+	var calls []struct {
+		Token string
+	}
+	mock.lockGetDigestSubscriptionByToken.RLock()
+	calls = mock.calls.GetDigestSubscriptionByToken
+	mock.lockGetDigestSubscriptionByToken.RUnlock()
+	return calls
+}
+
+// GetDigestSubscriptions calls GetDigestSubscriptionsFunc.
+func (mock *ProjectionMock) GetDigestSubscriptions(userID string) ([]*api.DigestSubscription, error) {
+	if mock.GetDigestSubscriptionsFunc == nil {
+		panic("ProjectionMock.GetDigestSubscriptionsFunc: method is nil but Projection.GetDigestSubscriptions was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetDigestSubscriptions.Lock()
+	mock.calls.GetDigestSubscriptions = append(mock.calls.GetDigestSubscriptions, callInfo)
+	mock.lockGetDigestSubscriptions.Unlock()
+	return mock.GetDigestSubscriptionsFunc(userID)
+}
+
+// GetDigestSubscriptionsCalls gets all the calls that were made to GetDigestSubscriptions.
+// Check the length with:
+//     len(mockedProjection.GetDigestSubscriptionsCalls())
+func (mock *ProjectionMock) GetDigestSubscriptionsCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetDigestSubscriptions.RLock()
+	calls = mock.calls.GetDigestSubscriptions
+	mock.lockGetDigestSubscriptions.RUnlock()
+	return calls
+}
+
 // GetEventHandler calls GetEventHandlerFunc.
 func (mock *ProjectionMock) GetEventHandler() weos.EventHandler {
 	if mock.GetEventHandlerFunc == nil {
@@ -247,6 +1181,196 @@ func (mock *ProjectionMock) GetEventHandlerCalls() []struct {
 	return calls
 }
 
+// GetFediverseFollowers calls GetFediverseFollowersFunc.
+func (mock *ProjectionMock) GetFediverseFollowers(blogID string) (*api.OrderedCollection, error) {
+	if mock.GetFediverseFollowersFunc == nil {
+		panic("ProjectionMock.GetFediverseFollowersFunc: method is nil but Projection.GetFediverseFollowers was just called")
+	}
+	callInfo := struct {
+		BlogID string
+	}{
+		BlogID: blogID,
+	}
+	mock.lockGetFediverseFollowers.Lock()
+	mock.calls.GetFediverseFollowers = append(mock.calls.GetFediverseFollowers, callInfo)
+	mock.lockGetFediverseFollowers.Unlock()
+	return mock.GetFediverseFollowersFunc(blogID)
+}
+
+// GetFediverseFollowersCalls gets all the calls that were made to GetFediverseFollowers.
+// Check the length with:
+//     len(mockedProjection.GetFediverseFollowersCalls())
+func (mock *ProjectionMock) GetFediverseFollowersCalls() []struct {
+	BlogID string
+} {
+	var calls []struct {
+		BlogID string
+	}
+	mock.lockGetFediverseFollowers.RLock()
+	calls = mock.calls.GetFediverseFollowers
+	mock.lockGetFediverseFollowers.RUnlock()
+	return calls
+}
+
+// GetMentions calls GetMentionsFunc.
+func (mock *ProjectionMock) GetMentions(postID string) ([]*api.Mention, error) {
+	if mock.GetMentionsFunc == nil {
+		panic("ProjectionMock.GetMentionsFunc: method is nil but Projection.GetMentions was just called")
+	}
+	callInfo := struct {
+		PostID string
+	}{
+		PostID: postID,
+	}
+	mock.lockGetMentions.Lock()
+	mock.calls.GetMentions = append(mock.calls.GetMentions, callInfo)
+	mock.lockGetMentions.Unlock()
+	return mock.GetMentionsFunc(postID)
+}
+
+// GetMentionsCalls gets all the calls that were made to GetMentions.
+// Check the length with:
+//     len(mockedProjection.GetMentionsCalls())
+func (mock *ProjectionMock) GetMentionsCalls() []struct {
+	PostID string
+} {
+	var calls []struct {
+		PostID string
+	}
+	mock.lockGetMentions.RLock()
+	calls = mock.calls.GetMentions
+	mock.lockGetMentions.RUnlock()
+	return calls
+}
+
+// GetOutbox calls GetOutboxFunc.
+func (mock *ProjectionMock) GetOutbox(blogID string) (*api.OrderedCollection, error) {
+	if mock.GetOutboxFunc == nil {
+		panic("ProjectionMock.GetOutboxFunc: method is nil but Projection.GetOutbox was just called")
+	}
+	callInfo := struct {
+		BlogID string
+	}{
+		BlogID: blogID,
+	}
+	mock.lockGetOutbox.Lock()
+	mock.calls.GetOutbox = append(mock.calls.GetOutbox, callInfo)
+	mock.lockGetOutbox.Unlock()
+	return mock.GetOutboxFunc(blogID)
+}
+
+// GetOutboxCalls gets all the calls that were made to GetOutbox.
+// Check the length with:
+//     len(mockedProjection.GetOutboxCalls())
+func (mock *ProjectionMock) GetOutboxCalls() []struct {
+	BlogID string
+} {
+	var calls []struct {
+		BlogID string
+	}
+	mock.lockGetOutbox.RLock()
+	calls = mock.calls.GetOutbox
+	mock.lockGetOutbox.RUnlock()
+	return calls
+}
+
+// GetPostByID calls GetPostByIDFunc.
+func (mock *ProjectionMock) GetPostByID(id string) (*api.Post, error) {
+	if mock.GetPostByIDFunc == nil {
+		panic("ProjectionMock.GetPostByIDFunc: method is nil but Projection.GetPostByID was just called")
+	}
+	callInfo := struct {
+		ID string
+	}{
+		ID: id,
+	}
+	mock.lockGetPostByID.Lock()
+	mock.calls.GetPostByID = append(mock.calls.GetPostByID, callInfo)
+	mock.lockGetPostByID.Unlock()
+	return mock.GetPostByIDFunc(id)
+}
+
+// GetPostByIDCalls gets all the calls that were made to GetPostByID.
+// Check the length with:
+//     len(mockedProjection.GetPostByIDCalls())
+func (mock *ProjectionMock) GetPostByIDCalls() []struct {
+	ID string
+} {
+	var calls []struct {
+		ID string
+	}
+	mock.lockGetPostByID.RLock()
+	calls = mock.calls.GetPostByID
+	mock.lockGetPostByID.RUnlock()
+	return calls
+}
+
+// GetPostByLink calls GetPostByLinkFunc.
+func (mock *ProjectionMock) GetPostByLink(blogID string, link string) (*api.Post, error) {
+	if mock.GetPostByLinkFunc == nil {
+		panic("ProjectionMock.GetPostByLinkFunc: method is nil but Projection.GetPostByLink was just called")
+	}
+	callInfo := struct {
+		BlogID string
+		Link   string
+	}{
+		BlogID: blogID,
+		Link:   link,
+	}
+	mock.lockGetPostByLink.Lock()
+	mock.calls.GetPostByLink = append(mock.calls.GetPostByLink, callInfo)
+	mock.lockGetPostByLink.Unlock()
+	return mock.GetPostByLinkFunc(blogID, link)
+}
+
+// GetPostByLinkCalls gets all the calls that were made to GetPostByLink.
+// Check the length with:
+//     len(mockedProjection.GetPostByLinkCalls())
+func (mock *ProjectionMock) GetPostByLinkCalls() []struct {
+	BlogID string
+	Link   string
+} {
+	var calls []struct {
+		BlogID string
+		Link   string
+	}
+	mock.lockGetPostByLink.RLock()
+	calls = mock.calls.GetPostByLink
+	mock.lockGetPostByLink.RUnlock()
+	return calls
+}
+
+// GetPostRevisions calls GetPostRevisionsFunc.
+func (mock *ProjectionMock) GetPostRevisions(id string) ([]*api.PostRevision, error) {
+	if mock.GetPostRevisionsFunc == nil {
+		panic("ProjectionMock.GetPostRevisionsFunc: method is nil but Projection.GetPostRevisions was just called")
+	}
+	callInfo := struct {
+		ID string
+	}{
+		ID: id,
+	}
+	mock.lockGetPostRevisions.Lock()
+	mock.calls.GetPostRevisions = append(mock.calls.GetPostRevisions, callInfo)
+	mock.lockGetPostRevisions.Unlock()
+	return mock.GetPostRevisionsFunc(id)
+}
+
+// GetPostRevisionsCalls gets all the calls that were made to GetPostRevisions.
+// Check the length with:
+//     len(mockedProjection.GetPostRevisionsCalls())
+func (mock *ProjectionMock) GetPostRevisionsCalls() []struct {
+	ID string
+} {
+	var calls []struct {
+		ID string
+	}
+	mock.lockGetPostRevisions.RLock()
+	calls = mock.calls.GetPostRevisions
+	mock.lockGetPostRevisions.RUnlock()
+	return calls
+}
+
 // GetPosts calls GetPostsFunc.
 func (mock *ProjectionMock) GetPosts(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*api.Post, int64, error) {
 	if mock.GetPostsFunc == nil {
@@ -294,6 +1418,317 @@ func (mock *ProjectionMock) GetPostsCalls() []struct {
 	return calls
 }
 
+// GetPostsAfter calls GetPostsAfterFunc.
+func (mock *ProjectionMock) GetPostsAfter(eventID string, filter *api.PostStreamFilter, limit int) ([]*api.Post, bool, error) {
+	if mock.GetPostsAfterFunc == nil {
+		panic("ProjectionMock.GetPostsAfterFunc: method is nil but Projection.GetPostsAfter was just called")
+	}
+	callInfo := struct {
+		EventID string
+		Filter  *api.PostStreamFilter
+		Limit   int
+	}{
+		EventID: eventID,
+		Filter:  filter,
+		Limit:   limit,
+	}
+	mock.lockGetPostsAfter.Lock()
+	mock.calls.GetPostsAfter = append(mock.calls.GetPostsAfter, callInfo)
+	mock.lockGetPostsAfter.Unlock()
+	return mock.GetPostsAfterFunc(eventID, filter, limit)
+}
+
+// GetPostsAfterCalls gets all the calls that were made to GetPostsAfter.
+// Check the length with:
+//     len(mockedProjection.GetPostsAfterCalls())
+func (mock *ProjectionMock) GetPostsAfterCalls() []struct {
+	EventID string
+	Filter  *api.PostStreamFilter
+	Limit   int
+} {
+	var calls []struct {
+		EventID string
+		Filter  *api.PostStreamFilter
+		Limit   int
+	}
+	mock.lockGetPostsAfter.RLock()
+	calls = mock.calls.GetPostsAfter
+	mock.lockGetPostsAfter.RUnlock()
+	return calls
+}
+
+// GetQueuedMentions calls GetQueuedMentionsFunc.
+func (mock *ProjectionMock) GetQueuedMentions(now time.Time, limit int) ([]*api.ReceivedMention, error) {
+	if mock.GetQueuedMentionsFunc == nil {
+		panic("ProjectionMock.GetQueuedMentionsFunc: method is nil but Projection.GetQueuedMentions was just called")
+	}
+	callInfo := struct {
+		Now   time.Time
+		Limit int
+	}{
+		Now:   now,
+		Limit: limit,
+	}
+	mock.lockGetQueuedMentions.Lock()
+	mock.calls.GetQueuedMentions = append(mock.calls.GetQueuedMentions, callInfo)
+	mock.lockGetQueuedMentions.Unlock()
+	return mock.GetQueuedMentionsFunc(now, limit)
+}
+
+// GetQueuedMentionsCalls gets all the calls that were made to GetQueuedMentions.
+// Check the length with:
+//     len(mockedProjection.GetQueuedMentionsCalls())
+func (mock *ProjectionMock) GetQueuedMentionsCalls() []struct {
+	Now   time.Time
+	Limit int
+} {
+	var calls []struct {
+		Now   time.Time
+		Limit int
+	}
+	mock.lockGetQueuedMentions.RLock()
+	calls = mock.calls.GetQueuedMentions
+	mock.lockGetQueuedMentions.RUnlock()
+	return calls
+}
+
+// GetRelatedPosts calls GetRelatedPostsFunc.
+func (mock *ProjectionMock) GetRelatedPosts(id string, limit int) ([]*api.Post, error) {
+	if mock.GetRelatedPostsFunc == nil {
+		panic("ProjectionMock.GetRelatedPostsFunc: method is nil but Projection.GetRelatedPosts was just called")
+	}
+	callInfo := struct {
+		ID    string
+		Limit int
+	}{
+		ID:    id,
+		Limit: limit,
+	}
+	mock.lockGetRelatedPosts.Lock()
+	mock.calls.GetRelatedPosts = append(mock.calls.GetRelatedPosts, callInfo)
+	mock.lockGetRelatedPosts.Unlock()
+	return mock.GetRelatedPostsFunc(id, limit)
+}
+
+// GetRelatedPostsCalls gets all the calls that were made to GetRelatedPosts.
+// Check the length with:
+//     len(mockedProjection.GetRelatedPostsCalls())
+func (mock *ProjectionMock) GetRelatedPostsCalls() []struct {
+	ID    string
+	Limit int
+} {
+	var calls []struct {
+		ID    string
+		Limit int
+	}
+	mock.lockGetRelatedPosts.RLock()
+	calls = mock.calls.GetRelatedPosts
+	mock.lockGetRelatedPosts.RUnlock()
+	return calls
+}
+
+// GetSavedSearch calls GetSavedSearchFunc.
+func (mock *ProjectionMock) GetSavedSearch(id string) (*api.SavedSearch, error) {
+	if mock.GetSavedSearchFunc == nil {
+		panic("ProjectionMock.GetSavedSearchFunc: method is nil but Projection.GetSavedSearch was just called")
+	}
+	callInfo := struct {
+		ID string
+	}{
+		ID: id,
+	}
+	mock.lockGetSavedSearch.Lock()
+	mock.calls.GetSavedSearch = append(mock.calls.GetSavedSearch, callInfo)
+	mock.lockGetSavedSearch.Unlock()
+	return mock.GetSavedSearchFunc(id)
+}
+
+// GetSavedSearchCalls gets all the calls that were made to GetSavedSearch.
+// Check the length with:
+//     len(mockedProjection.GetSavedSearchCalls())
+func (mock *ProjectionMock) GetSavedSearchCalls() []struct {
+	ID string
+} {
+	var calls []struct {
+		ID string
+	}
+	mock.lockGetSavedSearch.RLock()
+	calls = mock.calls.GetSavedSearch
+	mock.lockGetSavedSearch.RUnlock()
+	return calls
+}
+
+// GetSavedSearchMatches calls GetSavedSearchMatchesFunc.
+func (mock *ProjectionMock) GetSavedSearchMatches(searchID string, page int, limit int) ([]*api.SavedSearchMatch, int64, error) {
+	if mock.GetSavedSearchMatchesFunc == nil {
+		panic("ProjectionMock.GetSavedSearchMatchesFunc: method is nil but Projection.GetSavedSearchMatches was just called")
+	}
+	callInfo := struct {
+		SearchID string
+		Page     int
+		Limit    int
+	}{
+		SearchID: searchID,
+		Page:     page,
+		Limit:    limit,
+	}
+	mock.lockGetSavedSearchMatches.Lock()
+	mock.calls.GetSavedSearchMatches = append(mock.calls.GetSavedSearchMatches, callInfo)
+	mock.lockGetSavedSearchMatches.Unlock()
+	return mock.GetSavedSearchMatchesFunc(searchID, page, limit)
+}
+
+// GetSavedSearchMatchesCalls gets all the calls that were made to GetSavedSearchMatches.
+// Check the length with:
+//     len(mockedProjection.GetSavedSearchMatchesCalls())
+func (mock *ProjectionMock) GetSavedSearchMatchesCalls() []struct {
+	SearchID string
+	Page     int
+	Limit    int
+} {
+	var calls []struct {
+		SearchID string
+		Page     int
+		Limit    int
+	}
+	mock.lockGetSavedSearchMatches.RLock()
+	calls = mock.calls.GetSavedSearchMatches
+	mock.lockGetSavedSearchMatches.RUnlock()
+	return calls
+}
+
+// GetSavedSearches calls GetSavedSearchesFunc.
+func (mock *ProjectionMock) GetSavedSearches(userID string) ([]*api.SavedSearch, error) {
+	if mock.GetSavedSearchesFunc == nil {
+		panic("ProjectionMock.GetSavedSearchesFunc: method is nil but Projection.GetSavedSearches was just called")
+	}
+	callInfo := struct {
+		UserID string
+	}{
+		UserID: userID,
+	}
+	mock.lockGetSavedSearches.Lock()
+	mock.calls.GetSavedSearches = append(mock.calls.GetSavedSearches, callInfo)
+	mock.lockGetSavedSearches.Unlock()
+	return mock.GetSavedSearchesFunc(userID)
+}
+
+// GetSavedSearchesCalls gets all the calls that were made to GetSavedSearches.
+// Check the length with:
+//     len(mockedProjection.GetSavedSearchesCalls())
+func (mock *ProjectionMock) GetSavedSearchesCalls() []struct {
+	UserID string
+} {
+	var calls []struct {
+		UserID string
+	}
+	mock.lockGetSavedSearches.RLock()
+	calls = mock.calls.GetSavedSearches
+	mock.lockGetSavedSearches.RUnlock()
+	return calls
+}
+
+// GetSentMentions calls GetSentMentionsFunc.
+func (mock *ProjectionMock) GetSentMentions(blogID string, status string) ([]*api.SentMention, error) {
+	if mock.GetSentMentionsFunc == nil {
+		panic("ProjectionMock.GetSentMentionsFunc: method is nil but Projection.GetSentMentions was just called")
+	}
+	callInfo := struct {
+		BlogID string
+		Status string
+	}{
+		BlogID: blogID,
+		Status: status,
+	}
+	mock.lockGetSentMentions.Lock()
+	mock.calls.GetSentMentions = append(mock.calls.GetSentMentions, callInfo)
+	mock.lockGetSentMentions.Unlock()
+	return mock.GetSentMentionsFunc(blogID, status)
+}
+
+// GetSentMentionsCalls gets all the calls that were made to GetSentMentions.
+// Check the length with:
+//     len(mockedProjection.GetSentMentionsCalls())
+func (mock *ProjectionMock) GetSentMentionsCalls() []struct {
+	BlogID string
+	Status string
+} {
+	var calls []struct {
+		BlogID string
+		Status string
+	}
+	mock.lockGetSentMentions.RLock()
+	calls = mock.calls.GetSentMentions
+	mock.lockGetSentMentions.RUnlock()
+	return calls
+}
+
+// GetWebFinger calls GetWebFingerFunc.
+func (mock *ProjectionMock) GetWebFinger(resource string) (*api.WebFinger, error) {
+	if mock.GetWebFingerFunc == nil {
+		panic("ProjectionMock.GetWebFingerFunc: method is nil but Projection.GetWebFinger was just called")
+	}
+	callInfo := struct {
+		Resource string
+	}{
+		Resource: resource,
+	}
+	mock.lockGetWebFinger.Lock()
+	mock.calls.GetWebFinger = append(mock.calls.GetWebFinger, callInfo)
+	mock.lockGetWebFinger.Unlock()
+	return mock.GetWebFingerFunc(resource)
+}
+
+// GetWebFingerCalls gets all the calls that were made to GetWebFinger.
+// Check the length with:
+//     len(mockedProjection.GetWebFingerCalls())
+func (mock *ProjectionMock) GetWebFingerCalls() []struct {
+	Resource string
+} {
+	var calls []struct {
+		Resource string
+	}
+	mock.lockGetWebFinger.RLock()
+	calls = mock.calls.GetWebFinger
+	mock.lockGetWebFinger.RUnlock()
+	return calls
+}
+
+// IsBlogOwner calls IsBlogOwnerFunc.
+func (mock *ProjectionMock) IsBlogOwner(blogID string, userID string) (bool, error) {
+	if mock.IsBlogOwnerFunc == nil {
+		panic("ProjectionMock.IsBlogOwnerFunc: method is nil but Projection.IsBlogOwner was just called")
+	}
+	callInfo := struct {
+		BlogID string
+		UserID string
+	}{
+		BlogID: blogID,
+		UserID: userID,
+	}
+	mock.lockIsBlogOwner.Lock()
+	mock.calls.IsBlogOwner = append(mock.calls.IsBlogOwner, callInfo)
+	mock.lockIsBlogOwner.Unlock()
+	return mock.IsBlogOwnerFunc(blogID, userID)
+}
+
+// IsBlogOwnerCalls gets all the calls that were made to IsBlogOwner.
+// Check the length with:
+//     len(mockedProjection.IsBlogOwnerCalls())
+func (mock *ProjectionMock) IsBlogOwnerCalls() []struct {
+	BlogID string
+	UserID string
+} {
+	var calls []struct {
+		BlogID string
+		UserID string
+	}
+	mock.lockIsBlogOwner.RLock()
+	calls = mock.calls.IsBlogOwner
+	mock.lockIsBlogOwner.RUnlock()
+	return calls
+}
+
 // Migrate calls MigrateFunc.
 func (mock *ProjectionMock) Migrate(ctx context.Context) error {
 	if mock.MigrateFunc == nil {
@@ -324,3 +1759,267 @@ func (mock *ProjectionMock) MigrateCalls() []struct {
 	mock.lockMigrate.RUnlock()
 	return calls
 }
+
+// PostsAdded calls PostsAddedFunc.
+func (mock *ProjectionMock) PostsAdded(eventIDs []string) error {
+	if mock.PostsAddedFunc == nil {
+		panic("ProjectionMock.PostsAddedFunc: method is nil but Projection.PostsAdded was just called")
+	}
+	callInfo := struct {
+		EventIDs []string
+	}{
+		EventIDs: eventIDs,
+	}
+	mock.lockPostsAdded.Lock()
+	mock.calls.PostsAdded = append(mock.calls.PostsAdded, callInfo)
+	mock.lockPostsAdded.Unlock()
+	return mock.PostsAddedFunc(eventIDs)
+}
+
+// PostsAddedCalls gets all the calls that were made to PostsAdded.
+// Check the length with:
+//     len(mockedProjection.PostsAddedCalls())
+func (mock *ProjectionMock) PostsAddedCalls() []struct {
+	EventIDs []string
+} {
+	var calls []struct {
+		EventIDs []string
+	}
+	mock.lockPostsAdded.RLock()
+	calls = mock.calls.PostsAdded
+	mock.lockPostsAdded.RUnlock()
+	return calls
+}
+
+// QueueMention calls QueueMentionFunc.
+func (mock *ProjectionMock) QueueMention(mention *api.ReceivedMention) error {
+	if mock.QueueMentionFunc == nil {
+		panic("ProjectionMock.QueueMentionFunc: method is nil but Projection.QueueMention was just called")
+	}
+	callInfo := struct {
+		Mention *api.ReceivedMention
+	}{
+		Mention: mention,
+	}
+	mock.lockQueueMention.Lock()
+	mock.calls.QueueMention = append(mock.calls.QueueMention, callInfo)
+	mock.lockQueueMention.Unlock()
+	return mock.QueueMentionFunc(mention)
+}
+
+// QueueMentionCalls gets all the calls that were made to QueueMention.
+// Check the length with:
+//     len(mockedProjection.QueueMentionCalls())
+func (mock *ProjectionMock) QueueMentionCalls() []struct {
+	Mention *api.ReceivedMention
+} {
+	var calls []struct {
+		Mention *api.ReceivedMention
+	}
+	mock.lockQueueMention.RLock()
+	calls = mock.calls.QueueMention
+	mock.lockQueueMention.RUnlock()
+	return calls
+}
+
+// RecordFetch calls RecordFetchFunc.
+func (mock *ProjectionMock) RecordFetch(blogID string, fetched *api.BlogFetchedPayload) error {
+	if mock.RecordFetchFunc == nil {
+		panic("ProjectionMock.RecordFetchFunc: method is nil but Projection.RecordFetch was just called")
+	}
+	callInfo := struct {
+		BlogID  string
+		Fetched *api.BlogFetchedPayload
+	}{
+		BlogID:  blogID,
+		Fetched: fetched,
+	}
+	mock.lockRecordFetch.Lock()
+	mock.calls.RecordFetch = append(mock.calls.RecordFetch, callInfo)
+	mock.lockRecordFetch.Unlock()
+	return mock.RecordFetchFunc(blogID, fetched)
+}
+
+// RecordFetchCalls gets all the calls that were made to RecordFetch.
+// Check the length with:
+//     len(mockedProjection.RecordFetchCalls())
+func (mock *ProjectionMock) RecordFetchCalls() []struct {
+	BlogID  string
+	Fetched *api.BlogFetchedPayload
+} {
+	var calls []struct {
+		BlogID  string
+		Fetched *api.BlogFetchedPayload
+	}
+	mock.lockRecordFetch.RLock()
+	calls = mock.calls.RecordFetch
+	mock.lockRecordFetch.RUnlock()
+	return calls
+}
+
+// RecordPostView calls RecordPostViewFunc.
+func (mock *ProjectionMock) RecordPostView(id string, at time.Time) (bool, error) {
+	if mock.RecordPostViewFunc == nil {
+		panic("ProjectionMock.RecordPostViewFunc: method is nil but Projection.RecordPostView was just called")
+	}
+	callInfo := struct {
+		ID string
+		At time.Time
+	}{
+		ID: id,
+		At: at,
+	}
+	mock.lockRecordPostView.Lock()
+	mock.calls.RecordPostView = append(mock.calls.RecordPostView, callInfo)
+	mock.lockRecordPostView.Unlock()
+	return mock.RecordPostViewFunc(id, at)
+}
+
+// RecordPostViewCalls gets all the calls that were made to RecordPostView.
+// Check the length with:
+//     len(mockedProjection.RecordPostViewCalls())
+func (mock *ProjectionMock) RecordPostViewCalls() []struct {
+	ID string
+	At time.Time
+} {
+	var calls []struct {
+		ID string
+		At time.Time
+	}
+	mock.lockRecordPostView.RLock()
+	calls = mock.calls.RecordPostView
+	mock.lockRecordPostView.RUnlock()
+	return calls
+}
+
+// RemoveQueuedMention calls RemoveQueuedMentionFunc.
+func (mock *ProjectionMock) RemoveQueuedMention(id uint) error {
+	if mock.RemoveQueuedMentionFunc == nil {
+		panic("ProjectionMock.RemoveQueuedMentionFunc: method is nil but Projection.RemoveQueuedMention was just called")
+	}
+	callInfo := struct {
+		ID uint
+	}{
+		ID: id,
+	}
+	mock.lockRemoveQueuedMention.Lock()
+	mock.calls.RemoveQueuedMention = append(mock.calls.RemoveQueuedMention, callInfo)
+	mock.lockRemoveQueuedMention.Unlock()
+	return mock.RemoveQueuedMentionFunc(id)
+}
+
+// RemoveQueuedMentionCalls gets all the calls that were made to RemoveQueuedMention.
+// Check the length with:
+//     len(mockedProjection.RemoveQueuedMentionCalls())
+func (mock *ProjectionMock) RemoveQueuedMentionCalls() []struct {
+	ID uint
+} {
+	var calls []struct {
+		ID uint
+	}
+	mock.lockRemoveQueuedMention.RLock()
+	calls = mock.calls.RemoveQueuedMention
+	mock.lockRemoveQueuedMention.RUnlock()
+	return calls
+}
+
+// RetryQueuedMention calls RetryQueuedMentionFunc.
+func (mock *ProjectionMock) RetryQueuedMention(id uint, checkErr error, next time.Time) error {
+	if mock.RetryQueuedMentionFunc == nil {
+		panic("ProjectionMock.RetryQueuedMentionFunc: method is nil but Projection.RetryQueuedMention was just called")
+	}
+	callInfo := struct {
+		ID       uint
+		CheckErr error
+		Next     time.Time
+	}{
+		ID:       id,
+		CheckErr: checkErr,
+		Next:     next,
+	}
+	mock.lockRetryQueuedMention.Lock()
+	mock.calls.RetryQueuedMention = append(mock.calls.RetryQueuedMention, callInfo)
+	mock.lockRetryQueuedMention.Unlock()
+	return mock.RetryQueuedMentionFunc(id, checkErr, next)
+}
+
+// RetryQueuedMentionCalls gets all the calls that were made to RetryQueuedMention.
+// Check the length with:
+//     len(mockedProjection.RetryQueuedMentionCalls())
+func (mock *ProjectionMock) RetryQueuedMentionCalls() []struct {
+	ID       uint
+	CheckErr error
+	Next     time.Time
+} {
+	var calls []struct {
+		ID       uint
+		CheckErr error
+		Next     time.Time
+	}
+	mock.lockRetryQueuedMention.RLock()
+	calls = mock.calls.RetryQueuedMention
+	mock.lockRetryQueuedMention.RUnlock()
+	return calls
+}
+
+// SubscribePosts calls SubscribePostsFunc.
+func (mock *ProjectionMock) SubscribePosts(filter *api.PostStreamFilter) *api.PostListener {
+	if mock.SubscribePostsFunc == nil {
+		panic("ProjectionMock.SubscribePostsFunc: method is nil but Projection.SubscribePosts was just called")
+	}
+	callInfo := struct {
+		Filter *api.PostStreamFilter
+	}{
+		Filter: filter,
+	}
+	mock.lockSubscribePosts.Lock()
+	mock.calls.SubscribePosts = append(mock.calls.SubscribePosts, callInfo)
+	mock.lockSubscribePosts.Unlock()
+	return mock.SubscribePostsFunc(filter)
+}
+
+// SubscribePostsCalls gets all the calls that were made to SubscribePosts.
+// Check the length with:
+//     len(mockedProjection.SubscribePostsCalls())
+func (mock *ProjectionMock) SubscribePostsCalls() []struct {
+	Filter *api.PostStreamFilter
+} {
+	var calls []struct {
+		Filter *api.PostStreamFilter
+	}
+	mock.lockSubscribePosts.RLock()
+	calls = mock.calls.SubscribePosts
+	mock.lockSubscribePosts.RUnlock()
+	return calls
+}
+
+// UnsubscribePosts calls UnsubscribePostsFunc.
+func (mock *ProjectionMock) UnsubscribePosts(listener *api.PostListener) {
+	if mock.UnsubscribePostsFunc == nil {
+		panic("ProjectionMock.UnsubscribePostsFunc: method is nil but Projection.UnsubscribePosts was just called")
+	}
+	callInfo := struct {
+		Listener *api.PostListener
+	}{
+		Listener: listener,
+	}
+	mock.lockUnsubscribePosts.Lock()
+	mock.calls.UnsubscribePosts = append(mock.calls.UnsubscribePosts, callInfo)
+	mock.lockUnsubscribePosts.Unlock()
+	mock.UnsubscribePostsFunc(listener)
+}
+
+// UnsubscribePostsCalls gets all the calls that were made to UnsubscribePosts.
+// Check the length with:
+//     len(mockedProjection.UnsubscribePostsCalls())
+func (mock *ProjectionMock) UnsubscribePostsCalls() []struct {
+	Listener *api.PostListener
+} {
+	var calls []struct {
+		Listener *api.PostListener
+	}
+	mock.lockUnsubscribePosts.RLock()
+	calls = mock.calls.UnsubscribePosts
+	mock.lockUnsubscribePosts.RUnlock()
+	return calls
+}
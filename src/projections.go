@@ -3,10 +3,16 @@ package api
 
 import (
 	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"net/url"
+	gosort "sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
@@ -19,70 +25,717 @@ type Projection interface {
 	weos.Projection
 	GetBlogByID(id string) (*Blog, error)
 	GetBlogByURL(url string) (*Blog, error)
+	GetBlogs(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Blog, int64, error)
 	GetPosts(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Post, int64, error)
 	GetCategories(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Category, int64, error)
+	GetCategory(slug string) (*CategoryDetail, error)
+	GetCategoryByID(id uint) (*Category, error)
+	GetCategorySynonyms(categoryID uint) ([]*CategorySynonym, error)
+	GetAuthors(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Author, int64, error)
+	GetAuthor(id uint) (*AuthorDetail, error)
+	GetRelatedPosts(id string, limit int) ([]*Post, error)
+	GetPostRevisions(id string) ([]*PostRevision, error)
+	GetPostByID(id string) (*Post, error)
+	GetBlogClaim(blogID string, userID string) (*BlogClaim, error)
+	IsBlogOwner(blogID string, userID string) (bool, error)
+	RecordPostView(id string, at time.Time) (bool, error)
+	RecordFetch(blogID string, fetched *BlogFetchedPayload) error
+	PostsAdded(eventIDs []string) error
+	FediverseFollowed(blogID string, follower *BlogFediverseFollowedPayload) error
+	GetBlogStats(blogID string, from time.Time, to time.Time) (*BlogStats, error)
+	GetPostByLink(blogID string, link string) (*Post, error)
+	GetMentions(postID string) ([]*Mention, error)
+	GetSentMentions(blogID string, status string) ([]*SentMention, error)
+	QueueMention(mention *ReceivedMention) error
+	GetQueuedMentions(now time.Time, limit int) ([]*ReceivedMention, error)
+	RetryQueuedMention(id uint, checkErr error, next time.Time) error
+	RemoveQueuedMention(id uint) error
+	GetWebFinger(resource string) (*WebFinger, error)
+	GetActor(blogID string) (*Actor, error)
+	GetOutbox(blogID string) (*OrderedCollection, error)
+	GetFediverseFollowers(blogID string) (*OrderedCollection, error)
+	GetDigestSubscriptions(userID string) ([]*DigestSubscription, error)
+	GetDigestSubscription(id string) (*DigestSubscription, error)
+	GetDigestSubscriptionByToken(token string) (*DigestSubscription, error)
+	GetSavedSearches(userID string) ([]*SavedSearch, error)
+	GetSavedSearch(id string) (*SavedSearch, error)
+	GetSavedSearchMatches(searchID string, page int, limit int) ([]*SavedSearchMatch, int64, error)
+	SubscribePosts(filter *PostStreamFilter) *PostListener
+	UnsubscribePosts(listener *PostListener)
+	GetPostsAfter(eventID string, filter *PostStreamFilter, limit int) ([]*Post, bool, error)
 }
 
 type Blog struct {
 	gorm.Model
-	ID          string    `gorm:"primarykey"`
-	Title       string    `json:"title,omitempty"`
-	Description string    `json:"description,omitempty"`
-	URL         string    `json:"url,omitempty"`
-	FeedURL     string    `json:"feedUrl,omitempty"`
-	Authors     []*Author `json:"authors,omitempty"`
-	Posts       []*Post   `json:"posts,omitempty"`
+	ID               string    `gorm:"primarykey"`
+	Title            string    `json:"title,omitempty"`
+	Description      string    `json:"description,omitempty"`
+	URL              string    `json:"url,omitempty"`
+	FeedURL          string    `json:"feedUrl,omitempty"`
+	Authors          []*Author `json:"authors,omitempty"`
+	Posts            []*Post   `json:"posts,omitempty"`
+	FetchFullContent bool      `json:"fetchFullContent"`
+	//details from the blog's homepage
+	FaviconURL        string         `json:"faviconUrl,omitempty"`
+	AppleTouchIconURL string         `json:"appleTouchIconUrl,omitempty"`
+	ImageURL          string         `json:"imageUrl,omitempty"`
+	SiteName          string         `json:"siteName,omitempty"`
+	Language          string         `json:"language,omitempty"`
+	Profiles          []*BlogProfile `json:"profiles,omitempty"`
+	EnrichedAt        *time.Time     `json:"enrichedAt,omitempty"`
+	EnrichmentError   string         `json:"-"`
+	//the problems found the last time the feed was fetched
+	LastFetchedAt    *time.Time         `json:"lastFetchedAt,omitempty"`
+	FetchDiagnostics []*FetchDiagnostic `json:"fetchDiagnostics,omitempty"`
+	LastFetch        FetchSummary       `json:"lastFetch" gorm:"embedded;embeddedPrefix:last_fetch_"`
+	PreviousURLs     []*BlogURL         `json:"previousUrls,omitempty"`
+	//the health of the blog, the status is updated by the health job
+	Status              string     `json:"status,omitempty" gorm:"index"`
+	ConsecutiveFailures int        `json:"consecutiveFailures" gorm:"default:0"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastSucceededAt     *time.Time `json:"lastSucceededAt,omitempty"`
+	LastPostAt          *time.Time `json:"lastPostAt,omitempty"`
+	PollingPaused       bool       `json:"pollingPaused" gorm:"default:false"`
+	//where the blog's pages can send pingbacks and webmentions for the blog's posts
+	PingbackURL   string `json:"pingbackUrl,omitempty"`
+	WebmentionURL string `json:"webmentionUrl,omitempty"`
+	//whether webmentions are sent to the pages the blog's new posts link to
+	SendMentions bool `json:"sendMentions" gorm:"default:false"`
+	//the blog's handle on the fediverse is @slug@host
+	Slug string `json:"slug,omitempty" gorm:"index"`
+}
+
+//BeforeCreate generates the slug of the blog if it was not set
+func (b *Blog) BeforeCreate(tx *gorm.DB) error {
+	if b.Slug == "" {
+		b.Slug = availableSlug(tx.Session(&gorm.Session{NewDB: true}), &Blog{}, blogSlug(b))
+	}
+	return nil
+}
+
+//blogSlug get the slug of a blog from its url e.g. "https://www.ak33m.com/blog" becomes "ak33m-com-blog". The title is
+//used when the blog doesn't have a url
+func blogSlug(blog *Blog) string {
+	if link, err := url.Parse(blog.URL); err == nil && link.Host != "" {
+		if slug := Slugify(strings.TrimPrefix(strings.ToLower(link.Hostname()), "www.") + link.Path); slug != "" {
+			return slug
+		}
+	}
+	if slug := Slugify(blog.Title); slug != "" {
+		return slug
+	}
+	return "blog"
+}
+
+//Mention is a page (the source) that links to a post and sent a webmention or pingback
+type Mention struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	PostID    string    `json:"postId" gorm:"uniqueIndex:idx_post_mention"`
+	Source    string    `json:"source" gorm:"uniqueIndex:idx_post_mention"`
+	Target    string    `json:"target"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title,omitempty"`
+	CreatedAt time.Time `json:"receivedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+//ReceivedMention is a webmention or pingback waiting for its source to be checked. The same mention received again
+//before it's checked is only checked once
+type ReceivedMention struct {
+	ID     uint   `gorm:"primarykey"`
+	BlogID string `gorm:"uniqueIndex:idx_received_mention"`
+	Source string `gorm:"uniqueIndex:idx_received_mention"`
+	Target string `gorm:"uniqueIndex:idx_received_mention"`
+	Type   string
+	//mentions whose source couldn't be downloaded (e.g. the site was down) are checked again later
+	Attempts      int `gorm:"default:0"`
+	LastError     string
+	NextAttemptAt *time.Time `gorm:"index"`
+	CreatedAt     time.Time  `gorm:"index"`
+}
+
+//SentMention is a webmention sent (or waiting to be sent) for a link in a post. The source is the post on the blog
+type SentMention struct {
+	ID            uint       `json:"-" gorm:"primarykey"`
+	PostID        string     `json:"postId" gorm:"uniqueIndex:idx_sent_mention"`
+	BlogID        string     `json:"blogId" gorm:"index"`
+	Source        string     `json:"source"`
+	Target        string     `json:"target" gorm:"uniqueIndex:idx_sent_mention"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	Status        string     `json:"status" gorm:"index"`
+	StatusCode    int        `json:"statusCode,omitempty"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty" gorm:"index"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+//FediverseFollower is an ActivityPub actor following a blog. Activities are delivered to the shared inbox of the
+//actor's server when it has one
+type FediverseFollower struct {
+	BlogID      string    `json:"-" gorm:"primarykey"`
+	ActorID     string    `json:"actorId" gorm:"primarykey"`
+	Inbox       string    `json:"inbox"`
+	SharedInbox string    `json:"sharedInbox,omitempty"`
+	CreatedAt   time.Time `json:"followedAt"`
+}
+
+//ActorKey is the key pair a blog signs its activities with. The keys are generated the first time they're needed and
+//are kept out of the blog's events so that the private key isn't stored with them
+type ActorKey struct {
+	BlogID     string `gorm:"primarykey"`
+	PrivateKey string
+	PublicKey  string
+	CreatedAt  time.Time
+}
+
+//ActivityDelivery is an activity of a blog that is waiting to be (or was) delivered to the inbox of a follower
+type ActivityDelivery struct {
+	ID     uint   `gorm:"primarykey"`
+	BlogID string `gorm:"index"`
+	//the post the activity has the content of, the deliveries are removed when the post is purged
+	PostID        string `gorm:"index"`
+	Inbox         string
+	Activity      string
+	Status        string `gorm:"index"`
+	StatusCode    int
+	Attempts      int `gorm:"default:0"`
+	LastError     string
+	NextAttemptAt *time.Time `gorm:"index"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+}
+
+//DigestSubscription is a user getting an email with the new posts from the blogs they follow and/or categories each day
+//or week. The posts added after LastPosition are in the next digest
+type DigestSubscription struct {
+	ID            string      `json:"id" gorm:"primarykey"`
+	UserID        string      `json:"-" gorm:"index"`
+	Email         string      `json:"email"`
+	Frequency     string      `json:"frequency"`
+	FollowedBlogs bool        `json:"followedBlogs"`
+	Categories    []*Category `json:"categories,omitempty" gorm:"many2many:digest_subscription_categories;"`
+	Token         string      `json:"-" gorm:"uniqueIndex"`
+	LastPosition  int64       `json:"-"`
+	LastSentAt    *time.Time  `json:"lastSentAt,omitempty"`
+	NextSendAt    time.Time   `json:"nextSendAt" gorm:"index"`
+	LastError     string      `json:"-"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
+
+//SavedSearch is a query a user is notified about when new posts match it. The blogs and authors are stored separated
+//by commas
+type SavedSearch struct {
+	ID          string      `json:"id" gorm:"primarykey"`
+	UserID      string      `json:"-" gorm:"index"`
+	Name        string      `json:"name"`
+	Query       string      `json:"query,omitempty"`
+	Categories  []*Category `json:"categories,omitempty" gorm:"many2many:saved_search_categories;"`
+	BlogIDs     string      `json:"-"`
+	Blogs       []string    `json:"blogs,omitempty" gorm:"-"`
+	AuthorIDs   string      `json:"-"`
+	Authors     []uint      `json:"authors,omitempty" gorm:"-"`
+	Notify      string      `json:"notify"`
+	WebhookURL  string      `json:"webhookUrl,omitempty"`
+	Secret      string      `json:"webhookSecret,omitempty"`
+	Email       string      `json:"email,omitempty"`
+	LastMatchAt *time.Time  `json:"lastMatchAt,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	//the terms of the query, they're split once when the search is cached
+	terms []string
+}
+
+//AfterFind splits the blogs and authors into lists
+func (s *SavedSearch) AfterFind(tx *gorm.DB) error {
+	s.Blogs = nil
+	if s.BlogIDs != "" {
+		s.Blogs = strings.Split(s.BlogIDs, ",")
+	}
+	s.Authors = nil
+	if s.AuthorIDs != "" {
+		for _, value := range strings.Split(s.AuthorIDs, ",") {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return err
+			}
+			s.Authors = append(s.Authors, uint(id))
+		}
+	}
+	return nil
+}
+
+//SavedSearchMatch is a new post that matched a saved search. The webhooks and emails are sent by the alert job and tried
+//again later if they fail, in-app matches are notified as soon as they're found
+type SavedSearchMatch struct {
+	ID            uint         `json:"-" gorm:"primarykey"`
+	SavedSearchID string       `json:"-" gorm:"uniqueIndex:idx_saved_search_match"`
+	SavedSearch   *SavedSearch `json:"-"`
+	PostID        string       `json:"-" gorm:"uniqueIndex:idx_saved_search_match"`
+	Post          *Post        `json:"post"`
+	Status        string       `json:"status" gorm:"index"`
+	Attempts      int          `json:"-" gorm:"default:0"`
+	LastError     string       `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time   `json:"-" gorm:"index"`
+	NotifiedAt    *time.Time   `json:"notifiedAt,omitempty"`
+	CreatedAt     time.Time    `json:"matchedAt"`
+}
+
+//BlogClaim is a user asking to own a blog. The claim is verified once the token is found on the blog
+type BlogClaim struct {
+	ID         uint       `json:"-" gorm:"primarykey"`
+	BlogID     string     `json:"blogId" gorm:"uniqueIndex:idx_blog_claim"`
+	UserID     string     `json:"userId" gorm:"uniqueIndex:idx_blog_claim"`
+	Token      string     `json:"token"`
+	Status     string     `json:"status"`
+	Method     string     `json:"method,omitempty"`
+	CreatedAt  time.Time  `json:"claimedAt"`
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+}
+
+//BlogDailyStat is the rollup of a blog's views and fetches for a day so that the stats of a blog can be read without
+//going through its posts. The day is in UTC
+type BlogDailyStat struct {
+	BlogID        string `gorm:"primarykey"`
+	Day           string `gorm:"primarykey;size:10"`
+	Views         int64  `gorm:"default:0"`
+	Fetches       int64  `gorm:"default:0"`
+	FailedFetches int64  `gorm:"default:0"`
+}
+
+//PostDailyView is the rollup of a post's views for a day. Like the views of posts and blogs it isn't rebuilt from events
+type PostDailyView struct {
+	PostID string `gorm:"primarykey"`
+	Day    string `gorm:"primarykey;size:10"`
+	BlogID string `gorm:"index"`
+	Views  int64  `gorm:"default:0"`
+}
+
+//BlogFollower is a user following a blog
+type BlogFollower struct {
+	BlogID    string `gorm:"primarykey"`
+	UserID    string `gorm:"primarykey"`
+	CreatedAt time.Time
+}
+
+//BlogURL is an address the blog used to have e.g. the url of the feed before it moved
+type BlogURL struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	BlogID    string    `json:"-" gorm:"index"`
+	URL       string    `json:"url" gorm:"index"`
+	CreatedAt time.Time `json:"movedAt"`
+}
+
+//FetchDiagnostic is a problem that had to be fixed to parse a blog's feed e.g. the wrong charset or a broken item
+type FetchDiagnostic struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	BlogID    string    `json:"-" gorm:"index"`
+	Problem   string    `json:"problem"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//BlogProfile is a social profile that is linked to from a blog's homepage
+type BlogProfile struct {
+	ID      uint   `json:"-" gorm:"primarykey"`
+	BlogID  string `json:"-" gorm:"index"`
+	Network string `json:"network"`
+	URL     string `json:"url"`
 }
 
 type Author struct {
 	gorm.Model
-	Name   string `json:"name"`
-	Email  string `json:"email"`
-	BlogID string `json:"blogId"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	BlogID   string `json:"blogId"`
+	PersonID uint   `json:"personId" gorm:"index"`
+}
+
+//BeforeCreate normalizes the email so that authors can be matched across blogs
+func (a *Author) BeforeCreate(tx *gorm.DB) error {
+	a.Email = strings.ToLower(strings.TrimSpace(a.Email))
+	return nil
+}
+
+//AfterCreate links the author to the person they represent
+func (a *Author) AfterCreate(tx *gorm.DB) error {
+	return linkPerson(tx.Session(&gorm.Session{NewDB: true}), a)
+}
+
+//linkPerson sets the person id of the author. Authors with the same email are the same person and the person id is the
+//id of the first author with that email
+func linkPerson(db *gorm.DB, author *Author) error {
+	author.PersonID = author.ID
+	if author.Email != "" {
+		var person *Author
+		result := db.Where("email = ? AND id < ?", author.Email, author.ID).Order("id asc").Limit(1).Find(&person)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 && person.PersonID != 0 {
+			author.PersonID = person.PersonID
+		}
+	}
+	return db.Model(&Author{}).Where("id = ?", author.ID).Update("person_id", author.PersonID).Error
 }
 
 type Post struct {
 	gorm.Model
-	ID          string      `gorm:"primarykey"`
-	Title       string      `json:"title"`
-	Description string      `json:"description"`
-	Content     string      `json:"content"`
-	BlogID      string      `json:"blogId"`
-	Blog        *Blog       `json:"blog"`
-	Link        string      `json:"link"`
-	Categories  []*Category `json:"categories,omitempty" gorm:"many2many:post_categories;"`
-	Published   string      `json:"published"`
-	PublishDate time.Time
-	Views       int `json:"views"`
+	ID            string      `gorm:"primarykey"`
+	Title         string      `json:"title"`
+	Description   string      `json:"description"`
+	Content       string      `json:"content"`
+	BlogID        string      `json:"blogId"`
+	Blog          *Blog       `json:"blog"`
+	AuthorID      *uint       `json:"authorId,omitempty"`
+	Author        *Author     `json:"author,omitempty"`
+	Link          string      `json:"link"`
+	Categories    []*Category `json:"categories,omitempty" gorm:"many2many:post_categories;"`
+	Published     string      `json:"published"`
+	PublishDate   time.Time
+	Views         int     `json:"views"`
+	TrendingScore float64 `json:"trendingScore" gorm:"index"`
+	Excerpt       string  `json:"excerpt"`
+	WordCount     int     `json:"wordCount"`
+	ReadingTime   int     `json:"readingTime"`
+	ImageURL      string  `json:"imageUrl,omitempty"`
+	ContentSource string  `json:"contentSource" gorm:"default:feed"`
+	//the reason the full article couldn't be extracted, posts with an error are not retried
+	ExtractionError string       `json:"-"`
+	Enclosures      []*Enclosure `json:"enclosures,omitempty"`
+	//identifies the item in the feed so that changes to the item can be applied to the post
+	GUID string `json:"-" gorm:"index"`
+	//posts the owner of the blog hid are left out of the lists of posts
+	Hidden bool `json:"-" gorm:"default:false"`
+	//the id of the event the post was created from, clients of the post stream resume from it
+	EventID string `json:"-" gorm:"index"`
+	//the order the posts were added in. The ids of the events are only ordered to the second and the times of the posts
+	//change when the projection is rebuilt so the post stream resumes from the position of the post instead
+	Position int64 `json:"-" gorm:"index"`
+}
+
+//PostRevision is a post as it was before it was changed on the blog. The changes are the fields that were changed
+type PostRevision struct {
+	ID            uint      `json:"-" gorm:"primarykey"`
+	PostID        string    `json:"-" gorm:"index"`
+	Title         string    `json:"title"`
+	Link          string    `json:"link"`
+	Description   string    `json:"description"`
+	Content       string    `json:"content"`
+	Changes       string    `json:"-"`
+	ChangedFields []string  `json:"changes" gorm:"-"`
+	CreatedAt     time.Time `json:"revisedAt"`
+}
+
+//AfterFind splits the changes into a list
+func (r *PostRevision) AfterFind(tx *gorm.DB) error {
+	r.ChangedFields = strings.Split(r.Changes, ",")
+	return nil
+}
+
+//Enclosure is a media file attached to a post e.g. a podcast episode
+type Enclosure struct {
+	ID           uint   `json:"-" gorm:"primarykey"`
+	PostID       string `json:"-" gorm:"index"`
+	URL          string `json:"url"`
+	Type         string `json:"type,omitempty"`
+	Medium       string `json:"medium,omitempty" gorm:"index"`
+	Length       int64  `json:"length,omitempty"`
+	Duration     int    `json:"duration,omitempty"` //in seconds
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
 }
 
 type Category struct {
 	gorm.Model
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Posts       []*Post `json:"posts,omitempty" gorm:"many2many:post_categories;"`
+	Title           string  `json:"title"`
+	Slug            string  `json:"slug" gorm:"index"`
+	NormalizedTitle string  `json:"-" gorm:"index"`
+	Description     string  `json:"description"`
+	Posts           []*Post `json:"posts,omitempty" gorm:"many2many:post_categories;"`
+}
+
+//CategorySynonym maps a normalized tag to the category it should be added to on ingest
+type CategorySynonym struct {
+	gorm.Model
+	Synonym    string    `json:"synonym" gorm:"uniqueIndex"`
+	CategoryID uint      `json:"categoryId"`
+	Category   *Category `json:"category,omitempty"`
+}
+
+//BeforeCreate generates a slug and normalized title for the category if they were not set
+func (c *Category) BeforeCreate(tx *gorm.DB) error {
+	if c.NormalizedTitle == "" {
+		c.NormalizedTitle = NormalizeCategory(c.Title)
+	}
+	if c.Slug == "" {
+		c.Slug = uniqueSlug(tx.Session(&gorm.Session{NewDB: true}), Slugify(c.Title))
+	}
+	return nil
+}
+
+//uniqueSlug adds a numeric suffix to the slug if it's already used by another category
+func uniqueSlug(db *gorm.DB, slug string) string {
+	if slug == "" {
+		slug = "category"
+	}
+	return availableSlug(db, &Category{}, slug)
+}
+
+//availableSlug adds a numeric suffix to the slug if it's already used by another row of the model
+func availableSlug(db *gorm.DB, model interface{}, slug string) string {
+	candidate := slug
+	for i := 2; ; i++ {
+		var count int64
+		db.Unscoped().Model(model).Where("slug = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
 }
 
+//the number of top blogs and related categories returned with a category
+var topBlogsLimit = 5
+var relatedCategoriesLimit = 10
+var topPostsLimit = 10
+var topCategoriesLimit = 10
+
+const (
+	//the format of the days in the daily rollups
+	statsDayFormat = "2006-01-02"
+	//the number of days in the stats of a blog when no range is given
+	defaultStatsDays = 30
+	//the most days the stats of a blog can be asked for at once
+	maxStatsDays = 366
+)
+
 type GORMProjection struct {
 	db              *gorm.DB
 	logger          weos.Log
 	migrationFolder string
+	related         *relatedCache
+	searches        *searchCache
+	//the url the api is served from, it's used to generate the pingback and webmention urls and the ActivityPub actors of blogs
+	publicURL string
+	//new posts are sent to the listeners of the post stream
+	stream *PostBroker
+	//posts are added one at a time so that each gets the next position
+	positions sync.Mutex
 }
 
+//Persist saves the details of blogs. Posts and the other details of a blog are added by the event handler
 func (p *GORMProjection) Persist(entities []weos.Entity) error {
+	for _, entity := range entities {
+		var blog *blogaggregatormodule.Blog
+		switch entity := entity.(type) {
+		case *blogaggregatormodule.Blog:
+			blog = entity
+		case *BlogAggregate:
+			blog = &entity.Blog
+		default:
+			return fmt.Errorf("unable to persist entity '%s' of type %T", entity.GetID(), entity)
+		}
+		pingbackURL, webmentionURL := p.mentionEndpoints(blog.ID)
+		err := p.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "description", "url", "feed_url", "updated_at"}),
+		}).Create(&Blog{
+			ID:            blog.ID,
+			Title:         blog.Title,
+			Description:   blog.Description,
+			URL:           blog.URL,
+			FeedURL:       blog.FeedURL,
+			PingbackURL:   pingbackURL,
+			WebmentionURL: webmentionURL,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+//Remove deletes blogs with their posts and authors
 func (p *GORMProjection) Remove(entities []weos.Entity) error {
+	for _, entity := range entities {
+		switch entity.(type) {
+		case *blogaggregatormodule.Blog, *BlogAggregate:
+			err := p.deleteBlog(entity.GetID(), false)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unable to remove entity '%s' of type %T", entity.GetID(), entity)
+		}
+	}
+	return nil
+}
+
+//deleteBlog removes a blog with its posts and authors, and the categories that no longer have posts. The rows are soft
+//deleted unless the blog is purged
+func (p *GORMProjection) deleteBlog(id string, purge bool) error {
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		db := tx
+		if purge {
+			db = tx.Unscoped().Session(&gorm.Session{})
+		}
+		var postIDs []string
+		err := db.Model(&Post{}).Where("blog_id = ?", id).Pluck("id", &postIDs).Error
+		if err != nil {
+			return err
+		}
+		err = deletePosts(tx, postIDs, purge)
+		if err != nil {
+			return err
+		}
+		err = db.Where("blog_id = ?", id).Delete(&Author{}).Error
+		if err != nil {
+			return err
+		}
+		if purge {
+			for _, model := range []interface{}{&BlogProfile{}, &FetchDiagnostic{}, &BlogURL{}, &BlogClaim{}, &BlogDailyStat{}, &BlogFollower{}, &FediverseFollower{}, &ActorKey{}, &ActivityDelivery{}} {
+				err = tx.Where("blog_id = ?", id).Delete(model).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return db.Where("id = ?", id).Delete(&Blog{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	p.related.clear()
+	//the categories of the blog that no longer have posts are removed from the searches
+	p.searches.clear()
+	return nil
+}
+
+//itemPostIDs get the ids of the posts added from the item with the key on the blog. The ids of posts are generated when
+//they're projected so they change when the projection is rebuilt, events refer to posts by their item instead. The id is
+//only used for events recorded without the key
+func itemPostIDs(tx *gorm.DB, blogID string, key string, id string) ([]string, error) {
+	query := tx.Unscoped().Model(&Post{})
+	if key != "" {
+		//posts that were added before the guid was stored are matched by their link
+		query = query.Where("blog_id = ? AND (guid = ? OR ((guid = '' OR guid IS NULL) AND link = ?))", blogID, key, key)
+	} else {
+		query = query.Where("id = ? AND blog_id = ?", id, blogID)
+	}
+	var ids []string
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+//deletePosts removes posts and the categories that no longer have posts. Categories that have synonyms are kept since
+//they were set up by an admin. When the posts are purged everything about the posts is deleted
+func deletePosts(tx *gorm.DB, ids []string, purge bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var categoryIDs []uint
+	err := tx.Table("post_categories").Where("post_id IN ?", ids).Distinct().Pluck("category_id", &categoryIDs).Error
+	if err != nil {
+		return err
+	}
+	db := tx
+	if purge {
+		db = tx.Unscoped().Session(&gorm.Session{})
+		err = queuePostDeletes(tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&Enclosure{}, &PostRevision{}, &PostDailyView{}, &Mention{}, &SentMention{}, &SavedSearchMatch{}, &ActivityDelivery{}} {
+			err = tx.Where("post_id IN ?", ids).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Exec("DELETE FROM post_categories WHERE post_id IN ?", ids).Error
+		if err != nil {
+			return err
+		}
+	}
+	err = db.Where("id IN ?", ids).Delete(&Post{}).Error
+	if err != nil || len(categoryIDs) == 0 {
+		return err
+	}
+	withPosts := tx.Session(&gorm.Session{NewDB: true}).Table("post_categories").Select("post_categories.category_id").
+		Joins("JOIN posts ON posts.id = post_categories.post_id AND posts.deleted_at IS NULL")
+	withSynonyms := tx.Session(&gorm.Session{NewDB: true}).Model(&CategorySynonym{}).Select("category_id")
+	return db.Where("id IN ? AND id NOT IN (?) AND id NOT IN (?)", categoryIDs, withPosts, withSynonyms).Delete(&Category{}).Error
+}
+
+//queuePostDeletes deletes the posts on the fediverse servers they were delivered to. The deliveries of the posts are
+//removed with the posts so the deletes are only queued once
+func queuePostDeletes(tx *gorm.DB, ids []string) error {
+	var deliveries []*ActivityDelivery
+	err := tx.Where("post_id IN ? AND status = ?", ids, ACTIVITY_DELIVERY_DELIVERED).Order("id asc").Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	queued := make(map[string]bool)
+	for _, delivery := range deliveries {
+		var create *struct {
+			Actor  string `json:"actor"`
+			Object struct {
+				ID string `json:"id"`
+			} `json:"object"`
+		}
+		if err = json.Unmarshal([]byte(delivery.Activity), &create); err != nil || create.Object.ID == "" {
+			continue
+		}
+		if queued[delivery.Inbox+" "+create.Object.ID] {
+			continue
+		}
+		queued[delivery.Inbox+" "+create.Object.ID] = true
+		body, err := json.Marshal(&Activity{
+			Context: activityStreamsContext,
+			ID:      create.Object.ID + "#delete",
+			Type:    "Delete",
+			Actor:   create.Actor,
+			To:      []string{activityPublic},
+			Object:  &Tombstone{ID: create.Object.ID, Type: "Tombstone"},
+		})
+		if err != nil {
+			return err
+		}
+		//the delete doesn't have the post's content so it isn't removed with the post
+		err = tx.Create(&ActivityDelivery{
+			BlogID:        delivery.BlogID,
+			Inbox:         delivery.Inbox,
+			Activity:      string(body),
+			Status:        ACTIVITY_DELIVERY_PENDING,
+			NextAttemptAt: &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (p *GORMProjection) GetBlogByID(id string) (*Blog, error) {
-	return nil, nil
+	var blog *Blog
+	if err := p.db.Preload("Profiles").Preload("FetchDiagnostics").Preload("PreviousURLs").First(&blog, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return blog, nil
 }
 
-func (p *GORMProjection) GetBlogs() ([]*Blog, error) {
-	return nil, nil
+//GetBlogs get the blogs in the aggregator
+func (p *GORMProjection) GetBlogs(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Blog, int64, error) {
+	var blogs []*Blog
+	var count int64
+	db := p.db.Model(&Blog{})
+	if query != "" {
+		db = db.Where("LOWER(blogs.title) LIKE ?", "%"+strings.ToLower(query)+"%")
+	}
+	result := db.Scopes(filter(filterOptions), paginate(page, limit), sort(sortOptions)).Order("blogs.title").Find(&blogs).Offset(-1).Count(&count)
+	return blogs, count, result.Error
 }
 
 func (p *GORMProjection) GetCategories(page int, limit int, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Category, int64, error) {
@@ -92,9 +745,158 @@ func (p *GORMProjection) GetCategories(page int, limit int, sortOptions map[stri
 	return categories, count, result.Error
 }
 
+//GetCategory get a category by its slug along with stats about how it's used. Returns nil if the category doesn't exist
+func (p *GORMProjection) GetCategory(slug string) (*CategoryDetail, error) {
+	var category *Category
+	if err := p.db.First(&category, "slug = ?", slug).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	detail := &CategoryDetail{
+		Category: category,
+	}
+	result := p.db.Table("post_categories").
+		Joins("join posts on posts.id = post_categories.post_id").
+		Where("post_categories.category_id = ? AND posts.deleted_at IS NULL", category.ID).
+		Count(&detail.PostCount)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	//the blogs with the most posts in the category
+	result = p.db.Table("post_categories").
+		Select("blogs.id AS blog_id, blogs.title AS title, count(*) AS post_count").
+		Joins("join posts on posts.id = post_categories.post_id").
+		Joins("join blogs on blogs.id = posts.blog_id").
+		Where("post_categories.category_id = ? AND posts.deleted_at IS NULL", category.ID).
+		Group("blogs.id, blogs.title").
+		Order("post_count desc").
+		Limit(topBlogsLimit).
+		Scan(&detail.TopBlogs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	//categories that are used on the same posts as this category
+	result = p.db.Table("post_categories AS source").
+		Select("categories.id AS id, categories.title AS title, categories.slug AS slug, count(*) AS shared_posts").
+		Joins("join post_categories AS related on related.post_id = source.post_id AND related.category_id <> source.category_id").
+		Joins("join categories on categories.id = related.category_id").
+		Where("source.category_id = ? AND categories.deleted_at IS NULL", category.ID).
+		Group("categories.id, categories.title, categories.slug").
+		Order("shared_posts desc").
+		Limit(relatedCategoriesLimit).
+		Scan(&detail.RelatedCategories)
+	return detail, result.Error
+}
+
+//GetCategoryByID get a category by id. Returns nil if the category doesn't exist
+func (p *GORMProjection) GetCategoryByID(id uint) (*Category, error) {
+	var category *Category
+	if err := p.db.First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+//GetCategorySynonyms get the synonyms that are mapped to a category
+func (p *GORMProjection) GetCategorySynonyms(categoryID uint) ([]*CategorySynonym, error) {
+	var synonyms []*CategorySynonym
+	result := p.db.Where("category_id = ?", categoryID).Order("synonym asc").Find(&synonyms)
+	return synonyms, result.Error
+}
+
+//resolveCategory get the category for a tag taking into account synonyms and differences in case, spacing and unicode
+//normalization. A category is created if there isn't one that matches
+func (p *GORMProjection) resolveCategory(tag string) (*Category, error) {
+	normalized := NormalizeCategory(tag)
+	if normalized == "" {
+		return nil, nil
+	}
+	var synonym *CategorySynonym
+	result := p.db.Preload("Category").Where("synonym = ?", normalized).Limit(1).Find(&synonym)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 && synonym.Category != nil {
+		return synonym.Category, nil
+	}
+	var category *Category
+	result = p.db.Where(&Category{
+		NormalizedTitle: normalized,
+	}).Attrs(&Category{
+		Title: strings.TrimSpace(tag),
+	}).FirstOrCreate(&category)
+	return category, result.Error
+}
+
+//addCategorySynonym map a synonym to a category. If the synonym was mapped to another category it's moved
+func addCategorySynonym(tx *gorm.DB, synonym string, categoryID uint) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "synonym"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"category_id": categoryID, "updated_at": time.Now()}),
+	}).Create(&CategorySynonym{
+		Synonym:    synonym,
+		CategoryID: categoryID,
+	}).Error
+}
+
+//mergeCategory moves the posts and synonyms of a category to another category and then removes it
+func (p *GORMProjection) mergeCategory(slug string, intoSlug string) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var category *Category
+		var into *Category
+		if err := tx.First(&category, "slug = ?", slug).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&into, "slug = ?", intoSlug).Error; err != nil {
+			return err
+		}
+		//remove the links for posts that already have the category being merged into so there are no duplicates
+		err := tx.Exec("DELETE FROM post_categories WHERE category_id = ? AND post_id IN (SELECT post_id FROM post_categories WHERE category_id = ?)", category.ID, into.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE post_categories SET category_id = ? WHERE category_id = ?", into.ID, category.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&CategorySynonym{}).Where("category_id = ?", category.ID).Update("category_id", into.ID).Error
+		if err != nil {
+			return err
+		}
+		//digests with the merged category get the posts of the category it was merged into
+		err = tx.Exec("DELETE FROM digest_subscription_categories WHERE category_id = ? AND digest_subscription_id IN (SELECT digest_subscription_id FROM digest_subscription_categories WHERE category_id = ?)", category.ID, into.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE digest_subscription_categories SET category_id = ? WHERE category_id = ?", into.ID, category.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("DELETE FROM saved_search_categories WHERE category_id = ? AND saved_search_id IN (SELECT saved_search_id FROM saved_search_categories WHERE category_id = ?)", category.ID, into.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE saved_search_categories SET category_id = ? WHERE category_id = ?", into.ID, category.ID).Error
+		if err != nil {
+			return err
+		}
+		//new posts tagged with the merged category should be added to the category it was merged into
+		err = addCategorySynonym(tx, category.NormalizedTitle, into.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
 func (p *GORMProjection) GetBlogByURL(url string) (*Blog, error) {
 	var blog *Blog
-	if err := p.db.Debug().Preload(clause.Associations).First(&blog, "url = ? OR feed_url = ?", url, url).Error; err != nil {
+	if err := p.db.Debug().Preload(clause.Associations).First(&blog, "url = ? OR feed_url = ? OR id IN (?)", url, url, p.db.Model(&BlogURL{}).Select("blog_id").Where("url = ?", url)).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("blog '%s' not found", url)
 		}
@@ -105,55 +907,811 @@ func (p *GORMProjection) GetBlogByURL(url string) (*Blog, error) {
 	return blog, nil
 }
 
+//recordPreviousURLs keeps the urls the blog is moving from so the blog can still be found by them
+func (p *GORMProjection) recordPreviousURLs(updated *Blog) error {
+	var blog *Blog
+	result := p.db.Where("id = ?", updated.ID).Limit(1).Find(&blog)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	previous := []string{}
+	if updated.URL != "" && blog.URL != updated.URL {
+		previous = append(previous, blog.URL)
+	}
+	if updated.FeedURL != "" && blog.FeedURL != updated.FeedURL {
+		previous = append(previous, blog.FeedURL)
+	}
+	for _, link := range previous {
+		if link == "" || link == updated.URL || link == updated.FeedURL {
+			continue
+		}
+		var count int64
+		err := p.db.Model(&BlogURL{}).Where("blog_id = ? AND url = ?", updated.ID, link).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		err = p.db.Create(&BlogURL{BlogID: updated.ID, URL: link}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//BlogsToRefresh get the blogs whose feeds haven't been fetched in the max age, the blogs that were fetched the longest
+//ago are first. Blogs that aren't polled are still fetched once the paused max age has passed so that a blog that comes
+//back (the feed works again or there's a new post) gets a new status
+func (p *GORMProjection) BlogsToRefresh(maxAge time.Duration, pausedMaxAge time.Duration, limit int) ([]*Blog, error) {
+	var blogs []*Blog
+	now := time.Now()
+	err := p.db.Where("last_fetched_at IS NULL OR last_fetched_at < ?", now.Add(-maxAge)).
+		Where("polling_paused IS NULL OR polling_paused = ? OR last_fetched_at < ?", false, now.Add(-pausedMaxAge)).
+		Order("last_fetched_at IS NOT NULL, last_fetched_at").Limit(limit).Find(&blogs).Error
+	return blogs, err
+}
+
+//UpdateBlogHealth update the status of every blog and pause polling for the blogs with a status the policy pauses
+func (p *GORMProjection) UpdateBlogHealth(config *HealthConfig, now time.Time) error {
+	var blogs []*Blog
+	return p.db.FindInBatches(&blogs, 100, func(tx *gorm.DB, batch int) error {
+		for _, blog := range blogs {
+			status := HealthStatus(blog, config, now)
+			paused := config.Pauses(status)
+			if status == blog.Status && paused == blog.PollingPaused {
+				continue
+			}
+			err := p.db.Model(&Blog{}).Where("id = ?", blog.ID).Updates(map[string]interface{}{
+				"status":         status,
+				"polling_paused": paused,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 //GetPosts get all the posts in the aggregator
 func (p *GORMProjection) GetPosts(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Post, int64, error) {
 	var posts []*Post
 	var count int64
-	result := p.db.Debug().Preload("Categories").Preload("Blog").Scopes(filter(filterOptions), paginate(page, limit), sort(sortOptions)).Find(&posts).Offset(-1).Distinct("posts.id").Count(&count)
+	result := p.db.Debug().Preload("Categories").Preload("Blog").Preload("Author").Preload("Enclosures").Where("posts.hidden = ?", false).Scopes(filter(filterOptions), paginate(page, limit), sort(sortOptions)).Find(&posts).Offset(-1).Distinct("posts.id").Count(&count)
 	return posts, count, result.Error
 }
-//GetAuthors get all the authors in the aggregator
-func (p *GORMProjection) GetAuthors(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Author, int64, error) {
-	var authors []*Author
-	var count int64
-	result := p.db.Debug().Scopes(filter(filterOptions),paginate(page,limit),sort(sortOptions)).Find(&authors).Offset(-1).Distinct("authors.id").Count(&count)
-	return authors,count,result.Error
-}
 
-func sort(order map[string]string) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		for key, value := range order {
-			//only support certain values since GORM doesn't protect the order function https://gorm.io/docs/security.html#SQL-injection-Methods
-			if (value != "asc" && value != "desc" && value != "") || (key != "views" && key != "publish_date") {
-				return db
+//GetRelatedPosts get the posts that are related to a post. Posts are ranked by the categories they share with the post,
+//how similar their text is and whether they were published on the same blog around the same time
+func (p *GORMProjection) GetRelatedPosts(id string, limit int) ([]*Post, error) {
+	var post *Post
+	if err := p.db.Preload("Categories").First(&post, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var related []*Post
+	relatedIDs, ok := p.related.get(post.ID)
+	if ok {
+		if len(relatedIDs) > 0 {
+			err := p.db.Preload("Categories").Preload("Blog").Preload("Author").Where("id IN ? AND hidden = ?", relatedIDs, false).Find(&related).Error
+			if err != nil {
+				return nil, err
 			}
-			db.Order(key + " " + value)
 		}
+		//the posts are put back in the order they were ranked
+		order := make(map[string]int)
+		for i, id := range relatedIDs {
+			order[id] = i
+		}
+		gosort.Slice(related, func(i, j int) bool {
+			return order[related[i].ID] < order[related[j].ID]
+		})
+	} else {
+		//the candidates are posts that share a category, posts from the same blog and recent posts
+		shared := make(map[string]int)
+		var candidateIDs []string
+		if len(post.Categories) > 0 {
+			var categoryIDs []uint
+			for _, category := range post.Categories {
+				categoryIDs = append(categoryIDs, category.ID)
+			}
+			var rows []struct {
+				PostID string
+				Shared int
+			}
+			err := p.db.Table("post_categories").Select("post_id, COUNT(*) AS shared").
+				Where("category_id IN ? AND post_id <> ?", categoryIDs, post.ID).
+				Group("post_id").Order("shared desc").Limit(200).Scan(&rows).Error
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				shared[row.PostID] = row.Shared
+				candidateIDs = append(candidateIDs, row.PostID)
+			}
+		}
+		var ids []string
+		err := p.db.Model(&Post{}).Where("blog_id = ? AND id <> ? AND publish_date <= ?", post.BlogID, post.ID, post.PublishDate).Order("publish_date desc").Limit(25).Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		candidateIDs = append(candidateIDs, ids...)
+		ids = nil
+		err = p.db.Model(&Post{}).Where("blog_id = ? AND id <> ? AND publish_date > ?", post.BlogID, post.ID, post.PublishDate).Order("publish_date asc").Limit(25).Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		candidateIDs = append(candidateIDs, ids...)
+		ids = nil
+		err = p.db.Model(&Post{}).Where("id <> ?", post.ID).Order("publish_date desc").Limit(100).Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		candidateIDs = append(candidateIDs, ids...)
+		var candidates []*Post
+		if len(candidateIDs) > 0 {
+			err = p.db.Preload("Categories").Preload("Blog").Preload("Author").Where("id IN ? AND hidden = ?", candidateIDs, false).Find(&candidates).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+		related = rankRelated(post, candidates, shared)
+		p.related.set(post, related)
+	}
+	if limit > 0 && limit < len(related) {
+		related = related[:limit]
+	}
+	return related, nil
+}
 
-		return db
+//EnrichBlog update a blog with the details from its homepage. Returns nil if the blog doesn't exist
+func (p *GORMProjection) EnrichBlog(ctx context.Context, enricher *BlogEnricher, id string) (*Blog, error) {
+	blog, err := p.GetBlogByID(id)
+	if err != nil || blog == nil {
+		return blog, err
+	}
+	err = p.enrichBlog(ctx, enricher, blog)
+	if err != nil {
+		return nil, err
 	}
+	return p.GetBlogByID(id)
 }
 
-func category(categoryValue interface{}) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		if category, ok := categoryValue.(string); ok {
-			db.Joins("left join post_categories on post_id = posts.id").Joins("left join categories on categories.id = post_categories.category_id").Where("categories.title = ?", category)
+//EnrichBlogs enrich the blogs that haven't been enriched yet and the blogs that were enriched more than maxAge ago
+func (p *GORMProjection) EnrichBlogs(ctx context.Context, enricher *BlogEnricher, maxAge time.Duration, limit int) error {
+	var blogs []*Blog
+	err := p.db.Where("enriched_at IS NULL OR enriched_at < ?", time.Now().Add(-maxAge)).
+		Order("enriched_at IS NOT NULL, enriched_at").Limit(limit).Find(&blogs).Error
+	if err != nil {
+		return err
+	}
+	for _, blog := range blogs {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		return db
+		//the error is recorded on the blog so the other blogs can still be enriched
+		p.enrichBlog(ctx, enricher, blog)
 	}
+	return nil
 }
 
-func publishDate(startDateValue interface{}, endDateValue interface{}) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		if startDateValue != nil && endDateValue != nil {
-			var startDate time.Time
-			var endDate time.Time
-			var err error
-			if sdv, ok := startDateValue.(string); ok {
-				startDate, err = time.Parse("01/02/06", sdv)
-				if err != nil {
-					return db
-				}
+func (p *GORMProjection) enrichBlog(ctx context.Context, enricher *BlogEnricher, blog *Blog) error {
+	now := time.Now()
+	metadata, err := enricher.Enrich(ctx, blog.URL)
+	if err != nil {
+		p.logger.Errorf("error enriching blog '%s' from '%s': '%s'", blog.ID, blog.URL, err)
+		if updateErr := p.db.Model(blog).Updates(map[string]interface{}{"enriched_at": now, "enrichment_error": err.Error()}).Error; updateErr != nil {
+			return updateErr
+		}
+		return err
+	}
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"favicon_url":          metadata.FaviconURL,
+			"apple_touch_icon_url": metadata.AppleTouchIconURL,
+			"image_url":            metadata.ImageURL,
+			"site_name":            metadata.SiteName,
+			"language":             metadata.Language,
+			"enriched_at":          now,
+			"enrichment_error":     "",
+		}
+		//the description from the feed is kept if there is one
+		if blog.Description == "" && metadata.Description != "" {
+			updates["description"] = metadata.Description
+		}
+		if err := tx.Model(blog).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id = ?", blog.ID).Delete(&BlogProfile{}).Error; err != nil {
+			return err
+		}
+		for _, profile := range metadata.Profiles {
+			profile.BlogID = blog.ID
+			if err := tx.Create(profile).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//ExtractFullContent replaces the content of posts from blogs that have full content turned on with the article from the
+//post's page. If the article can't be extracted the feed content is kept and the error is logged
+func (p *GORMProjection) ExtractFullContent(ctx context.Context, extractor *ContentExtractor, limit int) error {
+	var posts []*Post
+	err := p.db.Joins("JOIN blogs ON blogs.id = posts.blog_id").
+		Where("blogs.fetch_full_content = ? AND posts.content_source = ? AND (posts.extraction_error = ? OR posts.extraction_error IS NULL) AND posts.link <> ?", true, CONTENT_SOURCE_FEED, "", "").
+		Order("posts.publish_date desc").Limit(limit).Find(&posts).Error
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		content, err := extractor.Extract(ctx, post.Link)
+		if err != nil {
+			p.logger.Errorf("error extracting the full content of post '%s' from '%s': '%s'", post.ID, post.Link, err)
+			err = p.db.Model(post).Update("extraction_error", err.Error()).Error
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		//the article is only used if it has more to read than the feed
+		if len(PlainText(content)) <= len(PlainText(post.Content)) {
+			err = p.db.Model(post).Update("extraction_error", "article is shorter than the feed content").Error
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		post.Content = content
+		post.ContentSource = CONTENT_SOURCE_ARTICLE
+		post.prepareContent(p.postBaseURL(post), post.ImageURL)
+		err = p.db.Model(post).Select("content", "content_source", "excerpt", "word_count", "reading_time", "image_url").Updates(post).Error
+		if err != nil {
+			return err
+		}
+		p.related.invalidate(post)
+	}
+	return nil
+}
+
+//queueWebmentions adds the links in a new post to the sent log so that the webmention job sends them. Only the posts of
+//blogs that turned on sending mentions are queued
+func (p *GORMProjection) queueWebmentions(post *Post) error {
+	if post.Link == "" {
+		return nil
+	}
+	var count int64
+	err := p.db.Model(&Blog{}).Where("id = ? AND send_mentions = ?", post.BlogID, true).Count(&count).Error
+	if err != nil || count == 0 {
+		return err
+	}
+	now := time.Now()
+	for _, target := range OutboundLinks(post.Content, post.Link, maxWebmentionLinks) {
+		err = p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&SentMention{
+			PostID:        post.ID,
+			BlogID:        post.BlogID,
+			Source:        post.Link,
+			Target:        target,
+			Status:        SENT_MENTION_PENDING,
+			NextAttemptAt: &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SendWebmentions sends the webmentions in the sent log that are due. Webmentions that fail with a temporary error are
+//tried again later, the delay doubles each attempt until the attempts run out
+func (p *GORMProjection) SendWebmentions(ctx context.Context, sender *WebmentionSender, config *WebmentionConfig, now time.Time) error {
+	var mentions []*SentMention
+	err := p.db.Joins("JOIN blogs ON blogs.id = sent_mentions.blog_id AND blogs.deleted_at IS NULL").
+		Joins("JOIN posts ON posts.id = sent_mentions.post_id AND posts.deleted_at IS NULL").
+		Where("blogs.send_mentions = ? AND sent_mentions.status = ? AND sent_mentions.next_attempt_at <= ?", true, SENT_MENTION_PENDING, now).
+		Order("sent_mentions.next_attempt_at asc").Limit(webmentionBatchSize).Find(&mentions).Error
+	if err != nil {
+		return err
+	}
+	for _, mention := range mentions {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		mention.Attempts++
+		var sendErr error
+		//the endpoint is only discovered once so that retries go straight to the endpoint
+		if mention.Endpoint == "" {
+			mention.Endpoint, sendErr = sender.Discover(ctx, mention.Target)
+		}
+		if sendErr == nil {
+			mention.StatusCode, sendErr = sender.Send(ctx, mention.Endpoint, mention.Source, mention.Target)
+		}
+		mention.LastError = ""
+		mention.NextAttemptAt = nil
+		switch {
+		case sendErr == nil:
+			mention.Status = SENT_MENTION_SENT
+			mention.SentAt = &now
+		case errors.Is(sendErr, ErrNoWebmentionEndpoint):
+			mention.Status = SENT_MENTION_NO_ENDPOINT
+		case temporaryError(sendErr) && mention.Attempts < config.MaxAttempts:
+			next := now.Add(config.RetryDelay * time.Duration(1<<(mention.Attempts-1)))
+			mention.NextAttemptAt = &next
+			mention.LastError = sendErr.Error()
+		default:
+			mention.Status = SENT_MENTION_FAILED
+			mention.LastError = sendErr.Error()
+		}
+		if sendErr != nil {
+			p.logger.Errorf("error sending webmention from '%s' to '%s': '%s'", mention.Source, mention.Target, sendErr)
+		}
+		err = p.db.Model(mention).Select("endpoint", "status", "status_code", "attempts", "last_error", "next_attempt_at", "sent_at").Updates(mention).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//DeliverActivities posts the activities that are due to the inboxes of the fediverse followers, signed with the key of
+//the blog. Deliveries that fail because the server is down are tried again later, the delay doubles each attempt
+func (p *GORMProjection) DeliverActivities(ctx context.Context, deliverer *ActivityDeliverer, config *FederationConfig, now time.Time) error {
+	var deliveries []*ActivityDelivery
+	err := p.db.Joins("JOIN blogs ON blogs.id = activity_deliveries.blog_id AND blogs.deleted_at IS NULL").
+		Where("activity_deliveries.status = ? AND activity_deliveries.next_attempt_at <= ?", ACTIVITY_DELIVERY_PENDING, now).
+		Order("activity_deliveries.next_attempt_at asc").Limit(activityDeliveryBatchSize).Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		key, ok := keys[delivery.BlogID]
+		if !ok {
+			actorKey, err := p.actorKey(delivery.BlogID)
+			if err != nil {
+				return err
+			}
+			key, err = parsePrivateKey(actorKey.PrivateKey)
+			if err != nil {
+				return err
+			}
+			keys[delivery.BlogID] = key
+		}
+		delivery.Attempts++
+		var deliverErr error
+		delivery.StatusCode, deliverErr = deliverer.Deliver(ctx, delivery.Inbox, []byte(delivery.Activity), p.actorURL(delivery.BlogID)+"#main-key", key)
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		switch {
+		case deliverErr == nil:
+			delivery.Status = ACTIVITY_DELIVERY_DELIVERED
+			delivery.DeliveredAt = &now
+		case temporaryError(deliverErr) && delivery.Attempts < config.MaxAttempts:
+			next := now.Add(config.RetryDelay * time.Duration(1<<(delivery.Attempts-1)))
+			delivery.NextAttemptAt = &next
+			delivery.LastError = deliverErr.Error()
+		default:
+			delivery.Status = ACTIVITY_DELIVERY_FAILED
+			delivery.LastError = deliverErr.Error()
+		}
+		if deliverErr != nil {
+			p.logger.Errorf("error delivering activity to '%s': '%s'", delivery.Inbox, deliverErr)
+		}
+		err = p.db.Model(delivery).Select("status", "status_code", "attempts", "last_error", "next_attempt_at", "delivered_at").Updates(delivery).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SendDigests emails the digests that are due. Subscriptions without new posts are skipped until their next digest and
+//digests that couldn't be sent are tried again later
+func (p *GORMProjection) SendDigests(ctx context.Context, mailer Mailer, config *DigestConfig, now time.Time) error {
+	if p.publicURL == "" {
+		return ErrDigestsDisabled
+	}
+	var subscriptions []*DigestSubscription
+	err := p.db.Preload("Categories").Where("next_send_at <= ?", now).Order("next_send_at asc").Limit(digestBatchSize).Find(&subscriptions).Error
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		digest := &Digest{
+			Subscription:   subscription,
+			UnsubscribeURL: p.publicURL + "/digests/unsubscribe?token=" + url.QueryEscape(subscription.Token),
+		}
+		//posts added while the digest is sent are left for the next digest
+		err = p.digestPosts(subscription, now).Count(&digest.Total).Error
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"next_send_at": nextDigest(subscription.Frequency, now), "last_error": ""}
+		if digest.Total > 0 {
+			err = p.digestPosts(subscription, now).Select("posts.*").Preload("Blog").Order("posts.publish_date desc, posts.id desc").
+				Limit(config.MaxPosts).Find(&digest.Posts).Error
+			if err != nil {
+				return err
+			}
+			//the last post added is where the next digest starts from. The position of the post is used since the times of
+			//the posts change when the projection is rebuilt
+			var newest *Post
+			err = p.digestPosts(subscription, now).Select("posts.position").Order("posts.position desc").Limit(1).Find(&newest).Error
+			if err != nil {
+				return err
+			}
+			message, err := digest.Message()
+			if err == nil {
+				err = mailer.Send(ctx, message)
+			}
+			if err != nil {
+				p.logger.Errorf("error sending digest '%s': '%s'", subscription.ID, err)
+				updates["next_send_at"] = now.Add(config.RetryDelay)
+				updates["last_error"] = err.Error()
+			} else {
+				updates["last_position"] = newest.Position
+				updates["last_sent_at"] = now
+			}
+		}
+		err = p.db.Model(&DigestSubscription{}).Where("id = ?", subscription.ID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//digestPosts get the query for the posts added to the followed blogs or categories of the subscription since its last
+//digest
+func (p *GORMProjection) digestPosts(subscription *DigestSubscription, until time.Time) *gorm.DB {
+	var sources []string
+	var args []interface{}
+	if subscription.FollowedBlogs {
+		sources = append(sources, "posts.blog_id IN (?)")
+		args = append(args, p.db.Model(&BlogFollower{}).Select("blog_id").Where("user_id = ?", subscription.UserID))
+	}
+	if len(subscription.Categories) > 0 {
+		categoryIDs := make([]uint, len(subscription.Categories))
+		for i, category := range subscription.Categories {
+			categoryIDs[i] = category.ID
+		}
+		sources = append(sources, "posts.id IN (?)")
+		args = append(args, p.db.Table("post_categories").Select("post_id").Where("category_id IN ?", categoryIDs))
+	}
+	if len(sources) == 0 {
+		sources = append(sources, "1 = 0")
+	}
+	return p.db.Model(&Post{}).Joins("JOIN blogs ON blogs.id = posts.blog_id AND blogs.deleted_at IS NULL").
+		Where("posts.hidden = ? AND posts.position > ? AND posts.created_at <= ?", false, subscription.LastPosition, until).
+		Where("("+strings.Join(sources, " OR ")+")", args...)
+}
+
+//streamPost sends a new post to the listeners of the post stream. The post is loaded with the same details as GetPosts
+func (p *GORMProjection) streamPost(id string) error {
+	if p.stream.Listeners() == 0 {
+		return nil
+	}
+	var post *Post
+	err := p.db.Preload("Categories").Preload("Blog").Preload("Author").Preload("Enclosures").First(&post, "id = ?", id).Error
+	if err != nil {
+		return err
+	}
+	p.stream.Publish(post)
+	return nil
+}
+
+//SubscribePosts add a listener to the post stream
+func (p *GORMProjection) SubscribePosts(filter *PostStreamFilter) *PostListener {
+	return p.stream.Subscribe(filter)
+}
+
+//UnsubscribePosts remove a listener from the post stream
+func (p *GORMProjection) UnsubscribePosts(listener *PostListener) {
+	p.stream.Unsubscribe(listener)
+}
+
+//GetPostsAfter get the posts that were added after the post that was created from the event, in the order they were added.
+//The posts aren't complete when there's no post for the event or more than the limit were added after it, no posts are
+//returned and the client has to load the posts again instead
+func (p *GORMProjection) GetPostsAfter(eventID string, streamFilter *PostStreamFilter, limit int) ([]*Post, bool, error) {
+	var last *Post
+	result := p.db.Unscoped().Select("id", "position").Where("event_id = ? AND event_id <> ?", eventID, "").Limit(1).Find(&last)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, false, result.Error
+	}
+	var posts []*Post
+	//one more than the limit is loaded to tell whether there were too many
+	err := p.db.Select("posts.*").Preload("Categories").Preload("Blog").Preload("Author").Preload("Enclosures").
+		Where("posts.hidden = ? AND posts.event_id <> ? AND posts.position > ?", false, "", last.Position).
+		Scopes(filter(streamFilter.filters())).Order("posts.position asc").Limit(limit + 1).Find(&posts).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(posts) > limit {
+		return nil, false, nil
+	}
+	return posts, true, nil
+}
+
+//createPost add the post at the next position
+func (p *GORMProjection) createPost(post *Post) error {
+	p.positions.Lock()
+	defer p.positions.Unlock()
+	var position int64
+	err := p.db.Unscoped().Model(&Post{}).Select("COALESCE(MAX(position), 0)").Scan(&position).Error
+	if err != nil {
+		return err
+	}
+	post.Position = position + 1
+	return p.db.Create(post).Error
+}
+
+//matchSavedSearches records the saved searches a new post matches. The post is checked against each search as it's added
+//instead of running the searches again
+func (p *GORMProjection) matchSavedSearches(post *Post) error {
+	searches, err := p.searches.get(p.db)
+	if err != nil || len(searches) == 0 {
+		return err
+	}
+	text := searchText(post)
+	now := time.Now()
+	for _, search := range searches {
+		if !search.Matches(post, text) {
+			continue
+		}
+		match := &SavedSearchMatch{
+			SavedSearchID: search.ID,
+			PostID:        post.ID,
+			Status:        SEARCH_MATCH_PENDING,
+			NextAttemptAt: &now,
+		}
+		//in-app notifications are the matches themselves
+		if search.Notify == SEARCH_NOTIFY_IN_APP {
+			match.Status = SEARCH_MATCH_NOTIFIED
+			match.NextAttemptAt = nil
+			match.NotifiedAt = &now
+		}
+		err = p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(match).Error
+		if err != nil {
+			return err
+		}
+		err = p.db.Model(&SavedSearch{}).Where("id = ?", search.ID).Update("last_match_at", now).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SendSearchAlerts sends the webhooks and emails for the saved search matches that are due. Notifications that fail
+//because the server is down are tried again later, the delay doubles each attempt. Emails fail if there's no mailer
+func (p *GORMProjection) SendSearchAlerts(ctx context.Context, webhooks *WebhookSender, mailer Mailer, config *SearchAlertConfig, now time.Time) error {
+	var matches []*SavedSearchMatch
+	err := p.db.Select("saved_search_matches.*").
+		Joins("JOIN posts ON posts.id = saved_search_matches.post_id AND posts.deleted_at IS NULL").
+		Where("saved_search_matches.status = ? AND saved_search_matches.next_attempt_at <= ?", SEARCH_MATCH_PENDING, now).
+		Preload("SavedSearch").Preload("Post.Blog").
+		Order("saved_search_matches.next_attempt_at asc").Limit(searchAlertBatchSize).Find(&matches).Error
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if match.SavedSearch == nil || match.Post == nil {
+			continue
+		}
+		match.Attempts++
+		alert := &SearchAlert{
+			Event:     SEARCH_ALERT_EVENT,
+			SearchID:  match.SavedSearch.ID,
+			Name:      match.SavedSearch.Name,
+			Post:      match.Post,
+			MatchedAt: match.CreatedAt,
+		}
+		var sendErr error
+		switch match.SavedSearch.Notify {
+		case SEARCH_NOTIFY_WEBHOOK:
+			_, sendErr = webhooks.Send(ctx, match.SavedSearch.WebhookURL, match.SavedSearch.Secret, alert)
+		case SEARCH_NOTIFY_EMAIL:
+			if mailer == nil {
+				sendErr = ErrEmailDisabled
+				break
+			}
+			var message *EmailMessage
+			if message, sendErr = alert.Message(match.SavedSearch.Email); sendErr == nil {
+				sendErr = mailer.Send(ctx, message)
+			}
+		}
+		match.LastError = ""
+		match.NextAttemptAt = nil
+		switch {
+		case sendErr == nil:
+			match.Status = SEARCH_MATCH_NOTIFIED
+			match.NotifiedAt = &now
+		case !errors.Is(sendErr, ErrEmailDisabled) && temporaryError(sendErr) && match.Attempts < config.MaxAttempts:
+			next := now.Add(config.RetryDelay * time.Duration(1<<(match.Attempts-1)))
+			match.NextAttemptAt = &next
+			match.LastError = sendErr.Error()
+		default:
+			match.Status = SEARCH_MATCH_FAILED
+			match.LastError = sendErr.Error()
+		}
+		if sendErr != nil {
+			p.logger.Errorf("error notifying saved search '%s' of post '%s': '%s'", match.SavedSearchID, match.PostID, sendErr)
+		}
+		err = p.db.Model(match).Select("status", "attempts", "last_error", "next_attempt_at", "notified_at").Updates(match).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//UpdateTrendingScores recalculates the trending scores of recent posts. The scores are stored so that sorting by
+//trending can use an index instead of calculating the score for every post on each request
+func (p *GORMProjection) UpdateTrendingScores(config *TrendingConfig, now time.Time) error {
+	since := now.Add(-config.Window)
+	//posts that are outside the window are no longer trending
+	err := p.db.Model(&Post{}).Where("publish_date < ? AND trending_score > 0", since).Update("trending_score", 0).Error
+	if err != nil {
+		return err
+	}
+	var posts []*Post
+	return p.db.Select("id", "views", "publish_date").Where("publish_date >= ?", since).FindInBatches(&posts, 500, func(tx *gorm.DB, batch int) error {
+		for _, post := range posts {
+			err := p.db.Model(&Post{}).Where("id = ?", post.ID).Update("trending_score", TrendingScore(post.Views, post.PublishDate, now, config)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+//GetAuthors get all the authors in the aggregator. Authors with the same email on different blogs are listed once
+func (p *GORMProjection) GetAuthors(page int, limit int, query string, sortOptions map[string]string, filterOptions map[string]interface{}) ([]*Author, int64, error) {
+	var authors []*Author
+	var count int64
+	//each person is listed once using their first author that wasn't deleted, the first author of a person is deleted with
+	//their blog
+	db := p.db.Where("authors.id IN (?)", p.db.Model(&Author{}).Select("MIN(id)").Group("person_id"))
+	if query != "" {
+		db = db.Where("LOWER(authors.name) LIKE ?", "%"+strings.ToLower(query)+"%")
+	}
+	if blogID, ok := filterOptions["blog_id"]; ok {
+		//the filters are copied so that the caller's filters aren't changed
+		filters := make(map[string]interface{}, len(filterOptions))
+		for key, value := range filterOptions {
+			if key != "blog_id" {
+				filters[key] = value
+			}
+		}
+		filterOptions = filters
+		db = db.Where("authors.person_id IN (?)", p.db.Model(&Author{}).Select("person_id").Where("blog_id = ?", blogID))
+	}
+	result := db.Debug().Scopes(filter(filterOptions),paginate(page,limit),sort(sortOptions)).Find(&authors).Offset(-1).Distinct("authors.id").Count(&count)
+	return authors,count,result.Error
+}
+
+//GetAuthor get an author with the blogs they write for. Returns nil if the author doesn't exist
+func (p *GORMProjection) GetAuthor(id uint) (*AuthorDetail, error) {
+	var author *Author
+	if err := p.db.Unscoped().First(&author, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	//use the first author for the person that wasn't deleted so that the same details are returned for all their blogs.
+	//Authors are deleted with their blog so the person is found through any of their authors
+	personID := author.PersonID
+	if personID == 0 {
+		personID = author.ID
+	}
+	author = nil
+	found := p.db.Where("person_id = ? OR id = ?", personID, personID).Order("id asc").Limit(1).Find(&author)
+	if found.Error != nil || found.RowsAffected == 0 {
+		return nil, found.Error
+	}
+	detail := &AuthorDetail{
+		Author: author,
+	}
+	result := p.db.Table("authors").
+		Select("blogs.id AS blog_id, blogs.title AS title, blogs.url AS url, count(posts.id) AS post_count").
+		Joins("join blogs on blogs.id = authors.blog_id").
+		Joins("left join posts on posts.author_id = authors.id AND posts.deleted_at IS NULL").
+		Where("authors.person_id = ? AND authors.deleted_at IS NULL", personID).
+		Group("blogs.id, blogs.title, blogs.url").
+		Order("post_count desc").
+		Scan(&detail.Blogs)
+	for _, blog := range detail.Blogs {
+		detail.PostCount += blog.PostCount
+	}
+	return detail, result.Error
+}
+
+//resolveAuthor get the author of a post on a blog, creating the author if they don't exist
+func (p *GORMProjection) resolveAuthor(blogID string, name string, email string) (*Author, error) {
+	name = strings.TrimSpace(name)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if name == "" && email == "" {
+		return nil, nil
+	}
+	var author *Author
+	db := p.db.Where("blog_id = ?", blogID)
+	if email != "" {
+		db = db.Where("email = ?", email)
+	} else {
+		db = db.Where("name = ?", name)
+	}
+	result := db.Attrs(&Author{
+		Name:   name,
+		Email:  email,
+		BlogID: blogID,
+	}).FirstOrCreate(&author)
+	return author, result.Error
+}
+
+func sort(order map[string]string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for key, value := range order {
+			//only support certain values since GORM doesn't protect the order function https://gorm.io/docs/security.html#SQL-injection-Methods
+			if (value != "asc" && value != "desc" && value != "") || (key != "views" && key != "publish_date" && key != "trending_score") {
+				return db
+			}
+			db.Order(key + " " + value)
+		}
+
+		return db
+	}
+}
+
+//author filter posts by author. Authors with the same email are the same person so posts by all of them are returned
+func author(authorValue interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		persons := db.Session(&gorm.Session{NewDB: true}).Model(&Author{}).Select("person_id").Where("id = ?", authorValue)
+		authors := db.Session(&gorm.Session{NewDB: true}).Model(&Author{}).Select("id").Where("person_id IN (?)", persons)
+		db.Where("posts.author_id IN (?)", authors)
+		return db
+	}
+}
+
+func category(categoryValue interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if category, ok := categoryValue.(string); ok {
+			db.Joins("left join post_categories on post_id = posts.id").Joins("left join categories on categories.id = post_categories.category_id").Where("categories.title = ? OR categories.slug = ?", category, category)
+		}
+		return db
+	}
+}
+
+func publishDate(startDateValue interface{}, endDateValue interface{}) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if startDateValue != nil && endDateValue != nil {
+			var startDate time.Time
+			var endDate time.Time
+			var err error
+			if sdv, ok := startDateValue.(string); ok {
+				startDate, err = time.Parse("01/02/06", sdv)
+				if err != nil {
+					return db
+				}
 			}
 
 			if edv, ok := endDateValue.(string); ok {
@@ -179,6 +1737,27 @@ func filter(filter map[string]interface{}) func(db *gorm.DB) *gorm.DB {
 				delete(filter, "category")
 			}
 
+			if _, ok := filter["trending"]; ok {
+				db.Where("posts.trending_score > 0")
+				delete(filter, "trending")
+			}
+
+			if mediumValue, ok := filter["has_media"]; ok {
+				db.Where("posts.id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&Enclosure{}).Select("post_id").Where("medium = ?", mediumValue))
+				delete(filter, "has_media")
+			}
+
+			//posts from dead blogs are left out unless a blog is asked for
+			if _, ok := filter["exclude_dead"]; ok {
+				db.Where("posts.blog_id NOT IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&Blog{}).Select("id").Where("status = ?", BLOG_STATUS_DEAD))
+				delete(filter, "exclude_dead")
+			}
+
+			if authorValue, ok := filter["author_id"]; ok {
+				db.Scopes(author(authorValue))
+				delete(filter, "author_id")
+			}
+
 			var startDateValue interface{}
 			var endDateValue interface{}
 			var ok bool
@@ -215,6 +1794,10 @@ func paginate(page int, limit int) func(db *gorm.DB) *gorm.DB {
 
 func (p *GORMProjection) GetEventHandler() weos.EventHandler {
 	return func(event weos.Event) {
+		//the content of purged blogs and posts is gone from their events, the events that record the purge remove the rest
+		if scrubbedEvent(&event) {
+			return
+		}
 		switch event.Type {
 		case blogaggregatormodule.BLOG_ADDED:
 			var blog *Blog
@@ -222,6 +1805,7 @@ func (p *GORMProjection) GetEventHandler() weos.EventHandler {
 			if err != nil {
 				p.logger.Errorf("error unmarshalling event '%s'", err)
 			}
+			blog.PingbackURL, blog.WebmentionURL = p.mentionEndpoints(blog.ID)
 			db := p.db.Create(blog)
 			if db.Error != nil {
 				p.logger.Errorf("error creating blog '%s'", err)
@@ -233,6 +1817,10 @@ func (p *GORMProjection) GetEventHandler() weos.EventHandler {
 				p.logger.Errorf("error unmarshalling event '%s'", err)
 			}
 			blog.ID = event.Meta.EntityID
+			err = p.recordPreviousURLs(blog)
+			if err != nil {
+				p.logger.Errorf("error recording previous urls of blog '%s'", err)
+			}
 			db := p.db.Model(blog).Updates(blog)
 			if db.Error != nil {
 				p.logger.Errorf("error updating blog '%s'", err)
@@ -254,47 +1842,1431 @@ func (p *GORMProjection) GetEventHandler() weos.EventHandler {
 				p.logger.Errorf("error unmarshalling event '%s'", err)
 			}
 			post := &Post{
-				ID:          blogaggregatormodule.GenerateID(),
-				Title:       postPayload.Title,
-				Description: postPayload.Description,
-				Content:     postPayload.Content,
-				BlogID:      postPayload.BlogID,
-				Link:        postPayload.Link,
-				Published:   postPayload.Published,
+				ID:            blogaggregatormodule.GenerateID(),
+				Title:         postPayload.Title,
+				Description:   postPayload.Description,
+				Content:       postPayload.Content,
+				BlogID:        postPayload.BlogID,
+				Link:          postPayload.Link,
+				Published:     postPayload.Published,
+				ContentSource: CONTENT_SOURCE_FEED,
+				GUID:          itemKey(&postPayload.Item),
+				EventID:       event.ID,
 			}
+			added := make(map[uint]bool)
 			for _, tag := range postPayload.Categories {
-				var category *Category
-				p.db.Where(&Category{
-					Title: strings.Trim(tag, " "),
-				}).FirstOrCreate(&category)
+				category, err := p.resolveCategory(tag)
+				if err != nil {
+					p.logger.Errorf("error getting category '%s'", err)
+					continue
+				}
+				if category == nil || added[category.ID] {
+					continue
+				}
+				added[category.ID] = true
 				post.Categories = append(post.Categories, category)
 			}
+			//the author of the post comes from the item's author or dc:creator
+			postAuthor := postPayload.Author
+			if postAuthor == nil && len(postPayload.Authors) > 0 {
+				postAuthor = postPayload.Authors[0]
+			}
+			if postAuthor != nil {
+				author, err := p.resolveAuthor(postPayload.BlogID, postAuthor.Name, postAuthor.Email)
+				if err != nil {
+					p.logger.Errorf("error getting post author '%s'", err)
+				} else if author != nil {
+					post.AuthorID = &author.ID
+				}
+			}
+			//the content comes straight from the feed so it's sanitized before it's stored
+			var image string
+			if postPayload.Image != nil {
+				image = postPayload.Image.URL
+			}
+			base := p.postBaseURL(post)
+			if base != nil && post.Link != "" {
+				post.Link = base.String()
+			}
+			post.prepareContent(base, image)
+			post.Enclosures = postEnclosures(&postPayload.Item, base)
 			post.PublishDate, err = time.Parse("Mon, 2 Jan 2006 15:04:05 -0700", postPayload.Published)
 			if err != nil {
 				p.logger.Errorf("error parsing publish date '%s'", err)
 			}
-			db := p.db.Create(post)
-			if db.Error != nil {
+			err = p.createPost(post)
+			if err != nil {
 				p.logger.Errorf("error creating post '%s'", err)
+				return
 			}
-		}
-	}
-}
-
-//runs migrations
-func (p *GORMProjection) Migrate(ctx context.Context) error {
-	err := p.db.AutoMigrate(&Blog{}, &Post{}, &Author{}, &Category{})
-	if err != nil {
-		return err
-	}
-
-	return nil
+			p.related.invalidate(post)
+			if !post.PublishDate.IsZero() {
+				err = p.db.Model(&Blog{}).Where("id = ? AND (last_post_at IS NULL OR last_post_at < ?)", post.BlogID, post.PublishDate).Update("last_post_at", post.PublishDate).Error
+				if err != nil {
+					p.logger.Errorf("error updating blog '%s'", err)
+				}
+			}
+		case POST_UPDATED:
+			var payload *PostUpdatedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.updatePost(payload)
+			if err != nil {
+				p.logger.Errorf("error updating post '%s'", err)
+			}
+		case BLOG_DELETED:
+			var payload *BlogDeletedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.deleteBlog(event.Meta.EntityID, payload.Purge)
+			if err != nil {
+				p.logger.Errorf("error deleting blog '%s'", err)
+			}
+		case POST_DELETED:
+			var payload *PostDeletedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Transaction(func(tx *gorm.DB) error {
+				ids, err := itemPostIDs(tx, event.Meta.EntityID, payload.ItemKey, payload.PostID)
+				if err != nil {
+					return err
+				}
+				return deletePosts(tx, ids, payload.Purge)
+			})
+			if err != nil {
+				p.logger.Errorf("error deleting post '%s'", err)
+			}
+			p.related.clear()
+			p.searches.clear()
+		case BLOG_FULL_CONTENT_SET:
+			var payload *BlogFullContentPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Model(&Blog{}).Where("id = ?", event.Meta.EntityID).Update("fetch_full_content", payload.Enabled).Error
+			if err != nil {
+				p.logger.Errorf("error updating blog '%s'", err)
+				return
+			}
+			//give posts that failed before another chance
+			if payload.Enabled {
+				err = p.db.Model(&Post{}).Where("blog_id = ? AND extraction_error <> ?", event.Meta.EntityID, "").Update("extraction_error", "").Error
+				if err != nil {
+					p.logger.Errorf("error updating posts '%s'", err)
+				}
+			}
+		case BLOG_SEND_MENTIONS_SET:
+			var payload *BlogSendMentionsPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Model(&Blog{}).Where("id = ?", event.Meta.EntityID).Update("send_mentions", payload.Enabled).Error
+			if err != nil {
+				p.logger.Errorf("error updating blog '%s'", err)
+			}
+		case BLOG_FEDIVERSE_FOLLOWED:
+			var follower *BlogFediverseFollowedPayload
+			err := json.Unmarshal(event.Payload, &follower)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "blog_id"}, {Name: "actor_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"inbox", "shared_inbox"}),
+			}).Create(&FediverseFollower{
+				BlogID:      event.Meta.EntityID,
+				ActorID:     follower.ActorID,
+				Inbox:       follower.Inbox,
+				SharedInbox: follower.SharedInbox,
+			}).Error
+			if err != nil {
+				p.logger.Errorf("error adding fediverse follower '%s'", err)
+			}
+		case BLOG_FEDIVERSE_UNFOLLOWED:
+			var follower *BlogFediverseFollowedPayload
+			err := json.Unmarshal(event.Payload, &follower)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Where("blog_id = ? AND actor_id = ?", event.Meta.EntityID, follower.ActorID).Delete(&FediverseFollower{}).Error
+			if err != nil {
+				p.logger.Errorf("error removing fediverse follower '%s'", err)
+			}
+		case BLOG_FETCHED:
+			var payload *BlogFetchedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.RecordFetch(event.Meta.EntityID, payload)
+			if err != nil {
+				p.logger.Errorf("error recording fetch of blog '%s'", err)
+			}
+		case BLOG_DESCRIPTION_SET:
+			var payload *BlogDescriptionPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Model(&Blog{}).Where("id = ?", event.Meta.EntityID).Update("description", payload.Description).Error
+			if err != nil {
+				p.logger.Errorf("error updating blog '%s'", err)
+			}
+		case BLOG_CLAIMED:
+			var payload *BlogClaimedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Create(&BlogClaim{
+				BlogID: event.Meta.EntityID,
+				UserID: payload.UserID,
+				Token:  payload.Token,
+				Status: CLAIM_STATUS_PENDING,
+			}).Error
+			if err != nil {
+				p.logger.Errorf("error creating blog claim '%s'", err)
+			}
+		case BLOG_CLAIM_VERIFIED:
+			var payload *BlogClaimVerifiedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Model(&BlogClaim{}).Where("blog_id = ? AND user_id = ?", event.Meta.EntityID, payload.UserID).Updates(map[string]interface{}{
+				"status":      CLAIM_STATUS_VERIFIED,
+				"method":      payload.Method,
+				"verified_at": payload.VerifiedAt,
+			}).Error
+			if err != nil {
+				p.logger.Errorf("error verifying blog claim '%s'", err)
+			}
+		case POST_MENTIONED:
+			var payload *PostMentionedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			ids, err := itemPostIDs(p.db, event.Meta.EntityID, payload.ItemKey, payload.PostID)
+			if err == nil && len(ids) > 0 {
+				err = p.db.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "post_id"}, {Name: "source"}},
+					DoUpdates: clause.AssignmentColumns([]string{"target", "type", "title", "updated_at"}),
+				}).Create(&Mention{
+					PostID: ids[0],
+					Source: payload.Source,
+					Target: payload.Target,
+					Type:   payload.Type,
+					Title:  payload.Title,
+				}).Error
+			}
+			if err != nil {
+				p.logger.Errorf("error creating mention '%s'", err)
+			}
+		case POST_MENTION_REMOVED:
+			var payload *PostMentionRemovedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			ids, err := itemPostIDs(p.db, event.Meta.EntityID, payload.ItemKey, payload.PostID)
+			if err == nil && len(ids) > 0 {
+				err = p.db.Where("post_id IN ? AND source = ?", ids, payload.Source).Delete(&Mention{}).Error
+			}
+			if err != nil {
+				p.logger.Errorf("error removing mention '%s'", err)
+			}
+		case BLOG_FOLLOWED, BLOG_UNFOLLOWED:
+			var payload *BlogFollowedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			follower := &BlogFollower{
+				BlogID: event.Meta.EntityID,
+				UserID: payload.UserID,
+			}
+			if event.Type == BLOG_FOLLOWED {
+				err = p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(follower).Error
+			} else {
+				err = p.db.Where(follower).Delete(&BlogFollower{}).Error
+			}
+			if err != nil {
+				p.logger.Errorf("error updating blog followers '%s'", err)
+			}
+		case POST_HIDDEN:
+			var payload *PostHiddenPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			ids, err := itemPostIDs(p.db, event.Meta.EntityID, payload.ItemKey, payload.PostID)
+			if err == nil && len(ids) > 0 {
+				err = p.db.Model(&Post{}).Where("id IN ?", ids).Update("hidden", payload.Hidden).Error
+			}
+			if err != nil {
+				p.logger.Errorf("error hiding post '%s'", err)
+			}
+			p.related.clear()
+		case POST_CATEGORIES_SET:
+			var payload *PostCategoriesSetPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.setPostCategories(event.Meta.EntityID, payload)
+			if err != nil {
+				p.logger.Errorf("error setting post categories '%s'", err)
+			}
+		case CATEGORY_SYNONYM_ADDED:
+			var payload *CategorySynonymPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			var category *Category
+			err = p.db.First(&category, "slug = ?", event.Meta.EntityID).Error
+			if err != nil {
+				p.logger.Errorf("error getting category '%s'", err)
+				return
+			}
+			err = addCategorySynonym(p.db, payload.Synonym, category.ID)
+			if err != nil {
+				p.logger.Errorf("error adding category synonym '%s'", err)
+			}
+		case CATEGORY_SYNONYM_REMOVED:
+			var payload *CategorySynonymPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.db.Unscoped().
+				Where("synonym = ? AND category_id IN (?)", payload.Synonym, p.db.Model(&Category{}).Select("id").Where("slug = ?", event.Meta.EntityID)).
+				Delete(&CategorySynonym{}).Error
+			if err != nil {
+				p.logger.Errorf("error removing category synonym '%s'", err)
+			}
+		case DIGEST_SUBSCRIBED:
+			var payload *DigestSubscribedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			now := time.Now()
+			subscription := &DigestSubscription{
+				ID:            event.Meta.EntityID,
+				UserID:        payload.UserID,
+				Email:         payload.Email,
+				Frequency:     payload.Frequency,
+				FollowedBlogs: payload.FollowedBlogs,
+				Token:         payload.Token,
+				NextSendAt:    nextDigest(payload.Frequency, now),
+			}
+			//the first digest starts from the posts added after the subscription
+			err = p.db.Unscoped().Model(&Post{}).Select("COALESCE(MAX(position), 0)").Scan(&subscription.LastPosition).Error
+			if err != nil {
+				p.logger.Errorf("error getting the last post for the digest '%s'", err)
+				return
+			}
+			if len(payload.Categories) > 0 {
+				err = p.db.Where("slug IN ?", payload.Categories).Find(&subscription.Categories).Error
+				if err != nil {
+					p.logger.Errorf("error getting digest categories '%s'", err)
+					return
+				}
+			}
+			err = p.db.Omit("Categories.*").Create(subscription).Error
+			if err != nil {
+				p.logger.Errorf("error creating digest subscription '%s'", err)
+			}
+		case DIGEST_UNSUBSCRIBED:
+			err := p.db.Select("Categories").Delete(&DigestSubscription{ID: event.Meta.EntityID}).Error
+			if err != nil {
+				p.logger.Errorf("error removing digest subscription '%s'", err)
+			}
+		case SAVED_SEARCH_CREATED:
+			var payload *SavedSearchCreatedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			search := &SavedSearch{
+				ID:         event.Meta.EntityID,
+				UserID:     payload.UserID,
+				Name:       payload.Name,
+				Query:      payload.Query,
+				BlogIDs:    strings.Join(payload.Blogs, ","),
+				Notify:     payload.Notify,
+				WebhookURL: payload.WebhookURL,
+				Secret:     payload.Secret,
+				Email:      payload.Email,
+			}
+			authorIDs := make([]string, len(payload.Authors))
+			for i, id := range payload.Authors {
+				authorIDs[i] = strconv.FormatUint(uint64(id), 10)
+			}
+			search.AuthorIDs = strings.Join(authorIDs, ",")
+			if len(payload.Categories) > 0 {
+				err = p.db.Where("slug IN ?", payload.Categories).Find(&search.Categories).Error
+				if err != nil {
+					p.logger.Errorf("error getting saved search categories '%s'", err)
+					return
+				}
+			}
+			err = p.db.Omit("Categories.*").Create(search).Error
+			if err != nil {
+				p.logger.Errorf("error creating saved search '%s'", err)
+			}
+			p.searches.clear()
+		case SAVED_SEARCH_DELETED:
+			err := p.db.Transaction(func(tx *gorm.DB) error {
+				err := tx.Where("saved_search_id = ?", event.Meta.EntityID).Delete(&SavedSearchMatch{}).Error
+				if err != nil {
+					return err
+				}
+				return tx.Select("Categories").Delete(&SavedSearch{ID: event.Meta.EntityID}).Error
+			})
+			if err != nil {
+				p.logger.Errorf("error deleting saved search '%s'", err)
+			}
+			p.searches.clear()
+		case CATEGORY_MERGED:
+			var payload *CategoryMergedPayload
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				p.logger.Errorf("error unmarshalling event '%s'", err)
+				return
+			}
+			err = p.mergeCategory(event.Meta.EntityID, payload.Into)
+			if err != nil {
+				p.logger.Errorf("error merging category '%s'", err)
+			}
+			p.related.clear()
+			p.searches.clear()
+		}
+	}
+}
+
+//updatePost applies the changes to the item in the feed to its post. The post as it was before is kept as a revision
+func (p *GORMProjection) updatePost(payload *PostUpdatedPayload) error {
+	var post *Post
+	//posts that were added before the guid was stored are found by their link
+	result := p.db.Where("blog_id = ? AND (guid = ? OR ((guid = ? OR guid IS NULL) AND link = ?))", payload.BlogID, itemKey(&payload.Item), "", payload.Link).Limit(1).Find(&post)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("post for item '%s' on blog '%s' not found", itemKey(&payload.Item), payload.BlogID)
+	}
+	updated := &Post{
+		ID:            post.ID,
+		BlogID:        post.BlogID,
+		Title:         payload.Title,
+		Description:   payload.Description,
+		Content:       payload.Content,
+		Link:          payload.Link,
+		ContentSource: CONTENT_SOURCE_FEED,
+		GUID:          itemKey(&payload.Item),
+	}
+	var image string
+	if payload.Image != nil {
+		image = payload.Image.URL
+	}
+	base := p.postBaseURL(updated)
+	if base != nil && updated.Link != "" {
+		updated.Link = base.String()
+	}
+	updated.prepareContent(base, image)
+	var changes []string
+	if updated.Title != post.Title {
+		changes = append(changes, "title")
+	}
+	if updated.Link != post.Link {
+		changes = append(changes, "link")
+	}
+	if updated.Description != post.Description {
+		changes = append(changes, "description")
+	}
+	if post.ContentSource == CONTENT_SOURCE_ARTICLE {
+		//the content from the feed can't be compared to the full article so the content has changed when nothing else
+		//has. The article is downloaded again when the content changes
+		if len(changes) > 0 {
+			updated.Content = post.Content
+			updated.ContentSource = CONTENT_SOURCE_ARTICLE
+			updated.ExtractionError = post.ExtractionError
+			updated.prepareContent(base, image)
+		} else {
+			changes = append(changes, "content")
+		}
+	} else if updated.Content != post.Content {
+		changes = append(changes, "content")
+	}
+	//the feed changed in a way that isn't shown in the post e.g. markup that is removed when it's sanitized
+	if len(changes) == 0 {
+		return nil
+	}
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&PostRevision{
+			PostID:      post.ID,
+			Title:       post.Title,
+			Link:        post.Link,
+			Description: post.Description,
+			Content:     post.Content,
+			Changes:     strings.Join(changes, ","),
+			CreatedAt:   payload.DetectedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Post{}).Where("id = ?", post.ID).
+			Select("title", "link", "description", "content", "content_source", "extraction_error", "excerpt", "word_count", "reading_time", "image_url", "guid").
+			Updates(updated).Error
+	})
+	if err != nil {
+		return err
+	}
+	p.related.invalidate(post)
+	return nil
+}
+
+//setPostCategories replaces the categories of a post with the categories the owner of the blog chose
+func (p *GORMProjection) setPostCategories(blogID string, payload *PostCategoriesSetPayload) error {
+	ids, err := itemPostIDs(p.db, blogID, payload.ItemKey, payload.PostID)
+	if err != nil {
+		return err
+	}
+	var post *Post
+	if err := p.db.First(&post, "id IN ?", ids).Error; err != nil {
+		return err
+	}
+	categories := []*Category{}
+	added := make(map[uint]bool)
+	for _, tag := range payload.Categories {
+		category, err := p.resolveCategory(tag)
+		if err != nil {
+			return err
+		}
+		if category == nil || added[category.ID] {
+			continue
+		}
+		added[category.ID] = true
+		categories = append(categories, category)
+	}
+	if err := p.db.Model(post).Association("Categories").Replace(categories); err != nil {
+		return err
+	}
+	post.Categories = categories
+	p.related.invalidate(post)
+	return nil
+}
+
+//GetBlogClaim get the claim a user made to own a blog. Nil is returned if the user hasn't claimed the blog
+func (p *GORMProjection) GetBlogClaim(blogID string, userID string) (*BlogClaim, error) {
+	var claim *BlogClaim
+	if err := p.db.First(&claim, "blog_id = ? AND user_id = ?", blogID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return claim, nil
+}
+
+//IsBlogOwner checks if the user has a verified claim on the blog
+func (p *GORMProjection) IsBlogOwner(blogID string, userID string) (bool, error) {
+	var count int64
+	err := p.db.Model(&BlogClaim{}).Where("blog_id = ? AND user_id = ? AND status = ?", blogID, userID, CLAIM_STATUS_VERIFIED).Count(&count).Error
+	return count > 0, err
+}
+
+//addBlogDailyStat adds the counts to the rollup of a blog for the day
+func addBlogDailyStat(tx *gorm.DB, stat *BlogDailyStat) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "blog_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"views":          gorm.Expr("blog_daily_stats.views + ?", stat.Views),
+			"fetches":        gorm.Expr("blog_daily_stats.fetches + ?", stat.Fetches),
+			"failed_fetches": gorm.Expr("blog_daily_stats.failed_fetches + ?", stat.FailedFetches),
+		}),
+	}).Create(stat).Error
+}
+
+//RecordPostView counts a view of a post in the post's view count and in the daily rollups. False is returned if the post
+//doesn't exist. Views aren't recorded as events (there would be an event for every page view) so the counts only live in
+//the projection, they can't be rebuilt from the event store
+func (p *GORMProjection) RecordPostView(id string, at time.Time) (bool, error) {
+	var post *Post
+	if err := p.db.Select("id", "blog_id").First(&post, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Post{}).Where("id = ?", post.ID).UpdateColumn("views", gorm.Expr("views + ?", 1)).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "post_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("post_daily_views.views + ?", 1)}),
+		}).Create(&PostDailyView{
+			PostID: post.ID,
+			Day:    at.UTC().Format(statsDayFormat),
+			BlogID: post.BlogID,
+			Views:  1,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return addBlogDailyStat(tx, &BlogDailyStat{
+			BlogID: post.BlogID,
+			Day:    at.UTC().Format(statsDayFormat),
+			Views:  1,
+		})
+	})
+	return err == nil, err
+}
+
+//GetBlogStats get the stats of a blog for the days from the day of from to the day of to. The views and fetches come
+//from the daily rollups. Nil is returned if the blog doesn't exist
+func (p *GORMProjection) GetBlogStats(blogID string, from time.Time, to time.Time) (*BlogStats, error) {
+	blog, err := p.GetBlogByID(blogID)
+	if err != nil || blog == nil {
+		return nil, err
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	stats := &BlogStats{
+		BlogID:        blogID,
+		From:          from.Format(statsDayFormat),
+		To:            to.Format(statsDayFormat),
+		DailyViews:    []*DailyViews{},
+		PostsPerMonth: []*MonthlyPosts{},
+		TopPosts:      []*PostViews{},
+		TopCategories: []*CategoryPosts{},
+	}
+	var days []*BlogDailyStat
+	err = p.db.Where("blog_id = ? AND day BETWEEN ? AND ?", blogID, stats.From, stats.To).Order("day asc").Find(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	//every day in the range is listed so that days without views are zero
+	views := make(map[string]int64)
+	for _, day := range days {
+		views[day.Day] = day.Views
+		stats.TotalViews += day.Views
+		stats.Fetches.Total += day.Fetches
+		stats.Fetches.Failed += day.FailedFetches
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		stats.DailyViews = append(stats.DailyViews, &DailyViews{
+			Day:   day.Format(statsDayFormat),
+			Views: views[day.Format(statsDayFormat)],
+		})
+	}
+	if stats.Fetches.Total > 0 {
+		reliability := float64(stats.Fetches.Total-stats.Fetches.Failed) / float64(stats.Fetches.Total)
+		stats.Fetches.Reliability = &reliability
+	}
+	err = p.db.Table("post_daily_views").
+		Select("posts.id AS id, posts.title AS title, posts.link AS link, SUM(post_daily_views.views) AS views").
+		Joins("join posts on posts.id = post_daily_views.post_id AND posts.deleted_at IS NULL").
+		Where("post_daily_views.blog_id = ? AND post_daily_views.day BETWEEN ? AND ?", blogID, stats.From, stats.To).
+		Group("posts.id, posts.title, posts.link").
+		Order("views desc").
+		Limit(topPostsLimit).
+		Scan(&stats.TopPosts).Error
+	if err != nil {
+		return nil, err
+	}
+	end := to.AddDate(0, 0, 1)
+	var published []time.Time
+	err = p.db.Model(&Post{}).Where("blog_id = ? AND publish_date >= ? AND publish_date < ?", blogID, from, end).Order("publish_date asc").Pluck("publish_date", &published).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, date := range published {
+		month := date.UTC().Format("2006-01")
+		if last := len(stats.PostsPerMonth) - 1; last >= 0 && stats.PostsPerMonth[last].Month == month {
+			stats.PostsPerMonth[last].Posts++
+			continue
+		}
+		stats.PostsPerMonth = append(stats.PostsPerMonth, &MonthlyPosts{Month: month, Posts: 1})
+	}
+	err = p.db.Table("post_categories").
+		Select("categories.id AS id, categories.title AS title, categories.slug AS slug, count(*) AS posts").
+		Joins("join posts on posts.id = post_categories.post_id").
+		Joins("join categories on categories.id = post_categories.category_id").
+		Where("posts.blog_id = ? AND posts.deleted_at IS NULL AND posts.publish_date >= ? AND posts.publish_date < ?", blogID, from, end).
+		Group("categories.id, categories.title, categories.slug").
+		Order("posts desc").
+		Limit(topCategoriesLimit).
+		Scan(&stats.TopCategories).Error
+	if err != nil {
+		return nil, err
+	}
+	err = p.db.Model(&BlogFollower{}).Where("blog_id = ?", blogID).Count(&stats.Followers).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+//mentionEndpoints get the pingback and webmention urls of a blog
+func (p *GORMProjection) mentionEndpoints(blogID string) (string, string) {
+	base := p.publicURL + "/blogs/" + url.PathEscape(blogID)
+	return base + "/pingback", base + "/webmention"
+}
+
+//GetPostByLink get the post on a blog that has the link. Links that only differ by a trailing slash or fragment are the
+//same. Nil is returned if there is no post with the link
+func (p *GORMProjection) GetPostByLink(blogID string, link string) (*Post, error) {
+	normalized := normalizeMentionURL(link)
+	var post *Post
+	result := p.db.Where("blog_id = ? AND link IN ?", blogID, []string{link, normalized, normalized + "/"}).Limit(1).Find(&post)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return post, nil
+}
+
+//GetMentions get the pages that mention a post, the latest mention is first. Nil is returned if the post doesn't exist
+func (p *GORMProjection) GetMentions(postID string) ([]*Mention, error) {
+	var count int64
+	err := p.db.Model(&Post{}).Where("id = ?", postID).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	mentions := []*Mention{}
+	err = p.db.Where("post_id = ?", postID).Order("updated_at desc, id desc").Find(&mentions).Error
+	return mentions, err
+}
+
+//GetSentMentions get the webmentions sent for the posts on a blog, the latest first. The mentions can be filtered by
+//status. Nil is returned if the blog doesn't exist
+func (p *GORMProjection) GetSentMentions(blogID string, status string) ([]*SentMention, error) {
+	var count int64
+	err := p.db.Model(&Blog{}).Where("id = ?", blogID).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	db := p.db.Where("blog_id = ?", blogID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	mentions := []*SentMention{}
+	err = db.Order("created_at desc, id desc").Find(&mentions).Error
+	return mentions, err
+}
+
+//QueueMention queues a webmention or pingback to be checked by the received mentions job
+func (p *GORMProjection) QueueMention(mention *ReceivedMention) error {
+	return p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(mention).Error
+}
+
+//GetQueuedMentions get the mentions that are due to be checked, the oldest first
+func (p *GORMProjection) GetQueuedMentions(now time.Time, limit int) ([]*ReceivedMention, error) {
+	var mentions []*ReceivedMention
+	err := p.db.Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).Order("created_at asc, id asc").Limit(limit).Find(&mentions).Error
+	return mentions, err
+}
+
+//RetryQueuedMention checks a mention again at the next attempt
+func (p *GORMProjection) RetryQueuedMention(id uint, checkErr error, next time.Time) error {
+	return p.db.Model(&ReceivedMention{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      checkErr.Error(),
+		"next_attempt_at": next,
+	}).Error
+}
+
+//RemoveQueuedMention removes a mention from the queue once it's been checked
+func (p *GORMProjection) RemoveQueuedMention(id uint) error {
+	return p.db.Delete(&ReceivedMention{}, id).Error
+}
+
+//GetWebFinger find the blog with the handle in the resource e.g. acct:ak33m-com@example.com. The url of the blog's
+//actor is accepted as well. Nil is returned if no blog has the handle
+func (p *GORMProjection) GetWebFinger(resource string) (*WebFinger, error) {
+	if p.publicURL == "" {
+		return nil, ErrFederationDisabled
+	}
+	host := p.publicHost()
+	db := p.db
+	actorPrefix := p.publicURL + "/blogs/"
+	switch {
+	case strings.HasPrefix(resource, "acct:"):
+		at := strings.LastIndex(resource, "@")
+		if at == -1 || !strings.EqualFold(resource[at+1:], host) {
+			return nil, nil
+		}
+		db = db.Where("slug = ?", strings.TrimPrefix(resource[:at], "acct:"))
+	case strings.HasPrefix(resource, actorPrefix) && strings.HasSuffix(resource, "/actor"):
+		id, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(resource, actorPrefix), "/actor"))
+		if err != nil {
+			return nil, nil
+		}
+		db = db.Where("id = ?", id)
+	default:
+		return nil, nil
+	}
+	var blog *Blog
+	result := db.Limit(1).Find(&blog)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	actor := p.actorURL(blog.ID)
+	finger := &WebFinger{
+		Subject: "acct:" + blog.Slug + "@" + host,
+		Aliases: []string{actor},
+		Links: []*WebFingerLink{
+			{Rel: "self", Type: ACTIVITY_CONTENT_TYPE, Href: actor},
+		},
+	}
+	if blog.URL != "" {
+		finger.Links = append(finger.Links, &WebFingerLink{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: blog.URL})
+	}
+	return finger, nil
+}
+
+//GetActor get the ActivityPub actor of a blog. The key pair of the blog is generated the first time. Nil is returned if
+//the blog doesn't exist
+func (p *GORMProjection) GetActor(blogID string) (*Actor, error) {
+	if p.publicURL == "" {
+		return nil, ErrFederationDisabled
+	}
+	var blog *Blog
+	result := p.db.Where("id = ?", blogID).Limit(1).Find(&blog)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	key, err := p.actorKey(blog.ID)
+	if err != nil {
+		return nil, err
+	}
+	actor := p.actorURL(blog.ID)
+	base := strings.TrimSuffix(actor, "/actor")
+	name := blog.Title
+	if name == "" {
+		name = blog.Slug
+	}
+	document := &Actor{
+		Context:           []string{activityStreamsContext, securityContext},
+		ID:                actor,
+		Type:              "Service",
+		PreferredUsername: blog.Slug,
+		Name:              name,
+		Summary:           html.EscapeString(blog.Description),
+		URL:               blog.URL,
+		Inbox:             base + "/inbox",
+		Outbox:            base + "/outbox",
+		Followers:         base + "/followers",
+		PublicKey: &PublicKey{
+			ID:           actor + "#main-key",
+			Owner:        actor,
+			PublicKeyPem: key.PublicKey,
+		},
+	}
+	for _, icon := range []string{blog.ImageURL, blog.AppleTouchIconURL, blog.FaviconURL} {
+		if icon != "" {
+			document.Icon = &ActivityImage{Type: "Image", URL: icon}
+			break
+		}
+	}
+	return document, nil
+}
+
+//GetOutbox get the latest posts of a blog as Create activities. Nil is returned if the blog doesn't exist
+func (p *GORMProjection) GetOutbox(blogID string) (*OrderedCollection, error) {
+	if p.publicURL == "" {
+		return nil, ErrFederationDisabled
+	}
+	var count int64
+	err := p.db.Model(&Blog{}).Where("id = ?", blogID).Count(&count).Error
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	db := p.db.Model(&Post{}).Where("blog_id = ? AND hidden = ?", blogID, false)
+	err = db.Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	var posts []*Post
+	err = db.Order("publish_date desc, id desc").Limit(outboxLimit).Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, len(posts))
+	for i, post := range posts {
+		items[i] = p.createActivity(post)
+	}
+	return &OrderedCollection{
+		Context:      activityStreamsContext,
+		ID:           strings.TrimSuffix(p.actorURL(blogID), "/actor") + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   count,
+		OrderedItems: items,
+	}, nil
+}
+
+//GetFediverseFollowers get the fediverse followers collection of a blog. Only the number of followers is shared. Nil is
+//returned if the blog doesn't exist
+func (p *GORMProjection) GetFediverseFollowers(blogID string) (*OrderedCollection, error) {
+	if p.publicURL == "" {
+		return nil, ErrFederationDisabled
+	}
+	var count int64
+	err := p.db.Model(&Blog{}).Where("id = ?", blogID).Count(&count).Error
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	err = p.db.Model(&FediverseFollower{}).Where("blog_id = ?", blogID).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedCollection{
+		Context:    activityStreamsContext,
+		ID:         strings.TrimSuffix(p.actorURL(blogID), "/actor") + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}, nil
+}
+
+//actorURL get the id of the ActivityPub actor of a blog
+func (p *GORMProjection) actorURL(blogID string) string {
+	return p.publicURL + "/blogs/" + url.PathEscape(blogID) + "/actor"
+}
+
+//publicHost get the host used in the fediverse handles of the blogs
+func (p *GORMProjection) publicHost() string {
+	link, err := url.Parse(p.publicURL)
+	if err != nil {
+		return ""
+	}
+	return link.Host
+}
+
+//actorKey get the key pair of a blog, it's generated the first time it's needed
+func (p *GORMProjection) actorKey(blogID string) (*ActorKey, error) {
+	var key *ActorKey
+	result := p.db.Where("blog_id = ?", blogID).Limit(1).Find(&key)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return key, result.Error
+	}
+	privateKey, publicKey, err := generateActorKey()
+	if err != nil {
+		return nil, err
+	}
+	//the key could have been generated by another request at the same time, the key that was saved first is used
+	err = p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ActorKey{BlogID: blogID, PrivateKey: privateKey, PublicKey: publicKey}).Error
+	if err != nil {
+		return nil, err
+	}
+	key = nil
+	err = p.db.Where("blog_id = ?", blogID).First(&key).Error
+	return key, err
+}
+
+//createActivity get the Create activity for a post. The article links to the post on the blog
+func (p *GORMProjection) createActivity(post *Post) *Activity {
+	actor := p.actorURL(post.BlogID)
+	followers := strings.TrimSuffix(actor, "/actor") + "/followers"
+	var published string
+	if !post.PublishDate.IsZero() {
+		published = post.PublishDate.UTC().Format(time.RFC3339)
+	}
+	article := p.publicURL + "/posts/" + url.PathEscape(post.ID)
+	return &Activity{
+		Context:   activityStreamsContext,
+		ID:        article + "#create",
+		Type:      "Create",
+		Actor:     actor,
+		Published: published,
+		To:        []string{activityPublic},
+		Cc:        []string{followers},
+		Object: &Article{
+			ID:           article,
+			Type:         "Article",
+			AttributedTo: actor,
+			Name:         post.Title,
+			Summary:      html.EscapeString(post.Excerpt),
+			Content:      post.Content,
+			URL:          post.Link,
+			Published:    published,
+			To:           []string{activityPublic},
+			Cc:           []string{followers},
+		},
+	}
+}
+
+//acceptActivity get the Accept activity for a follow of a blog
+func (p *GORMProjection) acceptActivity(blogID string, follower *BlogFediverseFollowedPayload) *Activity {
+	actor := p.actorURL(blogID)
+	return &Activity{
+		Context: activityStreamsContext,
+		ID:      actor + "#accept-" + blogaggregatormodule.GenerateID(),
+		Type:    "Accept",
+		Actor:   actor,
+		Object: &Activity{
+			ID:     follower.FollowID,
+			Type:   "Follow",
+			Actor:  follower.ActorID,
+			Object: actor,
+		},
+	}
+}
+
+//queueActivity adds the activity to the deliveries so that the delivery job posts it to each of the inboxes
+func (p *GORMProjection) queueActivity(blogID string, postID string, activity *Activity, inboxes ...string) error {
+	if p.publicURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, inbox := range inboxes {
+		err = p.db.Create(&ActivityDelivery{
+			BlogID:        blogID,
+			PostID:        postID,
+			Inbox:         inbox,
+			Activity:      string(body),
+			Status:        ACTIVITY_DELIVERY_PENDING,
+			NextAttemptAt: &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//queuePostActivity delivers a new post to the fediverse followers of the blog. Followers on the same server share an
+//inbox so the post is delivered to each server once
+func (p *GORMProjection) queuePostActivity(post *Post) error {
+	if p.publicURL == "" || post.Hidden {
+		return nil
+	}
+	var inboxes []string
+	err := p.db.Model(&FediverseFollower{}).Where("blog_id = ?", post.BlogID).Distinct().
+		Pluck("COALESCE(NULLIF(shared_inbox, ''), inbox)", &inboxes).Error
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	return p.queueActivity(post.BlogID, post.ID, p.createActivity(post), inboxes...)
+}
+
+//GetDigestSubscriptions get the digests a user subscribed to
+func (p *GORMProjection) GetDigestSubscriptions(userID string) ([]*DigestSubscription, error) {
+	subscriptions := []*DigestSubscription{}
+	err := p.db.Preload("Categories").Where("user_id = ?", userID).Order("created_at asc, id asc").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+//GetDigestSubscription get a digest subscription. Nil is returned if there's no subscription with the id
+func (p *GORMProjection) GetDigestSubscription(id string) (*DigestSubscription, error) {
+	var subscription *DigestSubscription
+	result := p.db.Preload("Categories").Where("id = ?", id).Limit(1).Find(&subscription)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return subscription, nil
+}
+
+//GetDigestSubscriptionByToken get the digest subscription with the token from an unsubscribe link
+func (p *GORMProjection) GetDigestSubscriptionByToken(token string) (*DigestSubscription, error) {
+	if token == "" {
+		return nil, nil
+	}
+	var subscription *DigestSubscription
+	result := p.db.Where("token = ?", token).Limit(1).Find(&subscription)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return subscription, nil
+}
+
+//GetSavedSearches get the searches a user saved
+func (p *GORMProjection) GetSavedSearches(userID string) ([]*SavedSearch, error) {
+	searches := []*SavedSearch{}
+	err := p.db.Preload("Categories").Where("user_id = ?", userID).Order("created_at asc, id asc").Find(&searches).Error
+	return searches, err
+}
+
+//GetSavedSearch get a saved search. Nil is returned if there's no search with the id
+func (p *GORMProjection) GetSavedSearch(id string) (*SavedSearch, error) {
+	var search *SavedSearch
+	result := p.db.Preload("Categories").Where("id = ?", id).Limit(1).Find(&search)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return search, nil
+}
+
+//GetSavedSearchMatches get the posts that matched a saved search, the newest first. Posts that were deleted are left out
+func (p *GORMProjection) GetSavedSearchMatches(searchID string, page int, limit int) ([]*SavedSearchMatch, int64, error) {
+	matches := []*SavedSearchMatch{}
+	var count int64
+	db := p.db.Model(&SavedSearchMatch{}).
+		Joins("JOIN posts ON posts.id = saved_search_matches.post_id AND posts.deleted_at IS NULL").
+		Where("saved_search_matches.saved_search_id = ?", searchID).Session(&gorm.Session{})
+	err := db.Count(&count).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = db.Select("saved_search_matches.*").Preload("Post.Blog").Preload("Post.Categories").
+		Order("saved_search_matches.created_at desc, saved_search_matches.id desc").Scopes(paginate(page, limit)).Find(&matches).Error
+	return matches, count, err
+}
+
+//GetPostByID get a post. Posts that were deleted are returned as well so that they can still be purged
+func (p *GORMProjection) GetPostByID(id string) (*Post, error) {
+	var post *Post
+	if err := p.db.Unscoped().First(&post, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return post, nil
+}
+
+//GetPostRevisions get the earlier versions of a post, the latest revision is first. Nil is returned when the post doesn't
+//exist
+func (p *GORMProjection) GetPostRevisions(id string) ([]*PostRevision, error) {
+	var count int64
+	err := p.db.Model(&Post{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	revisions := []*PostRevision{}
+	err = p.db.Where("post_id = ?", id).Order("created_at desc, id desc").Find(&revisions).Error
+	return revisions, err
+}
+
+//postBaseURL gets the url that relative urls in a post are resolved against i.e. the post link resolved against the blog url
+func (p *GORMProjection) postBaseURL(post *Post) *url.URL {
+	var blog *Blog
+	if err := p.db.First(&blog, "id = ?", post.BlogID).Error; err != nil {
+		blog = &Blog{}
+	}
+	base, err := url.Parse(blog.URL)
+	if err != nil || blog.URL == "" {
+		base = nil
+	}
+	if link, err := url.Parse(post.Link); err == nil && post.Link != "" {
+		if base == nil {
+			base = link
+		} else {
+			base = base.ResolveReference(link)
+		}
+	}
+	if base == nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil
+	}
+	return base
+}
+
+//runs migrations
+func (p *GORMProjection) Migrate(ctx context.Context) error {
+	err := p.db.AutoMigrate(&Blog{}, &Post{}, &Author{}, &Category{}, &CategorySynonym{}, &BlogProfile{}, &Enclosure{}, &FetchDiagnostic{}, &BlogURL{}, &PostRevision{}, &BlogClaim{}, &BlogDailyStat{}, &PostDailyView{}, &BlogFollower{}, &Mention{}, &ReceivedMention{}, &SentMention{}, &FediverseFollower{}, &ActorKey{}, &ActivityDelivery{}, &DigestSubscription{}, &SavedSearch{}, &SavedSearchMatch{})
+	if err != nil {
+		return err
+	}
+	//generate slugs for categories that were created before slugs were added
+	var categories []*Category
+	err = p.db.Where("slug = ? OR slug IS NULL", "").Find(&categories).Error
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		err = p.db.Model(category).Update("slug", uniqueSlug(p.db, Slugify(category.Title))).Error
+		if err != nil {
+			return err
+		}
+	}
+	//link authors that were created before authors were grouped by email
+	var authors []*Author
+	err = p.db.Where("person_id = ? OR person_id IS NULL", 0).Order("id asc").Find(&authors).Error
+	if err != nil {
+		return err
+	}
+	for _, author := range authors {
+		author.Email = strings.ToLower(strings.TrimSpace(author.Email))
+		err = p.db.Model(author).Update("email", author.Email).Error
+		if err != nil {
+			return err
+		}
+		err = linkPerson(p.db, author)
+		if err != nil {
+			return err
+		}
+	}
+	//sanitize posts that were stored before content was sanitized
+	var posts []*Post
+	err = p.db.Where("word_count = ? AND (content <> ? OR description <> ?)", 0, "", "").FindInBatches(&posts, 100, func(tx *gorm.DB, batch int) error {
+		for _, post := range posts {
+			post.prepareContent(p.postBaseURL(post), "")
+			err := p.db.Model(post).Select("content", "description", "excerpt", "word_count", "reading_time", "image_url").Updates(post).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	//normalize the titles of categories that were created before normalization was added
+	categories = nil
+	err = p.db.Where("normalized_title = ? OR normalized_title IS NULL", "").Find(&categories).Error
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		err = p.db.Model(category).Update("normalized_title", NormalizeCategory(category.Title)).Error
+		if err != nil {
+			return err
+		}
+	}
+	//number the posts that were added before the post stream resumed from the position of the post, in the order they
+	//were added
+	var position int64
+	err = p.db.Unscoped().Model(&Post{}).Select("COALESCE(MAX(position), 0)").Scan(&position).Error
+	if err != nil {
+		return err
+	}
+	var unnumbered []*Post
+	err = p.db.Unscoped().Select("id").Where("position = ? OR position IS NULL", 0).Order("created_at asc, event_id asc, id asc").Find(&unnumbered).Error
+	if err != nil {
+		return err
+	}
+	for _, post := range unnumbered {
+		position++
+		err = p.db.Unscoped().Model(&Post{}).Where("id = ?", post.ID).Update("position", position).Error
+		if err != nil {
+			return err
+		}
+	}
+	//move the digests of subscriptions that were created before digests started from the position of the post to the
+	//position of the last post they were sent
+	if p.db.Migrator().HasColumn(&DigestSubscription{}, "last_post_at") {
+		err = p.db.Model(&DigestSubscription{}).Where("last_post_at IS NOT NULL").Updates(map[string]interface{}{
+			"last_position": p.db.Unscoped().Model(&Post{}).Select("COALESCE(MAX(position), 0)").Where("posts.created_at <= digest_subscriptions.last_post_at"),
+			"last_post_at":  nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	//link the post deliveries that were queued before deliveries had the post so they're removed when the post is purged
+	var deliveries []*ActivityDelivery
+	err = p.db.Where("(post_id = ? OR post_id IS NULL) AND activity LIKE ?", "", `%"type":"Create"%`).FindInBatches(&deliveries, 100, func(tx *gorm.DB, batch int) error {
+		for _, delivery := range deliveries {
+			var create *struct {
+				Object struct {
+					ID string `json:"id"`
+				} `json:"object"`
+			}
+			if json.Unmarshal([]byte(delivery.Activity), &create) != nil {
+				continue
+			}
+			index := strings.LastIndex(create.Object.ID, "/posts/")
+			if index == -1 {
+				continue
+			}
+			postID, err := url.PathUnescape(create.Object.ID[index+len("/posts/"):])
+			if err != nil {
+				continue
+			}
+			err = p.db.Model(&ActivityDelivery{}).Where("id = ?", delivery.ID).Update("post_id", postID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	//set the date of the latest post for blogs that were added before blog health was tracked
+	err = p.db.Exec("UPDATE blogs SET last_post_at = (SELECT MAX(publish_date) FROM posts WHERE posts.blog_id = blogs.id) WHERE last_post_at IS NULL").Error
+	if err != nil {
+		return err
+	}
+	//generate the pingback and webmention urls of blogs that were added before mentions were received or when the public
+	//url changes
+	var blogs []*Blog
+	err = p.db.Select("id").Where("pingback_url IS NULL OR pingback_url NOT LIKE ?", p.publicURL+"/blogs/%").Find(&blogs).Error
+	if err != nil {
+		return err
+	}
+	for _, blog := range blogs {
+		pingbackURL, webmentionURL := p.mentionEndpoints(blog.ID)
+		err = p.db.Model(&Blog{}).Where("id = ?", blog.ID).Updates(map[string]interface{}{"pingback_url": pingbackURL, "webmention_url": webmentionURL}).Error
+		if err != nil {
+			return err
+		}
+	}
+	//generate the slugs of blogs that were added before blogs could be followed from the fediverse
+	blogs = nil
+	err = p.db.Unscoped().Where("slug = ? OR slug IS NULL", "").Order("created_at asc").Find(&blogs).Error
+	if err != nil {
+		return err
+	}
+	for _, blog := range blogs {
+		err = p.db.Unscoped().Model(&Blog{}).Where("id = ?", blog.ID).Update("slug", availableSlug(p.db, &Blog{}, blogSlug(blog))).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//PostsAdded sends the posts created by the events to the places new posts go: webmentions are queued for their links,
+//they're delivered to the fediverse followers of the blog, matched against the saved searches and sent to the post
+//stream. It's called by the receiver once the events are persisted instead of by the event handler so that nothing is
+//sent again when the projection is rebuilt from the events
+func (p *GORMProjection) PostsAdded(eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	var posts []*Post
+	err := p.db.Preload("Categories").Where("event_id IN ?", eventIDs).Order("publish_date asc").Find(&posts).Error
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		err = p.queueWebmentions(post)
+		if err != nil {
+			p.logger.Errorf("error queueing webmentions '%s'", err)
+		}
+		err = p.queuePostActivity(post)
+		if err != nil {
+			p.logger.Errorf("error queueing post for the fediverse followers '%s'", err)
+		}
+		err = p.matchSavedSearches(post)
+		if err != nil {
+			p.logger.Errorf("error matching saved searches '%s'", err)
+		}
+		err = p.streamPost(post.ID)
+		if err != nil {
+			p.logger.Errorf("error sending post to the stream '%s'", err)
+		}
+	}
+	return nil
+}
+
+//FediverseFollowed accepts the follow so that the follower's server starts showing the blog's posts. It's called by the
+//receiver once the follow is persisted so that follows aren't accepted again when the projection is rebuilt
+func (p *GORMProjection) FediverseFollowed(blogID string, follower *BlogFediverseFollowedPayload) error {
+	return p.queueActivity(blogID, "", p.acceptActivity(blogID, follower), follower.Inbox)
+}
+
+//RecordFetch update the fetch stats and the problems of a blog. Fetches that didn't change anything aren't recorded as
+//events so they're only counted here, they aren't counted again when the projection is rebuilt
+func (p *GORMProjection) RecordFetch(blogID string, fetched *BlogFetchedPayload) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var failed int64
+		if fetched.Error != "" {
+			failed = 1
+		}
+		err := addBlogDailyStat(tx, &BlogDailyStat{
+			BlogID:        blogID,
+			Day:           fetched.FetchedAt.UTC().Format(statsDayFormat),
+			Fetches:       1,
+			FailedFetches: failed,
+		})
+		if err != nil {
+			return err
+		}
+		//failed fetches are counted so that blogs that stop responding can be found
+		if fetched.Error != "" {
+			return tx.Model(&Blog{}).Where("id = ?", blogID).Updates(map[string]interface{}{
+				"last_fetched_at":      fetched.FetchedAt,
+				"last_error":           fetched.Error,
+				"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+			}).Error
+		}
+		err = tx.Model(&Blog{}).Where("id = ?", blogID).Updates(map[string]interface{}{
+			"last_fetched_at":            fetched.FetchedAt,
+			"last_succeeded_at":          fetched.FetchedAt,
+			"last_error":                 "",
+			"consecutive_failures":       0,
+			"last_fetch_new_items":       fetched.NewItems,
+			"last_fetch_updated_items":   fetched.UpdatedItems,
+			"last_fetch_unchanged_items": fetched.UnchangedItems,
+		}).Error
+		if err != nil {
+			return err
+		}
+		//only the problems from the latest fetch are kept
+		err = tx.Where("blog_id = ?", blogID).Delete(&FetchDiagnostic{}).Error
+		if err != nil {
+			return err
+		}
+		for _, problem := range fetched.Problems {
+			err = tx.Create(&FetchDiagnostic{
+				BlogID:    blogID,
+				Problem:   problem,
+				CreatedAt: fetched.FetchedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func NewProjection(application weos.Application) (*GORMProjection, error) {
 	projection := &GORMProjection{
-		db:     application.DB(),
-		logger: application.Logger(),
+		db:        application.DB(),
+		logger:    application.Logger(),
+		related:   newRelatedCache(relatedCacheSize),
+		searches:  &searchCache{},
+		publicURL: strings.TrimSuffix(envString("PUBLIC_URL", ""), "/"),
+		stream:    NewPostBroker(),
 	}
 	application.AddProjection(projection)
 	return projection, nil
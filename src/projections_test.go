@@ -1,20 +1,77 @@
 package api_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/mmcdole/gofeed"
 	api "github.com/wepala/blog-aggregator-api/src"
+	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
+	"github.com/wepala/go-testhelpers"
 	"github.com/wepala/weos"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+//newTestProjection setup a migrated projection on an empty test database. A logger that ignores errors is used if none
+//is passed
+func newTestProjection(t *testing.T, logger *LogMock) (*api.GORMProjection, *gorm.DB) {
+	os.Remove("test.db")
+	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database '%s'", err)
+	}
+	if logger == nil {
+		logger = &LogMock{
+			ErrorFunc:  func(args ...interface{}) {},
+			ErrorfFunc: func(format string, args ...interface{}) {},
+		}
+	}
+	application := &ApplicationMock{
+		DBFunc: func() *gorm.DB {
+			return db
+		},
+		LoggerFunc: func() weos.Log {
+			return logger
+		},
+		AddProjectionFunc: func(projection weos.Projection) error {
+			return nil
+		},
+	}
+	projection, err := api.NewProjection(application)
+	if err != nil {
+		t.Fatalf("unexpected error setting up projection '%s'", err)
+	}
+	if err = projection.Migrate(context.Background()); err != nil {
+		t.Fatalf("unexpected error migrating projection '%s'", err)
+	}
+	return projection, db
+}
+
 func TestProjection_GetPosts(t *testing.T) {
 	//setup gorm db connection
 	//TODO setup a way to test against multiple database
+	os.Remove("test.db")
 	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("failed to connect database '%s'", err)
@@ -455,13 +512,2603 @@ func TestGetAuthors(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error getting authors '%s'",err)
 		}
-		if count != 6 {
-			t.Errorf("expected the number authors to be returned to be %d, got %d",6,count)
+		//authors with the same email are the same person so they are only listed once
+		if count != 3 {
+			t.Errorf("expected the number authors to be returned to be %d, got %d",3,count)
+		}
+
+		if len(authors) != 3 {
+			t.Fatalf("expected %d authors to be returned, got %d",3,len(authors))
+		}
+
+	})
+}
+
+func TestProjection_GetCategory(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	mockBlogs := []*api.Blog{
+		{
+			ID:    "123",
+			Title: "Some Blog 1",
+		},
+		{
+			ID:    "456",
+			Title: "Some Blog 2",
+		},
+	}
+	db.Create(mockBlogs)
+	categories := []*api.Category{
+		{
+			Title: "Viro React",
+		},
+		{
+			Title: "ar",
+		},
+		{
+			Title: "vue",
+		},
+	}
+	db.Create(categories)
+	mockPosts := []*api.Post{
+		{
+			ID:         "1",
+			Title:      "Post 1",
+			BlogID:     "123",
+			Categories: categories[:2],
+		},
+		{
+			ID:         "2",
+			Title:      "Post 2",
+			BlogID:     "123",
+			Categories: categories,
+		},
+		{
+			ID:         "3",
+			Title:      "Post 3",
+			BlogID:     "456",
+			Categories: categories[:1],
+		},
+	}
+	db.Create(mockPosts)
+
+	t.Run("slugs are generated", func(t *testing.T) {
+		if categories[0].Slug != "viro-react" {
+			t.Errorf("expected the slug to be '%s', got '%s'", "viro-react", categories[0].Slug)
+		}
+		duplicate := &api.Category{Title: "Viro-React"}
+		db.Create(duplicate)
+		if duplicate.Slug != "viro-react-2" {
+			t.Errorf("expected the slug to be '%s', got '%s'", "viro-react-2", duplicate.Slug)
+		}
+		db.Unscoped().Delete(duplicate)
+	})
+
+	t.Run("get category details", func(t *testing.T) {
+		category, err := projection.GetCategory("viro-react")
+		if err != nil {
+			t.Fatalf("unexpected error getting category '%s'", err)
+		}
+		if category == nil {
+			t.Fatal("expected a category to be returned")
+		}
+		if category.PostCount != 3 {
+			t.Errorf("expected the post count to be %d, got %d", 3, category.PostCount)
+		}
+		if len(category.TopBlogs) != 2 {
+			t.Fatalf("expected %d top blogs, got %d", 2, len(category.TopBlogs))
+		}
+		if category.TopBlogs[0].BlogID != "123" || category.TopBlogs[0].PostCount != 2 {
+			t.Errorf("expected the top blog to be '%s' with %d posts, got '%s' with %d posts", "123", 2, category.TopBlogs[0].BlogID, category.TopBlogs[0].PostCount)
+		}
+		if len(category.RelatedCategories) != 2 {
+			t.Fatalf("expected %d related categories, got %d", 2, len(category.RelatedCategories))
+		}
+		if category.RelatedCategories[0].Slug != "ar" || category.RelatedCategories[0].SharedPosts != 2 {
+			t.Errorf("expected the most related category to be '%s' with %d shared posts, got '%s' with %d", "ar", 2, category.RelatedCategories[0].Slug, category.RelatedCategories[0].SharedPosts)
+		}
+	})
+
+	t.Run("get category that doesn't exist", func(t *testing.T) {
+		category, err := projection.GetCategory("angular")
+		if err != nil {
+			t.Fatalf("unexpected error getting category '%s'", err)
+		}
+		if category != nil {
+			t.Errorf("expected no category to be returned")
+		}
+	})
+
+	t.Run("get posts by category slug", func(t *testing.T) {
+		filters := make(map[string]interface{})
+		filters["category"] = "viro-react"
+		_, count, err := projection.GetPosts(1, 5, "", nil, filters)
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		if count != 3 {
+			t.Errorf("expected the number posts to be returned to be %d, got %d", 3, count)
+		}
+	})
+}
+
+func TestProjection_CategoryNormalization(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{
+		ID:    "123",
+		Title: "Some Blog 1",
+	})
+	handler := projection.GetEventHandler()
+	addPost := func(title string, tags string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"123","title":"%s","published":"Sat, 27 Mar 2021 17:05:53 -0400","categories":[%s]}`, title, tags)),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+	countPosts := func(category string) int64 {
+		_, count, err := projection.GetPosts(1, 10, "", nil, map[string]interface{}{"category": category})
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		return count
+	}
+
+	t.Run("tags that differ by case or unicode form are the same category", func(t *testing.T) {
+		addPost("Post 1", `"React"`)
+		addPost("Post 2", `" react "`)
+		addPost("Post 3", `"ＲＥＡＣＴ","react"`)
+		var count int64
+		db.Model(&api.Category{}).Count(&count)
+		if count != 1 {
+			t.Fatalf("expected %d category to be created, got %d", 1, count)
+		}
+		if posts := countPosts("react"); posts != 3 {
+			t.Errorf("expected %d posts in the category, got %d", 3, posts)
+		}
+	})
+
+	t.Run("synonyms are applied at ingest", func(t *testing.T) {
+		handler(weos.Event{
+			Type:    api.CATEGORY_SYNONYM_ADDED,
+			Payload: json.RawMessage(`{"synonym":"reactjs"}`),
+			Meta: weos.EventMeta{
+				EntityID:   "react",
+				EntityType: "Category",
+			},
+		})
+		addPost("Post 4", `"ReactJS"`)
+		if posts := countPosts("react"); posts != 4 {
+			t.Errorf("expected %d posts in the category, got %d", 4, posts)
+		}
+	})
+
+	t.Run("merge category", func(t *testing.T) {
+		addPost("Post 5", `"React.js"`)
+		addPost("Post 6", `"React.js","React"`)
+		handler(weos.Event{
+			Type:    api.CATEGORY_MERGED,
+			Payload: json.RawMessage(`{"into":"react"}`),
+			Meta: weos.EventMeta{
+				EntityID:   "react-js",
+				EntityType: "Category",
+			},
+		})
+		if posts := countPosts("react"); posts != 6 {
+			t.Errorf("expected %d posts in the category, got %d", 6, posts)
+		}
+		if posts := countPosts("react-js"); posts != 0 {
+			t.Errorf("expected %d posts in the merged category, got %d", 0, posts)
+		}
+		category, err := projection.GetCategory("react-js")
+		if err != nil {
+			t.Fatalf("unexpected error getting category '%s'", err)
+		}
+		if category != nil {
+			t.Errorf("expected the merged category to be removed")
+		}
+		//new posts with the merged tag should be added to the category it was merged into
+		addPost("Post 7", `"react.JS"`)
+		if posts := countPosts("react"); posts != 7 {
+			t.Errorf("expected %d posts in the category, got %d", 7, posts)
+		}
+	})
+}
+
+func TestProjection_GetAuthor(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create([]*api.Blog{
+		{
+			ID:    "123",
+			Title: "Some Blog 1",
+		},
+		{
+			ID:    "456",
+			Title: "Some Blog 2",
+		},
+	})
+	handler := projection.GetEventHandler()
+	handler(weos.Event{
+		Type:    blogaggregatormodule.AUTHOR_CREATED,
+		Payload: json.RawMessage(`{"blogId":"123","name":"Akeem Philbert","email":"akeem@example.org"}`),
+		Meta: weos.EventMeta{
+			EntityID:   "123",
+			EntityType: "Blog",
+		},
+	})
+	addPost := func(blogID string, title string, author string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"%s","title":"%s","published":"Sat, 27 Mar 2021 17:05:53 -0400","author":%s}`, blogID, title, author)),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	addPost("123", "Post 1", `{"name":"Akeem Philbert","email":"akeem@example.org"}`)
+	addPost("123", "Post 2", `{"name":"John Doe"}`)
+	addPost("456", "Post 3", `{"name":"Akeem","email":"Akeem@Example.org"}`)
+	addPost("456", "Post 4", `{"name":"Akeem","email":"akeem@example.org"}`)
+
+	var authors []*api.Author
+	db.Order("id asc").Find(&authors)
+	if len(authors) != 3 {
+		t.Fatalf("expected %d authors to be created, got %d", 3, len(authors))
+	}
+
+	t.Run("posts are linked to their author", func(t *testing.T) {
+		posts, _, err := projection.GetPosts(1, 10, "", nil, map[string]interface{}{"blog_id": "123"})
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		for _, post := range posts {
+			if post.Author == nil {
+				t.Fatalf("expected post '%s' to have an author", post.Title)
+			}
+		}
+	})
+
+	t.Run("get authors with the same email once", func(t *testing.T) {
+		list, count, err := projection.GetAuthors(1, 10, "", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error getting authors '%s'", err)
+		}
+		if count != 2 || len(list) != 2 {
+			t.Errorf("expected %d authors, got %d", 2, count)
+		}
+	})
+
+	t.Run("get authors by name and blog", func(t *testing.T) {
+		filters := map[string]interface{}{"blog_id": "456"}
+		list, count, err := projection.GetAuthors(1, 10, "akeem", nil, filters)
+		if err != nil {
+			t.Fatalf("unexpected error getting authors '%s'", err)
+		}
+		if count != 1 || len(list) != 1 {
+			t.Fatalf("expected %d author, got %d", 1, count)
+		}
+		if filters["blog_id"] != "456" {
+			t.Errorf("expected the filters not to be changed, got %v", filters)
+		}
+		if list[0].ID != authors[0].ID {
+			t.Errorf("expected the author to be %d, got %d", authors[0].ID, list[0].ID)
+		}
+		_, count, err = projection.GetAuthors(1, 10, "john", nil, map[string]interface{}{"blog_id": "456"})
+		if err != nil {
+			t.Fatalf("unexpected error getting authors '%s'", err)
+		}
+		if count != 0 {
+			t.Errorf("expected %d authors, got %d", 0, count)
+		}
+	})
+
+	t.Run("get author details", func(t *testing.T) {
+		author, err := projection.GetAuthor(authors[2].ID)
+		if err != nil {
+			t.Fatalf("unexpected error getting author '%s'", err)
+		}
+		if author == nil {
+			t.Fatal("expected an author to be returned")
+		}
+		if author.Name != "Akeem Philbert" {
+			t.Errorf("expected the author name to be '%s', got '%s'", "Akeem Philbert", author.Name)
+		}
+		if len(author.Blogs) != 2 {
+			t.Fatalf("expected the author to have %d blogs, got %d", 2, len(author.Blogs))
+		}
+		if author.Blogs[0].BlogID != "456" || author.Blogs[0].PostCount != 2 {
+			t.Errorf("expected the first blog to be '%s' with %d posts, got '%s' with %d posts", "456", 2, author.Blogs[0].BlogID, author.Blogs[0].PostCount)
+		}
+		if author.PostCount != 3 {
+			t.Errorf("expected the author to have %d posts, got %d", 3, author.PostCount)
+		}
+	})
+
+	t.Run("get posts by author", func(t *testing.T) {
+		_, count, err := projection.GetPosts(1, 10, "", nil, map[string]interface{}{"author_id": authors[0].ID})
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		if count != 3 {
+			t.Errorf("expected %d posts, got %d", 3, count)
+		}
+	})
+
+	t.Run("get author that doesn't exist", func(t *testing.T) {
+		author, err := projection.GetAuthor(100)
+		if err != nil {
+			t.Fatalf("unexpected error getting author '%s'", err)
+		}
+		if author != nil {
+			t.Errorf("expected no author to be returned")
+		}
+	})
+
+	t.Run("the author is kept when the blog of their first author is deleted", func(t *testing.T) {
+		handler(weos.Event{
+			Type:    api.BLOG_DELETED,
+			Payload: json.RawMessage(`{"purge":false}`),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+		for _, id := range []uint{authors[0].ID, authors[2].ID} {
+			author, err := projection.GetAuthor(id)
+			if err != nil {
+				t.Fatalf("unexpected error getting author '%s'", err)
+			}
+			if author == nil || author.ID != authors[2].ID || len(author.Blogs) != 1 || author.PostCount != 2 {
+				t.Fatalf("expected the author on the blog that's left, got %+v", author)
+			}
+		}
+		list, count, err := projection.GetAuthors(1, 10, "", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error getting authors '%s'", err)
+		}
+		if count != 1 || len(list) != 1 || list[0].ID != authors[2].ID {
+			t.Errorf("expected the author on the blog that's left to be listed, got %d", count)
+		}
+	})
+}
+
+func TestProjection_UpdateTrendingScores(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	db.Create([]*api.Post{
+		{
+			ID:          "1",
+			Title:       "Popular but old",
+			Views:       500,
+			PublishDate: now.Add(-10 * 24 * time.Hour),
+		},
+		{
+			ID:          "2",
+			Title:       "New with some views",
+			Views:       20,
+			PublishDate: now.Add(-2 * time.Hour),
+		},
+		{
+			ID:          "3",
+			Title:       "New without views",
+			PublishDate: now.Add(-time.Hour),
+		},
+		{
+			ID:            "4",
+			Title:         "Outside the window",
+			Views:         1000,
+			PublishDate:   now.Add(-60 * 24 * time.Hour),
+			TrendingScore: 10,
+		},
+	})
+
+	config := &api.TrendingConfig{
+		Gravity: 1.8,
+		Offset:  2,
+		Window:  30 * 24 * time.Hour,
+	}
+	err = projection.UpdateTrendingScores(config, now)
+	if err != nil {
+		t.Fatalf("unexpected error updating trending scores '%s'", err)
+	}
+
+	posts, count, err := projection.GetPosts(1, 10, "", map[string]string{"trending_score": "desc"}, map[string]interface{}{"trending": true})
+	if err != nil {
+		t.Fatalf("unexpected error getting trending posts '%s'", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected %d trending posts, got %d", 3, count)
+	}
+	expectedOrder := []string{"2", "3", "1"}
+	for i, id := range expectedOrder {
+		if posts[i].ID != id {
+			t.Errorf("expected post in position %d to be '%s', got '%s'", i, id, posts[i].ID)
+		}
+	}
+
+	var post *api.Post
+	db.First(&post, "id = ?", "4")
+	if post.TrendingScore != 0 {
+		t.Errorf("expected posts outside the window to have a score of 0, got %f", post.TrendingScore)
+	}
+}
+
+func TestProjection_GetRelatedPosts(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	handler := projection.GetEventHandler()
+	addPost := func(blogID string, title string, content string, categories string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"%s","title":"%s","content":"%s","link":"https://example.org/%s","published":"Sat, 27 Mar 2021 17:05:53 -0400","categories":[%s]}`, blogID, title, content, api.Slugify(title), categories)),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	addPost("123", "Getting started with Go", "<p>Go routines and channels make concurrency simple</p>", `"go","concurrency"`)
+	addPost("456", "Concurrency patterns in Go", "Worker pools built with go routines and channels", `"go","concurrency"`)
+	addPost("456", "Baking bread at home", "Flour, water, salt and yeast", `"baking"`)
+	addPost("789", "Getting Started With Go", "<p>Go routines and channels make concurrency simple</p>", `"go"`)
+
+	var post *api.Post
+	db.First(&post, "title = ?", "Getting started with Go")
+
+	related, err := projection.GetRelatedPosts(post.ID, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting related posts '%s'", err)
+	}
+	if len(related) != 1 {
+		t.Fatalf("expected %d related post, got %d", 1, len(related))
+	}
+	if related[0].Title != "Concurrency patterns in Go" {
+		t.Errorf("expected the related post to be '%s', got '%s'", "Concurrency patterns in Go", related[0].Title)
+	}
+
+	t.Run("cache is invalidated when a post is added to the same category", func(t *testing.T) {
+		addPost("456", "Testing concurrent code", "Race detector for go routines", `"concurrency"`)
+		related, err := projection.GetRelatedPosts(post.ID, 5)
+		if err != nil {
+			t.Fatalf("unexpected error getting related posts '%s'", err)
+		}
+		if len(related) != 2 {
+			t.Fatalf("expected %d related posts, got %d", 2, len(related))
+		}
+		//the cached posts are loaded in the same order
+		cached, err := projection.GetRelatedPosts(post.ID, 5)
+		if err != nil {
+			t.Fatalf("unexpected error getting cached related posts '%s'", err)
+		}
+		if len(cached) != 2 || cached[0].ID != related[0].ID || cached[1].ID != related[1].ID {
+			t.Errorf("expected the cached related posts to be the same as the ranked posts")
+		}
+	})
+
+	t.Run("post not found", func(t *testing.T) {
+		related, err := projection.GetRelatedPosts("missing", 5)
+		if err != nil {
+			t.Fatalf("unexpected error getting related posts '%s'", err)
+		}
+		if related != nil {
+			t.Errorf("expected no related posts for a post that doesn't exist")
 		}
+	})
+}
+
+func TestProjection_SanitizePostContent(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{
+		ID:  "123",
+		URL: "https://example.org/blog/",
+	})
+	content := `<p onclick="steal()">Hello <b>world</b><script>alert('xss')</script></p>` +
+		`<a href="/about">About</a> <a href="javascript:alert(1)">Click</a>` +
+		`<img src="images/cover.png" onerror="steal()"><iframe src="https://evil.example"></iframe>`
+	payload, _ := json.Marshal(map[string]interface{}{
+		"blogId":      "123",
+		"title":       "Post 1",
+		"description": "A <em>short</em> description",
+		"content":     content,
+		"link":        "posts/1",
+		"published":   "Sat, 27 Mar 2021 17:05:53 -0400",
+	})
+	projection.GetEventHandler()(weos.Event{
+		Type:    blogaggregatormodule.POST_CREATED,
+		Payload: payload,
+		Meta: weos.EventMeta{
+			EntityID:   "123",
+			EntityType: "Blog",
+		},
+	})
+
+	var post *api.Post
+	if err = db.First(&post).Error; err != nil {
+		t.Fatalf("unexpected error getting post '%s'", err)
+	}
+	expectedContent := `<p>Hello <b>world</b></p>` +
+		`<a href="https://example.org/about" rel="nofollow noopener">About</a> <a rel="nofollow noopener">Click</a>` +
+		`<img src="https://example.org/blog/posts/images/cover.png">`
+	if post.Content != expectedContent {
+		t.Errorf("expected the content to be '%s', got '%s'", expectedContent, post.Content)
+	}
+	if post.Link != "https://example.org/blog/posts/1" {
+		t.Errorf("expected the link to be '%s', got '%s'", "https://example.org/blog/posts/1", post.Link)
+	}
+	if post.Excerpt != "A short description" {
+		t.Errorf("expected the excerpt to be '%s', got '%s'", "A short description", post.Excerpt)
+	}
+	if post.WordCount != 4 {
+		t.Errorf("expected the word count to be %d, got %d", 4, post.WordCount)
+	}
+	if post.ReadingTime != 1 {
+		t.Errorf("expected the reading time to be %d, got %d", 1, post.ReadingTime)
+	}
+	if post.ImageURL != "https://example.org/blog/posts/images/cover.png" {
+		t.Errorf("expected the lead image to be '%s', got '%s'", "https://example.org/blog/posts/images/cover.png", post.ImageURL)
+	}
+}
 
-		if len(authors) != 5 {
-			t.Fatalf("expected %d authors to be returned, got %d",5,len(authors))
+func TestProjection_ExtractFullContent(t *testing.T) {
+	paragraph := "<p>This is a paragraph of the article, it has enough text in it to be treated as part of the article by the extractor.</p>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/posts/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
 		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><head><title>Post 1</title><script>track()</script></head><body>
+<nav class="menu"><a href="/">Home</a><a href="/about">About</a></nav>
+<div class="post-content">%s%s%s<img src="/images/cover.png"></div>
+<div class="comments"><p>This is a comment on the post, it should not be part of the article content.</p></div>
+</body></html>`, paragraph, paragraph, paragraph)
+	}))
+	defer server.Close()
+
+	var extractionErrors []string
+	logger := &LogMock{
+		ErrorfFunc: func(format string, args ...interface{}) {
+			extractionErrors = append(extractionErrors, fmt.Sprintf(format, args...))
+		},
+	}
 
+	projection, db := newTestProjection(t, logger)
+	db.Create(&api.Blog{
+		ID:  "123",
+		URL: server.URL,
+	})
+	handler := projection.GetEventHandler()
+	handler(weos.Event{
+		Type:    api.BLOG_FULL_CONTENT_SET,
+		Payload: json.RawMessage(`{"enabled":true}`),
+		Meta: weos.EventMeta{
+			EntityID:   "123",
+			EntityType: "Blog",
+		},
 	})
+	addPost := func(title string, link string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"123","title":"%s","content":"<p>Just a summary</p>","link":"%s","published":"Sat, 27 Mar 2021 17:05:53 -0400"}`, title, link)),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+	addPost("Post 1", "/posts/1")
+	addPost("Post 2", "/posts/2")
+	extractionErrors = nil
+
+	err = projection.ExtractFullContent(context.Background(), api.NewContentExtractor(server.Client()), 10)
+	if err != nil {
+		t.Fatalf("unexpected error extracting full content '%s'", err)
+	}
+
+	var post *api.Post
+	db.First(&post, "title = ?", "Post 1")
+	if post.ContentSource != api.CONTENT_SOURCE_ARTICLE {
+		t.Errorf("expected the content source to be '%s', got '%s'", api.CONTENT_SOURCE_ARTICLE, post.ContentSource)
+	}
+	if !strings.Contains(post.Content, "This is a paragraph of the article") {
+		t.Errorf("expected the content to be the article, got '%s'", post.Content)
+	}
+	if strings.Contains(post.Content, "comment") || strings.Contains(post.Content, "Home") {
+		t.Errorf("expected the comments and navigation to be removed, got '%s'", post.Content)
+	}
+	if post.ImageURL != server.URL+"/images/cover.png" {
+		t.Errorf("expected the lead image to be '%s', got '%s'", server.URL+"/images/cover.png", post.ImageURL)
+	}
+
+	post = nil
+	db.First(&post, "title = ?", "Post 2")
+	if post.ContentSource != api.CONTENT_SOURCE_FEED || post.Content != "<p>Just a summary</p>" {
+		t.Errorf("expected the feed content to be kept when the article can't be extracted, got '%s'", post.Content)
+	}
+	if len(extractionErrors) != 1 {
+		t.Errorf("expected %d extraction error to be logged, got %d", 1, len(extractionErrors))
+	}
+
+	t.Run("posts are not extracted again", func(t *testing.T) {
+		extractionErrors = nil
+		err = projection.ExtractFullContent(context.Background(), api.NewContentExtractor(server.Client()), 10)
+		if err != nil {
+			t.Fatalf("unexpected error extracting full content '%s'", err)
+		}
+		if len(extractionErrors) != 0 {
+			t.Errorf("expected posts that failed not to be retried")
+		}
+	})
+}
+
+func TestProjection_EnrichBlog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!DOCTYPE html><html lang="en"><head><title>Akeem Philbert's Blog</title>
+<meta name="description" content="Thoughts on software">
+<meta property="og:site_name" content="Akeem Philbert">
+<meta property="og:image" content="/images/og.png">
+<meta name="twitter:site" content="@akeemphilbert">
+<link rel="shortcut icon" href="https://ak33m.com/favicon.ico" type="image/x-icon">
+<link rel="apple-touch-icon" href="/apple-touch-icon.png">
+<link rel="me" href="https://mastodon.social/@akeem">
+</head><body><a href="https://github.com/akeemphilbert">GitHub</a><a href="https://github.com/">Not a profile</a></body></html>`)
+	}))
+	defer server.Close()
+
+	projection, db := newTestProjection(t, nil)
+	db.Create([]*api.Blog{
+		{
+			ID:  "123",
+			URL: server.URL,
+		},
+		{
+			ID:  "456",
+			URL: server.URL + "/missing",
+		},
+	})
+
+	enricher := api.NewBlogEnricher(server.Client())
+	err = projection.EnrichBlogs(context.Background(), enricher, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatalf("unexpected error enriching blogs '%s'", err)
+	}
+
+	blog, err := projection.GetBlogByID("123")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if blog.FaviconURL != "https://ak33m.com/favicon.ico" {
+		t.Errorf("expected the favicon to be '%s', got '%s'", "https://ak33m.com/favicon.ico", blog.FaviconURL)
+	}
+	if blog.AppleTouchIconURL != server.URL+"/apple-touch-icon.png" {
+		t.Errorf("expected the apple touch icon to be '%s', got '%s'", server.URL+"/apple-touch-icon.png", blog.AppleTouchIconURL)
+	}
+	if blog.ImageURL != server.URL+"/images/og.png" {
+		t.Errorf("expected the image to be '%s', got '%s'", server.URL+"/images/og.png", blog.ImageURL)
+	}
+	if blog.SiteName != "Akeem Philbert" {
+		t.Errorf("expected the site name to be '%s', got '%s'", "Akeem Philbert", blog.SiteName)
+	}
+	if blog.Language != "en" {
+		t.Errorf("expected the language to be '%s', got '%s'", "en", blog.Language)
+	}
+	if blog.Description != "Thoughts on software" {
+		t.Errorf("expected the description to be '%s', got '%s'", "Thoughts on software", blog.Description)
+	}
+	if len(blog.Profiles) != 3 {
+		t.Fatalf("expected %d profiles, got %d", 3, len(blog.Profiles))
+	}
+	if blog.EnrichedAt == nil {
+		t.Errorf("expected the enriched date to be set")
+	}
+
+	t.Run("failed enrichment is recorded", func(t *testing.T) {
+		blog, err := projection.GetBlogByID("456")
+		if err != nil {
+			t.Fatalf("unexpected error getting blog '%s'", err)
+		}
+		if blog.EnrichedAt == nil || blog.EnrichmentError == "" {
+			t.Errorf("expected the enrichment error to be recorded")
+		}
+	})
+
+	t.Run("enriching again replaces the profiles", func(t *testing.T) {
+		blog, err := projection.EnrichBlog(context.Background(), enricher, "123")
+		if err != nil {
+			t.Fatalf("unexpected error enriching blog '%s'", err)
+		}
+		if len(blog.Profiles) != 3 {
+			t.Errorf("expected %d profiles, got %d", 3, len(blog.Profiles))
+		}
+	})
+}
+
+func TestProjection_Enclosures(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{
+		ID:  "123",
+		URL: "https://example.org",
+	})
+	handler := projection.GetEventHandler()
+	addPost := func(item string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(item),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+	//podcast episode with an enclosure and iTunes tags
+	addPost(`{"blogId":"123","title":"Episode 1","link":"https://example.org/episodes/1","published":"Sat, 27 Mar 2021 17:05:53 -0400",
+		"enclosures":[{"url":"/audio/episode-1.mp3","length":"24986239","type":"audio/mpeg"}],
+		"itunesExt":{"duration":"01:02:03","image":"https://example.org/artwork.png"}}`)
+	//video with Media RSS elements
+	addPost(`{"blogId":"123","title":"Video 1","link":"https://example.org/videos/1","published":"Sat, 27 Mar 2021 17:05:53 -0400",
+		"extensions":{"media":{"group":[{"name":"group","children":{
+			"content":[{"name":"content","attrs":{"url":"https://example.org/video.mp4","type":"video/mp4","medium":"video","fileSize":"1000","duration":"90"}}],
+			"thumbnail":[{"name":"thumbnail","attrs":{"url":"https://example.org/video.jpg"}}]}}]}}}`)
+	addPost(`{"blogId":"123","title":"Post 1","link":"https://example.org/posts/1","published":"Sat, 27 Mar 2021 17:05:53 -0400"}`)
+
+	posts, count, err := projection.GetPosts(1, 10, "", nil, map[string]interface{}{"has_media": "audio"})
+	if err != nil {
+		t.Fatalf("unexpected error getting posts '%s'", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected %d post with audio, got %d", 1, count)
+	}
+	if len(posts[0].Enclosures) != 1 {
+		t.Fatalf("expected %d enclosure, got %d", 1, len(posts[0].Enclosures))
+	}
+	enclosure := posts[0].Enclosures[0]
+	if enclosure.URL != "https://example.org/audio/episode-1.mp3" {
+		t.Errorf("expected the enclosure url to be '%s', got '%s'", "https://example.org/audio/episode-1.mp3", enclosure.URL)
+	}
+	if enclosure.Length != 24986239 {
+		t.Errorf("expected the enclosure length to be %d, got %d", 24986239, enclosure.Length)
+	}
+	if enclosure.Duration != 3723 {
+		t.Errorf("expected the enclosure duration to be %d, got %d", 3723, enclosure.Duration)
+	}
+	if enclosure.ThumbnailURL != "https://example.org/artwork.png" {
+		t.Errorf("expected the enclosure thumbnail to be '%s', got '%s'", "https://example.org/artwork.png", enclosure.ThumbnailURL)
+	}
+
+	posts, count, err = projection.GetPosts(1, 10, "", nil, map[string]interface{}{"has_media": "video"})
+	if err != nil {
+		t.Fatalf("unexpected error getting posts '%s'", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected %d post with video, got %d", 1, count)
+	}
+	enclosure = posts[0].Enclosures[0]
+	if enclosure.Medium != "video" || enclosure.Duration != 90 || enclosure.ThumbnailURL != "https://example.org/video.jpg" {
+		t.Errorf("expected the Media RSS details to be set on the enclosure, got '%+v'", enclosure)
+	}
+}
+
+func TestProjection_BlogFetched(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{
+		ID:    "123",
+		Title: "Some Blog 1",
+	})
+	handler := projection.GetEventHandler()
+	fetched := func(fetchedAt string, problems string) {
+		handler(weos.Event{
+			Type:    api.BLOG_FETCHED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"feedUrl":"https://ak33m.com/feed.xml","fetchedAt":"%s","problems":[%s]}`, fetchedAt, problems)),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+
+	fetched("2021-03-27T17:05:53Z", `"the feed is not valid UTF-8, it was decoded as Windows-1252","item 2 could not be parsed"`)
+	blog, err := projection.GetBlogByID("123")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if blog.LastFetchedAt == nil || !blog.LastFetchedAt.Equal(time.Date(2021, 3, 27, 17, 5, 53, 0, time.UTC)) {
+		t.Errorf("expected the last fetched date to be set, got '%v'", blog.LastFetchedAt)
+	}
+	if len(blog.FetchDiagnostics) != 2 || blog.FetchDiagnostics[1].Problem != "item 2 could not be parsed" {
+		t.Fatalf("expected %d fetch diagnostics, got %d", 2, len(blog.FetchDiagnostics))
+	}
+
+	//the diagnostics are replaced with the problems from the latest fetch
+	fetched("2021-03-28T17:05:53Z", "")
+	blog, err = projection.GetBlogByID("123")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if len(blog.FetchDiagnostics) != 0 {
+		t.Errorf("expected the fetch diagnostics to be cleared, got %d", len(blog.FetchDiagnostics))
+	}
+}
+
+func TestProjection_BlogMoved(t *testing.T) {
+	projection, _ := newTestProjection(t, nil)
+	handler := projection.GetEventHandler()
+	meta := weos.EventMeta{
+		EntityID:   "123",
+		EntityType: "Blog",
+	}
+	handler(weos.Event{
+		Type:    blogaggregatormodule.BLOG_ADDED,
+		Payload: json.RawMessage(`{"id":"123","url":"https://ak33m.com/feed.xml"}`),
+		Meta:    meta,
+	})
+	handler(weos.Event{
+		Type:    blogaggregatormodule.BLOG_UPDATED,
+		Payload: json.RawMessage(`{"title":"Akeem Philbert","url":"https://ak33m.com/","feedUrl":"https://ak33m.com/feed.xml"}`),
+		Meta:    meta,
+	})
+	handler(weos.Event{
+		Type:    blogaggregatormodule.BLOG_UPDATED,
+		Payload: json.RawMessage(`{"url":"https://blog.ak33m.com/","feedUrl":"https://blog.ak33m.com/index.xml"}`),
+		Meta:    meta,
+	})
+
+	blog, err := projection.GetBlogByID("123")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if blog.FeedURL != "https://blog.ak33m.com/index.xml" || blog.URL != "https://blog.ak33m.com/" {
+		t.Errorf("expected the blog to be moved, got '%s' '%s'", blog.URL, blog.FeedURL)
+	}
+	//the url the blog was added with is the same as the first feed url so it's only recorded once
+	if len(blog.PreviousURLs) != 2 {
+		t.Fatalf("expected %d previous urls, got %d", 2, len(blog.PreviousURLs))
+	}
+	for _, link := range []string{"https://ak33m.com/feed.xml", "https://ak33m.com/", "https://blog.ak33m.com/index.xml"} {
+		found, err := projection.GetBlogByURL(link)
+		if err != nil {
+			t.Fatalf("unexpected error getting blog by url '%s'", err)
+		}
+		if found == nil || found.ID != "123" {
+			t.Errorf("expected the blog to be found by '%s'", link)
+		}
+	}
+}
+
+func TestProjection_UpdateBlogHealth(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	now := time.Now()
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	lastSeason := now.Add(-100 * 24 * time.Hour)
+	db.Create([]*api.Blog{
+		{ID: "failing", Title: "Failing Blog", Model: gorm.Model{CreatedAt: now.Add(-10 * 24 * time.Hour)}},
+		{ID: "dead", Title: "Dead Blog", Model: gorm.Model{CreatedAt: now.Add(-60 * 24 * time.Hour)}},
+		{ID: "stale", Title: "Stale Blog", LastPostAt: &lastSeason, Model: gorm.Model{CreatedAt: now.Add(-200 * 24 * time.Hour)}},
+		{ID: "active", Title: "Active Blog", LastPostAt: &lastWeek, Model: gorm.Model{CreatedAt: now.Add(-200 * 24 * time.Hour)}},
+	})
+	db.Create([]*api.Post{
+		{ID: "1", Title: "From a dead blog", BlogID: "dead", PublishDate: lastSeason},
+		{ID: "2", Title: "From an active blog", BlogID: "active", PublishDate: lastWeek},
+	})
+	handler := projection.GetEventHandler()
+	fetched := func(blogID string, fetchErr string) {
+		handler(weos.Event{
+			Type:    api.BLOG_FETCHED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"feedUrl":"https://ak33m.com/feed.xml","fetchedAt":"%s","error":"%s"}`, now.Format(time.RFC3339), fetchErr)),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	for i := 0; i < 3; i++ {
+		fetched("failing", "unexpected status 500")
+		fetched("dead", "no such host")
+	}
+	fetched("active", "unexpected status 500")
+
+	config := &api.HealthConfig{
+		FailingAfter:   3,
+		StaleAfter:     90 * 24 * time.Hour,
+		DeadAfter:      30 * 24 * time.Hour,
+		AbandonedAfter: 365 * 24 * time.Hour,
+		PauseStatuses:  []string{api.BLOG_STATUS_DEAD},
+	}
+	err = projection.UpdateBlogHealth(config, now)
+	if err != nil {
+		t.Fatalf("unexpected error updating blog health '%s'", err)
+	}
+
+	tests := []struct {
+		blogID   string
+		status   string
+		failures int
+		paused   bool
+	}{
+		{"failing", api.BLOG_STATUS_FAILING, 3, false},
+		{"dead", api.BLOG_STATUS_DEAD, 3, true},
+		{"stale", api.BLOG_STATUS_STALE, 0, false},
+		//a single failed fetch doesn't change the status
+		{"active", api.BLOG_STATUS_ACTIVE, 1, false},
+	}
+	for _, test := range tests {
+		blog, err := projection.GetBlogByID(test.blogID)
+		if err != nil {
+			t.Fatalf("unexpected error getting blog '%s'", err)
+		}
+		if blog.Status != test.status {
+			t.Errorf("expected blog '%s' to be '%s', got '%s'", test.blogID, test.status, blog.Status)
+		}
+		if blog.ConsecutiveFailures != test.failures {
+			t.Errorf("expected blog '%s' to have %d failures, got %d", test.blogID, test.failures, blog.ConsecutiveFailures)
+		}
+		if blog.PollingPaused != test.paused {
+			t.Errorf("expected polling paused for blog '%s' to be %t", test.blogID, test.paused)
+		}
+	}
+
+	//a successful fetch resets the failures
+	fetched("active", "")
+	blog, err := projection.GetBlogByID("active")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if blog.ConsecutiveFailures != 0 || blog.LastError != "" || blog.LastSucceededAt == nil {
+		t.Errorf("expected the failures to be reset, got %d failures and error '%s'", blog.ConsecutiveFailures, blog.LastError)
+	}
+
+	blogs, err := projection.BlogsToRefresh(0, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("unexpected error getting blogs to refresh '%s'", err)
+	}
+	for _, blog := range blogs {
+		if blog.ID == "dead" {
+			t.Errorf("expected polling of dead blogs to be paused")
+		}
+	}
+	//dead blogs are still fetched now and then so that they can come back
+	blogs, _ = projection.BlogsToRefresh(0, 0, 10)
+	retried := false
+	for _, blog := range blogs {
+		retried = retried || blog.ID == "dead"
+	}
+	if !retried {
+		t.Errorf("expected a dead blog to be fetched once the paused max age passed")
+	}
+
+	blogs, count, err := projection.GetBlogs(1, 10, "", nil, map[string]interface{}{"status": api.BLOG_STATUS_DEAD})
+	if err != nil {
+		t.Fatalf("unexpected error getting blogs '%s'", err)
+	}
+	if count != 1 || blogs[0].ID != "dead" {
+		t.Errorf("expected only the dead blog, got %d blogs", count)
+	}
+
+	posts, count, err := projection.GetPosts(1, 10, "", nil, map[string]interface{}{"exclude_dead": true})
+	if err != nil {
+		t.Fatalf("unexpected error getting posts '%s'", err)
+	}
+	if count != 1 || posts[0].ID != "2" {
+		t.Errorf("expected posts from dead blogs to be left out, got %d posts", count)
+	}
+	//a dead blog that comes back is polled again
+	fetched("dead", "")
+	db.Model(&api.Blog{}).Where("id = ?", "dead").Update("last_post_at", now)
+	projection.UpdateBlogHealth(config, now)
+	if blog, _ := projection.GetBlogByID("dead"); blog.Status != api.BLOG_STATUS_ACTIVE || blog.PollingPaused {
+		t.Errorf("expected a dead blog that came back to be polled again, got '%s'", blog.Status)
+	}
+}
+
+func TestProjection_PostUpdated(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{
+		ID:    "123",
+		Title: "Some Blog 1",
+		URL:   "https://ak33m.com/",
+	})
+	handler := projection.GetEventHandler()
+	handler(weos.Event{
+		Type:    blogaggregatormodule.POST_CREATED,
+		Payload: json.RawMessage(`{"blogId":"123","guid":"1","title":"Getting started with React","link":"https://ak33m.com/viro","content":"<p>How to get started</p>","published":"Sat, 27 Mar 2021 17:05:53 -0400"}`),
+		Meta: weos.EventMeta{
+			EntityID:   "123",
+			EntityType: "Blog",
+		},
+	})
+	updated := func(title string, content string) {
+		handler(weos.Event{
+			Type:    api.POST_UPDATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"123","guid":"1","title":"%s","link":"https://ak33m.com/viro","content":"%s","detectedAt":"2021-03-28T17:05:53Z"}`, title, content)),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+
+	updated("Getting started with Viro React", "<p>How to get started</p>")
+	//markup that is removed when the content is sanitized isn't a change
+	updated("Getting started with Viro React", "<p onclick='alert(1)'>How to get started</p>")
+
+	posts, _, err := projection.GetPosts(1, 10, "", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error getting posts '%s'", err)
+	}
+	if len(posts) != 1 || posts[0].Title != "Getting started with Viro React" {
+		t.Fatalf("expected the post to be updated")
+	}
+	revisions, err := projection.GetPostRevisions(posts[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting revisions '%s'", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected %d revision, got %d", 1, len(revisions))
+	}
+	if revisions[0].Title != "Getting started with React" {
+		t.Errorf("expected the revision to have the old title, got '%s'", revisions[0].Title)
+	}
+	if len(revisions[0].ChangedFields) != 1 || revisions[0].ChangedFields[0] != "title" {
+		t.Errorf("expected only the title to be changed, got %v", revisions[0].ChangedFields)
+	}
+	if !revisions[0].CreatedAt.Equal(time.Date(2021, 3, 28, 17, 5, 53, 0, time.UTC)) {
+		t.Errorf("expected the revision date to be when the change was found, got '%s'", revisions[0].CreatedAt)
+	}
+
+	revisions, err = projection.GetPostRevisions("missing")
+	if err != nil {
+		t.Fatalf("unexpected error getting revisions '%s'", err)
+	}
+	if revisions != nil {
+		t.Errorf("expected no revisions for a post that doesn't exist")
+	}
+}
+
+func TestProjection_DeleteBlog(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create([]*api.Blog{
+		{ID: "123", Title: "Some Blog 1"},
+		{ID: "456", Title: "Some Blog 2"},
+	})
+	handler := projection.GetEventHandler()
+	postCreated := func(blogID string, guid string, categories string) {
+		handler(weos.Event{
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"%s","guid":"%s","title":"Post %s","categories":[%s],"author":{"name":"Akeem Philbert"},"published":"Sat, 27 Mar 2021 17:05:53 -0400"}`, blogID, guid, guid, categories)),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	postCreated("123", "1", `"Go","Rust"`)
+	postCreated("456", "2", `"Go"`)
+	postCreated("456", "3", `"Vue"`)
+	deleted := func(eventType string, blogID string, payload string) {
+		handler(weos.Event{
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	count := func(model interface{}, unscoped bool, query string, args ...interface{}) int64 {
+		var count int64
+		tx := db.Model(model)
+		if unscoped {
+			tx = tx.Unscoped()
+		}
+		tx.Where(query, args...).Count(&count)
+		return count
+	}
+
+	deleted(api.BLOG_DELETED, "123", `{"purge":false}`)
+	blog, err := projection.GetBlogByID("123")
+	if err != nil {
+		t.Fatalf("unexpected error getting blog '%s'", err)
+	}
+	if blog != nil {
+		t.Errorf("expected the blog to be deleted")
+	}
+	posts, total, err := projection.GetPosts(1, 10, "", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error getting posts '%s'", err)
+	}
+	if total != 2 {
+		t.Errorf("expected the posts of the blog to be deleted, got %d posts", total)
+	}
+	if count(&api.Author{}, false, "blog_id = ?", "123") != 0 {
+		t.Errorf("expected the authors of the blog to be deleted")
+	}
+	//the blog is only soft deleted
+	if count(&api.Post{}, true, "blog_id = ?", "123") != 1 || count(&api.Blog{}, true, "id = ?", "123") != 1 {
+		t.Errorf("expected the blog and its posts to be soft deleted")
+	}
+	//categories that are still used by other posts are kept
+	if count(&api.Category{}, false, "normalized_title = ?", "rust") != 0 {
+		t.Errorf("expected the category that no longer has posts to be deleted")
+	}
+	if count(&api.Category{}, false, "normalized_title = ?", "go") != 1 {
+		t.Errorf("expected the category that still has posts to be kept")
+	}
+
+	var purged string
+	for _, post := range posts {
+		if post.Title == "Post 3" {
+			purged = post.ID
+		}
+	}
+	deleted(api.POST_DELETED, "456", fmt.Sprintf(`{"postId":"%s","itemKey":"3","purge":true}`, purged))
+	if count(&api.Post{}, true, "id = ?", purged) != 0 {
+		t.Errorf("expected the post to be purged")
+	}
+	if count(&api.Category{}, true, "normalized_title = ?", "vue") != 0 {
+		t.Errorf("expected the category of the purged post to be purged")
+	}
+
+	deleted(api.BLOG_DELETED, "123", `{"purge":true}`)
+	if count(&api.Post{}, true, "blog_id = ?", "123") != 0 || count(&api.Blog{}, true, "id = ?", "123") != 0 || count(&api.Author{}, true, "blog_id = ?", "123") != 0 {
+		t.Errorf("expected the blog to be purged")
+	}
+}
+
+func TestProjection_ReplayPurged(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.AutoMigrate(&weos.GormEvent{})
+	handler := projection.GetEventHandler()
+	store := func(events ...*weos.Event) {
+		for _, event := range events {
+			gormEvent, err := weos.NewGormEvent(event)
+			if err != nil {
+				t.Fatalf("unexpected error storing event '%s'", err)
+			}
+			db.Create(&gormEvent)
+			handler(*event)
+		}
+	}
+	stored := func(query string, args ...interface{}) []*weos.Event {
+		var gormEvents []weos.GormEvent
+		db.Order("sequence_no asc, created_at asc").Where(query, args...).Find(&gormEvents)
+		var events []*weos.Event
+		for _, event := range gormEvents {
+			events = append(events, &weos.Event{
+				ID:      event.ID,
+				Type:    event.Type,
+				Payload: json.RawMessage(event.Payload),
+				Meta: weos.EventMeta{
+					EntityID:   event.EntityID,
+					EntityType: event.EntityType,
+					SequenceNo: event.SequenceNo,
+				},
+			})
+		}
+		return events
+	}
+	receiver := api.NewReceiver(&ApplicationMock{
+		DBFunc: func() *gorm.DB {
+			return db
+		},
+		EventRepositoryFunc: func() weos.EventRepository {
+			return &EventRepositoryMock{
+				GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+					return stored("entity_id = ? AND entity_type = ?", ID, entityType), nil
+				},
+				PersistFunc: func(entity weos.AggregateInterface) error {
+					changes := entity.GetNewChanges()
+					entity.Persist()
+					for _, change := range changes {
+						store(change.(*weos.Event))
+					}
+					return nil
+				},
+			}
+		},
+	}, projection)
+
+	addBlog := func(id string, titles ...string) {
+		blog := blogaggregatormodule.Blog{URL: "https://" + id + ".example.com", Title: "Blog " + id}
+		blog.ID = id
+		added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, id, "Blog", &blogaggregatormodule.BlogCreatedPayload{Blog: blog})
+		added.Meta.SequenceNo = 1
+		store(added)
+		for i, title := range titles {
+			post, _ := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, id, "Blog", &blogaggregatormodule.PostCreatedPayload{
+				BlogID: id,
+				Item:   gofeed.Item{Title: title, GUID: title, Content: "<p>" + title + "</p>", Published: "Sat, 27 Mar 2021 17:05:53 -0400"},
+			})
+			post.Meta.SequenceNo = int64(i + 2)
+			store(post)
+		}
+	}
+	addBlog("123", "Taken down", "Deleted", "Kept")
+	addBlog("456", "Purged with its blog")
+	for guid, purge := range map[string]bool{"Taken down": true, "Deleted": false} {
+		var post *api.Post
+		db.First(&post, "guid = ?", guid)
+		if err := receiver.DeletePost(context.TODO(), api.DeletePostCommand(post.ID, purge)); err != nil {
+			t.Fatalf("unexpected error deleting post '%s'", err)
+		}
+	}
+	if err := receiver.DeleteBlog(context.TODO(), api.DeleteBlogCommand("456", true)); err != nil {
+		t.Fatalf("unexpected error purging blog '%s'", err)
+	}
+
+	//the projection is rebuilt from the events that are left
+	events := stored("1 = 1")
+	projection, db = newTestProjection(t, nil)
+	handler = projection.GetEventHandler()
+	for _, event := range events {
+		handler(*event)
+	}
+
+	var blogs []*api.Blog
+	db.Unscoped().Find(&blogs)
+	if len(blogs) != 1 || blogs[0].ID != "123" {
+		t.Errorf("expected only blog '%s' to be rebuilt, got %d blogs", "123", len(blogs))
+	}
+	var posts []*api.Post
+	db.Find(&posts)
+	if len(posts) != 1 || posts[0].Title != "Kept" {
+		t.Fatalf("expected only the post that wasn't deleted to be rebuilt, got %d posts", len(posts))
+	}
+	var all []*api.Post
+	db.Unscoped().Find(&all)
+	for _, post := range all {
+		if post.Title == "" || post.BlogID == "" || post.Title == "Taken down" {
+			t.Errorf("expected purged posts not to be rebuilt, got '%s' on blog '%s'", post.Title, post.BlogID)
+		}
+	}
+	if len(all) != 2 {
+		t.Errorf("expected the deleted post to be kept as deleted, got %d posts", len(all))
+	}
+}
+
+func TestProjection_BlogClaims(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{ID: "123", Title: "Some Blog", Description: "From the feed"})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, payload string) {
+		handler(weos.Event{
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+	apply(blogaggregatormodule.POST_CREATED, `{"blogId":"123","guid":"1","title":"Post 1","categories":["Go"],"published":"Sat, 27 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, `{"blogId":"123","guid":"2","title":"Post 2","categories":["Go"],"published":"Sun, 28 Mar 2021 17:05:53 -0400"}`)
+
+	t.Run("claim", func(t *testing.T) {
+		apply(api.BLOG_CLAIMED, `{"userId":"user1","token":"blog-aggregator-123"}`)
+		claim, err := projection.GetBlogClaim("123", "user1")
+		if err != nil {
+			t.Fatalf("unexpected error getting claim '%s'", err)
+		}
+		if claim == nil || claim.Token != "blog-aggregator-123" || claim.Status != api.CLAIM_STATUS_PENDING {
+			t.Fatalf("expected a pending claim with the token, got %+v", claim)
+		}
+		if owner, _ := projection.IsBlogOwner("123", "user1"); owner {
+			t.Errorf("expected the user not to own the blog before the claim is verified")
+		}
+		if claim, _ = projection.GetBlogClaim("123", "user2"); claim != nil {
+			t.Errorf("expected no claim for a user that hasn't claimed the blog")
+		}
+	})
+
+	t.Run("verify claim", func(t *testing.T) {
+		apply(api.BLOG_CLAIM_VERIFIED, `{"userId":"user1","method":"meta","verifiedAt":"2021-03-28T17:05:53Z"}`)
+		claim, err := projection.GetBlogClaim("123", "user1")
+		if err != nil {
+			t.Fatalf("unexpected error getting claim '%s'", err)
+		}
+		if claim.Status != api.CLAIM_STATUS_VERIFIED || claim.Method != api.CLAIM_METHOD_META || claim.VerifiedAt == nil {
+			t.Errorf("expected the claim to be verified by the meta tag, got %+v", claim)
+		}
+		if owner, _ := projection.IsBlogOwner("123", "user1"); !owner {
+			t.Errorf("expected the user to own the blog")
+		}
+		if owner, _ := projection.IsBlogOwner("456", "user1"); owner {
+			t.Errorf("expected the user not to own another blog")
+		}
+	})
+
+	t.Run("set description", func(t *testing.T) {
+		apply(api.BLOG_DESCRIPTION_SET, `{"description":""}`)
+		blog, _ := projection.GetBlogByID("123")
+		if blog.Description != "" {
+			t.Errorf("expected the description to be cleared, got '%s'", blog.Description)
+		}
+	})
+
+	var posts []*api.Post
+	db.Order("publish_date asc").Find(&posts)
+	if len(posts) != 2 {
+		t.Fatalf("expected %d posts, got %d", 2, len(posts))
+	}
+
+	t.Run("hide post", func(t *testing.T) {
+		apply(api.POST_HIDDEN, `{"postId":"old-id","itemKey":"1","hidden":true}`)
+		visible, count, err := projection.GetPosts(1, 10, "", nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		if count != 1 || len(visible) != 1 || visible[0].ID != posts[1].ID {
+			t.Errorf("expected only the post that isn't hidden to be listed, got %d posts", count)
+		}
+		related, _ := projection.GetRelatedPosts(posts[1].ID, 10)
+		for _, post := range related {
+			if post.ID == posts[0].ID {
+				t.Errorf("expected the hidden post not to be related")
+			}
+		}
+		apply(api.POST_HIDDEN, `{"postId":"old-id","itemKey":"1","hidden":false}`)
+		if _, count, _ = projection.GetPosts(1, 10, "", nil, nil); count != 2 {
+			t.Errorf("expected the post to be shown again, got %d posts", count)
+		}
+	})
+
+	t.Run("hide post recorded without the item key", func(t *testing.T) {
+		apply(api.POST_HIDDEN, fmt.Sprintf(`{"postId":"%s","hidden":true}`, posts[1].ID))
+		if _, count, _ := projection.GetPosts(1, 10, "", nil, nil); count != 1 {
+			t.Errorf("expected the post to be hidden by its id, got %d posts", count)
+		}
+		apply(api.POST_HIDDEN, fmt.Sprintf(`{"postId":"%s","hidden":false}`, posts[1].ID))
+	})
+
+	t.Run("set categories", func(t *testing.T) {
+		apply(api.POST_CATEGORIES_SET, `{"postId":"old-id","itemKey":"1","categories":["Rust","rust","Web Assembly"]}`)
+		var post *api.Post
+		db.Preload("Categories").First(&post, "id = ?", posts[0].ID)
+		var titles []string
+		for _, category := range post.Categories {
+			titles = append(titles, category.Title)
+		}
+		if len(titles) != 2 || titles[0] != "Rust" || titles[1] != "Web Assembly" {
+			t.Errorf("expected the categories to be replaced with %v, got %v", []string{"Rust", "Web Assembly"}, titles)
+		}
+	})
+}
+
+func TestProjection_Mentions(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{ID: "123", Title: "Some Blog"})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, payload string) {
+		handler(weos.Event{
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+	}
+	apply(blogaggregatormodule.POST_CREATED, `{"blogId":"123","guid":"1","title":"Post 1","link":"https://ak33m.com/posts/1","published":"Sat, 27 Mar 2021 17:05:53 -0400"}`)
+	var post *api.Post
+	db.First(&post, "guid = ?", "1")
+
+	//the id of the post was different when the mention was recorded e.g. before the projection was rebuilt
+	apply(api.POST_MENTIONED, `{"postId":"old-id","itemKey":"1","source":"https://example.com/reply","target":"https://ak33m.com/posts/1","type":"webmention","title":"A reply"}`)
+	mentions, err := projection.GetMentions(post.ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting mentions '%s'", err)
+	}
+	if len(mentions) != 1 || mentions[0].Source != "https://example.com/reply" || mentions[0].Title != "A reply" {
+		t.Fatalf("expected the mention to be found by the item of the post, got %+v", mentions)
+	}
+	apply(api.POST_MENTION_REMOVED, `{"postId":"old-id","itemKey":"1","source":"https://example.com/reply"}`)
+	if mentions, _ = projection.GetMentions(post.ID); len(mentions) != 0 {
+		t.Errorf("expected the mention to be removed, got %d mentions", len(mentions))
+	}
+
+	t.Run("queue", func(t *testing.T) {
+		for _, source := range []string{"https://example.com/reply", "https://example.com/reply", "https://example.com/other"} {
+			err := projection.QueueMention(&api.ReceivedMention{BlogID: "123", Source: source, Target: "https://ak33m.com/posts/1", Type: api.MENTION_TYPE_WEBMENTION})
+			if err != nil {
+				t.Fatalf("unexpected error queueing mention '%s'", err)
+			}
+		}
+		now := time.Now()
+		queued, err := projection.GetQueuedMentions(now, 10)
+		if err != nil {
+			t.Fatalf("unexpected error getting queued mentions '%s'", err)
+		}
+		if len(queued) != 2 || queued[0].Source != "https://example.com/reply" {
+			t.Fatalf("expected the same mention to only be queued once, got %d mentions", len(queued))
+		}
+		if err = projection.RemoveQueuedMention(queued[0].ID); err != nil {
+			t.Fatalf("unexpected error removing queued mention '%s'", err)
+		}
+		if queued, _ = projection.GetQueuedMentions(now, 10); len(queued) != 1 || queued[0].Source != "https://example.com/other" {
+			t.Fatalf("expected the checked mention to be removed from the queue, got %d mentions", len(queued))
+		}
+		//a mention that couldn't be checked stays in the queue until it's due again
+		if err = projection.RetryQueuedMention(queued[0].ID, errors.New("the site is down"), now.Add(time.Hour)); err != nil {
+			t.Fatalf("unexpected error retrying queued mention '%s'", err)
+		}
+		if queued, _ = projection.GetQueuedMentions(now, 10); len(queued) != 0 {
+			t.Errorf("expected the mention not to be checked before it's due, got %d mentions", len(queued))
+		}
+		queued, _ = projection.GetQueuedMentions(now.Add(2*time.Hour), 10)
+		if len(queued) != 1 || queued[0].Attempts != 1 || queued[0].LastError != "the site is down" {
+			t.Errorf("expected the mention to be checked again once it's due, got %+v", queued)
+		}
+	})
+}
+
+func TestProjection_GetBlogStats(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create([]*api.Blog{
+		{ID: "123", Title: "Some Blog 1"},
+		{ID: "456", Title: "Some Blog 2"},
+	})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, blogID string, payload string) {
+		handler(weos.Event{
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+	}
+	apply(blogaggregatormodule.POST_CREATED, "123", `{"blogId":"123","guid":"1","title":"Post 1","categories":["Go","Rust"],"published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", `{"blogId":"123","guid":"2","title":"Post 2","categories":["Go"],"published":"Sun, 28 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", `{"blogId":"123","guid":"3","title":"Post 3","categories":["Go"],"published":"Mon, 29 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "456", `{"blogId":"456","guid":"4","title":"Post 4","categories":["Vue"],"published":"Mon, 29 Mar 2021 17:05:53 -0400"}`)
+	posts := make(map[string]*api.Post)
+	var all []*api.Post
+	db.Find(&all)
+	for _, post := range all {
+		posts[post.Title] = post
+	}
+
+	day := func(value string) time.Time {
+		date, _ := time.Parse("2006-01-02 15:04", value)
+		return date
+	}
+	views := []struct {
+		title string
+		at    string
+	}{
+		{"Post 1", "2021-03-01 10:00"},
+		{"Post 2", "2021-03-29 10:00"},
+		{"Post 2", "2021-03-29 23:00"},
+		{"Post 3", "2021-03-30 10:00"},
+		{"Post 2", "2021-03-30 11:00"},
+		{"Post 4", "2021-03-30 11:00"},
+		{"Post 2", "2021-04-02 11:00"},
+	}
+	for _, view := range views {
+		found, err := projection.RecordPostView(posts[view.title].ID, day(view.at))
+		if err != nil || !found {
+			t.Fatalf("expected the view to be recorded, got found %t and error '%v'", found, err)
+		}
+	}
+	if found, err := projection.RecordPostView("missing", time.Now()); err != nil || found {
+		t.Errorf("expected a view of a post that doesn't exist not to be recorded, got found %t and error '%v'", found, err)
+	}
+	var post *api.Post
+	db.First(&post, "id = ?", posts["Post 2"].ID)
+	if post.Views != 4 {
+		t.Errorf("expected the post to have %d views, got %d", 4, post.Views)
+	}
+
+	apply(api.BLOG_FETCHED, "123", `{"fetchedAt":"2021-03-29T10:00:00Z"}`)
+	apply(api.BLOG_FETCHED, "123", `{"fetchedAt":"2021-03-29T11:00:00Z","error":"unexpected status 500"}`)
+	apply(api.BLOG_FETCHED, "123", `{"fetchedAt":"2021-03-30T10:00:00Z"}`)
+	apply(api.BLOG_FETCHED, "123", `{"fetchedAt":"2021-03-31T10:00:00Z"}`)
+	apply(api.BLOG_FOLLOWED, "123", `{"userId":"user1"}`)
+	apply(api.BLOG_FOLLOWED, "123", `{"userId":"user2"}`)
+	apply(api.BLOG_FOLLOWED, "123", `{"userId":"user1"}`)
+	apply(api.BLOG_FOLLOWED, "456", `{"userId":"user3"}`)
+	apply(api.BLOG_UNFOLLOWED, "123", `{"userId":"user2"}`)
+
+	stats, err := projection.GetBlogStats("123", day("2021-03-01 00:00"), day("2021-03-31 00:00"))
+	if err != nil {
+		t.Fatalf("unexpected error getting stats '%s'", err)
+	}
+	if stats.TotalViews != 5 {
+		t.Errorf("expected %d views, got %d", 5, stats.TotalViews)
+	}
+	if len(stats.DailyViews) != 31 {
+		t.Fatalf("expected a count for each of the %d days, got %d", 31, len(stats.DailyViews))
+	}
+	for _, expected := range []*api.DailyViews{{Day: "2021-03-01", Views: 1}, {Day: "2021-03-02", Views: 0}, {Day: "2021-03-29", Views: 2}, {Day: "2021-03-30", Views: 2}} {
+		for _, daily := range stats.DailyViews {
+			if daily.Day == expected.Day && daily.Views != expected.Views {
+				t.Errorf("expected %d views on '%s', got %d", expected.Views, expected.Day, daily.Views)
+			}
+		}
+	}
+	if len(stats.TopPosts) != 3 || stats.TopPosts[0].Title != "Post 2" || stats.TopPosts[0].Views != 3 {
+		t.Errorf("expected 'Post 2' to be the top post with %d views, got %+v", 3, stats.TopPosts)
+	}
+	if len(stats.PostsPerMonth) != 1 || stats.PostsPerMonth[0].Month != "2021-03" || stats.PostsPerMonth[0].Posts != 2 {
+		t.Errorf("expected %d posts in '%s', got %+v", 2, "2021-03", stats.PostsPerMonth)
+	}
+	if len(stats.TopCategories) != 1 || stats.TopCategories[0].Title != "Go" || stats.TopCategories[0].Posts != 2 {
+		t.Errorf("expected 'Go' to be the top category with %d posts, got %+v", 2, stats.TopCategories)
+	}
+	if stats.Followers != 1 {
+		t.Errorf("expected %d follower, got %d", 1, stats.Followers)
+	}
+	if stats.Fetches.Total != 4 || stats.Fetches.Failed != 1 || stats.Fetches.Reliability == nil || *stats.Fetches.Reliability != 0.75 {
+		t.Errorf("expected %d fetches with %d failed, got %+v", 4, 1, stats.Fetches)
+	}
+
+	stats, err = projection.GetBlogStats("123", day("2021-02-01 00:00"), day("2021-02-28 00:00"))
+	if err != nil {
+		t.Fatalf("unexpected error getting stats '%s'", err)
+	}
+	if stats.TotalViews != 0 || stats.Fetches.Reliability != nil || len(stats.PostsPerMonth) != 1 || len(stats.TopPosts) != 0 {
+		t.Errorf("expected only the post from February, got %+v", stats)
+	}
+
+	if stats, err = projection.GetBlogStats("789", day("2021-03-01 00:00"), day("2021-03-31 00:00")); err != nil || stats != nil {
+		t.Errorf("expected no stats for a blog that doesn't exist, got %+v and error '%v'", stats, err)
+	}
+}
+
+func TestProjection_SendWebmentions(t *testing.T) {
+	var received []url.Values
+	discovered := make(map[string]int)
+	brokenCalls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.Header().Add("Link", `</other>; rel="alternate", </endpoint?version=1>; rel="webmention"`)
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="webmention" href="/endpoint"></head><body></body></html>`)
+	})
+	mux.HandleFunc("/posts/anchor", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a rel="me webmention" href="../endpoint">Send a webmention</a></body></html>`)
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>No endpoint</body></html>`)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="webmention" href="/broken"></head></html>`)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		discovered[r.URL.Path]++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/endpoint", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := r.PostForm
+		form.Set("version", r.URL.Query().Get("version"))
+		received = append(received, form)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		brokenCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	projection, db := newTestProjection(t, nil)
+	db.Create([]*api.Blog{
+		{ID: "123", Title: "Some Blog 1", URL: "https://blog.example.com"},
+		{ID: "456", Title: "Some Blog 2", URL: "https://other.example.com"},
+	})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, blogID string, payload string) {
+		event := weos.Event{
+			ID:      blogaggregatormodule.GenerateID(),
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		}
+		handler(event)
+		//the receiver sends new posts on once their events are persisted
+		if eventType == blogaggregatormodule.POST_CREATED {
+			projection.PostsAdded([]string{event.ID})
+		}
+	}
+	content := fmt.Sprintf(`<p>Links to <a href="%[1]s/header">a</a>, <a href="%[1]s/html#comments">b</a>, <a href="%[1]s/posts/anchor">c</a>, `+
+		`<a href="%[1]s/none">d</a>, <a href="%[1]s/down">e</a>, <a href="%[1]s/missing">f</a>, <a href="%[1]s/html">the same page</a>, `+
+		`<a href="/posts/2">another post on the blog</a> and <a href="mailto:someone@example.com">an email</a></p>`, server.URL)
+	apply(api.BLOG_SEND_MENTIONS_SET, "123", `{"enabled":true}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", fmt.Sprintf(`{"blogId":"123","guid":"1","title":"Post 1","link":"https://blog.example.com/posts/1","content":%q,"published":"Sat, 27 Feb 2021 17:05:53 -0400"}`, content))
+	apply(blogaggregatormodule.POST_CREATED, "456", fmt.Sprintf(`{"blogId":"456","guid":"2","title":"Post 2","link":"https://other.example.com/posts/2","content":%q,"published":"Sat, 27 Feb 2021 17:05:53 -0400"}`, content))
+
+	mentions, err := projection.GetSentMentions("456", "")
+	if err != nil {
+		t.Fatalf("unexpected error getting sent mentions '%s'", err)
+	}
+	if len(mentions) != 0 {
+		t.Errorf("expected no webmentions for a blog that didn't turn them on, got %d", len(mentions))
+	}
+	mentions, err = projection.GetSentMentions("123", api.SENT_MENTION_PENDING)
+	if err != nil {
+		t.Fatalf("unexpected error getting sent mentions '%s'", err)
+	}
+	if len(mentions) != 6 {
+		t.Fatalf("expected %d webmentions to be queued, got %d", 6, len(mentions))
+	}
+
+	config := &api.WebmentionConfig{MaxAttempts: 2, RetryDelay: time.Hour}
+	sender := api.NewWebmentionSender(server.Client())
+	now := time.Now()
+	err = projection.SendWebmentions(context.Background(), sender, config, now)
+	if err != nil {
+		t.Fatalf("unexpected error sending webmentions '%s'", err)
+	}
+	if len(received) != 3 {
+		t.Fatalf("expected %d webmentions to be received, got %d", 3, len(received))
+	}
+	for _, form := range received {
+		if form.Get("source") != "https://blog.example.com/posts/1" {
+			t.Errorf("expected the source to be '%s', got '%s'", "https://blog.example.com/posts/1", form.Get("source"))
+		}
+		if form.Get("target") == server.URL+"/header" && form.Get("version") != "1" {
+			t.Errorf("expected the query of the endpoint to be kept, got version '%s'", form.Get("version"))
+		}
+	}
+	mentions, _ = projection.GetSentMentions("123", "")
+	statuses := make(map[string]*api.SentMention)
+	for _, mention := range mentions {
+		statuses[strings.TrimPrefix(mention.Target, server.URL)] = mention
+	}
+	expected := map[string]string{
+		"/header":       api.SENT_MENTION_SENT,
+		"/html":         api.SENT_MENTION_SENT,
+		"/posts/anchor": api.SENT_MENTION_SENT,
+		"/none":         api.SENT_MENTION_NO_ENDPOINT,
+		"/down":         api.SENT_MENTION_PENDING,
+		"/missing":      api.SENT_MENTION_FAILED,
+	}
+	for target, status := range expected {
+		mention, ok := statuses[target]
+		if !ok {
+			t.Errorf("expected a webmention to be sent to '%s'", target)
+			continue
+		}
+		if mention.Status != status {
+			t.Errorf("expected the webmention to '%s' to be '%s', got '%s' (%s)", target, status, mention.Status, mention.LastError)
+		}
+	}
+	if mention := statuses["/header"]; mention.StatusCode != http.StatusAccepted || mention.SentAt == nil || mention.Attempts != 1 {
+		t.Errorf("expected the webmention to be sent on the first attempt, got %+v", mention)
+	}
+	if mention := statuses["/down"]; mention.NextAttemptAt == nil || !mention.NextAttemptAt.Equal(now.Add(time.Hour)) || mention.LastError == "" {
+		t.Errorf("expected the webmention to be tried again in an hour, got %+v", mention)
+	}
+
+	//the webmention isn't tried again until it's due and the endpoint isn't discovered again
+	err = projection.SendWebmentions(context.Background(), sender, config, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error sending webmentions '%s'", err)
+	}
+	if brokenCalls != 1 {
+		t.Errorf("expected the webmention not to be sent before it's due, got %d calls", brokenCalls)
+	}
+	err = projection.SendWebmentions(context.Background(), sender, config, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error sending webmentions '%s'", err)
+	}
+	if brokenCalls != 2 || discovered["/down"] != 1 {
+		t.Errorf("expected the webmention to be sent again to the same endpoint, got %d calls and %d discoveries", brokenCalls, discovered["/down"])
+	}
+	mentions, _ = projection.GetSentMentions("123", api.SENT_MENTION_FAILED)
+	if len(mentions) != 2 {
+		t.Errorf("expected %d webmentions to fail once the attempts ran out, got %d", 2, len(mentions))
+	}
+
+	if mentions, err := projection.GetSentMentions("789", ""); err != nil || mentions != nil {
+		t.Errorf("expected no webmentions for a blog that doesn't exist, got %v and error '%v'", mentions, err)
+	}
+}
+
+func TestProjection_ActivityPub(t *testing.T) {
+	os.Setenv("PUBLIC_URL", "https://aggregator.example.com/")
+	defer os.Unsetenv("PUBLIC_URL")
+	projection, db := newTestProjection(t, nil)
+
+	//the fake fediverse server checks that the activities are signed by the blog
+	actorClient := testhelpers.NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Host != "aggregator.example.com" {
+			return testhelpers.NewStringResponse(http.StatusNotFound, "")
+		}
+		actor, err := projection.GetActor(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/blogs/"), "/actor"))
+		if err != nil || actor == nil {
+			return testhelpers.NewStringResponse(http.StatusNotFound, "")
+		}
+		body, _ := json.Marshal(actor)
+		return testhelpers.NewBytesResponse(http.StatusOK, body)
+	})
+	verifier := api.NewSignatureVerifier(actorClient)
+	received := make(map[string][]*api.Activity)
+	downCalls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/down/inbox", func(w http.ResponseWriter, r *http.Request) {
+		downCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		actor, err := verifier.Verify(r.Context(), r, body)
+		if err != nil {
+			t.Errorf("expected the delivery to '%s' to be signed, got '%s'", r.URL.Path, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var activity *api.Activity
+		json.Unmarshal(body, &activity)
+		if activity.Actor != actor.ID {
+			t.Errorf("expected the activity to be signed by '%s', got '%s'", activity.Actor, actor.ID)
+		}
+		received[r.URL.Path] = append(received[r.URL.Path], activity)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db.Create(&api.Blog{ID: "123", Title: "Some Blog", Description: "Posts about <code>", URL: "https://www.blog.example.com/"})
+	db.Create(&api.Blog{ID: "456", Title: "Same Host", URL: "https://blog.example.com"})
+	//blogs added before they had slugs get one when the projection is migrated
+	db.Session(&gorm.Session{SkipHooks: true}).Create(&api.Blog{ID: "789", Title: "Older Blog"})
+	projection.Migrate(context.Background())
+
+	t.Run("handles are looked up with webfinger", func(t *testing.T) {
+		expected := map[string]string{"123": "blog-example-com", "456": "blog-example-com-2", "789": "older-blog"}
+		for id, slug := range expected {
+			blog, _ := projection.GetBlogByID(id)
+			if blog.Slug != slug {
+				t.Errorf("expected the slug of blog '%s' to be '%s', got '%s'", id, slug, blog.Slug)
+			}
+		}
+		finger, err := projection.GetWebFinger("acct:blog-example-com@aggregator.example.com")
+		if err != nil {
+			t.Fatalf("unexpected error looking up the handle '%s'", err)
+		}
+		if finger == nil {
+			t.Fatal("expected the handle to be found")
+		}
+		if finger.Subject != "acct:blog-example-com@aggregator.example.com" {
+			t.Errorf("expected the subject to be '%s', got '%s'", "acct:blog-example-com@aggregator.example.com", finger.Subject)
+		}
+		if finger.Links[0].Rel != "self" || finger.Links[0].Type != api.ACTIVITY_CONTENT_TYPE || finger.Links[0].Href != "https://aggregator.example.com/blogs/123/actor" {
+			t.Errorf("expected the self link to be the actor, got %+v", finger.Links[0])
+		}
+		finger, err = projection.GetWebFinger("https://aggregator.example.com/blogs/456/actor")
+		if err != nil || finger == nil || finger.Subject != "acct:blog-example-com-2@aggregator.example.com" {
+			t.Errorf("expected the actor url to be looked up, got %+v and error '%v'", finger, err)
+		}
+		for _, resource := range []string{"acct:blog-example-com@other.example.com", "acct:missing@aggregator.example.com", "mailto:blog-example-com@aggregator.example.com"} {
+			if finger, err := projection.GetWebFinger(resource); err != nil || finger != nil {
+				t.Errorf("expected '%s' not to be found, got %+v and error '%v'", resource, finger, err)
+			}
+		}
+	})
+
+	t.Run("blogs are actors with a stable key", func(t *testing.T) {
+		actor, err := projection.GetActor("123")
+		if err != nil {
+			t.Fatalf("unexpected error getting actor '%s'", err)
+		}
+		if actor.ID != "https://aggregator.example.com/blogs/123/actor" || actor.Inbox != "https://aggregator.example.com/blogs/123/inbox" ||
+			actor.PreferredUsername != "blog-example-com" || actor.Summary != "Posts about &lt;code&gt;" {
+			t.Errorf("unexpected actor %+v", actor)
+		}
+		if actor.PublicKey == nil || actor.PublicKey.ID != actor.ID+"#main-key" || !strings.Contains(actor.PublicKey.PublicKeyPem, "PUBLIC KEY") {
+			t.Fatalf("expected the actor to have a public key, got %+v", actor.PublicKey)
+		}
+		again, _ := projection.GetActor("123")
+		if again.PublicKey.PublicKeyPem != actor.PublicKey.PublicKeyPem {
+			t.Error("expected the key of the blog not to change")
+		}
+		if actor, err := projection.GetActor("999"); err != nil || actor != nil {
+			t.Errorf("expected no actor for a blog that doesn't exist, got %+v and error '%v'", actor, err)
+		}
+	})
+
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, blogID string, payload string) {
+		event := weos.Event{
+			ID:      blogaggregatormodule.GenerateID(),
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		}
+		handler(event)
+		//the receiver sends new posts on and accepts follows once their events are persisted
+		switch eventType {
+		case blogaggregatormodule.POST_CREATED:
+			projection.PostsAdded([]string{event.ID})
+		case api.BLOG_FEDIVERSE_FOLLOWED:
+			var follower *api.BlogFediverseFollowedPayload
+			json.Unmarshal(event.Payload, &follower)
+			projection.FediverseFollowed(blogID, follower)
+		}
+	}
+	config := &api.FederationConfig{MaxAttempts: 2, RetryDelay: time.Hour}
+	deliverer := api.NewActivityDeliverer(server.Client())
+
+	t.Run("follows are accepted", func(t *testing.T) {
+		for _, name := range []string{"alice", "bob"} {
+			apply(api.BLOG_FEDIVERSE_FOLLOWED, "123", fmt.Sprintf(`{"actorId":"%[1]s/users/%[2]s","inbox":"%[1]s/users/%[2]s/inbox","sharedInbox":"%[1]s/inbox","followId":"%[1]s/follows/%[2]s"}`, server.URL, name))
+		}
+		apply(api.BLOG_FEDIVERSE_FOLLOWED, "123", fmt.Sprintf(`{"actorId":"%[1]s/users/carol","inbox":"%[1]s/down/inbox","followId":"%[1]s/follows/carol"}`, server.URL))
+		err := projection.DeliverActivities(context.Background(), deliverer, config, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		accepts := received["/users/alice/inbox"]
+		if len(accepts) != 1 || accepts[0].Type != "Accept" {
+			t.Fatalf("expected the follow to be accepted, got %+v", accepts)
+		}
+		if id, _ := accepts[0].Object.(map[string]interface{})["id"].(string); id != server.URL+"/follows/alice" {
+			t.Errorf("expected the follow '%s' to be accepted, got '%s'", server.URL+"/follows/alice", id)
+		}
+		if len(received["/users/bob/inbox"]) != 1 || downCalls != 1 {
+			t.Errorf("expected each follower to get an accept, got %d and %d", len(received["/users/bob/inbox"]), downCalls)
+		}
+		followers, _ := projection.GetFediverseFollowers("123")
+		if followers.TotalItems != 3 {
+			t.Errorf("expected %d followers, got %d", 3, followers.TotalItems)
+		}
+	})
+
+	t.Run("follows aren't accepted again when the projection is rebuilt", func(t *testing.T) {
+		var before int64
+		db.Model(&api.ActivityDelivery{}).Count(&before)
+		handler(weos.Event{
+			ID:      blogaggregatormodule.GenerateID(),
+			Type:    api.BLOG_FEDIVERSE_FOLLOWED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"actorId":"%[1]s/users/alice","inbox":"%[1]s/users/alice/inbox","sharedInbox":"%[1]s/inbox","followId":"%[1]s/follows/alice"}`, server.URL)),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+		var after int64
+		db.Model(&api.ActivityDelivery{}).Count(&after)
+		if after != before {
+			t.Errorf("expected a replayed follow not to be accepted again, got %d deliveries", after-before)
+		}
+		followers, _ := projection.GetFediverseFollowers("123")
+		if followers.TotalItems != 3 {
+			t.Errorf("expected the follower to be kept, got %d followers", followers.TotalItems)
+		}
+	})
+
+	t.Run("new posts are delivered to the followers", func(t *testing.T) {
+		apply(blogaggregatormodule.POST_CREATED, "123", `{"blogId":"123","guid":"1","title":"Post 1","link":"https://blog.example.com/posts/1","content":"<p>Hello</p>","published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+		apply(blogaggregatormodule.POST_CREATED, "456", `{"blogId":"456","guid":"2","title":"Post 2","link":"https://blog.example.com/posts/2","content":"<p>Not followed</p>","published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+		now := time.Now()
+		err := projection.DeliverActivities(context.Background(), deliverer, config, now)
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		//alice and bob share an inbox so the post is delivered to their server once
+		creates := received["/inbox"]
+		if len(creates) != 1 || creates[0].Type != "Create" {
+			t.Fatalf("expected the post to be delivered to the shared inbox once, got %+v", creates)
+		}
+		article, _ := creates[0].Object.(map[string]interface{})
+		if article["type"] != "Article" || article["name"] != "Post 1" || article["url"] != "https://blog.example.com/posts/1" || article["content"] != "<p>Hello</p>" {
+			t.Errorf("unexpected article %+v", article)
+		}
+		if downCalls != 2 {
+			t.Errorf("expected the post to be delivered to the inbox that's down, got %d calls", downCalls)
+		}
+		//the inbox that's down is tried again once the delivery is due
+		err = projection.DeliverActivities(context.Background(), deliverer, config, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		if downCalls != 2 {
+			t.Errorf("expected the delivery not to be tried before it's due, got %d calls", downCalls)
+		}
+		err = projection.DeliverActivities(context.Background(), deliverer, config, now.Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		if downCalls != 4 {
+			t.Errorf("expected the accept and the post to be delivered again, got %d calls", downCalls)
+		}
+		var failed int64
+		db.Model(&api.ActivityDelivery{}).Where("status = ?", api.ACTIVITY_DELIVERY_FAILED).Count(&failed)
+		if failed != 2 {
+			t.Errorf("expected %d deliveries to fail once the attempts ran out, got %d", 2, failed)
+		}
+	})
+
+	t.Run("the outbox has the latest posts", func(t *testing.T) {
+		outbox, err := projection.GetOutbox("123")
+		if err != nil {
+			t.Fatalf("unexpected error getting outbox '%s'", err)
+		}
+		if outbox.TotalItems != 1 || len(outbox.OrderedItems) != 1 {
+			t.Fatalf("expected %d post in the outbox, got %d", 1, outbox.TotalItems)
+		}
+		if activity := outbox.OrderedItems[0].(*api.Activity); activity.Type != "Create" || activity.Actor != "https://aggregator.example.com/blogs/123/actor" {
+			t.Errorf("unexpected activity %+v", activity)
+		}
+		if outbox, err := projection.GetOutbox("999"); err != nil || outbox != nil {
+			t.Errorf("expected no outbox for a blog that doesn't exist, got %+v and error '%v'", outbox, err)
+		}
+	})
+
+	t.Run("unfollowing stops deliveries", func(t *testing.T) {
+		apply(api.BLOG_FEDIVERSE_UNFOLLOWED, "123", fmt.Sprintf(`{"actorId":"%s/users/alice"}`, server.URL))
+		apply(api.BLOG_FEDIVERSE_UNFOLLOWED, "123", fmt.Sprintf(`{"actorId":"%s/users/bob"}`, server.URL))
+		apply(blogaggregatormodule.POST_CREATED, "123", `{"blogId":"123","guid":"3","title":"Post 3","link":"https://blog.example.com/posts/3","content":"<p>Again</p>","published":"Sun, 28 Feb 2021 17:05:53 -0400"}`)
+		err := projection.DeliverActivities(context.Background(), deliverer, config, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		if len(received["/inbox"]) != 1 {
+			t.Errorf("expected no more deliveries to the shared inbox, got %d", len(received["/inbox"]))
+		}
+		followers, _ := projection.GetFediverseFollowers("123")
+		if followers.TotalItems != 1 {
+			t.Errorf("expected %d follower, got %d", 1, followers.TotalItems)
+		}
+	})
+
+	t.Run("purged posts are deleted from the fediverse", func(t *testing.T) {
+		//post 1 was delivered to the shared inbox and post 3 is waiting for the inbox that's down
+		for _, guid := range []string{"1", "3"} {
+			var post *api.Post
+			db.Where("guid = ?", guid).First(&post)
+			apply(api.POST_DELETED, "123", fmt.Sprintf(`{"postId":"%s","itemKey":"%s","purge":true}`, post.ID, guid))
+		}
+		var withContent int64
+		db.Model(&api.ActivityDelivery{}).Where("activity LIKE ? OR activity LIKE ?", "%Hello%", "%Again%").Count(&withContent)
+		if withContent != 0 {
+			t.Errorf("expected the deliveries with the content of the posts to be removed, got %d", withContent)
+		}
+		err := projection.DeliverActivities(context.Background(), deliverer, config, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error delivering activities '%s'", err)
+		}
+		deletes := received["/inbox"][1:]
+		if len(deletes) != 1 || deletes[0].Type != "Delete" {
+			t.Fatalf("expected the post to be deleted on the server it was delivered to, got %+v", deletes)
+		}
+		article, _ := received["/inbox"][0].Object.(map[string]interface{})
+		if tombstone, _ := deletes[0].Object.(map[string]interface{}); tombstone["type"] != "Tombstone" || tombstone["id"] != article["id"] {
+			t.Errorf("expected a tombstone of the post, got %+v", deletes[0].Object)
+		}
+	})
+}
+
+func TestProjection_SendDigests(t *testing.T) {
+	os.Setenv("PUBLIC_URL", "https://aggregator.example.com")
+	defer os.Unsetenv("PUBLIC_URL")
+	sink := newSMTPSink(t)
+	defer sink.Close()
+
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{ID: "123", Title: "Followed Blog", URL: "https://followed.example.com"})
+	db.Create(&api.Blog{ID: "456", Title: "Other Blog", URL: "https://other.example.com"})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, entityID string, entityType string, payload string) {
+		handler(weos.Event{
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   entityID,
+				EntityType: entityType,
+			},
+		})
+	}
+	//posts from before the subscription aren't in the digests
+	apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"0","title":"Old Post","link":"https://followed.example.com/posts/0","published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"0","title":"Old Go Post","categories":["Go"],"link":"https://other.example.com/posts/0","published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+	apply(api.BLOG_FOLLOWED, "123", "Blog", `{"userId":"user1"}`)
+	apply(api.DIGEST_SUBSCRIBED, "daily", "DigestSubscription", `{"userId":"user1","token":"daily-token","email":"reader@example.com","frequency":"daily","followedBlogs":true}`)
+	apply(api.DIGEST_SUBSCRIBED, "weekly", "DigestSubscription", `{"userId":"user1","token":"weekly-token","email":"reader@example.com","frequency":"weekly","categories":["go"]}`)
+	apply(api.DIGEST_SUBSCRIBED, "nothing", "DigestSubscription", `{"userId":"user2","token":"nothing-token","email":"other@example.com","frequency":"daily","followedBlogs":true}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"1","title":"Post <1>","description":"About the first post","link":"https://followed.example.com/posts/1","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"1","title":"Go Post","categories":["Go"],"link":"https://other.example.com/posts/1","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"2","title":"Not Followed","link":"https://other.example.com/posts/2","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+
+	subscriptions, err := projection.GetDigestSubscriptions("user1")
+	if err != nil {
+		t.Fatalf("unexpected error getting subscriptions '%s'", err)
+	}
+	if len(subscriptions) != 2 {
+		t.Fatalf("expected %d subscriptions, got %d", 2, len(subscriptions))
+	}
+	if subscription, _ := projection.GetDigestSubscription("weekly"); subscription == nil || len(subscription.Categories) != 1 || subscription.Categories[0].Slug != "go" {
+		t.Errorf("expected the weekly digest to have the category 'go', got %+v", subscription)
+	}
+
+	mailer := api.NewSMTPMailer(&api.SMTPConfig{Host: "127.0.0.1", Port: sink.Port(), From: "digest@aggregator.example.com", Timeout: 5 * time.Second})
+	config := &api.DigestConfig{MaxPosts: 10, RetryDelay: time.Hour}
+	now := time.Now()
+	err = projection.SendDigests(context.Background(), mailer, config, now)
+	if err != nil {
+		t.Fatalf("unexpected error sending digests '%s'", err)
+	}
+	if len(sink.Messages()) != 0 {
+		t.Fatalf("expected no digests to be due, got %d", len(sink.Messages()))
+	}
+
+	t.Run("daily digest of the followed blogs", func(t *testing.T) {
+		err := projection.SendDigests(context.Background(), mailer, config, now.Add(25*time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error sending digests '%s'", err)
+		}
+		messages := sink.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("expected %d digest to be sent, got %d", 1, len(messages))
+		}
+		message := messages[0]
+		if message.to != "reader@example.com" || message.header.Get("Subject") != "1 new post this day" {
+			t.Errorf("expected the daily digest to be sent to '%s', got '%s' with subject '%s'", "reader@example.com", message.to, message.header.Get("Subject"))
+		}
+		if unsubscribe := message.header.Get("List-Unsubscribe"); unsubscribe != "<https://aggregator.example.com/digests/unsubscribe?token=daily-token>" {
+			t.Errorf("expected the unsubscribe link in the headers, got '%s'", unsubscribe)
+		}
+		if !strings.Contains(message.html, `<a href="https://followed.example.com/posts/1">Post &lt;1&gt;</a>`) || !strings.Contains(message.html, "Followed Blog") ||
+			!strings.Contains(message.html, "https://aggregator.example.com/digests/unsubscribe?token=daily-token") {
+			t.Errorf("expected the html digest to have the post and the unsubscribe link, got '%s'", message.html)
+		}
+		if !strings.Contains(message.text, "Post <1>\nFollowed Blog - Mar 1, 2021\nhttps://followed.example.com/posts/1") || strings.Contains(message.text, "Not Followed") {
+			t.Errorf("expected the text digest to only have the post from the followed blog, got '%s'", message.text)
+		}
+		subscription, _ := projection.GetDigestSubscription("daily")
+		if subscription.LastSentAt == nil || !subscription.NextSendAt.Equal(now.Add(25*time.Hour).AddDate(0, 0, 1)) {
+			t.Errorf("expected the next digest to be sent in a day, got %+v", subscription)
+		}
+	})
+
+	t.Run("weekly digest of a category", func(t *testing.T) {
+		err := projection.SendDigests(context.Background(), mailer, config, now.Add(8*24*time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error sending digests '%s'", err)
+		}
+		//there are no new posts on the followed blog for the daily digest
+		messages := sink.Messages()
+		if len(messages) != 2 {
+			t.Fatalf("expected %d digests to be sent, got %d", 2, len(messages))
+		}
+		if message := messages[1]; message.header.Get("Subject") != "1 new post this week" || !strings.Contains(message.text, "Go Post") || strings.Contains(message.text, "Old Go Post") {
+			t.Errorf("expected the weekly digest to have the new post in the category, got '%s'", message.text)
+		}
+	})
+
+	t.Run("digests that couldn't be sent are tried again", func(t *testing.T) {
+		apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"2","title":"Post 2","link":"https://followed.example.com/posts/2","published":"Tue, 02 Mar 2021 17:05:53 -0400"}`)
+		apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"3","title":"Post 3","link":"https://followed.example.com/posts/3","published":"Wed, 03 Mar 2021 17:05:53 -0400"}`)
+		sink.Fail(true)
+		sendAt := now.Add(10 * 24 * time.Hour)
+		err := projection.SendDigests(context.Background(), mailer, config, sendAt)
+		if err != nil {
+			t.Fatalf("unexpected error sending digests '%s'", err)
+		}
+		subscription, _ := projection.GetDigestSubscription("daily")
+		if !subscription.NextSendAt.Equal(sendAt.Add(time.Hour)) || subscription.LastError == "" {
+			t.Errorf("expected the digest to be tried again in an hour, got %+v", subscription)
+		}
+		sink.Fail(false)
+		config.MaxPosts = 1
+		err = projection.SendDigests(context.Background(), mailer, config, sendAt.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error sending digests '%s'", err)
+		}
+		messages := sink.Messages()
+		if len(messages) != 3 {
+			t.Fatalf("expected %d digests to be sent, got %d", 3, len(messages))
+		}
+		if message := messages[2]; message.header.Get("Subject") != "2 new posts this day" || !strings.Contains(message.text, "Post 3") ||
+			strings.Contains(message.text, "Post 2") || !strings.Contains(message.html, "And 1 more posts.") {
+			t.Errorf("expected the digest to have the latest post and the number of posts left out, got '%s'", message.text)
+		}
+	})
+
+	t.Run("posts aren't sent again when their times change", func(t *testing.T) {
+		//the times of the posts change when the projection is rebuilt
+		sendAt := now.Add(12 * 24 * time.Hour)
+		db.Model(&api.Post{}).Where("1 = 1").Update("created_at", sendAt.Add(-time.Hour))
+		err := projection.SendDigests(context.Background(), mailer, config, sendAt)
+		if err != nil {
+			t.Fatalf("unexpected error sending digests '%s'", err)
+		}
+		if messages := sink.Messages(); len(messages) != 3 {
+			t.Errorf("expected no digests to be sent, got %d", len(messages)-3)
+		}
+	})
+
+	t.Run("subscriptions from before digests started from the position of the post", func(t *testing.T) {
+		db.Exec("ALTER TABLE digest_subscriptions ADD COLUMN last_post_at datetime")
+		db.Model(&api.Post{}).Where("position <= ?", 2).Update("created_at", now.Add(-48*time.Hour))
+		db.Model(&api.DigestSubscription{}).Where("id = ?", "weekly").Updates(map[string]interface{}{"last_position": 0, "last_post_at": now.Add(-24 * time.Hour)})
+		err := projection.Migrate(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error migrating '%s'", err)
+		}
+		if subscription, _ := projection.GetDigestSubscription("weekly"); subscription == nil || subscription.LastPosition != 2 {
+			t.Errorf("expected the digest to start after the post at position %d, got %+v", 2, subscription)
+		}
+		//subscriptions are only started from the time of their last post once
+		db.Model(&api.DigestSubscription{}).Where("id = ?", "weekly").Update("last_position", 5)
+		projection.Migrate(context.Background())
+		if subscription, _ := projection.GetDigestSubscription("weekly"); subscription == nil || subscription.LastPosition != 5 {
+			t.Errorf("expected the digest to be left at position %d, got %+v", 5, subscription)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		if subscription, err := projection.GetDigestSubscriptionByToken("daily-token"); err != nil || subscription == nil || subscription.ID != "daily" {
+			t.Fatalf("expected the subscription to be found by its token, got %+v and error '%v'", subscription, err)
+		}
+		apply(api.DIGEST_UNSUBSCRIBED, "daily", "DigestSubscription", `null`)
+		if subscription, err := projection.GetDigestSubscription("daily"); err != nil || subscription != nil {
+			t.Errorf("expected the subscription to be removed, got %+v and error '%v'", subscription, err)
+		}
+		if subscriptions, _ := projection.GetDigestSubscriptions("user1"); len(subscriptions) != 1 {
+			t.Errorf("expected %d subscription, got %d", 1, len(subscriptions))
+		}
+	})
+}
+
+func TestProjection_SavedSearches(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.Close()
+	var webhookStatus int
+	var webhooks []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		webhooks = append(webhooks, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(webhookStatus)
+	}))
+	defer server.Close()
+
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{ID: "123", Title: "Magento Blog", URL: "https://magento.example.com"})
+	db.Create(&api.Blog{ID: "456", Title: "Go Blog", URL: "https://go.example.com"})
+	handler := projection.GetEventHandler()
+	apply := func(eventType string, entityID string, entityType string, payload string) {
+		event := weos.Event{
+			ID:      blogaggregatormodule.GenerateID(),
+			Type:    eventType,
+			Payload: json.RawMessage(payload),
+			Meta: weos.EventMeta{
+				EntityID:   entityID,
+				EntityType: entityType,
+			},
+		}
+		handler(event)
+		//the receiver sends new posts on once their events are persisted
+		if eventType == blogaggregatormodule.POST_CREATED {
+			projection.PostsAdded([]string{event.ID})
+		}
+	}
+	//posts from before the searches were saved are not matched
+	apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"0","title":"Old Post","categories":["Go"],"author":{"name":"Jane Doe"},"link":"https://go.example.com/posts/0","published":"Sat, 27 Feb 2021 17:05:53 -0400"}`)
+	var author *api.Author
+	if err = db.Where("name = ?", "Jane Doe").First(&author).Error; err != nil {
+		t.Fatalf("expected the author to be created '%s'", err)
+	}
+	apply(api.SAVED_SEARCH_CREATED, "webhook", "SavedSearch", fmt.Sprintf(`{"userId":"user1","name":"Page Builder","query":"magento \"page builder\"","notify":"webhook","webhookUrl":"%s/hook","secret":"secret"}`, server.URL))
+	apply(api.SAVED_SEARCH_CREATED, "email", "SavedSearch", `{"userId":"user1","name":"Go","categories":["go"],"blogs":["456"],"notify":"email","email":"analyst@example.com"}`)
+	apply(api.SAVED_SEARCH_CREATED, "in-app", "SavedSearch", fmt.Sprintf(`{"userId":"user2","name":"Jane","authors":[%d],"notify":"in-app"}`, author.ID))
+	apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"1","title":"Using Magento's Page Builder","content":"<p>The <b>page</b>\n builder</p>","link":"https://magento.example.com/posts/1","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"2","title":"Magentos 2 Page Builders","link":"https://magento.example.com/posts/2","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"1","title":"Go Generics","categories":["Go"],"author":{"name":"Jane Doe"},"link":"https://go.example.com/posts/1","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+	apply(blogaggregatormodule.POST_CREATED, "123", "Blog", `{"blogId":"123","guid":"3","title":"Go and Magento","categories":["Go"],"link":"https://magento.example.com/posts/3","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`)
+
+	searches, err := projection.GetSavedSearches("user1")
+	if err != nil {
+		t.Fatalf("unexpected error getting searches '%s'", err)
+	}
+	if len(searches) != 2 {
+		t.Fatalf("expected %d searches, got %d", 2, len(searches))
+	}
+	if search, _ := projection.GetSavedSearch("email"); search == nil || len(search.Categories) != 1 || len(search.Blogs) != 1 || search.Blogs[0] != "456" {
+		t.Errorf("expected the search to have the category and the blog, got %+v", search)
+	}
+	if search, _ := projection.GetSavedSearch("in-app"); search == nil || len(search.Authors) != 1 || search.Authors[0] != author.ID || search.LastMatchAt == nil {
+		t.Errorf("expected the search to have the author and a match, got %+v", search)
+	}
+	tests := []struct {
+		search string
+		posts  []string
+		status string
+	}{
+		{"webhook", []string{"Using Magento's Page Builder"}, api.SEARCH_MATCH_PENDING},
+		{"email", []string{"Go Generics"}, api.SEARCH_MATCH_PENDING},
+		{"in-app", []string{"Go Generics"}, api.SEARCH_MATCH_NOTIFIED},
+	}
+	for _, test := range tests {
+		matches, total, err := projection.GetSavedSearchMatches(test.search, 1, 10)
+		if err != nil {
+			t.Fatalf("unexpected error getting matches '%s'", err)
+		}
+		if total != int64(len(test.posts)) || len(matches) != len(test.posts) {
+			t.Fatalf("expected search '%s' to match %d posts, got %d", test.search, len(test.posts), total)
+		}
+		for i, match := range matches {
+			if match.Post == nil || match.Post.Title != test.posts[i] || match.Status != test.status {
+				t.Errorf("expected search '%s' to match '%s' with the status '%s', got %+v", test.search, test.posts[i], test.status, match)
+			}
+		}
+	}
+
+	mailer := api.NewSMTPMailer(&api.SMTPConfig{Host: "127.0.0.1", Port: sink.Port(), From: "alerts@aggregator.example.com", Timeout: 5 * time.Second})
+	webhookSender := api.NewWebhookSender(server.Client())
+	config := &api.SearchAlertConfig{MaxAttempts: 3, RetryDelay: time.Minute}
+	now := time.Now().Add(time.Second)
+
+	t.Run("webhooks that fail are tried again", func(t *testing.T) {
+		webhookStatus = http.StatusServiceUnavailable
+		err := projection.SendSearchAlerts(context.Background(), webhookSender, mailer, config, now)
+		if err != nil {
+			t.Fatalf("unexpected error sending alerts '%s'", err)
+		}
+		if len(webhooks) != 1 {
+			t.Fatalf("expected %d webhook to be sent, got %d", 1, len(webhooks))
+		}
+		matches, _, _ := projection.GetSavedSearchMatches("webhook", 1, 10)
+		if matches[0].Status != api.SEARCH_MATCH_PENDING || matches[0].LastError == "" {
+			t.Errorf("expected the webhook to be tried again, got %+v", matches[0])
+		}
+		webhookStatus = http.StatusOK
+		err = projection.SendSearchAlerts(context.Background(), webhookSender, mailer, config, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error sending alerts '%s'", err)
+		}
+		if len(webhooks) != 2 {
+			t.Fatalf("expected the webhook to be sent again, got %d", len(webhooks))
+		}
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(bodies[1])
+		if signature := webhooks[1].Header.Get(api.SEARCH_ALERT_SIGNATURE_HEADER); signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("expected the webhook to be signed with the secret of the search, got '%s'", signature)
+		}
+		var alert *api.SearchAlert
+		json.Unmarshal(bodies[1], &alert)
+		if alert.Event != api.SEARCH_ALERT_EVENT || alert.SearchID != "webhook" || alert.Post == nil || alert.Post.Title != "Using Magento's Page Builder" {
+			t.Errorf("expected the webhook to have the post, got %s", bodies[1])
+		}
+		matches, _, _ = projection.GetSavedSearchMatches("webhook", 1, 10)
+		if matches[0].Status != api.SEARCH_MATCH_NOTIFIED || matches[0].NotifiedAt == nil {
+			t.Errorf("expected the match to be notified, got %+v", matches[0])
+		}
+	})
+
+	t.Run("email", func(t *testing.T) {
+		messages := sink.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("expected %d email to be sent, got %d", 1, len(messages))
+		}
+		if messages[0].to != "analyst@example.com" || messages[0].header.Get("Subject") != `New post matching "Go": Go Generics` {
+			t.Errorf("expected the alert to be sent to '%s', got '%s' with subject '%s'", "analyst@example.com", messages[0].to, messages[0].header.Get("Subject"))
+		}
+		if !strings.Contains(messages[0].text, "Go Generics\nGo Blog - Mar 1, 2021\nhttps://go.example.com/posts/1") {
+			t.Errorf("expected the email to have the post, got '%s'", messages[0].text)
+		}
+	})
+
+	t.Run("emails fail without a mailer", func(t *testing.T) {
+		apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"2","title":"Go Modules","categories":["Go"],"link":"https://go.example.com/posts/2","published":"Tue, 02 Mar 2021 17:05:53 -0400"}`)
+		err := projection.SendSearchAlerts(context.Background(), webhookSender, nil, config, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error sending alerts '%s'", err)
+		}
+		matches, _, _ := projection.GetSavedSearchMatches("email", 1, 10)
+		if len(matches) != 2 || matches[0].Post.Title != "Go Modules" || matches[0].Status != api.SEARCH_MATCH_FAILED {
+			t.Errorf("expected the newest match to have failed, got %+v", matches[0])
+		}
+	})
+
+	t.Run("delete search", func(t *testing.T) {
+		apply(api.SAVED_SEARCH_DELETED, "email", "SavedSearch", `null`)
+		if search, _ := projection.GetSavedSearch("email"); search != nil {
+			t.Errorf("expected the search to be deleted")
+		}
+		if _, total, _ := projection.GetSavedSearchMatches("email", 1, 10); total != 0 {
+			t.Errorf("expected the matches of the search to be deleted, got %d", total)
+		}
+	})
+
+	t.Run("searches are only loaded again when they change", func(t *testing.T) {
+		loads := 0
+		db.Callback().Query().After("gorm:query").Register("count_saved_searches", func(tx *gorm.DB) {
+			if tx.Statement.Table == "saved_searches" {
+				loads++
+			}
+		})
+		defer db.Callback().Query().Remove("count_saved_searches")
+		apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"4","title":"Go Modules","link":"https://go.example.com/posts/4","published":"Tue, 02 Mar 2021 17:05:53 -0400"}`)
+		apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"5","title":"Go Workspaces","link":"https://go.example.com/posts/5","published":"Tue, 02 Mar 2021 17:05:53 -0400"}`)
+		if loads != 1 {
+			t.Errorf("expected the searches to be loaded once, got %d", loads)
+		}
+		apply(api.SAVED_SEARCH_CREATED, "workspaces", "SavedSearch", `{"userId":"user1","name":"Workspaces","query":"workspaces","notify":"in-app"}`)
+		apply(blogaggregatormodule.POST_CREATED, "456", "Blog", `{"blogId":"456","guid":"6","title":"More Go Workspaces","link":"https://go.example.com/posts/6","published":"Tue, 02 Mar 2021 17:05:53 -0400"}`)
+		if loads != 2 {
+			t.Errorf("expected the searches to be loaded again once a search is added, got %d", loads)
+		}
+		if _, total, _ := projection.GetSavedSearchMatches("workspaces", 1, 10); total != 1 {
+			t.Errorf("expected the new search to match %d post, got %d", 1, total)
+		}
+	})
+}
+
+func TestProjection_PostStream(t *testing.T) {
+	projection, db := newTestProjection(t, nil)
+	db.Create(&api.Blog{ID: "123", Title: "Go Blog", URL: "https://go.example.com"})
+	db.Create(&api.Blog{ID: "456", Title: "Other Blog", URL: "https://other.example.com"})
+	handler := projection.GetEventHandler()
+	createPost := func(eventID string, blogID string, guid string, categories string) {
+		handler(weos.Event{
+			ID:      eventID,
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(fmt.Sprintf(`{"blogId":"%s","guid":"%s","title":"Post %s","categories":[%s],"link":"https://example.com/%s","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`, blogID, guid, guid, categories, guid)),
+			Meta: weos.EventMeta{
+				EntityID:   blogID,
+				EntityType: "Blog",
+			},
+		})
+		projection.PostsAdded([]string{eventID})
+	}
+	//posts added before they had positions get one when the projection is migrated
+	db.Create(&api.Post{ID: "older", Title: "Older Post", BlogID: "123", EventID: "older"})
+	projection.Migrate(context.Background())
+	createPost("event1", "123", "1", `"Go"`)
+
+	goListener := projection.SubscribePosts(&api.PostStreamFilter{Category: "go"})
+	blogListener := projection.SubscribePosts(&api.PostStreamFilter{BlogID: "456"})
+	createPost("event2", "123", "2", `"Go"`)
+	createPost("event3", "456", "3", `"Rust"`)
+	createPost("event4", "456", "4", `"Go"`)
+
+	t.Run("new posts are sent to the listeners they match", func(t *testing.T) {
+		tests := []struct {
+			listener *api.PostListener
+			events   []string
+		}{
+			{goListener, []string{"event2", "event4"}},
+			{blogListener, []string{"event3", "event4"}},
+		}
+		for _, test := range tests {
+			if len(test.listener.Posts) != len(test.events) {
+				t.Fatalf("expected %d posts to be sent, got %d", len(test.events), len(test.listener.Posts))
+			}
+			for _, eventID := range test.events {
+				post := <-test.listener.Posts
+				if post.EventID != eventID || post.Blog == nil || len(post.Categories) != 1 {
+					t.Errorf("expected the post from '%s' with its blog and categories, got %+v", eventID, post)
+				}
+			}
+		}
+		projection.UnsubscribePosts(goListener)
+		projection.UnsubscribePosts(blogListener)
+		if _, ok := <-goListener.Posts; ok {
+			t.Errorf("expected the listener to be closed")
+		}
+	})
+
+	t.Run("posts after the last event", func(t *testing.T) {
+		posts, complete, err := projection.GetPostsAfter("event2", &api.PostStreamFilter{}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error getting posts '%s'", err)
+		}
+		if !complete || len(posts) != 2 || posts[0].EventID != "event3" || posts[1].EventID != "event4" {
+			t.Fatalf("expected the posts after the event in the order they were added, got %d", len(posts))
+		}
+		posts, _, _ = projection.GetPostsAfter("event1", &api.PostStreamFilter{Category: "go"}, 10)
+		if len(posts) != 2 || posts[0].EventID != "event2" || posts[1].EventID != "event4" {
+			t.Errorf("expected the posts in the category after the event, got %d", len(posts))
+		}
+		var older *api.Post
+		db.First(&older, "id = ?", "older")
+		if posts, _, _ = projection.GetPostsAfter("older", &api.PostStreamFilter{}, 10); older.Position != 1 || len(posts) != 4 || posts[0].EventID != "event1" {
+			t.Errorf("expected the posts after a post added before posts had positions, got %d", len(posts))
+		}
+		if posts, complete, _ = projection.GetPostsAfter("unknown", &api.PostStreamFilter{}, 10); complete || len(posts) != 0 {
+			t.Errorf("expected the posts for an unknown event not to be complete, got %d", len(posts))
+		}
+		if posts, complete, _ = projection.GetPostsAfter("event1", &api.PostStreamFilter{}, 2); complete || len(posts) != 0 {
+			t.Errorf("expected the posts not to be complete when there are more than the limit, got %d", len(posts))
+		}
+	})
+
+	t.Run("posts are resumed in the order they were added instead of their event ids or times", func(t *testing.T) {
+		//the id of the event sorts before the ids of the posts that were added before it
+		createPost("event0", "123", "6", `"Go"`)
+		//nor are the times of the posts, they change when the projection is rebuilt
+		db.Model(&api.Post{}).Where("event_id = ?", "event0").Update("created_at", time.Now().Add(-time.Hour))
+		posts, complete, _ := projection.GetPostsAfter("event4", &api.PostStreamFilter{}, 10)
+		if !complete || len(posts) != 1 || posts[0].EventID != "event0" {
+			t.Errorf("expected the post added last to be sent, got %d", len(posts))
+		}
+		posts, _, _ = projection.GetPostsAfter("event0", &api.PostStreamFilter{}, 10)
+		if len(posts) != 0 {
+			t.Errorf("expected no posts after the post added last, got %d", len(posts))
+		}
+	})
+
+	t.Run("posts aren't sent again when the projection is rebuilt", func(t *testing.T) {
+		listener := projection.SubscribePosts(&api.PostStreamFilter{})
+		defer projection.UnsubscribePosts(listener)
+		handler(weos.Event{
+			ID:      "event5",
+			Type:    blogaggregatormodule.POST_CREATED,
+			Payload: json.RawMessage(`{"blogId":"123","guid":"5","title":"Post 5","link":"https://example.com/5","published":"Mon, 01 Mar 2021 17:05:53 -0400"}`),
+			Meta: weos.EventMeta{
+				EntityID:   "123",
+				EntityType: "Blog",
+			},
+		})
+		if len(listener.Posts) != 0 {
+			t.Errorf("expected a replayed post not to be sent, got %d posts", len(listener.Posts))
+		}
+	})
+
+	t.Run("listeners that fall behind are closed", func(t *testing.T) {
+		broker := api.NewPostBroker()
+		listener := broker.Subscribe(&api.PostStreamFilter{})
+		for i := 0; i <= cap(listener.Posts); i++ {
+			broker.Publish(&api.Post{ID: fmt.Sprint(i)})
+		}
+		if broker.Listeners() != 0 {
+			t.Errorf("expected the listener to be removed")
+		}
+		for range listener.Posts {
+		}
+		broker.Unsubscribe(listener)
+	})
+}
+
+//smtpMessage is an email received by the SMTP sink
+type smtpMessage struct {
+	to     string
+	header mail.Header
+	text   string
+	html   string
+}
+
+//smtpSink is a local SMTP server that keeps the emails it receives
+type smtpSink struct {
+	t        *testing.T
+	listener net.Listener
+	mu       sync.Mutex
+	messages []*smtpMessage
+	fail     bool
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error starting smtp sink '%s'", err)
+	}
+	sink := &smtpSink{t: t, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	var to string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			to = strings.Trim(strings.TrimPrefix(strings.SplitN(line, ":", 2)[1], " "), "<>")
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			fail := s.fail
+			s.mu.Unlock()
+			if fail {
+				text.PrintfLine("451 Try again later")
+				continue
+			}
+			s.receive(to, data)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpSink) receive(to string, data []byte) {
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		s.t.Errorf("unexpected error reading email '%s'", err)
+		return
+	}
+	received := &smtpMessage{to: to, header: message.Header}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject")); err == nil {
+		received.header["Subject"] = []string{subject}
+	}
+	_, params, _ := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			received.html = string(body)
+		} else {
+			received.text = string(body)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, received)
+}
+
+//Fail makes the sink reject the emails it receives
+func (s *smtpSink) Fail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *smtpSink) Messages() []*smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpSink) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) Close() {
+	s.listener.Close()
 }
@@ -0,0 +1,768 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
+	"github.com/wepala/weos"
+	"gorm.io/gorm"
+)
+
+//Receiver handles the commands for the parts of the aggregator that are managed in the api
+type Receiver struct {
+	application weos.Application
+	projection  Projection
+	refreshed   *blogCache
+}
+
+//AddBlog adds a blog using the feed service so that all the feed formats the api supports can be added
+func (r *Receiver) AddBlog(ctx context.Context, command *weos.Command) error {
+	var request *blogaggregatormodule.AddBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	//blogs can be found by their current and previous urls so the same blog isn't added twice
+	if existing, err := r.projection.GetBlogByURL(request.Url); err == nil && existing != nil {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' has already been added", request.Url), "Blog", existing.ID, nil)
+	}
+	blog, err := NewFeedService(r.application.HTTPClient(), r.projection).AddBlog(ctx, request)
+	if err != nil {
+		return err
+	}
+	feedURL := blog.FeedURL
+	if feedURL == "" {
+		feedURL = blog.URL
+	}
+	if feedURL != request.Url {
+		if existing, err := r.projection.GetBlogByURL(feedURL); err == nil && existing != nil {
+			return weos.NewDomainError(fmt.Sprintf("blog '%s' has already been added", feedURL), "Blog", existing.ID, nil)
+		}
+	}
+	return r.persistPosts(blog)
+}
+
+//RefreshBlog fetches the feed of the blog and adds the new posts
+func (r *Receiver) RefreshBlog(ctx context.Context, command *weos.Command) error {
+	var request *RefreshBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getRefreshedBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	fetched, err := NewFeedService(r.application.HTTPClient(), r.projection).Refresh(ctx, aggregate)
+	if err != nil {
+		return err
+	}
+	if len(aggregate.GetNewChanges()) > 0 {
+		err = r.persistPosts(aggregate)
+	} else {
+		//a fetch that didn't change anything isn't recorded as an event, only the stats of the blog are updated
+		err = r.projection.RecordFetch(request.BlogID, fetched)
+	}
+	if err != nil {
+		return err
+	}
+	r.refreshed.put(aggregate)
+	return nil
+}
+
+func (r *Receiver) AddCategorySynonym(ctx context.Context, command *weos.Command) error {
+	var request *CategorySynonymRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	synonym := NormalizeCategory(request.Synonym)
+	if synonym == "" {
+		return weos.NewDomainError("a synonym must be specified", "Category", strconv.FormatUint(uint64(request.CategoryID), 10), nil)
+	}
+	category, err := r.getCategory(request.CategoryID)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getCategoryAggregate(category)
+	if err != nil {
+		return err
+	}
+	err = aggregate.AddSynonym(synonym)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) RemoveCategorySynonym(ctx context.Context, command *weos.Command) error {
+	var request *CategorySynonymRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	category, err := r.getCategory(request.CategoryID)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getCategoryAggregate(category)
+	if err != nil {
+		return err
+	}
+	err = aggregate.RemoveSynonym(NormalizeCategory(request.Synonym))
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) MergeCategory(ctx context.Context, command *weos.Command) error {
+	var request *MergeCategoryRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	if request.CategoryID == request.IntoID {
+		return weos.NewDomainError("a category can't be merged into itself", "Category", strconv.FormatUint(uint64(request.CategoryID), 10), nil)
+	}
+	category, err := r.getCategory(request.CategoryID)
+	if err != nil {
+		return err
+	}
+	into, err := r.getCategory(request.IntoID)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getCategoryAggregate(category)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Merge(into)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) SetBlogFullContent(ctx context.Context, command *weos.Command) error {
+	var request *BlogFullContentRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.SetFullContent(request.Enabled)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) SetBlogSendMentions(ctx context.Context, command *weos.Command) error {
+	var request *BlogSendMentionsRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.SetSendMentions(request.Enabled)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+//DeleteBlog removes a blog. A blog that was deleted can still be purged so the blog is rebuilt from its events instead of
+//being looked up in the projection
+func (r *Receiver) DeleteBlog(ctx context.Context, command *weos.Command) error {
+	var request *DeleteBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	events, err := r.application.EventRepository().GetByAggregateAndType(request.BlogID, "Blog")
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' not found", request.BlogID), "Blog", request.BlogID, nil)
+	}
+	aggregate, err := NewBlogAggregate(request.BlogID, events)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Delete(request.Purge)
+	if err != nil {
+		return err
+	}
+	err = r.application.EventRepository().Persist(aggregate)
+	if err != nil || !request.Purge {
+		return err
+	}
+	//only the events that record the deletion are left intact
+	scrubbed, err := json.Marshal(&ScrubbedPayload{Scrubbed: true})
+	if err != nil {
+		return err
+	}
+	return r.scrubEvents(events, func(event *weos.Event) json.RawMessage {
+		if event.Type == BLOG_DELETED {
+			return nil
+		}
+		return scrubbed
+	})
+}
+
+//DeletePost removes a post. Purging also scrubs the post from the events of its blog
+func (r *Receiver) DeletePost(ctx context.Context, command *weos.Command) error {
+	var request *DeletePostRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	post, err := r.projection.GetPostByID(request.PostID)
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		return weos.NewDomainError(fmt.Sprintf("post '%s' not found", request.PostID), "Post", request.PostID, nil)
+	}
+	if post.DeletedAt.Valid && !request.Purge {
+		return weos.NewDomainError(fmt.Sprintf("post '%s' has already been deleted", request.PostID), "Post", request.PostID, nil)
+	}
+	events, err := r.application.EventRepository().GetByAggregateAndType(post.BlogID, "Blog")
+	if err != nil {
+		return err
+	}
+	aggregate, err := NewBlogAggregate(post.BlogID, events)
+	if err != nil {
+		return err
+	}
+	key := postItemKey(post)
+	err = aggregate.DeletePost(post.ID, key, request.Purge)
+	if err != nil {
+		return err
+	}
+	err = r.application.EventRepository().Persist(aggregate)
+	if err != nil || !request.Purge {
+		return err
+	}
+	//the key is kept so that the item isn't added again when the feed is fetched
+	scrubbed, err := json.Marshal(&ScrubbedPayload{
+		Scrubbed: true,
+		BlogID:   post.BlogID,
+		GUID:     key,
+	})
+	if err != nil {
+		return err
+	}
+	return r.scrubEvents(events, func(event *weos.Event) json.RawMessage {
+		if event.Type != blogaggregatormodule.POST_CREATED && event.Type != POST_UPDATED {
+			return nil
+		}
+		var item *PostUpdatedPayload
+		if err := json.Unmarshal(event.Payload, &item); err != nil {
+			return nil
+		}
+		if itemKey(&item.Item) != key && (item.Link == "" || item.Link != post.Link) {
+			return nil
+		}
+		return scrubbed
+	})
+}
+
+//ClaimBlog records a user asking to own a blog. The token for the claim is stored with the claim in the projection
+func (r *Receiver) ClaimBlog(ctx context.Context, command *weos.Command) error {
+	var request *ClaimBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	_, err = aggregate.Claim(request.UserID)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+//VerifyBlogClaim looks for the token of the user's claim on the blog and makes the user an owner when it's found
+func (r *Receiver) VerifyBlogClaim(ctx context.Context, command *weos.Command) error {
+	var request *ClaimBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	blog, err := r.projection.GetBlogByID(request.BlogID)
+	if err != nil {
+		return err
+	}
+	if blog == nil {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' not found", request.BlogID), "Blog", request.BlogID, nil)
+	}
+	events, err := r.application.EventRepository().GetByAggregateAndType(request.BlogID, "Blog")
+	if err != nil {
+		return err
+	}
+	aggregate, err := NewBlogAggregate(request.BlogID, events)
+	if err != nil {
+		return err
+	}
+	token := aggregate.ClaimToken(request.UserID)
+	if token == "" {
+		return weos.NewDomainError(fmt.Sprintf("blog '%s' hasn't been claimed by the user", request.BlogID), "Blog", request.BlogID, nil)
+	}
+	feedURL := blog.FeedURL
+	if feedURL == "" {
+		feedURL = blog.URL
+	}
+	method, err := NewClaimVerifier(r.application.HTTPClient()).Verify(ctx, blog.URL, feedURL, token)
+	if err != nil {
+		return weos.NewDomainError(err.Error(), "Blog", request.BlogID, err)
+	}
+	err = aggregate.VerifyClaim(request.UserID, method)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) UpdateBlog(ctx context.Context, command *weos.Command) error {
+	var request *UpdateBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.SetDescription(request.Description)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) HidePost(ctx context.Context, command *weos.Command) error {
+	var request *HidePostRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, post, err := r.getBlogPostAggregate(request.BlogID, request.PostID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.HidePost(post.ID, postItemKey(post), request.Hidden)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) SetPostCategories(ctx context.Context, command *weos.Command) error {
+	var request *SetPostCategoriesRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, post, err := r.getBlogPostAggregate(request.BlogID, request.PostID)
+	if err != nil {
+		return err
+	}
+	var categories []string
+	for _, category := range request.Categories {
+		if NormalizeCategory(category) != "" {
+			categories = append(categories, strings.TrimSpace(category))
+		}
+	}
+	err = aggregate.SetPostCategories(post.ID, postItemKey(post), categories)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) FollowBlog(ctx context.Context, command *weos.Command) error {
+	var request *FollowBlogRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Follow(request.UserID, request.Follow)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) FediverseFollow(ctx context.Context, command *weos.Command) error {
+	var request *FediverseFollowRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.FediverseFollow(request.Follower, request.Follow)
+	if err != nil {
+		return err
+	}
+	err = r.application.EventRepository().Persist(aggregate)
+	if err != nil || !request.Follow {
+		return err
+	}
+	return r.projection.FediverseFollowed(request.BlogID, request.Follower)
+}
+
+//SubscribeDigest subscribes a user to an email digest after checking that the categories exist
+func (r *Receiver) SubscribeDigest(ctx context.Context, command *weos.Command) error {
+	var request *SubscribeDigestRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	for _, slug := range request.Categories {
+		category, err := r.projection.GetCategory(slug)
+		if err != nil {
+			return err
+		}
+		if category == nil {
+			return weos.NewDomainError(fmt.Sprintf("category '%s' not found", slug), "DigestSubscription", request.SubscriptionID, nil)
+		}
+	}
+	aggregate, err := r.getDigestSubscriptionAggregate(request.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Subscribe(request.UserID, &request.DigestSubscriptionRequest)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) UnsubscribeDigest(ctx context.Context, command *weos.Command) error {
+	var request *UnsubscribeDigestRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getDigestSubscriptionAggregate(request.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Unsubscribe()
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+//SaveSearch saves a search after checking that its categories, blogs and authors exist
+func (r *Receiver) SaveSearch(ctx context.Context, command *weos.Command) error {
+	var request *SaveSearchRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	for _, slug := range request.Categories {
+		category, err := r.projection.GetCategory(slug)
+		if err != nil {
+			return err
+		}
+		if category == nil {
+			return weos.NewDomainError(fmt.Sprintf("category '%s' not found", slug), "SavedSearch", request.SearchID, nil)
+		}
+	}
+	for _, blogID := range request.Blogs {
+		blog, err := r.projection.GetBlogByID(blogID)
+		if err != nil {
+			return err
+		}
+		if blog == nil {
+			return weos.NewDomainError(fmt.Sprintf("blog '%s' not found", blogID), "SavedSearch", request.SearchID, nil)
+		}
+	}
+	for _, authorID := range request.Authors {
+		author, err := r.projection.GetAuthor(authorID)
+		if err != nil {
+			return err
+		}
+		if author == nil {
+			return weos.NewDomainError(fmt.Sprintf("author '%d' not found", authorID), "SavedSearch", request.SearchID, nil)
+		}
+	}
+	aggregate, err := r.getSavedSearchAggregate(request.SearchID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Save(request.UserID, &request.SavedSearchRequest)
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+func (r *Receiver) DeleteSearch(ctx context.Context, command *weos.Command) error {
+	var request *DeleteSearchRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	aggregate, err := r.getSavedSearchAggregate(request.SearchID)
+	if err != nil {
+		return err
+	}
+	err = aggregate.Delete()
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+//ReceiveMention records a webmention or pingback after checking that the source links to the post. A mention whose source
+//was removed or no longer links to the post is removed
+func (r *Receiver) ReceiveMention(ctx context.Context, command *weos.Command) error {
+	var request *ReceiveMentionRequest
+	err := json.Unmarshal(command.Payload, &request)
+	if err != nil {
+		return err
+	}
+	if err = checkMention(request.Source, request.Target); err != nil {
+		return weos.NewDomainError(err.Error(), "Blog", request.BlogID, nil)
+	}
+	post, err := r.projection.GetPostByLink(request.BlogID, request.Target)
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		return weos.NewDomainError(fmt.Sprintf("'%s' is not a post on blog '%s'", request.Target, request.BlogID), "Blog", request.BlogID, ErrMentionTargetNotFound)
+	}
+	aggregate, err := r.getBlogAggregate(request.BlogID)
+	if err != nil {
+		return err
+	}
+	key := postItemKey(post)
+	if request.Type == MENTION_TYPE_PINGBACK && aggregate.HasMention(post.ID, key, request.Source) {
+		return weos.NewDomainError(ErrMentionExists.Error(), "Blog", request.BlogID, ErrMentionExists)
+	}
+	title, err := NewMentionVerifier(r.application.HTTPClient()).Verify(ctx, request.Source, request.Target)
+	if err != nil {
+		if (errors.Is(err, ErrMentionSourceGone) || errors.Is(err, ErrMentionNoLink)) && aggregate.HasMention(post.ID, key, request.Source) {
+			err = aggregate.RemoveMention(post.ID, key, request.Source)
+			if err != nil {
+				return err
+			}
+			return r.application.EventRepository().Persist(aggregate)
+		}
+		//sources that couldn't be downloaded because of a problem that could go away (e.g. the site is down) aren't
+		//rejected so that the mention is checked again later
+		if errors.Is(err, ErrMentionSourceNotFound) && temporaryError(err) {
+			return err
+		}
+		return weos.NewDomainError(err.Error(), "Blog", request.BlogID, err)
+	}
+	err = aggregate.Mention(&PostMentionedPayload{
+		PostID:  post.ID,
+		ItemKey: key,
+		Source:  request.Source,
+		Target:  request.Target,
+		Type:    request.Type,
+		Title:   title,
+	})
+	if err != nil {
+		return err
+	}
+	return r.application.EventRepository().Persist(aggregate)
+}
+
+//scrubEvents replaces the payloads of stored events e.g. to remove content for a takedown request. The events are kept
+//so the aggregate can still be rebuilt. The payload isn't changed when scrub returns nil
+func (r *Receiver) scrubEvents(events []*weos.Event, scrub func(event *weos.Event) json.RawMessage) error {
+	return r.application.DB().Transaction(func(tx *gorm.DB) error {
+		for _, event := range events {
+			payload := scrub(event)
+			if payload == nil {
+				continue
+			}
+			err := tx.Model(&weos.GormEvent{}).Where("id = ?", event.ID).Update("payload", []byte(payload)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//scrubbedEvent checks whether the payload of the event was scrubbed when its blog or post was purged. Events that were
+//scrubbed before the payloads were marked are empty (blogs) or only have the blog and the guid of the item (posts)
+func scrubbedEvent(event *weos.Event) bool {
+	var payload *ScrubbedPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil || payload == nil {
+		return false
+	}
+	if payload.Scrubbed || (event.Meta.EntityType == "Blog" && string(event.Payload) == "{}") {
+		return true
+	}
+	if event.Type != blogaggregatormodule.POST_CREATED && event.Type != POST_UPDATED {
+		return false
+	}
+	legacy, err := json.Marshal(&PostUpdatedPayload{
+		BlogID: payload.BlogID,
+		Item:   gofeed.Item{GUID: payload.GUID},
+	})
+	return err == nil && string(legacy) == string(event.Payload)
+}
+
+func (r *Receiver) getBlogAggregate(id string) (*BlogAggregate, error) {
+	blog, err := r.projection.GetBlogByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if blog == nil {
+		return nil, weos.NewDomainError(fmt.Sprintf("blog '%s' not found", id), "Blog", id, nil)
+	}
+	events, err := r.application.EventRepository().GetByAggregateAndType(id, "Blog")
+	if err != nil {
+		return nil, err
+	}
+	return NewBlogAggregate(id, events)
+}
+
+//getRefreshedBlogAggregate get the aggregate of a blog that is polled. The blog is kept from the last time it was
+//refreshed and only the events recorded since then are applied, instead of the blog's whole history
+func (r *Receiver) getRefreshedBlogAggregate(id string) (*BlogAggregate, error) {
+	blog, err := r.projection.GetBlogByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if blog == nil {
+		return nil, weos.NewDomainError(fmt.Sprintf("blog '%s' not found", id), "Blog", id, nil)
+	}
+	aggregate := r.refreshed.take(id)
+	if aggregate == nil {
+		events, err := r.application.EventRepository().GetByAggregateAndType(id, "Blog")
+		if err != nil {
+			return nil, err
+		}
+		if aggregate, err = NewBlogAggregate(id, events); err != nil {
+			return nil, err
+		}
+	} else {
+		events, err := r.application.EventRepository().GetByAggregateAndSequenceRange(id, aggregate.SequenceNo+1, math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+		if err = aggregate.ApplyChanges(events); err != nil {
+			return nil, err
+		}
+	}
+	//the posts aren't needed to refresh the blog, the items that were added are tracked by their content hash
+	aggregate.Posts = nil
+	return aggregate, nil
+}
+
+//persistPosts persists the changes to a blog and then sends the posts that were added to the places new posts go (see
+//Projection.PostsAdded)
+func (r *Receiver) persistPosts(aggregate weos.AggregateInterface) error {
+	var added []string
+	for _, change := range aggregate.GetNewChanges() {
+		if event := change.(*weos.Event); event.Type == blogaggregatormodule.POST_CREATED {
+			added = append(added, event.ID)
+		}
+	}
+	err := r.application.EventRepository().Persist(aggregate)
+	if err != nil {
+		return err
+	}
+	return r.projection.PostsAdded(added)
+}
+
+//getBlogPostAggregate get the aggregate of a blog after checking that the post is on the blog
+func (r *Receiver) getBlogPostAggregate(blogID string, postID string) (*BlogAggregate, *Post, error) {
+	post, err := r.projection.GetPostByID(postID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if post == nil || post.DeletedAt.Valid || post.BlogID != blogID {
+		return nil, nil, weos.NewDomainError(fmt.Sprintf("post '%s' not found on blog '%s'", postID, blogID), "Post", postID, nil)
+	}
+	aggregate, err := r.getBlogAggregate(blogID)
+	return aggregate, post, err
+}
+
+//postItemKey get the key of the item in the feed the post was added from. Posts that were added before the guid was
+//stored are matched by their link
+func postItemKey(post *Post) string {
+	if post.GUID == "" {
+		return post.Link
+	}
+	return post.GUID
+}
+
+func (r *Receiver) getCategory(id uint) (*Category, error) {
+	category, err := r.projection.GetCategoryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, weos.NewDomainError(fmt.Sprintf("category '%d' not found", id), "Category", strconv.FormatUint(uint64(id), 10), nil)
+	}
+	return category, nil
+}
+
+//getDigestSubscriptionAggregate get the aggregate of a digest subscription from its events
+func (r *Receiver) getDigestSubscriptionAggregate(id string) (*DigestSubscriptionAggregate, error) {
+	events, err := r.application.EventRepository().GetByAggregateAndType(id, "DigestSubscription")
+	if err != nil {
+		return nil, err
+	}
+	return NewDigestSubscriptionAggregate(id, events)
+}
+
+func (r *Receiver) getSavedSearchAggregate(id string) (*SavedSearchAggregate, error) {
+	events, err := r.application.EventRepository().GetByAggregateAndType(id, "SavedSearch")
+	if err != nil {
+		return nil, err
+	}
+	return NewSavedSearchAggregate(id, events)
+}
+
+func (r *Receiver) getCategoryAggregate(category *Category) (*CategoryAggregate, error) {
+	events, err := r.application.EventRepository().GetByAggregateAndType(category.Slug, "Category")
+	if err != nil {
+		return nil, err
+	}
+	return NewCategoryAggregate(category.Slug, events), nil
+}
+
+func NewReceiver(application weos.Application, projection Projection) *Receiver {
+	return &Receiver{
+		application: application,
+		projection:  projection,
+		refreshed:   newBlogCache(),
+	}
+}
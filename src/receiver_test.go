@@ -0,0 +1,1181 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	api "github.com/wepala/blog-aggregator-api/src"
+	blogaggregatormodule "github.com/wepala/blog-aggregator-module"
+	"github.com/wepala/weos"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReceiver_MergeCategory(t *testing.T) {
+	mockCategories := map[uint]*api.Category{
+		1: {Title: "React.js", Slug: "react-js"},
+		2: {Title: "React", Slug: "react"},
+	}
+	projection := &ProjectionMock{
+		GetCategoryByIDFunc: func(id uint) (*api.Category, error) {
+			return mockCategories[id], nil
+		},
+	}
+	var persistedEvents []weos.Entity
+	eventRepository := &EventRepositoryMock{
+		GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+			return []*weos.Event{{Meta: weos.EventMeta{EntityID: ID, SequenceNo: 3}}}, nil
+		},
+		PersistFunc: func(entity weos.AggregateInterface) error {
+			persistedEvents = entity.GetNewChanges()
+			return nil
+		},
+	}
+	application := &ApplicationMock{
+		EventRepositoryFunc: func() weos.EventRepository {
+			return eventRepository
+		},
+	}
+	receiver := api.NewReceiver(application, projection)
+
+	t.Run("merge category", func(t *testing.T) {
+		err := receiver.MergeCategory(context.TODO(), api.MergeCategoryCommand(1, 2))
+		if err != nil {
+			t.Fatalf("unexpected error merging category '%s'", err)
+		}
+		if len(persistedEvents) != 1 {
+			t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+		}
+		event := persistedEvents[0].(*weos.Event)
+		if event.Type != api.CATEGORY_MERGED {
+			t.Errorf("expected the event type to be '%s', got '%s'", api.CATEGORY_MERGED, event.Type)
+		}
+		if event.Meta.EntityID != "react-js" {
+			t.Errorf("expected the event to be for category '%s', got '%s'", "react-js", event.Meta.EntityID)
+		}
+		if event.Meta.SequenceNo != 4 {
+			t.Errorf("expected the sequence no to be %d, got %d", 4, event.Meta.SequenceNo)
+		}
+		var payload *api.CategoryMergedPayload
+		json.Unmarshal(event.Payload, &payload)
+		if payload.Into != "react" {
+			t.Errorf("expected the category to be merged into '%s', got '%s'", "react", payload.Into)
+		}
+	})
+
+	t.Run("merge category into itself", func(t *testing.T) {
+		err := receiver.MergeCategory(context.TODO(), api.MergeCategoryCommand(1, 1))
+		if _, ok := err.(*weos.DomainError); !ok {
+			t.Errorf("expected a domain error, got '%v'", err)
+		}
+	})
+
+	t.Run("merge category that doesn't exist", func(t *testing.T) {
+		err := receiver.MergeCategory(context.TODO(), api.MergeCategoryCommand(3, 2))
+		if _, ok := err.(*weos.DomainError); !ok {
+			t.Errorf("expected a domain error, got '%v'", err)
+		}
+	})
+}
+
+func TestReceiver_AddBlog(t *testing.T) {
+	fixture := func(name string, contentType string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatalf("unable to read fixture '%s'", name)
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Write(content)
+		}
+	}
+	//the blog at /existing.rdf has already been added
+	var added []string
+	projection := &ProjectionMock{
+		GetBlogByURLFunc: func(url string) (*api.Blog, error) {
+			if strings.HasSuffix(url, "/existing.rdf") {
+				return &api.Blog{ID: "123", FeedURL: url}, nil
+			}
+			return nil, fmt.Errorf("blog '%s' not found", url)
+		},
+		PostsAddedFunc: func(eventIDs []string) error {
+			added = eventIDs
+			return nil
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", fixture("fixtures/html/json_feed.html", "text/html; charset=utf-8"))
+	mux.Handle("/feed.json", fixture("fixtures/feed/json_feed.json", "application/feed+json"))
+	mux.Handle("/index.rdf", fixture("fixtures/feed/rdf.xml", "application/rdf+xml"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		feedURL string
+	}{
+		{"json feed linked from the homepage", server.URL, server.URL + "/feed.json"},
+		{"json feed", server.URL + "/feed.json", server.URL + "/feed.json"},
+		{"rdf feed", server.URL + "/index.rdf", server.URL + "/index.rdf"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var persistedEvents []weos.Entity
+			eventRepository := &EventRepositoryMock{
+				PersistFunc: func(entity weos.AggregateInterface) error {
+					persistedEvents = entity.GetNewChanges()
+					return nil
+				},
+			}
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return eventRepository
+				},
+				HTTPClientFunc: func() *http.Client {
+					return server.Client()
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.AddBlog(context.TODO(), blogaggregatormodule.AddBlogCommand(test.url))
+			if err != nil {
+				t.Fatalf("unexpected error adding blog '%s'", err)
+			}
+			var posts []*blogaggregatormodule.PostCreatedPayload
+			for _, entity := range persistedEvents {
+				event := entity.(*weos.Event)
+				switch event.Type {
+				case blogaggregatormodule.BLOG_UPDATED:
+					var blog *blogaggregatormodule.BlogCreatedPayload
+					json.Unmarshal(event.Payload, &blog)
+					if blog.FeedURL != test.feedURL {
+						t.Errorf("expected the feed url to be '%s', got '%s'", test.feedURL, blog.FeedURL)
+					}
+					if blog.URL != "https://ak33m.com/" {
+						t.Errorf("expected the blog url to be '%s', got '%s'", "https://ak33m.com/", blog.URL)
+					}
+				case blogaggregatormodule.POST_CREATED:
+					var post *blogaggregatormodule.PostCreatedPayload
+					json.Unmarshal(event.Payload, &post)
+					posts = append(posts, post)
+					if len(added) < len(posts) || added[len(posts)-1] != event.ID {
+						t.Errorf("expected the post from event '%s' to be sent on once it's persisted", event.ID)
+					}
+				}
+			}
+			if len(posts) != 2 {
+				t.Fatalf("expected %d posts to be created, got %d", 2, len(posts))
+			}
+			if posts[0].Title != "Getting started with Viro React" || posts[0].Link != "https://ak33m.com/posts/viro-react/" {
+				t.Errorf("expected the post details to be mapped from the feed, got '%s' '%s'", posts[0].Title, posts[0].Link)
+			}
+			if posts[0].Published != "Sat, 27 Mar 2021 17:05:53 -0400" {
+				t.Errorf("expected the post published date to be '%s', got '%s'", "Sat, 27 Mar 2021 17:05:53 -0400", posts[0].Published)
+			}
+			if posts[1].Published == "" {
+				t.Errorf("expected a published date to be set on posts without one")
+			}
+		})
+	}
+
+	t.Run("blog that has already been added", func(t *testing.T) {
+		application := &ApplicationMock{
+			HTTPClientFunc: func() *http.Client {
+				return server.Client()
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.AddBlog(context.TODO(), blogaggregatormodule.AddBlogCommand(server.URL+"/existing.rdf"))
+		if _, ok := err.(*weos.DomainError); !ok {
+			t.Errorf("expected a domain error, got '%v'", err)
+		}
+	})
+
+	t.Run("page without a feed", func(t *testing.T) {
+		application := &ApplicationMock{
+			HTTPClientFunc: func() *http.Client {
+				return &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"text/html"}}, Body: io.NopCloser(strings.NewReader("<html><body>Not Blog</body></html>")), Request: r}, nil
+				})}
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.AddBlog(context.TODO(), blogaggregatormodule.AddBlogCommand("https://google.com"))
+		if _, ok := err.(*weos.DomainError); !ok {
+			t.Errorf("expected a domain error, got '%v'", err)
+		}
+	})
+
+	t.Run("broken latin-1 feed", func(t *testing.T) {
+		var persistedEvents []weos.Entity
+		application := &ApplicationMock{
+			EventRepositoryFunc: func() weos.EventRepository {
+				return &EventRepositoryMock{
+					PersistFunc: func(entity weos.AggregateInterface) error {
+						persistedEvents = entity.GetNewChanges()
+						return nil
+					},
+				}
+			},
+			HTTPClientFunc: func() *http.Client {
+				return &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					content, err := os.ReadFile("fixtures/feed/latin1.xml")
+					if err != nil {
+						t.Fatalf("unable to read fixture '%s'", err)
+					}
+					return &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"text/xml"}}, Body: io.NopCloser(bytes.NewReader(content)), Request: r}, nil
+				})}
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.AddBlog(context.TODO(), blogaggregatormodule.AddBlogCommand("https://ak33m.com/feed.xml"))
+		if err != nil {
+			t.Fatalf("unexpected error adding blog '%s'", err)
+		}
+		var titles []string
+		var fetched *api.BlogFetchedPayload
+		for _, entity := range persistedEvents {
+			event := entity.(*weos.Event)
+			switch event.Type {
+			case blogaggregatormodule.POST_CREATED:
+				var post *blogaggregatormodule.PostCreatedPayload
+				json.Unmarshal(event.Payload, &post)
+				titles = append(titles, post.Title)
+			case api.BLOG_FETCHED:
+				json.Unmarshal(event.Payload, &fetched)
+			}
+		}
+		expectedTitles := []string{"Crème brûlée & more\u00a0desserts", "Tom & Jerry"}
+		if len(titles) != len(expectedTitles) {
+			t.Fatalf("expected the valid items to be added, got '%v'", titles)
+		}
+		for i, title := range expectedTitles {
+			if titles[i] != title {
+				t.Errorf("expected the title to be '%s', got '%s'", title, titles[i])
+			}
+		}
+		if fetched == nil {
+			t.Fatalf("expected the fetch to be recorded")
+		}
+		expectedProblems := []string{
+			"1 control characters that aren't allowed in XML were removed",
+			"1 ampersands that weren't escaped were escaped",
+			"undeclared entities were replaced &nbsp;",
+			"item 2 could not be parsed",
+		}
+		if len(fetched.Problems) != len(expectedProblems) {
+			t.Fatalf("expected the problems to be %v, got %v", expectedProblems, fetched.Problems)
+		}
+		for i, problem := range expectedProblems {
+			if fetched.Problems[i] != problem {
+				t.Errorf("expected the problem to be '%s', got '%s'", problem, fetched.Problems[i])
+			}
+		}
+	})
+}
+
+func TestReceiver_RefreshBlog(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/temporary.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new.xml", http.StatusFound)
+	})
+	mux.HandleFunc("/podcast.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Podcast</title>
+<link>https://ak33m.com/</link><itunes:new-feed-url>http://%s/old.xml</itunes:new-feed-url></channel></rss>`, r.Host)
+	})
+	//the feed of the blog, the new feed url is added when the feed says it moved
+	feed := func(w http.ResponseWriter, newFeedURL string) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Akeem Philbert</title><link>https://ak33m.com/</link>%s
+<item><title>Getting started with Viro React</title><guid>1</guid><pubDate>Sat, 27 Mar 2021 17:05:53 -0400</pubDate></item>
+<item><title>Event sourcing</title><guid>2</guid><pubDate>Sun, 28 Mar 2021 17:05:53 -0400</pubDate></item>
+</channel></rss>`, newFeedURL)
+	}
+	mux.HandleFunc("/new.xml", func(w http.ResponseWriter, r *http.Request) {
+		feed(w, "")
+	})
+	mux.HandleFunc("/other.xml", func(w http.ResponseWriter, r *http.Request) {
+		feed(w, "")
+	})
+	mux.HandleFunc("/hijacked.xml", func(w http.ResponseWriter, r *http.Request) {
+		feed(w, fmt.Sprintf("<itunes:new-feed-url>http://%s/other.xml</itunes:new-feed-url>", r.Host))
+	})
+	mux.HandleFunc("/redirected.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/other.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/unrelated.xml", func(w http.ResponseWriter, r *http.Request) {
+		feed(w, fmt.Sprintf("<itunes:new-feed-url>http://%s/spam.xml</itunes:new-feed-url>", r.Host))
+	})
+	mux.HandleFunc("/spam.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Spam</title><link>https://spam.example.com/</link>
+<item><title>Buy now</title><guid>99</guid></item>
+</channel></rss>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		feedURL string
+		movedTo string
+		problem string
+	}{
+		{"permanent redirect", "/old.xml", "/new.xml", ""},
+		{"new feed url", "/podcast.xml", "/new.xml", ""},
+		{"temporary redirect", "/temporary.xml", "", ""},
+		{"new feed url of another blog", "/hijacked.xml", "", fmt.Sprintf("the feed says it moved to '%s/other.xml' but that's the feed of another blog", server.URL)},
+		{"permanent redirect to another blog", "/redirected.xml", "", fmt.Sprintf("the feed was redirected to '%s/other.xml' but that's the feed of another blog", server.URL)},
+		{"new feed url with another feed", "/unrelated.xml", "", fmt.Sprintf("the feed says it moved to '%s/spam.xml' but there's another feed there", server.URL)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+				Blog: blogaggregatormodule.Blog{URL: server.URL + test.feedURL},
+			})
+			updated, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_UPDATED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+				Blog: blogaggregatormodule.Blog{URL: "https://ak33m.com/", FeedURL: server.URL + test.feedURL},
+			})
+			post, _ := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, "123", "Blog", &blogaggregatormodule.PostCreatedPayload{
+				BlogID: "123",
+				Item:   gofeed.Item{Title: "Getting started with Viro React", GUID: "1"},
+			})
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							return []*weos.Event{added, updated, post}, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+				HTTPClientFunc: func() *http.Client {
+					return server.Client()
+				},
+			}
+			projection := &ProjectionMock{
+				GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+					return &api.Blog{ID: id}, nil
+				},
+				GetBlogByURLFunc: func(url string) (*api.Blog, error) {
+					if url == server.URL+"/other.xml" {
+						return &api.Blog{ID: "456", FeedURL: url}, nil
+					}
+					return nil, fmt.Errorf("blog '%s' not found", url)
+				},
+				PostsAddedFunc: func(eventIDs []string) error {
+					return nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.RefreshBlog(context.TODO(), api.RefreshBlogCommand("123"))
+			if err != nil {
+				t.Fatalf("unexpected error refreshing blog '%s'", err)
+			}
+			var feedURL string
+			var titles []string
+			var fetched *api.BlogFetchedPayload
+			for _, entity := range persistedEvents {
+				event := entity.(*weos.Event)
+				switch event.Type {
+				case blogaggregatormodule.BLOG_UPDATED:
+					var blog *blogaggregatormodule.BlogCreatedPayload
+					json.Unmarshal(event.Payload, &blog)
+					feedURL = blog.FeedURL
+				case blogaggregatormodule.POST_CREATED:
+					var post *blogaggregatormodule.PostCreatedPayload
+					json.Unmarshal(event.Payload, &post)
+					titles = append(titles, post.Title)
+				case api.BLOG_FETCHED:
+					json.Unmarshal(event.Payload, &fetched)
+				}
+			}
+			expectedFeedURL := ""
+			if test.movedTo != "" {
+				expectedFeedURL = server.URL + test.movedTo
+			}
+			if feedURL != expectedFeedURL {
+				t.Errorf("expected the feed url to be updated to '%s', got '%s'", expectedFeedURL, feedURL)
+			}
+			if len(titles) != 1 || titles[0] != "Event sourcing" {
+				t.Errorf("expected only the new item to be added, got %v", titles)
+			}
+			if fetched == nil {
+				t.Fatalf("expected the fetch to be recorded")
+			}
+			if fetched.NewItems != 1 || fetched.UnchangedItems != 1 {
+				t.Errorf("expected %d new and %d unchanged items, got %d new and %d unchanged", 1, 1, fetched.NewItems, fetched.UnchangedItems)
+			}
+			if test.problem != "" && (len(fetched.Problems) != 1 || fetched.Problems[0] != test.problem) {
+				t.Errorf("expected the move to be recorded as the problem '%s', got %v", test.problem, fetched.Problems)
+			}
+		})
+	}
+
+	t.Run("changed item", func(t *testing.T) {
+		added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+			Blog: blogaggregatormodule.Blog{URL: server.URL + "/new.xml"},
+		})
+		post, _ := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, "123", "Blog", &blogaggregatormodule.PostCreatedPayload{
+			BlogID: "123",
+			Item:   gofeed.Item{Title: "Getting started with React", GUID: "1"},
+		})
+		var persistedEvents []weos.Entity
+		application := &ApplicationMock{
+			EventRepositoryFunc: func() weos.EventRepository {
+				return &EventRepositoryMock{
+					GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+						return []*weos.Event{added, post}, nil
+					},
+					PersistFunc: func(entity weos.AggregateInterface) error {
+						persistedEvents = entity.GetNewChanges()
+						return nil
+					},
+				}
+			},
+			HTTPClientFunc: func() *http.Client {
+				return server.Client()
+			},
+		}
+		projection := &ProjectionMock{
+			GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+				return &api.Blog{ID: id}, nil
+			},
+			PostsAddedFunc: func(eventIDs []string) error {
+				return nil
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.RefreshBlog(context.TODO(), api.RefreshBlogCommand("123"))
+		if err != nil {
+			t.Fatalf("unexpected error refreshing blog '%s'", err)
+		}
+		var updated *api.PostUpdatedPayload
+		var fetched *api.BlogFetchedPayload
+		for _, entity := range persistedEvents {
+			event := entity.(*weos.Event)
+			switch event.Type {
+			case api.POST_UPDATED:
+				json.Unmarshal(event.Payload, &updated)
+			case api.BLOG_FETCHED:
+				json.Unmarshal(event.Payload, &fetched)
+			}
+		}
+		if updated == nil || updated.Title != "Getting started with Viro React" {
+			t.Fatalf("expected the post to be updated with the new title")
+		}
+		if fetched == nil || fetched.NewItems != 1 || fetched.UpdatedItems != 1 || fetched.UnchangedItems != 0 {
+			t.Errorf("expected %d new and %d updated items, got %+v", 1, 1, fetched)
+		}
+	})
+
+	t.Run("unchanged feed", func(t *testing.T) {
+		added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+			Blog: blogaggregatormodule.Blog{URL: server.URL + "/new.xml"},
+		})
+		var persisted int
+		var lastSequenceNo int64
+		var ranges []int64
+		repository := &EventRepositoryMock{
+			GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+				return []*weos.Event{added}, nil
+			},
+			GetByAggregateAndSequenceRangeFunc: func(ID string, start int64, end int64) ([]*weos.Event, error) {
+				ranges = append(ranges, start)
+				return nil, nil
+			},
+			PersistFunc: func(entity weos.AggregateInterface) error {
+				persisted++
+				for _, change := range entity.GetNewChanges() {
+					lastSequenceNo = change.(*weos.Event).Meta.SequenceNo
+				}
+				entity.Persist()
+				return nil
+			},
+		}
+		application := &ApplicationMock{
+			EventRepositoryFunc: func() weos.EventRepository {
+				return repository
+			},
+			HTTPClientFunc: func() *http.Client {
+				return server.Client()
+			},
+		}
+		var recorded []*api.BlogFetchedPayload
+		projection := &ProjectionMock{
+			GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+				return &api.Blog{ID: id}, nil
+			},
+			RecordFetchFunc: func(blogID string, fetched *api.BlogFetchedPayload) error {
+				recorded = append(recorded, fetched)
+				return nil
+			},
+			PostsAddedFunc: func(eventIDs []string) error {
+				return nil
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		for i := 0; i < 2; i++ {
+			err := receiver.RefreshBlog(context.TODO(), api.RefreshBlogCommand("123"))
+			if err != nil {
+				t.Fatalf("unexpected error refreshing blog '%s'", err)
+			}
+		}
+		if persisted != 1 {
+			t.Errorf("expected only the fetch that added posts to be persisted, got %d persists", persisted)
+		}
+		if len(recorded) != 1 || recorded[0].UnchangedItems != 2 || recorded[0].NewItems != 0 {
+			t.Fatalf("expected the fetch that didn't change anything to be recorded in the projection, got %+v", recorded)
+		}
+		//the second refresh only loads the events added since the first
+		if len(repository.GetByAggregateAndTypeCalls()) != 1 {
+			t.Errorf("expected the blog to be rebuilt from its history once, got %d", len(repository.GetByAggregateAndTypeCalls()))
+		}
+		if len(ranges) != 1 || ranges[0] != lastSequenceNo+1 {
+			t.Errorf("expected the events after sequence %d to be loaded, got %v", lastSequenceNo, ranges)
+		}
+	})
+}
+
+func TestReceiver_DeletePost(t *testing.T) {
+	os.Remove("test.db")
+	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database '%s'", err)
+	}
+	db.AutoMigrate(&weos.GormEvent{})
+	added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+		Blog: blogaggregatormodule.Blog{URL: "https://ak33m.com/feed.xml"},
+	})
+	taken, _ := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, "123", "Blog", &blogaggregatormodule.PostCreatedPayload{
+		BlogID: "123",
+		Item:   gofeed.Item{Title: "Taken down", GUID: "1", Content: "<p>Something that shouldn't be shared</p>"},
+	})
+	kept, _ := weos.NewBasicEvent(blogaggregatormodule.POST_CREATED, "123", "Blog", &blogaggregatormodule.PostCreatedPayload{
+		BlogID: "123",
+		Item:   gofeed.Item{Title: "Event sourcing", GUID: "2"},
+	})
+	events := []*weos.Event{added, taken, kept}
+	for _, event := range events {
+		gormEvent, _ := weos.NewGormEvent(event)
+		db.Create(&gormEvent)
+	}
+	var persistedEvents []*weos.Event
+	application := &ApplicationMock{
+		DBFunc: func() *gorm.DB {
+			return db
+		},
+		EventRepositoryFunc: func() weos.EventRepository {
+			return &EventRepositoryMock{
+				GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+					return events, nil
+				},
+				PersistFunc: func(entity weos.AggregateInterface) error {
+					for _, change := range entity.GetNewChanges() {
+						persistedEvents = append(persistedEvents, change.(*weos.Event))
+					}
+					return nil
+				},
+			}
+		},
+	}
+	projection := &ProjectionMock{
+		GetPostByIDFunc: func(id string) (*api.Post, error) {
+			return &api.Post{ID: id, BlogID: "123", GUID: "1", Title: "Taken down"}, nil
+		},
+	}
+	receiver := api.NewReceiver(application, projection)
+	err = receiver.DeletePost(context.TODO(), api.DeletePostCommand("post-1", true))
+	if err != nil {
+		t.Fatalf("unexpected error deleting post '%s'", err)
+	}
+	if len(persistedEvents) != 1 || persistedEvents[0].Type != api.POST_DELETED {
+		t.Fatalf("expected the post to be deleted")
+	}
+
+	//the content of the post is scrubbed from the stored events
+	var stored []weos.GormEvent
+	db.Order("sequence_no asc, created_at asc").Find(&stored)
+	for _, event := range stored {
+		switch event.ID {
+		case taken.ID:
+			if strings.Contains(string(event.Payload), "shouldn't be shared") || strings.Contains(string(event.Payload), "Taken down") {
+				t.Errorf("expected the post to be scrubbed from the event, got '%s'", event.Payload)
+			}
+			if !strings.Contains(string(event.Payload), `"guid":"1"`) {
+				t.Errorf("expected the guid of the post to be kept, got '%s'", event.Payload)
+			}
+		case kept.ID:
+			if !strings.Contains(string(event.Payload), "Event sourcing") {
+				t.Errorf("expected the other posts to be left alone, got '%s'", event.Payload)
+			}
+		}
+	}
+
+	//the deleted post isn't added again when the feed is fetched
+	blog, err := api.NewBlogAggregate("123", append(events, persistedEvents...))
+	if err != nil {
+		t.Fatalf("unexpected error rebuilding blog '%s'", err)
+	}
+	summary, err := blog.AddItems(&gofeed.Feed{Items: []*gofeed.Item{{Title: "Taken down", GUID: "1", Content: "<p>Something that shouldn't be shared</p>"}}})
+	if err != nil {
+		t.Fatalf("unexpected error adding items '%s'", err)
+	}
+	if summary.NewItems != 0 || summary.UpdatedItems != 0 {
+		t.Errorf("expected the deleted post not to be added again, got %+v", summary)
+	}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestReceiver_VerifyBlogClaim(t *testing.T) {
+	token := "blog-aggregator-0123456789abcdef"
+	tests := []struct {
+		name     string
+		homepage string
+		file     string
+		feed     string
+		method   string
+	}{
+		{"meta tag", `<html><head><meta name="blog-aggregator-verification" content="` + token + `"></head></html>`, "", "<rss></rss>", api.CLAIM_METHOD_META},
+		{"well-known file", "<html></html>", "other-token\n" + token + "\n", "<rss></rss>", api.CLAIM_METHOD_FILE},
+		{"feed", "<html></html>", "", "<rss><channel><description>" + token + "</description></channel></rss>", api.CLAIM_METHOD_FEED},
+		{"token not found", `<html><head><meta name="blog-aggregator-verification" content="other-token"></head></html>`, "", "<rss></rss>", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, test.homepage)
+			})
+			mux.HandleFunc("/.well-known/blog-aggregator-verification.txt", func(w http.ResponseWriter, r *http.Request) {
+				if test.file == "" {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, test.file)
+			})
+			mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, test.feed)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+				Blog: blogaggregatormodule.Blog{URL: server.URL + "/feed.xml"},
+			})
+			claimed, _ := weos.NewBasicEvent(api.BLOG_CLAIMED, "123", "Blog", &api.BlogClaimedPayload{
+				UserID: "user1",
+				Token:  token,
+			})
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							return []*weos.Event{added, claimed}, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+				HTTPClientFunc: func() *http.Client {
+					return server.Client()
+				},
+			}
+			projection := &ProjectionMock{
+				GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+					return &api.Blog{ID: id, URL: server.URL + "/", FeedURL: server.URL + "/feed.xml"}, nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.VerifyBlogClaim(context.TODO(), api.VerifyBlogClaimCommand("123", "user1"))
+			if test.method == "" {
+				if _, ok := err.(*weos.DomainError); !ok {
+					t.Fatalf("expected a domain error when the token isn't on the blog, got '%v'", err)
+				}
+				if len(persistedEvents) != 0 {
+					t.Errorf("expected no events to be persisted, got %d", len(persistedEvents))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error verifying claim '%s'", err)
+			}
+			if len(persistedEvents) != 1 {
+				t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+			}
+			event := persistedEvents[0].(*weos.Event)
+			if event.Type != api.BLOG_CLAIM_VERIFIED {
+				t.Fatalf("expected the event to be '%s', got '%s'", api.BLOG_CLAIM_VERIFIED, event.Type)
+			}
+			var verified *api.BlogClaimVerifiedPayload
+			json.Unmarshal(event.Payload, &verified)
+			if verified.UserID != "user1" || verified.Method != test.method {
+				t.Errorf("expected user '%s' to be verified by '%s', got user '%s' by '%s'", "user1", test.method, verified.UserID, verified.Method)
+			}
+		})
+	}
+
+	t.Run("well-known file on the host of the feed", func(t *testing.T) {
+		homepage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, "<html></html>")
+		}))
+		defer homepage.Close()
+		feeds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/.well-known/blog-aggregator-verification.txt" {
+				fmt.Fprint(w, token)
+				return
+			}
+			fmt.Fprint(w, "<rss></rss>")
+		}))
+		defer feeds.Close()
+		added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+			Blog: blogaggregatormodule.Blog{URL: feeds.URL + "/feed.xml"},
+		})
+		claimed, _ := weos.NewBasicEvent(api.BLOG_CLAIMED, "123", "Blog", &api.BlogClaimedPayload{
+			UserID: "user1",
+			Token:  token,
+		})
+		var persistedEvents []weos.Entity
+		application := &ApplicationMock{
+			EventRepositoryFunc: func() weos.EventRepository {
+				return &EventRepositoryMock{
+					GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+						return []*weos.Event{added, claimed}, nil
+					},
+					PersistFunc: func(entity weos.AggregateInterface) error {
+						persistedEvents = entity.GetNewChanges()
+						return nil
+					},
+				}
+			},
+			HTTPClientFunc: func() *http.Client {
+				return homepage.Client()
+			},
+		}
+		projection := &ProjectionMock{
+			GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+				return &api.Blog{ID: id, URL: homepage.URL + "/", FeedURL: feeds.URL + "/feed.xml"}, nil
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.VerifyBlogClaim(context.TODO(), api.VerifyBlogClaimCommand("123", "user1"))
+		if err != nil {
+			t.Fatalf("unexpected error verifying claim '%s'", err)
+		}
+		if len(persistedEvents) != 1 {
+			t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+		}
+		var verified *api.BlogClaimVerifiedPayload
+		json.Unmarshal(persistedEvents[0].(*weos.Event).Payload, &verified)
+		if verified.Method != api.CLAIM_METHOD_FILE {
+			t.Errorf("expected the claim to be verified by '%s', got '%s'", api.CLAIM_METHOD_FILE, verified.Method)
+		}
+	})
+
+	t.Run("not claimed", func(t *testing.T) {
+		added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+			Blog: blogaggregatormodule.Blog{URL: "https://ak33m.com/feed.xml"},
+		})
+		application := &ApplicationMock{
+			EventRepositoryFunc: func() weos.EventRepository {
+				return &EventRepositoryMock{
+					GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+						return []*weos.Event{added}, nil
+					},
+				}
+			},
+		}
+		projection := &ProjectionMock{
+			GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+				return &api.Blog{ID: id, URL: "https://ak33m.com/feed.xml"}, nil
+			},
+		}
+		receiver := api.NewReceiver(application, projection)
+		err := receiver.VerifyBlogClaim(context.TODO(), api.VerifyBlogClaimCommand("123", "user1"))
+		if _, ok := err.(*weos.DomainError); !ok {
+			t.Fatalf("expected a domain error when the user hasn't claimed the blog, got '%v'", err)
+		}
+	})
+}
+
+func TestReceiver_ReceiveMention(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/links", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>A reply</title></head><body><p>I liked <a href="https://ak33m.com/posts/viro-react/#intro">this post</a></p></body></html>`)
+	})
+	mux.HandleFunc("/no-link", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a href="https://ak33m.com/posts/other/">another post</a></body></html>`)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	target := "https://ak33m.com/posts/viro-react"
+	added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+		Blog: blogaggregatormodule.Blog{URL: "https://ak33m.com/index.xml"},
+	})
+	mentioned, _ := weos.NewBasicEvent(api.POST_MENTIONED, "123", "Blog", &api.PostMentionedPayload{
+		PostID: "post1",
+		Source: server.URL + "/gone",
+		Target: target,
+		Type:   api.MENTION_TYPE_WEBMENTION,
+	})
+	tests := []struct {
+		name        string
+		source      string
+		target      string
+		mentionType string
+		eventType   string
+		err         error
+	}{
+		{"source links to the post", server.URL + "/links", target + "/", api.MENTION_TYPE_WEBMENTION, api.POST_MENTIONED, nil},
+		{"source doesn't link to the post", server.URL + "/no-link", target, api.MENTION_TYPE_WEBMENTION, "", api.ErrMentionNoLink},
+		{"source of a mention was removed", server.URL + "/gone", target, api.MENTION_TYPE_WEBMENTION, api.POST_MENTION_REMOVED, nil},
+		{"target isn't a post on the blog", server.URL + "/links", "https://ak33m.com/about", api.MENTION_TYPE_WEBMENTION, "", api.ErrMentionTargetNotFound},
+		{"pingback that was already registered", server.URL + "/gone", target, api.MENTION_TYPE_PINGBACK, "", api.ErrMentionExists},
+		{"source that isn't a web page", "ftp://ak33m.com/links", target, api.MENTION_TYPE_WEBMENTION, "", nil},
+		{"source that doesn't exist", server.URL + "/missing", target, api.MENTION_TYPE_WEBMENTION, "", api.ErrMentionSourceNotFound},
+		//a source that is down isn't rejected so that the mention is checked again
+		{"source that is down", server.URL + "/down", target, api.MENTION_TYPE_WEBMENTION, "-", api.ErrMentionSourceNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							return []*weos.Event{added, mentioned}, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+				HTTPClientFunc: func() *http.Client {
+					return server.Client()
+				},
+			}
+			projection := &ProjectionMock{
+				GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+					return &api.Blog{ID: id}, nil
+				},
+				GetPostByLinkFunc: func(blogID string, link string) (*api.Post, error) {
+					if blogID == "123" && strings.TrimSuffix(link, "/") == target {
+						return &api.Post{ID: "post1", BlogID: blogID, Link: target}, nil
+					}
+					return nil, nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.ReceiveMention(context.TODO(), api.ReceiveMentionCommand("123", test.source, test.target, test.mentionType))
+			if test.eventType == "-" {
+				if _, ok := err.(*weos.DomainError); ok || !errors.Is(err, test.err) {
+					t.Fatalf("expected the error to be '%s' and not a domain error, got '%v'", test.err, err)
+				}
+				if len(persistedEvents) != 0 {
+					t.Errorf("expected no events to be persisted, got %d", len(persistedEvents))
+				}
+				return
+			}
+			if test.eventType == "" {
+				if _, ok := err.(*weos.DomainError); !ok {
+					t.Fatalf("expected a domain error, got '%v'", err)
+				}
+				if test.err != nil && !errors.Is(err, test.err) {
+					t.Errorf("expected the error to be '%s', got '%s'", test.err, err)
+				}
+				if len(persistedEvents) != 0 {
+					t.Errorf("expected no events to be persisted, got %d", len(persistedEvents))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error receiving mention '%s'", err)
+			}
+			if len(persistedEvents) != 1 {
+				t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+			}
+			event := persistedEvents[0].(*weos.Event)
+			if event.Type != test.eventType {
+				t.Fatalf("expected the event to be '%s', got '%s'", test.eventType, event.Type)
+			}
+			if event.Type == api.POST_MENTIONED {
+				var mention *api.PostMentionedPayload
+				json.Unmarshal(event.Payload, &mention)
+				if mention.PostID != "post1" || mention.ItemKey != target || mention.Source != test.source || mention.Title != "A reply" {
+					t.Errorf("expected the mention of post '%s' from '%s' titled '%s', got %+v", "post1", test.source, "A reply", mention)
+				}
+			}
+		})
+	}
+}
+
+func TestReceiver_FediverseFollow(t *testing.T) {
+	added, _ := weos.NewBasicEvent(blogaggregatormodule.BLOG_ADDED, "123", "Blog", &blogaggregatormodule.BlogCreatedPayload{
+		Blog: blogaggregatormodule.Blog{URL: "https://ak33m.com/index.xml"},
+	})
+	followed, _ := weos.NewBasicEvent(api.BLOG_FEDIVERSE_FOLLOWED, "123", "Blog", &api.BlogFediverseFollowedPayload{
+		ActorID: "https://social.example.com/users/alice",
+		Inbox:   "https://social.example.com/users/alice/inbox",
+	})
+	tests := []struct {
+		name      string
+		follower  *api.BlogFediverseFollowedPayload
+		follow    bool
+		eventType string
+		err       bool
+	}{
+		{"follow", &api.BlogFediverseFollowedPayload{ActorID: "https://social.example.com/users/bob", Inbox: "https://social.example.com/users/bob/inbox"}, true, api.BLOG_FEDIVERSE_FOLLOWED, false},
+		{"follow again", &api.BlogFediverseFollowedPayload{ActorID: "https://social.example.com/users/alice", Inbox: "https://social.example.com/users/alice/inbox"}, true, api.BLOG_FEDIVERSE_FOLLOWED, false},
+		{"unfollow", &api.BlogFediverseFollowedPayload{ActorID: "https://social.example.com/users/alice"}, false, api.BLOG_FEDIVERSE_UNFOLLOWED, false},
+		{"unfollow without following", &api.BlogFediverseFollowedPayload{ActorID: "https://social.example.com/users/bob"}, false, "", false},
+		{"follow without an inbox", &api.BlogFediverseFollowedPayload{ActorID: "https://social.example.com/users/bob"}, true, "", true},
+		{"follow without an actor", nil, true, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							return []*weos.Event{added, followed}, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+			}
+			projection := &ProjectionMock{
+				GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+					return &api.Blog{ID: id}, nil
+				},
+				FediverseFollowedFunc: func(blogID string, follower *api.BlogFediverseFollowedPayload) error {
+					return nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.FediverseFollow(context.TODO(), api.FediverseFollowCommand("123", test.follower, test.follow))
+			if test.err {
+				if _, ok := err.(*weos.DomainError); !ok {
+					t.Fatalf("expected a domain error, got '%v'", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error following blog '%s'", err)
+			}
+			if test.eventType == "" {
+				if len(persistedEvents) != 0 {
+					t.Errorf("expected no events to be persisted, got %d", len(persistedEvents))
+				}
+				return
+			}
+			if len(persistedEvents) != 1 {
+				t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+			}
+			if event := persistedEvents[0].(*weos.Event); event.Type != test.eventType {
+				t.Errorf("expected the event to be '%s', got '%s'", test.eventType, event.Type)
+			}
+			//the follow is accepted once it's persisted
+			accepted := projection.FediverseFollowedCalls()
+			if test.follow && (len(accepted) != 1 || accepted[0].BlogID != "123" || accepted[0].Follower.ActorID != test.follower.ActorID) {
+				t.Errorf("expected the follow to be accepted, got %+v", accepted)
+			}
+			if !test.follow && len(accepted) != 0 {
+				t.Errorf("expected an unfollow not to be accepted, got %d", len(accepted))
+			}
+		})
+	}
+}
+
+func TestReceiver_SubscribeDigest(t *testing.T) {
+	subscribed, _ := weos.NewBasicEvent(api.DIGEST_SUBSCRIBED, "existing", "DigestSubscription", &api.DigestSubscribedPayload{
+		UserID: "user1",
+		Token:  "token",
+	})
+	tests := []struct {
+		name         string
+		id           string
+		subscription *api.DigestSubscriptionRequest
+		err          bool
+	}{
+		{"subscribe", "new", &api.DigestSubscriptionRequest{Email: "Reader <reader@example.com>", Frequency: api.DIGEST_DAILY, FollowedBlogs: true, Categories: []string{"go"}}, false},
+		{"subscription that already exists", "existing", &api.DigestSubscriptionRequest{Email: "reader@example.com", Frequency: api.DIGEST_DAILY, FollowedBlogs: true}, true},
+		{"invalid frequency", "new", &api.DigestSubscriptionRequest{Email: "reader@example.com", Frequency: "hourly", FollowedBlogs: true}, true},
+		{"invalid email", "new", &api.DigestSubscriptionRequest{Email: "reader", Frequency: api.DIGEST_WEEKLY, FollowedBlogs: true}, true},
+		{"nothing to send", "new", &api.DigestSubscriptionRequest{Email: "reader@example.com", Frequency: api.DIGEST_WEEKLY}, true},
+		{"category that doesn't exist", "new", &api.DigestSubscriptionRequest{Email: "reader@example.com", Frequency: api.DIGEST_WEEKLY, Categories: []string{"missing"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							if ID == "existing" {
+								return []*weos.Event{subscribed}, nil
+							}
+							return nil, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+			}
+			projection := &ProjectionMock{
+				GetCategoryFunc: func(slug string) (*api.CategoryDetail, error) {
+					if slug == "go" {
+						return &api.CategoryDetail{Category: &api.Category{Slug: slug}}, nil
+					}
+					return nil, nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.SubscribeDigest(context.TODO(), api.SubscribeDigestCommand(test.id, "user1", test.subscription))
+			if test.err {
+				if _, ok := err.(*weos.DomainError); !ok {
+					t.Fatalf("expected a domain error, got '%v'", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error subscribing '%s'", err)
+			}
+			if len(persistedEvents) != 1 {
+				t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+			}
+			var payload *api.DigestSubscribedPayload
+			json.Unmarshal(persistedEvents[0].(*weos.Event).Payload, &payload)
+			if payload.Email != "reader@example.com" || payload.Token == "" || payload.UserID != "user1" {
+				t.Errorf("expected the subscription to have the address and a token, got %+v", payload)
+			}
+		})
+	}
+}
+
+func TestReceiver_SaveSearch(t *testing.T) {
+	created, _ := weos.NewBasicEvent(api.SAVED_SEARCH_CREATED, "existing", "SavedSearch", &api.SavedSearchCreatedPayload{
+		UserID: "user1",
+	})
+	tests := []struct {
+		name   string
+		id     string
+		search *api.SavedSearchRequest
+		err    bool
+	}{
+		{"save search", "new", &api.SavedSearchRequest{Query: `magento "page builder"`, Categories: []string{"php"}, Blogs: []string{"123"}, Authors: []uint{1}, Notify: api.SEARCH_NOTIFY_WEBHOOK, WebhookURL: "https://hooks.example.com/alerts"}, false},
+		{"search that already exists", "existing", &api.SavedSearchRequest{Query: "magento", Notify: api.SEARCH_NOTIFY_IN_APP}, true},
+		{"nothing to search for", "new", &api.SavedSearchRequest{Query: ` "" `, Notify: api.SEARCH_NOTIFY_IN_APP}, true},
+		{"invalid notification", "new", &api.SavedSearchRequest{Query: "magento", Notify: "sms"}, true},
+		{"invalid webhook", "new", &api.SavedSearchRequest{Query: "magento", Notify: api.SEARCH_NOTIFY_WEBHOOK, WebhookURL: "ftp://hooks.example.com"}, true},
+		{"invalid email", "new", &api.SavedSearchRequest{Query: "magento", Notify: api.SEARCH_NOTIFY_EMAIL, Email: "analyst"}, true},
+		{"category that doesn't exist", "new", &api.SavedSearchRequest{Categories: []string{"missing"}, Notify: api.SEARCH_NOTIFY_IN_APP}, true},
+		{"blog that doesn't exist", "new", &api.SavedSearchRequest{Blogs: []string{"missing"}, Notify: api.SEARCH_NOTIFY_IN_APP}, true},
+		{"author that doesn't exist", "new", &api.SavedSearchRequest{Authors: []uint{2}, Notify: api.SEARCH_NOTIFY_IN_APP}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var persistedEvents []weos.Entity
+			application := &ApplicationMock{
+				EventRepositoryFunc: func() weos.EventRepository {
+					return &EventRepositoryMock{
+						GetByAggregateAndTypeFunc: func(ID string, entityType string) ([]*weos.Event, error) {
+							if ID == "existing" {
+								return []*weos.Event{created}, nil
+							}
+							return nil, nil
+						},
+						PersistFunc: func(entity weos.AggregateInterface) error {
+							persistedEvents = entity.GetNewChanges()
+							return nil
+						},
+					}
+				},
+			}
+			projection := &ProjectionMock{
+				GetCategoryFunc: func(slug string) (*api.CategoryDetail, error) {
+					if slug == "php" {
+						return &api.CategoryDetail{Category: &api.Category{Slug: slug}}, nil
+					}
+					return nil, nil
+				},
+				GetBlogByIDFunc: func(id string) (*api.Blog, error) {
+					if id == "123" {
+						return &api.Blog{ID: id}, nil
+					}
+					return nil, nil
+				},
+				GetAuthorFunc: func(id uint) (*api.AuthorDetail, error) {
+					if id == 1 {
+						return &api.AuthorDetail{}, nil
+					}
+					return nil, nil
+				},
+			}
+			receiver := api.NewReceiver(application, projection)
+			err := receiver.SaveSearch(context.TODO(), api.SaveSearchCommand(test.id, "user1", test.search))
+			if test.err {
+				if _, ok := err.(*weos.DomainError); !ok {
+					t.Fatalf("expected a domain error, got '%v'", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error saving search '%s'", err)
+			}
+			if len(persistedEvents) != 1 {
+				t.Fatalf("expected %d event to be persisted, got %d", 1, len(persistedEvents))
+			}
+			var payload *api.SavedSearchCreatedPayload
+			json.Unmarshal(persistedEvents[0].(*weos.Event).Payload, &payload)
+			if payload.UserID != "user1" || payload.Secret == "" || payload.Name != test.search.Query {
+				t.Errorf("expected the search to be named after the query and have a secret, got %+v", payload)
+			}
+		})
+	}
+}
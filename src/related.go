@@ -0,0 +1,218 @@
+package api
+
+import (
+	"container/list"
+	"math"
+	"regexp"
+	gosort "sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	relatedCategoryWeight = 0.5
+	relatedTextWeight     = 0.4
+	relatedBlogWeight     = 0.1
+	//posts that are this similar to each other are treated as the same post e.g. a post syndicated to another blog
+	nearDuplicateSimilarity = 0.9
+	//the number of related posts that are calculated and cached for each post
+	maxRelatedPosts = 20
+	//the number of posts that the related posts are cached for
+	relatedCacheSize = 5000
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true, "all": true,
+	"any": true, "can": true, "had": true, "her": true, "was": true, "one": true, "our": true, "out": true,
+	"has": true, "have": true, "this": true, "that": true, "with": true, "from": true, "they": true, "will": true,
+	"what": true, "when": true, "your": true, "there": true, "their": true, "which": true, "would": true, "about": true,
+	"into": true, "than": true, "then": true, "them": true, "these": true, "some": true, "how": true, "its": true,
+}
+
+//terms get the words of a post that are used to compare it to other posts
+func terms(post *Post) []string {
+	text := htmlTag.ReplaceAllString(post.Title+" "+post.Description+" "+post.Content, " ")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var result []string
+	for _, word := range words {
+		if len(word) > 2 && !stopWords[word] {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+//tfidf calculates the term frequency–inverse document frequency vector of each document
+func tfidf(documents [][]string) []map[string]float64 {
+	frequency := make(map[string]int)
+	for _, document := range documents {
+		seen := make(map[string]bool)
+		for _, term := range document {
+			if !seen[term] {
+				seen[term] = true
+				frequency[term]++
+			}
+		}
+	}
+	vectors := make([]map[string]float64, len(documents))
+	for i, document := range documents {
+		vectors[i] = make(map[string]float64)
+		for _, term := range document {
+			vectors[i][term]++
+		}
+		for term, count := range vectors[i] {
+			idf := math.Log(float64(len(documents)+1)/float64(frequency[term]+1)) + 1
+			vectors[i][term] = count / float64(len(document)) * idf
+		}
+	}
+	return vectors
+}
+
+//cosineSimilarity returns how similar two vectors are from 0 (nothing in common) to 1 (the same)
+func cosineSimilarity(a map[string]float64, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, value := range a {
+		dot += value * b[term]
+		normA += value * value
+	}
+	for _, value := range b {
+		normB += value * value
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+//rankRelated scores the candidates against a post and returns them from most to least related. shared is the number of
+//categories each candidate has in common with the post
+func rankRelated(post *Post, candidates []*Post, shared map[string]int) []*Post {
+	documents := [][]string{terms(post)}
+	for _, candidate := range candidates {
+		documents = append(documents, terms(candidate))
+	}
+	vectors := tfidf(documents)
+	scores := make(map[string]float64)
+	related := make([]*Post, 0)
+	for i, candidate := range candidates {
+		if candidate.ID == post.ID {
+			continue
+		}
+		similarity := cosineSimilarity(vectors[0], vectors[i+1])
+		//skip near duplicates, it's not useful to recommend the same post
+		if similarity >= nearDuplicateSimilarity || (post.Link != "" && candidate.Link == post.Link) || NormalizeCategory(candidate.Title) == NormalizeCategory(post.Title) {
+			continue
+		}
+		score := relatedTextWeight * similarity
+		if len(post.Categories) > 0 {
+			score += relatedCategoryWeight * float64(shared[candidate.ID]) / float64(len(post.Categories))
+		}
+		//posts from the same blog published around the same time are likely to be part of the same series
+		if candidate.BlogID == post.BlogID {
+			days := math.Abs(candidate.PublishDate.Sub(post.PublishDate).Hours()) / 24
+			score += relatedBlogWeight / (1 + days/30)
+		}
+		if score > 0 {
+			scores[candidate.ID] = score
+			related = append(related, candidate)
+		}
+	}
+	gosort.SliceStable(related, func(i, j int) bool {
+		return scores[related[i].ID] > scores[related[j].ID]
+	})
+	if len(related) > maxRelatedPosts {
+		related = related[:maxRelatedPosts]
+	}
+	return related
+}
+
+type relatedEntry struct {
+	postID     string
+	blogID     string
+	categories map[uint]bool
+	related    []string
+}
+
+//relatedCache stores the ids of the related posts of a post until a new post is added that could change them. Only the
+//posts viewed most recently are kept
+type relatedCache struct {
+	mutex   sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func (c *relatedCache) get(postID string) ([]string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[postID]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*relatedEntry).related, true
+	}
+	return nil, false
+}
+
+func (c *relatedCache) set(post *Post, posts []*Post) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := &relatedEntry{
+		postID:     post.ID,
+		blogID:     post.BlogID,
+		categories: make(map[uint]bool),
+	}
+	for _, category := range post.Categories {
+		entry.categories[category.ID] = true
+	}
+	for _, related := range posts {
+		entry.related = append(entry.related, related.ID)
+	}
+	if element, ok := c.entries[post.ID]; ok {
+		c.order.Remove(element)
+	}
+	c.entries[post.ID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*relatedEntry).postID)
+	}
+}
+
+//invalidate removes the entries of posts that share a category or blog with a new post
+func (c *relatedCache) invalidate(post *Post) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id, element := range c.entries {
+		entry := element.Value.(*relatedEntry)
+		remove := entry.blogID == post.BlogID
+		for _, category := range post.Categories {
+			if remove {
+				break
+			}
+			remove = entry.categories[category.ID]
+		}
+		if remove {
+			c.order.Remove(element)
+			delete(c.entries, id)
+		}
+	}
+}
+
+func (c *relatedCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func newRelatedCache(size int) *relatedCache {
+	return &relatedCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	feedTitle        = "Blog Aggregator"
+	feedDescription  = "The latest posts from the blogs in the aggregator"
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+)
+
+type RSS struct {
+	XMLName xml.Name    `xml:"rss"`
+	Version string      `xml:"version,attr"`
+	Media   string      `xml:"xmlns:media,attr"`
+	Channel *RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*RSSItem `xml:"item"`
+}
+
+type RSSItem struct {
+	Title        string             `xml:"title"`
+	Link         string             `xml:"link,omitempty"`
+	Description  string             `xml:"description,omitempty"`
+	GUID         *RSSGUID           `xml:"guid"`
+	PubDate      string             `xml:"pubDate,omitempty"`
+	Categories   []string           `xml:"category,omitempty"`
+	Source       *RSSSource         `xml:"source,omitempty"`
+	Enclosure    *RSSEnclosure      `xml:"enclosure,omitempty"`
+	MediaContent []*RSSMediaContent `xml:"media:content,omitempty"`
+}
+
+type RSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type RSSSource struct {
+	URL   string `xml:"url,attr"`
+	Title string `xml:",chardata"`
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type RSSMediaContent struct {
+	URL       string             `xml:"url,attr"`
+	Type      string             `xml:"type,attr,omitempty"`
+	Medium    string             `xml:"medium,attr,omitempty"`
+	FileSize  int64              `xml:"fileSize,attr,omitempty"`
+	Duration  int                `xml:"duration,attr,omitempty"`
+	Thumbnail *RSSMediaThumbnail `xml:"media:thumbnail,omitempty"`
+}
+
+type RSSMediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+//NewRSS create an RSS feed of posts. The enclosures of the posts are included so podcast apps can subscribe to the feed
+func NewRSS(link string, posts []*Post) *RSS {
+	channel := &RSSChannel{
+		Title:       feedTitle,
+		Link:        link,
+		Description: feedDescription,
+	}
+	for _, post := range posts {
+		item := &RSSItem{
+			Title:       post.Title,
+			Link:        post.Link,
+			Description: post.Content,
+			GUID: &RSSGUID{
+				Value: post.ID,
+			},
+		}
+		if item.Description == "" {
+			item.Description = post.Description
+		}
+		if !post.PublishDate.IsZero() {
+			item.PubDate = post.PublishDate.Format(time.RFC1123Z)
+			if channel.LastBuildDate == "" {
+				channel.LastBuildDate = item.PubDate
+			}
+		}
+		for _, category := range post.Categories {
+			item.Categories = append(item.Categories, category.Title)
+		}
+		if post.Blog != nil {
+			item.Source = &RSSSource{
+				URL:   post.Blog.FeedURL,
+				Title: post.Blog.Title,
+			}
+			if item.Source.URL == "" {
+				item.Source.URL = post.Blog.URL
+			}
+		}
+		for _, enclosure := range post.Enclosures {
+			//RSS only allows one enclosure per item so the first audio or video is used and the rest are in the Media RSS elements
+			if item.Enclosure == nil && (enclosure.Medium == MEDIUM_AUDIO || enclosure.Medium == MEDIUM_VIDEO) {
+				item.Enclosure = &RSSEnclosure{
+					URL:    enclosure.URL,
+					Length: enclosure.Length,
+					Type:   enclosure.Type,
+				}
+			}
+			content := &RSSMediaContent{
+				URL:      enclosure.URL,
+				Type:     enclosure.Type,
+				Medium:   enclosure.Medium,
+				FileSize: enclosure.Length,
+				Duration: enclosure.Duration,
+			}
+			if enclosure.ThumbnailURL != "" {
+				content.Thumbnail = &RSSMediaThumbnail{URL: enclosure.ThumbnailURL}
+			}
+			item.MediaContent = append(item.MediaContent, content)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return &RSS{
+		Version: "2.0",
+		Media:   "http://search.yahoo.com/mrss/",
+		Channel: channel,
+	}
+}
+
+//Get the latest posts as an RSS feed. The same filters as the post list can be used e.g. has_media=audio for a podcast feed
+func (a *API) GetFeed(e echo.Context) error {
+	var lastError error
+	_, _, _, filters, err := postQuery(e)
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.Atoi(e.QueryParam("limit"))
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	limit = Min(limit, maxFeedLimit)
+	for _, projection := range a.Application.Projections() {
+		posts, _, err := projection.(Projection).GetPosts(1, limit, "", map[string]string{"publish_date": "desc"}, filters)
+		if err == nil {
+			return e.XML(http.StatusOK, NewRSS(e.Scheme()+"://"+e.Request().Host, posts))
+		} else {
+			lastError = err
+		}
+	}
+	return lastError
+}
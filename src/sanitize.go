@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	excerptLength = 280
+	//average words read per minute, used to estimate the reading time of a post
+	wordsPerMinute = 200
+)
+
+//allowedTags are the elements that are kept when sanitizing html and the attributes that are kept on each of them.
+//Elements that aren't in the list are removed but their text is kept
+var allowedTags = map[string]map[string]bool{
+	"a":          {"href": true, "title": true},
+	"abbr":       {"title": true},
+	"b":          {},
+	"blockquote": {"cite": true},
+	"br":         {},
+	"code":       {},
+	"dd":         {},
+	"del":        {},
+	"dl":         {},
+	"dt":         {},
+	"em":         {},
+	"figcaption": {},
+	"figure":     {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src": true, "alt": true, "title": true, "width": true, "height": true},
+	"ins":        {},
+	"li":         {},
+	"ol":         {},
+	"p":          {},
+	"pre":        {},
+	"q":          {"cite": true},
+	"s":          {},
+	"small":      {},
+	"span":       {},
+	"strong":     {},
+	"sub":        {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"colspan": true, "rowspan": true},
+	"tfoot":      {},
+	"th":         {"colspan": true, "rowspan": true},
+	"thead":      {},
+	"tr":         {},
+	"u":          {},
+	"ul":         {},
+}
+
+//droppedTags are the elements that are removed along with everything in them
+var droppedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"math":     true,
+	"head":     true,
+	"title":    true,
+}
+
+var voidTags = map[string]bool{
+	"br":  true,
+	"hr":  true,
+	"img": true,
+}
+
+var urlAttributes = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+//SanitizeHTML removes the elements and attributes that are not in the allow list so that the content is safe to render.
+//Relative urls are resolved against the base url and urls that are not http(s) or mailto are removed
+func SanitizeHTML(content string, base *url.URL) string {
+	var buffer bytes.Buffer
+	var open []string
+	skip := 0
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				break
+			}
+			return ""
+		}
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[token.Data] {
+				if tokenType == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			attributes, ok := allowedTags[token.Data]
+			if skip > 0 || !ok {
+				continue
+			}
+			buffer.WriteString("<" + token.Data)
+			for _, attribute := range token.Attr {
+				if attribute.Namespace != "" || !attributes[attribute.Key] {
+					continue
+				}
+				value := attribute.Val
+				if urlAttributes[attribute.Key] {
+					if value = safeURL(value, base); value == "" {
+						continue
+					}
+				}
+				buffer.WriteString(" " + attribute.Key + `="` + html.EscapeString(value) + `"`)
+			}
+			if token.Data == "a" {
+				buffer.WriteString(` rel="nofollow noopener"`)
+			}
+			buffer.WriteString(">")
+			if !voidTags[token.Data] && tokenType == html.StartTagToken {
+				open = append(open, token.Data)
+			}
+		case html.EndTagToken:
+			if droppedTags[token.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			//close the element and any elements in it that were left open
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						buffer.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				buffer.WriteString(html.EscapeString(token.Data))
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		buffer.WriteString("</" + open[i] + ">")
+	}
+	return buffer.String()
+}
+
+//safeURL resolves a url against the base url. An empty string is returned if the url is not http(s) or mailto
+func safeURL(value string, base *url.URL) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	link, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		link = base.ResolveReference(link)
+	}
+	switch strings.ToLower(link.Scheme) {
+	case "http", "https", "mailto":
+		return link.String()
+	case "":
+		//relative urls are kept when there is no base url to resolve them against
+		if base == nil && link.Opaque == "" {
+			return link.String()
+		}
+	}
+	return ""
+}
+
+//PlainText gets the text in html with the whitespace collapsed
+func PlainText(content string) string {
+	var builder strings.Builder
+	skip := 0
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken:
+			if droppedTags[token.Data] {
+				skip++
+			}
+			builder.WriteString(" ")
+		case html.EndTagToken:
+			if droppedTags[token.Data] && skip > 0 {
+				skip--
+			}
+			builder.WriteString(" ")
+		case html.SelfClosingTagToken:
+			builder.WriteString(" ")
+		case html.TextToken:
+			if skip == 0 {
+				builder.WriteString(token.Data)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+//Excerpt shortens text to the excerpt length, breaking on a word
+func Excerpt(text string) string {
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+	excerpt := string([]rune(text)[:excerptLength])
+	if i := strings.LastIndex(excerpt, " "); i > 0 {
+		excerpt = excerpt[:i]
+	}
+	return strings.TrimRight(excerpt, " .,;:") + "…"
+}
+
+//ReadingTime estimates the number of minutes it takes to read the words
+func ReadingTime(words int) int {
+	if words == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(words) / wordsPerMinute))
+}
+
+//leadImage gets the src of the first image in sanitized html
+func leadImage(content string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return ""
+		}
+		if tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken {
+			token := tokenizer.Token()
+			if token.Data != "img" {
+				continue
+			}
+			for _, attribute := range token.Attr {
+				if attribute.Key == "src" && attribute.Val != "" {
+					return attribute.Val
+				}
+			}
+		}
+	}
+}
+
+//prepareContent sanitizes the content and description of a post and sets the fields that are derived from them.
+//image is the image from the feed item which is used as the lead image if it's set
+func (p *Post) prepareContent(base *url.URL, image string) {
+	p.Content = SanitizeHTML(p.Content, base)
+	p.Description = SanitizeHTML(p.Description, base)
+	text := PlainText(p.Content)
+	if text == "" {
+		text = PlainText(p.Description)
+	}
+	p.WordCount = len(strings.Fields(text))
+	p.ReadingTime = ReadingTime(p.WordCount)
+	if description := PlainText(p.Description); description != "" {
+		p.Excerpt = Excerpt(description)
+	} else {
+		p.Excerpt = Excerpt(text)
+	}
+	p.ImageURL = safeURL(image, base)
+	if p.ImageURL == "" {
+		if p.ImageURL = leadImage(p.Content); p.ImageURL == "" {
+			p.ImageURL = leadImage(p.Description)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/wepala/weos"
+)
+
+const (
+	SEARCH_NOTIFY_WEBHOOK = "webhook"
+	SEARCH_NOTIFY_EMAIL   = "email"
+	SEARCH_NOTIFY_IN_APP  = "in-app"
+)
+
+//SavedSearchAggregate is used to record a user saving (and deleting) a search they want to be notified about
+type SavedSearchAggregate struct {
+	weos.AggregateRoot
+	userID  string
+	deleted bool
+}
+
+//NewSavedSearchAggregate rebuild the saved search from its events
+func NewSavedSearchAggregate(id string, events []*weos.Event) (*SavedSearchAggregate, error) {
+	search := &SavedSearchAggregate{}
+	search.ID = id
+	err := search.ApplyChanges(events)
+	if err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+func (s *SavedSearchAggregate) ApplyChanges(changes []*weos.Event) error {
+	for _, change := range changes {
+		s.SequenceNo = change.Meta.SequenceNo
+		switch change.Type {
+		case SAVED_SEARCH_CREATED:
+			var payload *SavedSearchCreatedPayload
+			if err := json.Unmarshal(change.Payload, &payload); err != nil {
+				return err
+			}
+			s.userID = payload.UserID
+		case SAVED_SEARCH_DELETED:
+			s.deleted = true
+		}
+	}
+	return nil
+}
+
+//Save records the user saving the search. The categories, blogs and authors are expected to be checked before this is
+//called. A secret is generated for searches that notify a webhook so that the webhooks can be verified
+func (s *SavedSearchAggregate) Save(userID string, search *SavedSearchRequest) error {
+	if s.userID != "" {
+		return weos.NewDomainError(fmt.Sprintf("saved search '%s' already exists", s.ID), "SavedSearch", s.ID, nil)
+	}
+	if userID == "" {
+		return weos.NewDomainError("a user is required to save a search", "SavedSearch", s.ID, nil)
+	}
+	if len(searchTerms(search.Query)) == 0 && len(search.Categories) == 0 && len(search.Blogs) == 0 && len(search.Authors) == 0 {
+		return weos.NewDomainError("the search needs a query, a category, a blog or an author", "SavedSearch", s.ID, nil)
+	}
+	payload := &SavedSearchCreatedPayload{
+		UserID:             userID,
+		SavedSearchRequest: *search,
+	}
+	payload.Name = strings.TrimSpace(search.Name)
+	if payload.Name == "" {
+		payload.Name = strings.TrimSpace(search.Query)
+	}
+	switch search.Notify {
+	case SEARCH_NOTIFY_IN_APP:
+		payload.WebhookURL = ""
+		payload.Email = ""
+	case SEARCH_NOTIFY_WEBHOOK:
+		link, err := url.Parse(search.WebhookURL)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+			return weos.NewDomainError(fmt.Sprintf("invalid webhook url '%s'", search.WebhookURL), "SavedSearch", s.ID, err)
+		}
+		if payload.Secret, err = newToken(); err != nil {
+			return err
+		}
+		payload.Email = ""
+	case SEARCH_NOTIFY_EMAIL:
+		address, err := mail.ParseAddress(search.Email)
+		if err != nil || strings.ContainsAny(address.Address, "\r\n") {
+			return weos.NewDomainError(fmt.Sprintf("invalid email '%s'", search.Email), "SavedSearch", s.ID, err)
+		}
+		payload.Email = address.Address
+		payload.WebhookURL = ""
+	default:
+		return weos.NewDomainError(fmt.Sprintf("the notification must be '%s', '%s' or '%s'", SEARCH_NOTIFY_IN_APP, SEARCH_NOTIFY_WEBHOOK, SEARCH_NOTIFY_EMAIL), "SavedSearch", s.ID, nil)
+	}
+	event, err := weos.NewBasicEvent(SAVED_SEARCH_CREATED, s.ID, "SavedSearch", payload)
+	if err != nil {
+		return err
+	}
+	s.NewChange(event)
+	return s.ApplyChanges([]*weos.Event{event})
+}
+
+//Delete removes the saved search. Nothing is recorded if it was already deleted
+func (s *SavedSearchAggregate) Delete() error {
+	if s.userID == "" {
+		return weos.NewDomainError(fmt.Sprintf("saved search '%s' not found", s.ID), "SavedSearch", s.ID, nil)
+	}
+	if s.deleted {
+		return nil
+	}
+	event, err := weos.NewBasicEvent(SAVED_SEARCH_DELETED, s.ID, "SavedSearch", nil)
+	if err != nil {
+		return err
+	}
+	s.NewChange(event)
+	return s.ApplyChanges([]*weos.Event{event})
+}
+
+//searchTerms split the query of a saved search into lower case terms. Quoted phrases are kept together
+func searchTerms(query string) []string {
+	var terms []string
+	for i, part := range strings.Split(strings.ToLower(query), `"`) {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		//the odd parts were between quotes
+		if i%2 == 1 {
+			terms = append(terms, strings.Join(fields, " "))
+		} else {
+			terms = append(terms, fields...)
+		}
+	}
+	return terms
+}
+
+//containsTerm check whether the term is in the text as a whole word (or phrase) e.g. "go" isn't found in "google". The
+//text is expected to be lower case with single spaces like the terms
+func containsTerm(text string, term string) bool {
+	for offset := 0; offset < len(text); {
+		index := strings.Index(text[offset:], term)
+		if index == -1 {
+			return false
+		}
+		start := offset + index
+		end := start + len(term)
+		if (start == 0 || !isWordByte(text[start-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+//isWordByte whether the byte is part of a word. Bytes of multi-byte characters are treated as letters
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '_' || b >= 0x80
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	//the posts that can wait to be sent to a listener of the post stream before it's closed
+	postListenerBuffer = 64
+)
+
+//PostStreamFilter is the posts a listener of the post stream gets, it's the same as the blog_id and category parameters
+//of GetPosts
+type PostStreamFilter struct {
+	BlogID   string
+	Category string
+}
+
+//Matches check whether the post should be sent to the listener
+func (f *PostStreamFilter) Matches(post *Post) bool {
+	if f.BlogID != "" && post.BlogID != f.BlogID {
+		return false
+	}
+	if f.Category != "" {
+		for _, category := range post.Categories {
+			if category.Title == f.Category || category.Slug == f.Category {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+//filters get the filter in the format of the filters of GetPosts
+func (f *PostStreamFilter) filters() map[string]interface{} {
+	filters := make(map[string]interface{})
+	if f.BlogID != "" {
+		filters["blog_id"] = f.BlogID
+	}
+	if f.Category != "" {
+		filters["category"] = f.Category
+	}
+	return filters
+}
+
+//PostListener gets the new posts that match its filter. Posts is closed if the listener falls behind
+type PostListener struct {
+	Posts  chan *Post
+	filter *PostStreamFilter
+}
+
+//PostBroker fans out the posts that are projected to the listeners of the post stream. The projection never waits on a
+//listener, a listener that falls too far behind is closed instead so that it can reconnect and resume from the last
+//post it got
+type PostBroker struct {
+	mutex     sync.Mutex
+	listeners map[*PostListener]bool
+}
+
+//Subscribe add a listener for the new posts that match the filter
+func (b *PostBroker) Subscribe(filter *PostStreamFilter) *PostListener {
+	listener := &PostListener{
+		Posts:  make(chan *Post, postListenerBuffer),
+		filter: filter,
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.listeners[listener] = true
+	return listener
+}
+
+//Unsubscribe remove the listener. It's safe to call after the listener was closed for falling behind
+func (b *PostBroker) Unsubscribe(listener *PostListener) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.listeners[listener] {
+		delete(b.listeners, listener)
+		close(listener.Posts)
+	}
+}
+
+//Publish send the post to the listeners with a filter the post matches
+func (b *PostBroker) Publish(post *Post) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for listener := range b.listeners {
+		if !listener.filter.Matches(post) {
+			continue
+		}
+		select {
+		case listener.Posts <- post:
+		default:
+			delete(b.listeners, listener)
+			close(listener.Posts)
+		}
+	}
+}
+
+//Listeners get the number of listeners
+func (b *PostBroker) Listeners() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.listeners)
+}
+
+func NewPostBroker() *PostBroker {
+	return &PostBroker{
+		listeners: make(map[*PostListener]bool),
+	}
+}
+
+//writePostEvent write the post as a server-sent event. The id is the id of the event the post was created from so that
+//clients can resume with Last-Event-ID
+func writePostEvent(w io.Writer, post *Post) error {
+	data, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	//the id can't have line breaks, it's left out (and the client keeps its last id) if it does
+	if post.EventID != "" && !strings.ContainsAny(post.EventID, "\r\n") {
+		if _, err = fmt.Fprintf(w, "id: %s\n", post.EventID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: post\ndata: %s\n\n", data)
+	return err
+}
+
+//writeResetEvent tell the client that the posts it missed can't be sent. The client loads the posts again and reconnects
+//without Last-Event-ID
+func writeResetEvent(w io.Writer) error {
+	_, err := fmt.Fprint(w, "event: reset\ndata: {\"message\":\"The missed posts could not be sent\"}\n\n")
+	return err
+}
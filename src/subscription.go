@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/wepala/weos"
+)
+
+const (
+	DIGEST_DAILY  = "daily"
+	DIGEST_WEEKLY = "weekly"
+)
+
+//DigestSubscriptionAggregate is used to record a user subscribing to (and unsubscribing from) an email digest
+type DigestSubscriptionAggregate struct {
+	weos.AggregateRoot
+	userID     string
+	subscribed bool
+}
+
+//NewDigestSubscriptionAggregate rebuild the subscription from its events
+func NewDigestSubscriptionAggregate(id string, events []*weos.Event) (*DigestSubscriptionAggregate, error) {
+	subscription := &DigestSubscriptionAggregate{}
+	subscription.ID = id
+	err := subscription.ApplyChanges(events)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *DigestSubscriptionAggregate) ApplyChanges(changes []*weos.Event) error {
+	for _, change := range changes {
+		s.SequenceNo = change.Meta.SequenceNo
+		switch change.Type {
+		case DIGEST_SUBSCRIBED:
+			var payload *DigestSubscribedPayload
+			if err := json.Unmarshal(change.Payload, &payload); err != nil {
+				return err
+			}
+			s.userID = payload.UserID
+			s.subscribed = true
+		case DIGEST_UNSUBSCRIBED:
+			s.subscribed = false
+		}
+	}
+	return nil
+}
+
+//Subscribe records the user subscribing to a digest of the posts from the blogs they follow and/or the categories. The
+//email and categories are expected to be checked before this is called
+func (s *DigestSubscriptionAggregate) Subscribe(userID string, subscription *DigestSubscriptionRequest) error {
+	if s.userID != "" {
+		return weos.NewDomainError(fmt.Sprintf("subscription '%s' already exists", s.ID), "DigestSubscription", s.ID, nil)
+	}
+	if userID == "" {
+		return weos.NewDomainError("a user is required to subscribe to a digest", "DigestSubscription", s.ID, nil)
+	}
+	if subscription.Frequency != DIGEST_DAILY && subscription.Frequency != DIGEST_WEEKLY {
+		return weos.NewDomainError(fmt.Sprintf("the frequency must be '%s' or '%s'", DIGEST_DAILY, DIGEST_WEEKLY), "DigestSubscription", s.ID, nil)
+	}
+	if !subscription.FollowedBlogs && len(subscription.Categories) == 0 {
+		return weos.NewDomainError("the digest needs the followed blogs or a category", "DigestSubscription", s.ID, nil)
+	}
+	address, err := mail.ParseAddress(subscription.Email)
+	if err != nil || strings.ContainsAny(address.Address, "\r\n") {
+		return weos.NewDomainError(fmt.Sprintf("invalid email '%s'", subscription.Email), "DigestSubscription", s.ID, err)
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	payload := &DigestSubscribedPayload{
+		UserID:                    userID,
+		Token:                     token,
+		DigestSubscriptionRequest: *subscription,
+	}
+	payload.Email = address.Address
+	event, err := weos.NewBasicEvent(DIGEST_SUBSCRIBED, s.ID, "DigestSubscription", payload)
+	if err != nil {
+		return err
+	}
+	s.NewChange(event)
+	return s.ApplyChanges([]*weos.Event{event})
+}
+
+//Unsubscribe stops the digest. Nothing is recorded if the user already unsubscribed
+func (s *DigestSubscriptionAggregate) Unsubscribe() error {
+	if s.userID == "" {
+		return weos.NewDomainError(fmt.Sprintf("subscription '%s' not found", s.ID), "DigestSubscription", s.ID, nil)
+	}
+	if !s.subscribed {
+		return nil
+	}
+	event, err := weos.NewBasicEvent(DIGEST_UNSUBSCRIBED, s.ID, "DigestSubscription", nil)
+	if err != nil {
+		return err
+	}
+	s.NewChange(event)
+	return s.ApplyChanges([]*weos.Event{event})
+}
+
+//newToken generates a random token e.g. for the unsubscribe links of a subscription or to sign webhooks
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"math"
+	"time"
+)
+
+//TrendingScore calculate the trending score of a post. Posts with more views and newer posts have higher scores
+func TrendingScore(views int, publishDate time.Time, now time.Time, config *TrendingConfig) float64 {
+	if publishDate.IsZero() {
+		return 0
+	}
+	age := now.Sub(publishDate).Hours()
+	if age < 0 {
+		age = 0
+	}
+	return float64(views+1) / math.Pow(age+config.Offset, config.Gravity)
+}
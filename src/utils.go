@@ -1,4 +1,13 @@
 package api
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
 func Max(x, y int) int {
     if x < y {
         return y
@@ -12,4 +21,26 @@ func Min(x, y int) int {
         return y
     }
     return x
-}
\ No newline at end of file
+}
+
+//Slugify converts a title to a url friendly identifier e.g. "Viro React" becomes "viro-react"
+func Slugify(title string) string {
+	var builder strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash && builder.Len() > 0 {
+			builder.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "-")
+}
+
+//NormalizeCategory is used to match categories regardless of case, spacing or how unicode characters are encoded e.g.
+//"React", " react " and "ＲＥＡＣＴ" all normalize to "react"
+func NormalizeCategory(title string) string {
+	return strings.Join(strings.Fields(cases.Fold().String(norm.NFKC.String(title))), " ")
+}
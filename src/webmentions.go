@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	SENT_MENTION_PENDING     = "pending"
+	SENT_MENTION_SENT        = "sent"
+	SENT_MENTION_NO_ENDPOINT = "no_endpoint"
+	SENT_MENTION_FAILED      = "failed"
+	//the largest target page that is downloaded to discover its webmention endpoint
+	maxWebmentionPageSize = 2 << 20
+	//the number of webmentions that are sent each time the job runs
+	webmentionBatchSize = 20
+	//the most links in a post that are sent webmentions
+	maxWebmentionLinks = 20
+)
+
+var ErrNoWebmentionEndpoint = errors.New("the target doesn't have a webmention endpoint")
+
+var (
+	linkHeaderPattern = regexp.MustCompile(`<([^>]*)>((?:\s*;\s*[^;,]*)*)`)
+	linkRelPattern    = regexp.MustCompile(`(?i);\s*rel\s*=\s*(?:"([^"]*)"|([^\s;,]+))`)
+)
+
+//WebmentionSender sends webmentions for the links in posts on behalf of the blogs that turned it on
+type WebmentionSender struct {
+	client *http.Client
+}
+
+//Discover find the webmention endpoint of the target. The Link header is checked first and then the first <link> or
+//<a> element with rel="webmention" in the page
+func (s *WebmentionSender) Discover(ctx context.Context, target string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", &StatusError{URL: target, StatusCode: response.StatusCode}
+	}
+	//relative endpoints are resolved against the page the target redirected to
+	base := request.URL
+	if response.Request != nil && response.Request.URL != nil {
+		base = response.Request.URL
+	}
+	for _, header := range response.Header.Values("Link") {
+		if endpoint := linkHeaderEndpoint(header, base); endpoint != "" {
+			return endpoint, nil
+		}
+	}
+	if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+		return "", ErrNoWebmentionEndpoint
+	}
+	page, err := io.ReadAll(io.LimitReader(response.Body, maxWebmentionPageSize))
+	if err != nil {
+		return "", err
+	}
+	if endpoint := pageWebmentionEndpoint(page, base); endpoint != "" {
+		return endpoint, nil
+	}
+	return "", ErrNoWebmentionEndpoint
+}
+
+//Send notify the endpoint that the source links to the target. The status code of the response is returned
+func (s *WebmentionSender) Send(ctx context.Context, endpoint string, source string, target string) (int, error) {
+	form := url.Values{
+		"source": {source},
+		"target": {target},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxWebmentionPageSize))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, &StatusError{URL: endpoint, StatusCode: response.StatusCode}
+	}
+	return response.StatusCode, nil
+}
+
+//linkHeaderEndpoint get the webmention endpoint from a Link header e.g. <https://example.com/webmention>; rel="webmention"
+func linkHeaderEndpoint(header string, base *url.URL) string {
+	for _, link := range linkHeaderPattern.FindAllStringSubmatch(header, -1) {
+		rel := linkRelPattern.FindStringSubmatch(link[2])
+		if rel == nil || !hasRel(rel[1]+rel[2], "webmention") {
+			continue
+		}
+		if endpoint := webmentionEndpoint(link[1], base); endpoint != "" {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+//pageWebmentionEndpoint get the endpoint from the first <link> or <a> element with rel="webmention" in the page
+func pageWebmentionEndpoint(page []byte, base *url.URL) string {
+	document, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return ""
+	}
+	var endpoint string
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if endpoint != "" {
+			return
+		}
+		if node.Type == html.ElementNode && (node.DataAtom == atom.Link || node.DataAtom == atom.A) && hasRel(attribute(node, "rel"), "webmention") {
+			//an empty href is the page itself
+			for _, attr := range node.Attr {
+				if attr.Key == "href" {
+					endpoint = webmentionEndpoint(attr.Val, base)
+					return
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	return endpoint
+}
+
+//webmentionEndpoint resolve the endpoint against the target. Only http(s) endpoints are used
+func webmentionEndpoint(link string, base *url.URL) string {
+	endpoint, err := base.Parse(strings.TrimSpace(link))
+	if err != nil || !mentionURL(endpoint.String()) {
+		return ""
+	}
+	endpoint.Fragment = ""
+	return endpoint.String()
+}
+
+func hasRel(rels string, rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rels)) {
+		if value == rel {
+			return true
+		}
+	}
+	return false
+}
+
+//OutboundLinks get the links in the content of a post to pages on other sites. Each page is only listed once
+func OutboundLinks(content string, source string, limit int) []string {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return nil
+	}
+	document, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil
+	}
+	var links []string
+	seen := make(map[string]bool)
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if len(links) >= limit {
+			return
+		}
+		if node.Type == html.ElementNode && node.DataAtom == atom.A {
+			if link, err := sourceURL.Parse(strings.TrimSpace(attribute(node, "href"))); err == nil && mentionURL(link.String()) &&
+				!strings.EqualFold(link.Hostname(), sourceURL.Hostname()) {
+				link.Fragment = ""
+				if key := normalizeMentionURL(link.String()); !seen[key] {
+					seen[key] = true
+					links = append(links, link.String())
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	return links
+}
+
+func NewWebmentionSender(client *http.Client) *WebmentionSender {
+	return &WebmentionSender{
+		client: client,
+	}
+}